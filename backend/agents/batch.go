@@ -0,0 +1,251 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/pdf-viewer/backend/models"
+)
+
+// BatchItem is one classification or extraction request of a message batch
+type BatchItem struct {
+	CustomID     string // Unique within the batch; matches results to items
+	AgentType    string // StepClassification or StepExtraction
+	PDFData      []byte // Only needed when submitting
+	DocumentType string // Extraction only
+	Schema       string
+}
+
+// Message Batches API limits on a single batch
+const (
+	maxBatchRequests = 100000
+	maxBatchBytes    = 256 << 20
+)
+
+// BatchStatus is the provider's view of a submitted batch
+type BatchStatus struct {
+	ID     string
+	Status string // models.BatchInProgress, BatchCanceling or BatchEnded
+	Counts models.BatchCounts
+	// CustomIDs lists the items submitted in the batch. Only set by SubmitBatch.
+	CustomIDs []string
+}
+
+// BatchResult is the outcome of one BatchItem. Classification or
+// Extraction is set, matching the item's agent type, when Status is
+// "succeeded"; otherwise Error says why it failed.
+type BatchResult struct {
+	CustomID       string
+	Status         string // succeeded, errored, canceled or expired
+	Classification *models.Classification
+	Extraction     *models.Extraction
+	TokenUsage     *models.TokenUsage // Priced at the batch discount
+	Error          string
+}
+
+// BatchClient is implemented by clients that can submit requests through a
+// discounted, asynchronous batch API. Batches are processed within 24 hours.
+type BatchClient interface {
+	// SubmitBatch submits the items, split across as many batches as the
+	// provider's limits require, and returns the status of each batch and
+	// the prompt sent for each item
+	SubmitBatch(ctx context.Context, items []BatchItem, opts Options) ([]*BatchStatus, []string, error)
+	GetBatch(ctx context.Context, batchID string) (*BatchStatus, error)
+	// GetBatchResults returns the results of an ended batch. items are
+	// those submitted; their PDF data is not needed.
+	GetBatchResults(ctx context.Context, batchID string, items []BatchItem, opts Options) ([]BatchResult, error)
+}
+
+// Ensure ClaudeClient implements BatchClient interface
+var _ BatchClient = (*ClaudeClient)(nil)
+
+// WaitForBatch polls a batch every interval until it has ended
+func WaitForBatch(ctx context.Context, client BatchClient, batchID string, interval time.Duration) (*BatchStatus, error) {
+	for {
+		status, err := client.GetBatch(ctx, batchID)
+		if err != nil {
+			return nil, err
+		}
+		if status.Status == models.BatchEnded {
+			return status, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return status, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// batchUsage discounts the usage of a batched call
+func batchUsage(tokenUsage *models.TokenUsage) *models.TokenUsage {
+	tokenUsage.TotalCost *= models.BatchPriceMultiplier
+	tokenUsage.CacheSavings *= models.BatchPriceMultiplier
+	return tokenUsage
+}
+
+// SubmitBatch submits the items as Message Batches, as few as the API's
+// request count and size limits allow. Each request is built exactly like
+// its interactive counterpart, prompt caching included. If a batch fails to
+// submit, those already submitted are canceled.
+func (c *ClaudeClient) SubmitBatch(ctx context.Context, items []BatchItem, opts Options) ([]*BatchStatus, []string, error) {
+	requests := make([]anthropic.MessageBatchNewParamsRequest, len(items))
+	prompts := make([]string, len(items))
+	for i, item := range items {
+		params, prompt, err := c.agentParams(item.PDFData, item.AgentType, item.DocumentType, item.Schema, opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("batch item %s: %w", item.CustomID, err)
+		}
+		prompts[i] = prompt
+		requests[i] = anthropic.MessageBatchNewParamsRequest{
+			CustomID: item.CustomID,
+			Params: anthropic.MessageBatchNewParamsRequestParams{
+				Model:      params.Model,
+				MaxTokens:  params.MaxTokens,
+				Messages:   params.Messages,
+				Tools:      params.Tools,
+				ToolChoice: params.ToolChoice,
+			},
+		}
+	}
+
+	groups, err := splitBatch(requests, maxBatchRequests, maxBatchBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	var statuses []*BatchStatus
+	for _, group := range groups {
+		var batch *anthropic.MessageBatch
+		attempts, err := c.RetryPolicy.Do(ctx, func() error {
+			var err error
+			batch, err = c.client.Messages.Batches.New(ctx, anthropic.MessageBatchNewParams{Requests: group})
+			return err
+		})
+		if err != nil {
+			for _, submitted := range statuses {
+				c.client.Messages.Batches.Cancel(context.WithoutCancel(ctx), submitted.ID)
+			}
+			return nil, nil, fmt.Errorf("claude API error after %d attempt(s): %w", attempts, err)
+		}
+
+		status := batchStatus(batch)
+		for _, request := range group {
+			status.CustomIDs = append(status.CustomIDs, request.CustomID)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, prompts, nil
+}
+
+// splitBatch groups requests, in order, into batches of at most maxRequests
+// requests and maxBytes of request body
+func splitBatch(requests []anthropic.MessageBatchNewParamsRequest, maxRequests, maxBytes int) ([][]anthropic.MessageBatchNewParamsRequest, error) {
+	envelope := len(`{"requests":[]}`)
+	var groups [][]anthropic.MessageBatchNewParamsRequest
+	start, size := 0, envelope
+	for i, request := range requests {
+		data, err := json.Marshal(request)
+		if err != nil {
+			return nil, fmt.Errorf("batch item %s: %w", request.CustomID, err)
+		}
+		n := len(data)
+		if envelope+n > maxBytes {
+			return nil, fmt.Errorf("batch item %s is %d bytes, over the %d byte batch limit", request.CustomID, n, maxBytes)
+		}
+		// Requests after the first of a batch are preceded by a comma
+		if i > start && (i-start == maxRequests || size+1+n > maxBytes) {
+			groups = append(groups, requests[start:i])
+			start, size = i, envelope
+		}
+		if i > start {
+			size++
+		}
+		size += n
+	}
+	if start < len(requests) {
+		groups = append(groups, requests[start:])
+	}
+	return groups, nil
+}
+
+func (c *ClaudeClient) GetBatch(ctx context.Context, batchID string) (*BatchStatus, error) {
+	var batch *anthropic.MessageBatch
+	attempts, err := c.RetryPolicy.Do(ctx, func() error {
+		var err error
+		batch, err = c.client.Messages.Batches.Get(ctx, batchID)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("claude API error after %d attempt(s): %w", attempts, err)
+	}
+	return batchStatus(batch), nil
+}
+
+func (c *ClaudeClient) GetBatchResults(ctx context.Context, batchID string, items []BatchItem, opts Options) ([]BatchResult, error) {
+	agentTypes := make(map[string]string, len(items))
+	for _, item := range items {
+		agentTypes[item.CustomID] = item.AgentType
+	}
+
+	stream := c.client.Messages.Batches.ResultsStreaming(ctx, batchID)
+	if err := stream.Err(); err != nil {
+		return nil, fmt.Errorf("claude API error: %w", err)
+	}
+	defer stream.Close()
+
+	var results []BatchResult
+	for stream.Next() {
+		response := stream.Current()
+		result := BatchResult{CustomID: response.CustomID, Status: response.Result.Type}
+
+		switch response.Result.Type {
+		case "succeeded":
+			message := response.Result.Message
+			var repairs []JSONRepair
+			var err error
+			switch agentTypes[response.CustomID] {
+			case StepClassification:
+				result.Classification, repairs, err = decodeToolOrText[models.Classification](message.Content, ClassificationToolName)
+			case StepExtraction:
+				result.Extraction, repairs, err = decodeToolOrText[models.Extraction](message.Content, ExtractionToolName)
+			default:
+				err = fmt.Errorf("unknown batch item %s", response.CustomID)
+			}
+			if err != nil {
+				result.Status, result.Error = "errored", err.Error()
+			}
+			result.TokenUsage = batchUsage(usageFromMessage(opts.model(), &message, sendStats{attempts: 1}))
+			result.TokenUsage.JSONRepairs = repairNames(repairs)
+			if result.Extraction != nil {
+				result.Extraction.Truncated = TruncationRepaired(result.TokenUsage)
+			}
+		case "errored":
+			result.Error = response.Result.Error.Error.Message
+		default:
+			result.Error = "request " + response.Result.Type
+		}
+		results = append(results, result)
+	}
+	if err := stream.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read batch results: %w", err)
+	}
+	return results, nil
+}
+
+func batchStatus(batch *anthropic.MessageBatch) *BatchStatus {
+	return &BatchStatus{
+		ID:     batch.ID,
+		Status: string(batch.ProcessingStatus),
+		Counts: models.BatchCounts{
+			Processing: int(batch.RequestCounts.Processing),
+			Succeeded:  int(batch.RequestCounts.Succeeded),
+			Errored:    int(batch.RequestCounts.Errored),
+			Canceled:   int(batch.RequestCounts.Canceled),
+			Expired:    int(batch.RequestCounts.Expired),
+		},
+	}
+}
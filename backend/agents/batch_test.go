@@ -0,0 +1,169 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/pdf-viewer/backend/models"
+)
+
+// fakeBatchAPI serves the Message Batches endpoints. The batch reports
+// in_progress until it has been checked pendingChecks times.
+type fakeBatchAPI struct {
+	submitted     map[string]interface{}
+	checks        int
+	pendingChecks int
+	results       string
+}
+
+func (f *fakeBatchAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/messages/batches":
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &f.submitted)
+		io.WriteString(w, fakeBatch("in_progress", 2, 0))
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/messages/batches/msgbatch_1":
+		f.checks++
+		if f.checks <= f.pendingChecks {
+			io.WriteString(w, fakeBatch("in_progress", 2, 0))
+			return
+		}
+		io.WriteString(w, fakeBatch("ended", 0, 2))
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/messages/batches/msgbatch_1/results":
+		w.Header().Set("Content-Type", "application/x-jsonl")
+		io.WriteString(w, f.results)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func fakeBatch(status string, processing, succeeded int) string {
+	b, _ := json.Marshal(map[string]interface{}{
+		"id":                "msgbatch_1",
+		"type":              "message_batch",
+		"processing_status": status,
+		"request_counts":    map[string]int{"processing": processing, "succeeded": succeeded, "errored": 0, "canceled": 0, "expired": 0},
+		"created_at":        "2025-01-01T00:00:00Z",
+		"expires_at":        "2025-01-02T00:00:00Z",
+	})
+	return string(b)
+}
+
+const fakeBatchResults = `{"custom_id": "doc-2", "result": {"type": "errored", "error": {"type": "error", "error": {"type": "invalid_request_error", "message": "PDF too large"}}}}
+{"custom_id": "doc-1", "result": {"type": "succeeded", "message": {"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5-20250929", "content": [{"type": "tool_use", "id": "toolu_1", "name": "record_classification", "input": {"document_type": "invoice", "confidence": 0.9, "reasoning": "Invoice"}}], "usage": {"input_tokens": 1000, "output_tokens": 200}}}}
+`
+
+func TestClaudeClient_BatchRoundTrip(t *testing.T) {
+	api := &fakeBatchAPI{pendingChecks: 1, results: fakeBatchResults}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	client := NewClaudeClient(option.WithBaseURL(server.URL), option.WithAPIKey("test-key"))
+	items := []BatchItem{
+		{CustomID: "doc-1", AgentType: StepClassification, PDFData: []byte("%PDF-1.4 one")},
+		{CustomID: "doc-2", AgentType: StepClassification, PDFData: []byte("%PDF-1.4 two")},
+	}
+	opts := Options{Model: "claude-sonnet-4-5-20250929"}
+
+	statuses, prompts, err := client.SubmitBatch(context.Background(), items, opts)
+	if err != nil {
+		t.Fatalf("SubmitBatch failed: %v", err)
+	}
+	if len(statuses) != 1 {
+		t.Fatalf("Expected one batch, got %d", len(statuses))
+	}
+	status := statuses[0]
+	if status.ID != "msgbatch_1" || status.Status != models.BatchInProgress || status.Counts.Processing != 2 || len(status.CustomIDs) != 2 {
+		t.Errorf("Unexpected batch status %+v", status)
+	}
+	if len(prompts) != 2 || prompts[0] != opts.classificationPrompt() {
+		t.Errorf("Expected the classification prompt for each item, got %v", prompts)
+	}
+
+	requests, _ := api.submitted["requests"].([]interface{})
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 batch requests, got %v", api.submitted)
+	}
+	first := requests[0].(map[string]interface{})
+	params := first["params"].(map[string]interface{})
+	if first["custom_id"] != "doc-1" || params["model"] != "claude-sonnet-4-5-20250929" || params["tools"] == nil {
+		t.Errorf("Expected the custom ID, model and tools in the batch request, got %v", first)
+	}
+
+	status, err = WaitForBatch(context.Background(), client, status.ID, time.Millisecond)
+	if err != nil {
+		t.Fatalf("WaitForBatch failed: %v", err)
+	}
+	if status.Status != models.BatchEnded || api.checks != 2 {
+		t.Errorf("Expected the batch to end on the second check, got %+v after %d checks", status, api.checks)
+	}
+
+	results, err := client.GetBatchResults(context.Background(), status.ID, items, opts)
+	if err != nil {
+		t.Fatalf("GetBatchResults failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].CustomID != "doc-2" || results[0].Status != "errored" || results[0].Error != "PDF too large" {
+		t.Errorf("Unexpected errored result %+v", results[0])
+	}
+	succeeded := results[1]
+	if succeeded.Classification == nil || succeeded.Classification.DocumentType != "invoice" {
+		t.Fatalf("Expected an invoice classification, got %+v", succeeded)
+	}
+	// 1000 input and 200 output tokens at Sonnet prices cost $0.006, halved for the batch
+	if math.Abs(succeeded.TokenUsage.TotalCost-0.003) > 1e-9 {
+		t.Errorf("Expected discounted cost 0.003, got %f", succeeded.TokenUsage.TotalCost)
+	}
+}
+
+func TestSplitBatch(t *testing.T) {
+	requests := make([]anthropic.MessageBatchNewParamsRequest, 5)
+	for i := range requests {
+		requests[i] = anthropic.MessageBatchNewParamsRequest{CustomID: fmt.Sprintf("req-%d", i)}
+	}
+	size := func(requests []anthropic.MessageBatchNewParamsRequest) int {
+		data, _ := json.Marshal(anthropic.MessageBatchNewParams{Requests: requests})
+		return len(data)
+	}
+
+	groups, err := splitBatch(requests, 2, 1<<20)
+	if err != nil || len(groups) != 3 || len(groups[0]) != 2 || len(groups[2]) != 1 || groups[2][0].CustomID != "req-4" {
+		t.Errorf("Expected batches of 2, 2 and 1 requests, got %v (%v)", groups, err)
+	}
+
+	limit := size(requests[:3])
+	groups, err = splitBatch(requests, 100, limit)
+	if err != nil || len(groups) != 2 || len(groups[0]) != 3 {
+		t.Fatalf("Expected batches of 3 and 2 requests, got %v (%v)", groups, err)
+	}
+	for _, group := range groups {
+		if size(group) > limit {
+			t.Errorf("Expected each batch within %d bytes, got %d", limit, size(group))
+		}
+	}
+
+	if _, err := splitBatch(requests, 100, 10); err == nil || !strings.Contains(err.Error(), "batch limit") {
+		t.Errorf("Expected an error for a request over the size limit, got %v", err)
+	}
+}
+
+func TestClaudeClient_SubmitBatchRejectsUnknownAgentType(t *testing.T) {
+	client := NewClaudeClient(option.WithBaseURL("http://localhost:0"), option.WithAPIKey("test-key"))
+	_, _, err := client.SubmitBatch(context.Background(), []BatchItem{{CustomID: "a", AgentType: "chat"}}, Options{})
+	if err == nil || !strings.Contains(err.Error(), "unsupported agent type") {
+		t.Errorf("Expected unsupported agent type error, got %v", err)
+	}
+}
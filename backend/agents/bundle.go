@@ -0,0 +1,160 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/pdf-viewer/backend/models"
+)
+
+// StepBundle is the agent type of bundle segmentation calls
+const StepBundle = "bundle"
+
+// BundleToolName is the tool the segments of a bundle are recorded with
+const BundleToolName = "record_bundle_segments"
+
+// bundleMaxTokens is the output token limit of a bundle segmentation
+const bundleMaxTokens = 4096
+
+// BundleInputSchema returns the JSON schema of a bundle's segments
+func BundleInputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"segments": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"start_page":    map[string]interface{}{"type": "integer", "description": "1-indexed first page of the document"},
+						"end_page":      map[string]interface{}{"type": "integer", "description": "1-indexed last page of the document"},
+						"document_type": map[string]interface{}{"type": "string", "description": "The matching document type, exactly as listed"},
+						"confidence":    map[string]interface{}{"type": "number", "minimum": 0, "maximum": 1},
+						"title":         map[string]interface{}{"type": "string", "description": "Short description, e.g. 'Invoice INV-001 from Acme'"},
+						"reasoning":     map[string]interface{}{"type": "string", "description": "Why the document starts and ends on these pages"},
+					},
+					"required": []string{"start_page", "end_page", "document_type", "confidence"},
+				},
+			},
+		},
+		"required": []string{"segments"},
+	}
+}
+
+const bundleToolDescription = "Record the documents the PDF bundles together and their page ranges."
+
+// BuildBundleTool creates the tool whose input is a BundleReport
+func BuildBundleTool() anthropic.ToolParam {
+	return newTool(BundleToolName, bundleToolDescription, BundleInputSchema())
+}
+
+// BuildBundlePrompt creates the prompt for dividing a PDF that may bundle
+// several documents into their page ranges, classified against the
+// taxonomy. No types means DefaultDocumentTypes.
+func BuildBundlePrompt(types []models.DocumentType) string {
+	return fmt.Sprintf(`This PDF may contain several separate documents scanned or merged into one file, such as an invoice, two receipts and a letter. Go through it page by page and find where each document starts and ends.
+
+A new document usually starts with a new letterhead, title, sender, document number or date, or a change of layout. Pages of the same document continue its numbering ("Page 2 of 3"), tables or text. Cover letters, attachments and appendices belong with the document they accompany only if they refer to it.
+
+Classify each document as one of the following document types:
+
+%s
+Give one segment per document, in page order, covering every page exactly once. A PDF holding a single document is one segment.
+
+Return a JSON object with this structure:
+{
+  "segments": [
+    {"start_page": 1, "end_page": 2, "document_type": "invoice", "confidence": 0.95, "title": "Invoice INV-001 from Acme", "reasoning": "..."}
+  ]
+}`, FormatDocumentTypes(types))
+}
+
+// SegmentBundle proposes the document boundaries of a PDF. The segments the
+// model returns are fixed up to cover every page once, and their document
+// types kept within the taxonomy.
+func SegmentBundle(ctx context.Context, client Client, pdfData []byte, opts Options) (*models.BundleReport, ExtractionStep, error) {
+	pages := pageCount(pdfData)
+	if pages == 0 {
+		return nil, ExtractionStep{AgentType: StepBundle}, fmt.Errorf("failed to read PDF pages")
+	}
+
+	bundle, prompt, tokenUsage, err := client.SegmentBundle(ctx, pdfData, opts)
+	step := ExtractionStep{AgentType: StepBundle, Prompt: prompt, TokenUsage: tokenUsage}
+	if err != nil {
+		return nil, step, err
+	}
+	step.Response = marshalStep(bundle)
+
+	bundle.Segments = NormalizeSegments(bundle.Segments, pages, opts.DocumentTypes)
+	if tokenUsage != nil {
+		bundle.Model = tokenUsage.Model
+	}
+	return bundle, step, nil
+}
+
+// NormalizeSegments orders the segments and makes them cover pages 1 to
+// pages exactly once: ranges are clipped to the document, overlaps go to the
+// earlier segment and gaps to the segment before them. Document types
+// outside the taxonomy become OtherDocumentType.
+func NormalizeSegments(segments []models.BundleSegment, pages int, types []models.DocumentType) []models.BundleSegment {
+	if len(types) == 0 {
+		types = DefaultDocumentTypes()
+	}
+
+	sorted := make([]models.BundleSegment, len(segments))
+	copy(sorted, segments)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].StartPage < sorted[j].StartPage })
+
+	var normalized []models.BundleSegment
+	next := 1
+	for _, segment := range sorted {
+		segment.StartPage = max(segment.StartPage, next)
+		segment.EndPage = min(segment.EndPage, pages)
+		if segment.EndPage < segment.StartPage {
+			continue
+		}
+		if segment.StartPage > next {
+			if len(normalized) > 0 {
+				normalized[len(normalized)-1].EndPage = segment.StartPage - 1
+			} else {
+				segment.StartPage = next
+			}
+		}
+		if documentType, ok := FindDocumentType(types, segment.DocumentType); ok {
+			segment.DocumentType = documentType.Name
+		} else {
+			segment.DocumentType = OtherDocumentType
+		}
+		segment.ChildID = ""
+		normalized = append(normalized, segment)
+		next = segment.EndPage + 1
+	}
+
+	if len(normalized) == 0 {
+		return []models.BundleSegment{{StartPage: 1, EndPage: pages, DocumentType: OtherDocumentType}}
+	}
+	normalized[len(normalized)-1].EndPage = pages
+	return normalized
+}
+
+// ValidateSegments checks that segments are in page order, do not overlap
+// and lie within a document of the given number of pages. Unlike
+// NormalizeSegments it leaves pages between segments uncovered.
+func ValidateSegments(segments []models.BundleSegment, pages int) error {
+	if len(segments) == 0 {
+		return fmt.Errorf("no segments to split")
+	}
+	next := 1
+	for i, segment := range segments {
+		if segment.StartPage < 1 || segment.EndPage < segment.StartPage || segment.EndPage > pages {
+			return fmt.Errorf("segment %d has invalid page range %d-%d (document has %d pages)", i+1, segment.StartPage, segment.EndPage, pages)
+		}
+		if segment.StartPage < next {
+			return fmt.Errorf("segment %d (pages %d-%d) overlaps the segment before it", i+1, segment.StartPage, segment.EndPage)
+		}
+		next = segment.EndPage + 1
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package agents
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/pdf"
+)
+
+func TestBuildBundlePrompt_ListsTaxonomy(t *testing.T) {
+	prompt := BuildBundlePrompt([]models.DocumentType{{Name: "purchase_order", Description: "An order placed with a supplier"}})
+	if !strings.Contains(prompt, "- purchase_order: An order placed with a supplier") || !strings.Contains(prompt, "every page exactly once") {
+		t.Errorf("Expected the taxonomy and coverage instructions, got %s", prompt)
+	}
+}
+
+func TestNormalizeSegments(t *testing.T) {
+	segments := NormalizeSegments([]models.BundleSegment{
+		{StartPage: 5, EndPage: 9, DocumentType: "Letter"},
+		{StartPage: 2, EndPage: 3, DocumentType: "invoice", ChildID: "stale"},
+		{StartPage: 3, EndPage: 3, DocumentType: "receipt"}, // Inside the invoice
+		{StartPage: 4, EndPage: 4, DocumentType: "memo"},
+	}, 6, nil)
+
+	expected := []models.BundleSegment{
+		{StartPage: 1, EndPage: 3, DocumentType: "invoice"},
+		{StartPage: 4, EndPage: 4, DocumentType: OtherDocumentType},
+		{StartPage: 5, EndPage: 6, DocumentType: "letter"},
+	}
+	if len(segments) != len(expected) {
+		t.Fatalf("Expected %d segments, got %+v", len(expected), segments)
+	}
+	for i, segment := range segments {
+		if segment != expected[i] {
+			t.Errorf("Segment %d: expected %+v, got %+v", i+1, expected[i], segment)
+		}
+	}
+
+	if segments := NormalizeSegments(nil, 3, nil); len(segments) != 1 || segments[0].EndPage != 3 {
+		t.Errorf("Expected one segment of the whole document, got %+v", segments)
+	}
+	if segments := NormalizeSegments([]models.BundleSegment{{StartPage: 1, EndPage: 1}, {StartPage: 3, EndPage: 4}}, 4, nil); segments[0].EndPage != 2 {
+		t.Errorf("Expected the gap given to the segment before it, got %+v", segments)
+	}
+}
+
+func TestValidateSegments(t *testing.T) {
+	if err := ValidateSegments([]models.BundleSegment{{StartPage: 1, EndPage: 2}, {StartPage: 4, EndPage: 4}}, 4); err != nil {
+		t.Errorf("Expected ordered segments to be valid, got %v", err)
+	}
+	tests := map[string][]models.BundleSegment{
+		"no segments":  nil,
+		"invalid page": {{StartPage: 1, EndPage: 5}},
+		"overlaps":     {{StartPage: 1, EndPage: 2}, {StartPage: 2, EndPage: 3}},
+	}
+	for name, segments := range tests {
+		if err := ValidateSegments(segments, 4); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("Expected a %q error, got %v", name, err)
+		}
+	}
+}
+
+func TestSegmentBundle(t *testing.T) {
+	client := &MockClient{
+		BundleFunc: func(ctx context.Context, pdfData []byte, opts Options) (*models.BundleReport, string, *models.TokenUsage, error) {
+			return &models.BundleReport{Segments: []models.BundleSegment{
+				{StartPage: 1, EndPage: 1, DocumentType: "invoice"},
+				{StartPage: 2, EndPage: 2, DocumentType: "receipt"},
+			}}, "bundle prompt", &models.TokenUsage{Model: "model"}, nil
+		},
+	}
+	bundle, step, err := SegmentBundle(context.Background(), client, pdf.GenerateTextPDF([]string{"Invoice", "Receipt", "Receipt"}), Options{})
+	if err != nil {
+		t.Fatalf("Failed to segment: %v", err)
+	}
+	if step.AgentType != StepBundle || bundle.Model != "model" {
+		t.Errorf("Unexpected step or model: %+v %+v", step, bundle)
+	}
+	if len(bundle.Segments) != 2 || bundle.Segments[1].EndPage != 3 {
+		t.Errorf("Expected the last segment to run to the end, got %+v", bundle.Segments)
+	}
+
+	if _, _, err := SegmentBundle(context.Background(), client, []byte("not a pdf"), Options{}); err == nil {
+		t.Error("Expected an error for an unreadable PDF")
+	}
+}
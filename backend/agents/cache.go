@@ -0,0 +1,18 @@
+package agents
+
+import (
+	"encoding/base64"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// documentBlock builds the PDF document block. It must be the first content
+// block of the request and is marked with cache_control, so every call on
+// the same PDF after the first reads it from the prompt cache.
+func documentBlock(pdfData []byte) anthropic.ContentBlockParamUnion {
+	block := anthropic.NewDocumentBlock(anthropic.Base64PDFSourceParam{
+		Data: base64.StdEncoding.EncodeToString(pdfData),
+	})
+	block.OfDocument.CacheControl = anthropic.NewCacheControlEphemeralParam()
+	return block
+}
@@ -0,0 +1,120 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+func TestDocumentBlock_HasCacheControl(t *testing.T) {
+	block := documentBlock([]byte("%PDF-1.4"))
+
+	data, err := json.Marshal(block)
+	if err != nil {
+		t.Fatalf("Failed to marshal block: %v", err)
+	}
+
+	var parsed map[string]interface{}
+	json.Unmarshal(data, &parsed)
+	cacheControl, ok := parsed["cache_control"].(map[string]interface{})
+	if !ok || cacheControl["type"] != "ephemeral" {
+		t.Errorf("Expected ephemeral cache_control on document block, got %s", data)
+	}
+}
+
+func TestClaudeClient_CachesDocumentAcrossCalls(t *testing.T) {
+	var requests []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var parsed map[string]interface{}
+		json.Unmarshal(body, &parsed)
+		requests = append(requests, parsed)
+
+		w.Header().Set("Content-Type", "application/json")
+		if len(requests) == 1 {
+			io.WriteString(w, `{
+				"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5-20250929",
+				"content": [{"type": "tool_use", "id": "toolu_1", "name": "record_classification",
+					"input": {"document_type": "invoice", "confidence": 0.9, "reasoning": "Invoice"}}],
+				"usage": {"input_tokens": 50, "output_tokens": 100, "cache_creation_input_tokens": 10000, "cache_read_input_tokens": 0}
+			}`)
+			return
+		}
+		io.WriteString(w, `{
+			"id": "msg_2", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5-20250929",
+			"content": [{"type": "tool_use", "id": "toolu_2", "name": "record_extraction",
+				"input": {"schema_used": "invoice", "data": {"total": 10}, "fields": []}}],
+			"usage": {"input_tokens": 600, "output_tokens": 300, "cache_creation_input_tokens": 0, "cache_read_input_tokens": 10000}
+		}`)
+	}))
+	defer server.Close()
+
+	client := NewClaudeClient(option.WithBaseURL(server.URL), option.WithAPIKey("test-key"))
+	pdf := []byte("%PDF-1.4 cached document")
+
+	_, classifyPrompt, classifyUsage, err := client.ClassifyDocument(context.Background(), pdf, Options{})
+	if err != nil {
+		t.Fatalf("Classification failed: %v", err)
+	}
+	_, extractPrompt, extractUsage, err := client.ExtractData(context.Background(), pdf, "invoice", GetSchemaForDocumentType("invoice"), Options{})
+	if err != nil {
+		t.Fatalf("Extraction failed: %v", err)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(requests))
+	}
+	classify, extract := requests[0], requests[1]
+
+	// The document block comes first and is identical in both requests
+	firstBlock := func(req map[string]interface{}) interface{} {
+		messages := req["messages"].([]interface{})
+		content := messages[0].(map[string]interface{})["content"].([]interface{})
+		return content[0]
+	}
+	if !reflect.DeepEqual(firstBlock(classify), firstBlock(extract)) {
+		t.Error("Expected identical document block in both requests")
+	}
+	if firstBlock(classify).(map[string]interface{})["type"] != "document" {
+		t.Error("Expected document block to come first")
+	}
+
+	// Each call forces its own tool, with the schema of the document type
+	for _, tc := range []struct {
+		req  map[string]interface{}
+		tool string
+	}{{classify, ClassificationToolName}, {extract, ExtractionToolName}} {
+		choice, _ := tc.req["tool_choice"].(map[string]interface{})
+		tools, _ := tc.req["tools"].([]interface{})
+		if choice["type"] != "tool" || choice["name"] != tc.tool || len(tools) != 1 {
+			t.Errorf("Expected only %s to be sent and forced, got %v and %d tools", tc.tool, choice, len(tools))
+		}
+	}
+	tools := extract["tools"].([]interface{})
+	data := tools[0].(map[string]interface{})["input_schema"].(map[string]interface{})["properties"].(map[string]interface{})["data"].(map[string]interface{})
+	if data["properties"] == nil {
+		t.Errorf("Expected the invoice schema as the data schema, got %v", data)
+	}
+	if classifyPrompt != (Options{}).classificationPrompt() || extractPrompt == "" {
+		t.Errorf("Expected the prompts as sent, got %q and %q", classifyPrompt, extractPrompt)
+	}
+
+	if classifyUsage.CacheCreationInputTokens != 10000 {
+		t.Errorf("Expected 10000 cache write tokens, got %d", classifyUsage.CacheCreationInputTokens)
+	}
+	if extractUsage.CacheReadInputTokens != 10000 {
+		t.Errorf("Expected 10000 cache read tokens, got %d", extractUsage.CacheReadInputTokens)
+	}
+	if classifyUsage.CacheSavings >= 0 {
+		t.Errorf("Expected cache write to cost extra, got savings %f", classifyUsage.CacheSavings)
+	}
+	if extractUsage.CacheSavings <= 0 {
+		t.Errorf("Expected cache read to save money, got savings %f", extractUsage.CacheSavings)
+	}
+}
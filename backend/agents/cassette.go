@@ -0,0 +1,388 @@
+package agents
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Cassette modes accepted by AGENT_CASSETTE_MODE
+const (
+	CassetteRecord = "record"
+	CassetteReplay = "replay"
+)
+
+// minHashedDataLength is the length from which base64 strings in a request,
+// such as PDFs and page images, are replaced by their hash in a cassette
+const minHashedDataLength = 64
+
+// Interaction is one recorded HTTP exchange with a model API. Requests are
+// matched on the method, the path and the JSON body, in which base64 data
+// is replaced by its SHA-256 so cassettes stay small and PDFs are not stored.
+type Interaction struct {
+	Method string `json:"method"`
+	// Path is the URL path and query, without the host, so a cassette
+	// replays against any base URL
+	Path    string          `json:"path"`
+	Request json.RawMessage `json:"request,omitempty"`
+
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	// Response holds a JSON response body, ResponseText any other body, such
+	// as an event stream
+	Response     json.RawMessage `json:"response,omitempty"`
+	ResponseText string          `json:"response_text,omitempty"`
+}
+
+// cassetteFile is the on-disk format of a cassette
+type cassetteFile struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// CassetteMissError is returned when replaying a request that was never recorded
+type CassetteMissError struct {
+	Path   string
+	Method string
+	URL    string
+	Model  string
+	// Diff describes how the request differs from a recording to the same
+	// URL and model, if there is one
+	Diff string
+}
+
+func (e *CassetteMissError) Error() string {
+	msg := fmt.Sprintf("cassette %s has no recorded %s %s request for model %q", e.Path, e.Method, e.URL, e.Model)
+	if e.Diff != "" {
+		msg += "; the request changed since it was recorded (" + e.Diff + "), re-record the cassette"
+	}
+	return msg
+}
+
+// Cassette is an http.RoundTripper that records the requests a client sends
+// to a model API and their responses to a cassette file, or replays a
+// cassette without any network access. Installed as the HTTP client of a
+// real client, replay exercises its request building, retries and response
+// decoding. Replay fails with a CassetteMissError for any request that does
+// not match a recording, so tests notice when a prompt changes.
+type Cassette struct {
+	path  string
+	inner http.RoundTripper // nil when replaying
+
+	mu           sync.Mutex
+	interactions []Interaction
+	// Recording: requests already recorded in this session, whose earlier
+	// recordings were dropped. Replay: responses served so far, by request.
+	seen map[string]int
+}
+
+// NewRecordingCassette creates a cassette that sends requests through inner,
+// or http.DefaultTransport if it is nil, and records every response to the
+// file at path. Recordings already in the file are kept unless the same
+// request is made again.
+func NewRecordingCassette(path string, inner http.RoundTripper) (*Cassette, error) {
+	interactions, err := loadCassette(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if inner == nil {
+		inner = http.DefaultTransport
+	}
+	return &Cassette{path: path, inner: inner, interactions: interactions, seen: map[string]int{}}, nil
+}
+
+// NewReplayCassette creates a cassette that answers requests from the file at path
+func NewReplayCassette(path string) (*Cassette, error) {
+	interactions, err := loadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Cassette{path: path, interactions: interactions, seen: map[string]int{}}, nil
+}
+
+// NewCassetteFromEnv creates the cassette at AGENT_CASSETTE in the mode
+// given by AGENT_CASSETTE_MODE, "replay" (default) or "record". Without
+// AGENT_CASSETTE it returns nil.
+func NewCassetteFromEnv() (*Cassette, error) {
+	path := os.Getenv("AGENT_CASSETTE")
+	if path == "" {
+		return nil, nil
+	}
+
+	switch mode := strings.ToLower(os.Getenv("AGENT_CASSETTE_MODE")); mode {
+	case "", CassetteReplay:
+		return NewReplayCassette(path)
+	case CassetteRecord:
+		return NewRecordingCassette(path, nil)
+	default:
+		return nil, fmt.Errorf("unknown cassette mode '%s' (available: %s, %s)", mode, CassetteRecord, CassetteReplay)
+	}
+}
+
+func loadCassette(path string) ([]Interaction, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	var file cassetteFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	// Requests are indented in the file and compared compacted
+	for i := range file.Interactions {
+		file.Interactions[i].Request = cassetteRequest(file.Interactions[i].Request)
+	}
+	return file.Interactions, nil
+}
+
+// HTTPClient returns an HTTP client that sends its requests through the cassette
+func (c *Cassette) HTTPClient() *http.Client {
+	return &http.Client{Transport: c}
+}
+
+// Interactions returns a copy of the recorded exchanges
+func (c *Cassette) Interactions() []Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Interaction(nil), c.interactions...)
+}
+
+// RoundTrip records or replays a request
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request for cassette: %w", err)
+		}
+	}
+	key := Interaction{Method: req.Method, Path: req.URL.RequestURI(), Request: cassetteRequest(body)}
+
+	if c.inner == nil {
+		interaction, err := c.find(key)
+		if err != nil {
+			return nil, err
+		}
+		return interaction.response(req), nil
+	}
+
+	forwarded := req.Clone(req.Context())
+	forwarded.Body = io.NopCloser(bytes.NewReader(body))
+	forwarded.ContentLength = int64(len(body))
+	resp, err := c.inner.RoundTrip(forwarded)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response for cassette: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	key.Status = resp.StatusCode
+	key.ContentType = resp.Header.Get("Content-Type")
+	if json.Valid(data) {
+		key.Response = data
+	} else {
+		key.ResponseText = string(data)
+	}
+	return resp, c.record(key)
+}
+
+// cassetteRequest returns the recorded form of a request body: compact JSON
+// with sorted keys and base64 data replaced by its hash
+func cassetteRequest(body []byte) json.RawMessage {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		quoted, _ := json.Marshal(string(body))
+		return quoted
+	}
+	normalized, _ := json.Marshal(hashData(value))
+	return normalized
+}
+
+// hashData replaces the base64 strings in a decoded JSON value by their hash
+func hashData(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = hashData(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = hashData(item)
+		}
+	case string:
+		if isBase64Data(v) {
+			sum := sha256.Sum256([]byte(v))
+			return "sha256:" + hex.EncodeToString(sum[:])
+		}
+	}
+	return value
+}
+
+// isBase64Data reports whether s is a long base64 string or base64 data URL
+func isBase64Data(s string) bool {
+	if len(s) < minHashedDataLength {
+		return false
+	}
+	if strings.HasPrefix(s, "data:") {
+		if i := strings.Index(s, ";base64,"); i >= 0 {
+			s = s[i+len(";base64,"):]
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		if b := s[i]; !isLetter(b) && !isDigit(b) && b != '+' && b != '/' && b != '=' {
+			return false
+		}
+	}
+	return true
+}
+
+// key identifies the requests an interaction answers
+func (i Interaction) key() string {
+	return i.Method + " " + i.Path + "\n" + string(i.Request)
+}
+
+// model returns the model named in the request, if any
+func (i Interaction) model() string {
+	var request struct {
+		Model string `json:"model"`
+	}
+	json.Unmarshal(i.Request, &request)
+	return request.Model
+}
+
+// response builds the recorded response to req
+func (i Interaction) response(req *http.Request) *http.Response {
+	body := []byte(i.ResponseText)
+	if len(i.Response) > 0 {
+		body = i.Response
+	}
+	header := http.Header{}
+	if i.ContentType != "" {
+		header.Set("Content-Type", i.ContentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.Status, http.StatusText(i.Status)),
+		StatusCode:    i.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// find returns the recording answering key. A request made several times,
+// such as one that was retried or a batch that was polled, is answered by
+// its recordings in order, and by the last one once they run out.
+func (c *Cassette) find(key Interaction) (Interaction, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var matches []Interaction
+	miss := &CassetteMissError{Path: c.path, Method: key.Method, URL: key.Path, Model: key.model()}
+	for _, interaction := range c.interactions {
+		if interaction.key() == key.key() {
+			matches = append(matches, interaction)
+		} else if miss.Diff == "" && interaction.Method == key.Method && interaction.Path == key.Path && interaction.model() == miss.Model {
+			miss.Diff = requestDiff(interaction.Request, key.Request)
+		}
+	}
+	if len(matches) == 0 {
+		return Interaction{}, miss
+	}
+
+	n := c.seen[key.key()]
+	c.seen[key.key()] = n + 1
+	return matches[min(n, len(matches)-1)], nil
+}
+
+// record stores an exchange and writes the cassette. The first time a
+// request is made while recording, earlier recordings of it are replaced.
+func (c *Cassette) record(interaction Interaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := interaction.key()
+	if c.seen[key] == 0 {
+		kept := c.interactions[:0]
+		for _, recorded := range c.interactions {
+			if recorded.key() != key {
+				kept = append(kept, recorded)
+			}
+		}
+		c.interactions = kept
+	}
+	c.seen[key]++
+	c.interactions = append(c.interactions, interaction)
+
+	data, err := json.MarshalIndent(cassetteFile{Interactions: c.interactions}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+	if err := os.WriteFile(c.path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+// requestDiff describes the first difference between two recorded requests
+func requestDiff(recorded, request json.RawMessage) string {
+	var was, now bytes.Buffer
+	json.Indent(&was, recorded, "", "  ")
+	json.Indent(&now, request, "", "  ")
+	return firstDiff(was.String(), now.String())
+}
+
+// firstDiff describes the first line where two texts differ, quoting both
+// from shortly before the first differing character
+func firstDiff(recorded, text string) string {
+	recordedLines := strings.Split(recorded, "\n")
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(recordedLines) || i < len(lines); i++ {
+		var was, now string
+		if i < len(recordedLines) {
+			was = recordedLines[i]
+		}
+		if i < len(lines) {
+			now = lines[i]
+		}
+		if was != now {
+			start := 0
+			for start < len(was) && start < len(now) && was[start] == now[start] {
+				start++
+			}
+			start = max(start-20, 0)
+			return fmt.Sprintf("line %d was %q, now %q", i+1, excerpt(was, start), excerpt(now, start))
+		}
+	}
+	return ""
+}
+
+// excerpt returns up to 60 bytes of s from start
+func excerpt(s string, start int) string {
+	s = s[min(start, len(s)):]
+	if len(s) > 60 {
+		return s[:60] + "..."
+	}
+	return s
+}
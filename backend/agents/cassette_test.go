@@ -0,0 +1,222 @@
+package agents
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+const cassetteClassification = `{
+	"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5-20250929",
+	"content": [{"type": "tool_use", "id": "toolu_1", "name": "record_classification",
+		"input": {"document_type": "invoice", "confidence": 0.9, "reasoning": "Invoice number"}}],
+	"stop_reason": "tool_use",
+	"usage": {"input_tokens": 1000, "output_tokens": 100}
+}`
+
+const cassetteExtraction = `{
+	"id": "msg_2", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5-20250929",
+	"content": [{"type": "tool_use", "id": "toolu_2", "name": "record_extraction",
+		"input": {"schema_used": "invoice", "data": {"total": 10}, "fields": [{"name": "total", "value": 10, "confidence": 0.9}]}}],
+	"stop_reason": "tool_use",
+	"usage": {"input_tokens": 1200, "output_tokens": 300}
+}`
+
+// recordClaude records calls made by fn through a Claude client pointed at
+// a server answering with responses, and returns the cassette path
+func recordClaude(t *testing.T, fn func(*ClaudeClient), responses ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	server, _ := claudeServer(t, responses...)
+
+	recorder, err := NewRecordingCassette(path, nil)
+	if err != nil {
+		t.Fatalf("NewRecordingCassette failed: %v", err)
+	}
+	fn(NewClaudeClient(option.WithBaseURL(server.URL), option.WithAPIKey("test-key"), option.WithHTTPClient(recorder.HTTPClient())))
+	server.Close()
+	return path
+}
+
+// replayClaude returns a Claude client answered by the cassette at path
+func replayClaude(t *testing.T, path string) *ClaudeClient {
+	t.Helper()
+	replayer, err := NewReplayCassette(path)
+	if err != nil {
+		t.Fatalf("NewReplayCassette failed: %v", err)
+	}
+	return NewClaudeClient(option.WithAPIKey("test-key"), option.WithHTTPClient(replayer.HTTPClient()))
+}
+
+func TestCassette_RecordAndReplayThroughClaudeClient(t *testing.T) {
+	pdfData := []byte("%PDF-1.4 invoice " + strings.Repeat("x", 200))
+	schema := GetSchemaForDocumentType("invoice")
+	opts := Options{Model: "claude-sonnet-4-5-20250929"}
+	ctx := context.Background()
+
+	var classified, extracted interface{}
+	path := recordClaude(t, func(client *ClaudeClient) {
+		var err error
+		if classified, _, _, err = client.ClassifyDocument(ctx, pdfData, opts); err != nil {
+			t.Fatalf("ClassifyDocument failed: %v", err)
+		}
+		if extracted, _, _, err = client.ExtractData(ctx, pdfData, "invoice", schema, opts); err != nil {
+			t.Fatalf("ExtractData failed: %v", err)
+		}
+	}, cassetteClassification, cassetteExtraction)
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), base64.StdEncoding.EncodeToString(pdfData)) || strings.Contains(string(data), "test-key") {
+		t.Error("Expected the PDF and API key not to be stored in the cassette")
+	}
+
+	client := replayClaude(t, path)
+	classification, prompt, usage, err := client.ClassifyDocument(ctx, pdfData, opts)
+	if err != nil {
+		t.Fatalf("Replayed ClassifyDocument failed: %v", err)
+	}
+	if !reflect.DeepEqual(classification, classified) || prompt != opts.classificationPrompt() || usage.InputTokens != 1000 {
+		t.Errorf("Expected the recorded classification, got %+v, %q, %+v", classification, prompt, usage)
+	}
+	extraction, _, _, err := client.ExtractData(ctx, pdfData, "invoice", schema, opts)
+	if err != nil {
+		t.Fatalf("Replayed ExtractData failed: %v", err)
+	}
+	if !reflect.DeepEqual(extraction, extracted) {
+		t.Errorf("Expected the recorded extraction, got %+v", extraction)
+	}
+}
+
+func TestCassette_ReplaysRetriesAndTruncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		switch calls {
+		case 1:
+			w.WriteHeader(529)
+			io.WriteString(w, `{"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}`)
+		case 2:
+			io.WriteString(w, `{
+				"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5-20250929",
+				"content": [{"type": "tool_use", "id": "toolu_1", "name": "record_extraction", "input": {}}],
+				"stop_reason": "max_tokens",
+				"usage": {"input_tokens": 1000, "output_tokens": 4096}
+			}`)
+		default:
+			io.WriteString(w, cassetteExtraction)
+		}
+	}))
+	defer server.Close()
+
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	extract := func(client *ClaudeClient) (interface{}, int, int) {
+		t.Helper()
+		client.RetryPolicy = policy
+		extraction, _, usage, err := client.ExtractData(context.Background(), []byte("%PDF-1.4"), "invoice", GetSchemaForDocumentType("invoice"), Options{})
+		if err != nil {
+			t.Fatalf("ExtractData failed: %v", err)
+		}
+		return extraction, usage.Attempts, usage.Continuations
+	}
+
+	recorder, _ := NewRecordingCassette(path, nil)
+	recorded, attempts, continuations := extract(NewClaudeClient(option.WithBaseURL(server.URL), option.WithAPIKey("test-key"), option.WithHTTPClient(recorder.HTTPClient())))
+	if n := len(recorder.Interactions()); n != 3 {
+		t.Fatalf("Expected 3 recorded requests, got %d", n)
+	}
+
+	replayed, replayedAttempts, replayedContinuations := extract(replayClaude(t, path))
+	if !reflect.DeepEqual(replayed, recorded) || replayedAttempts != attempts || replayedContinuations != continuations || continuations != 1 {
+		t.Errorf("Expected the retry and larger limit to replay (%d attempts, %d continuations), got %d and %d",
+			attempts, continuations, replayedAttempts, replayedContinuations)
+	}
+}
+
+func TestCassette_ReplayMissFailsLoudly(t *testing.T) {
+	pdfData := []byte("%PDF-1.4 invoice")
+	opts := Options{Model: "claude-sonnet-4-5-20250929"}
+	path := recordClaude(t, func(client *ClaudeClient) {
+		if _, _, _, err := client.ExtractData(context.Background(), pdfData, "invoice", GetSchemaForDocumentType("invoice"), opts); err != nil {
+			t.Fatalf("ExtractData failed: %v", err)
+		}
+	}, cassetteExtraction)
+	client := replayClaude(t, path)
+
+	tests := []struct {
+		name      string
+		pdfData   []byte
+		docType   string
+		model     string
+		wantDiff  bool
+		wantInMsg string
+	}{
+		{"different model", pdfData, "invoice", "claude-haiku-4-5-20251001", false, "claude-haiku-4-5-20251001"},
+		{"different PDF", []byte("%PDF-1.4 receipt"), "invoice", opts.Model, true, "re-record the cassette"},
+		{"changed prompt", pdfData, "contract", opts.Model, true, "re-record the cassette"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, err := client.ExtractData(context.Background(), tt.pdfData, tt.docType, GetSchemaForDocumentType(tt.docType), Options{Model: tt.model})
+			var miss *CassetteMissError
+			if !errors.As(err, &miss) {
+				t.Fatalf("Expected a CassetteMissError, got %v", err)
+			}
+			if (miss.Diff != "") != tt.wantDiff || !strings.Contains(err.Error(), tt.wantInMsg) || !strings.Contains(err.Error(), "POST /v1/messages") {
+				t.Errorf("Unexpected miss error: %v", err)
+			}
+		})
+	}
+}
+
+func TestCassetteRequest_HashesBase64Data(t *testing.T) {
+	image := "data:image/png;base64," + strings.Repeat("iVBORw0K", 10)
+	got := string(cassetteRequest([]byte(`{"text": "hello", "image": "` + image + `", "n": 1.50}`)))
+	if strings.Contains(got, "iVBORw0K") || !strings.Contains(got, `"image":"sha256:`) || !strings.Contains(got, `"n":1.50`) {
+		t.Errorf("Expected the image hashed and the rest kept, got %s", got)
+	}
+	if cassetteRequest(nil) != nil {
+		t.Error("Expected no request body for an empty request")
+	}
+}
+
+func TestNewReplayCassette_MissingCassette(t *testing.T) {
+	_, err := NewReplayCassette(filepath.Join(t.TempDir(), "missing.json"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a not-exist error, got %v", err)
+	}
+}
+
+func TestNewCassetteFromEnv(t *testing.T) {
+	t.Setenv("AGENT_CASSETTE", "")
+	if cassette, err := NewCassetteFromEnv(); err != nil || cassette != nil {
+		t.Errorf("Expected no cassette without AGENT_CASSETTE, got %v, %v", cassette, err)
+	}
+
+	t.Setenv("AGENT_CASSETTE", filepath.Join(t.TempDir(), "new.json"))
+	t.Setenv("AGENT_CASSETTE_MODE", "record")
+	if cassette, err := NewCassetteFromEnv(); err != nil || cassette == nil || cassette.inner == nil {
+		t.Errorf("Expected a recording cassette, got %v, %v", cassette, err)
+	}
+	t.Setenv("LLM_PROVIDER", "openai")
+	if client, err := NewClientFromEnv(); err != nil || client.(*OpenAIClient).HTTPClient.Transport == nil {
+		t.Errorf("Expected the client to send requests through the cassette, got %v", err)
+	}
+
+	t.Setenv("AGENT_CASSETTE_MODE", "rewind")
+	if _, err := NewCassetteFromEnv(); err == nil || !strings.Contains(err.Error(), "unknown cassette mode") {
+		t.Errorf("Expected unknown cassette mode error, got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/pdf-viewer/backend/models"
+)
+
+// StepChat is the agent type of chat turns
+const StepChat = "chat"
+
+// chatMaxTokens is the output token limit of a chat answer
+const chatMaxTokens = 2048
+
+// chatSystemPrompt keeps chat answers grounded in the document
+const chatSystemPrompt = `You answer questions about the attached PDF document. Base every answer on
+the document and cite the page numbers you rely on. If the document does not
+contain the answer, say so instead of guessing. Answer concisely in plain
+prose.`
+
+// Chatter is implemented by clients that can hold a conversation about a
+// PDF. Chat answers question given the earlier turns of the thread,
+// calling onText with each piece of the answer as it is generated, and
+// returns the whole answer. If the stream fails after text was passed on,
+// the answer so far is returned with its usage and the error, as its tokens
+// were billed.
+//
+// Chat is not part of Client: only the Claude client implements it. The
+// others answer through one forced function call per request and send the
+// PDF as page text and images with every call, so a thread would resend the
+// whole document each turn and could not stream its answer; ChatWithDocument
+// reports chat as unsupported for them.
+type Chatter interface {
+	Chat(ctx context.Context, pdfData []byte, history []models.ChatTurn, question string, opts Options, onText func(string)) (string, *models.TokenUsage, error)
+}
+
+// Ensure ClaudeClient implements Chatter interface
+var _ Chatter = (*ClaudeClient)(nil)
+
+// Chat streams an answer from Claude. The PDF is sent with the first
+// question of the thread, so follow-up turns read it from the prompt cache.
+// No tools are sent; the cache prefix is the chat's own.
+func (c *ClaudeClient) Chat(ctx context.Context, pdfData []byte, history []models.ChatTurn, question string, opts Options, onText func(string)) (string, *models.TokenUsage, error) {
+	turns := append(append([]models.ChatTurn(nil), history...), models.ChatTurn{Role: models.ChatRoleUser, Content: question})
+	messages := make([]anthropic.MessageParam, len(turns))
+	for i, turn := range turns {
+		switch {
+		case i == 0:
+			messages[i] = anthropic.NewUserMessage(documentBlock(pdfData), anthropic.NewTextBlock(turn.Content))
+		case turn.Role == models.ChatRoleAssistant:
+			messages[i] = anthropic.NewAssistantMessage(anthropic.NewTextBlock(turn.Content))
+		default:
+			messages[i] = anthropic.NewUserMessage(anthropic.NewTextBlock(turn.Content))
+		}
+	}
+
+	params := anthropic.MessageNewParams{
+		Model:     anthropic.Model(opts.model()),
+		MaxTokens: chatMaxTokens,
+		System:    []anthropic.TextBlockParam{{Text: chatSystemPrompt}},
+		Messages:  messages,
+	}
+
+	message, attempts, err := c.streamMessage(ctx, withThinking(params, opts.ThinkingBudget), onText)
+	if err != nil {
+		if message == nil {
+			return "", nil, err
+		}
+		return ExtractTextFromResponse(message.Content), interruptedUsage(opts.model(), message, attempts), err
+	}
+	return ExtractTextFromResponse(message.Content), usageFromMessage(opts.model(), message, sendStats{attempts: attempts}), nil
+}
+
+// interruptedUsage returns the usage of a message whose stream failed. The
+// output tokens are only reported at the end of a stream, so they are
+// estimated from the text received, at about four characters a token.
+func interruptedUsage(model string, message *anthropic.Message, attempts int) *models.TokenUsage {
+	received := 0
+	for _, block := range message.Content {
+		received += len(block.Text) + len(block.Thinking)
+	}
+	message.Usage.OutputTokens = max(message.Usage.OutputTokens, int64((received+3)/4))
+	return usageFromMessage(model, message, sendStats{attempts: attempts})
+}
+
+// streamMessage calls the Messages API with streaming, passing each text
+// delta to onText. Failures are retried according to the client's
+// RetryPolicy only until the first text has been passed on; after that a
+// retry would repeat it, and the message received so far is returned with
+// the error.
+func (c *ClaudeClient) streamMessage(ctx context.Context, params anthropic.MessageNewParams, onText func(string)) (*anthropic.Message, int, error) {
+	var message anthropic.Message
+	streamed := false
+	attempts, err := c.RetryPolicy.Do(ctx, func() error {
+		message = anthropic.Message{}
+		stream := c.client.Messages.NewStreaming(ctx, params)
+		defer stream.Close()
+		for stream.Next() {
+			event := stream.Current()
+			if err := message.Accumulate(event); err != nil {
+				return err
+			}
+			if event.Type == "content_block_delta" && event.Delta.Type == "text_delta" {
+				streamed = true
+				onText(event.Delta.Text)
+			}
+		}
+		if err := stream.Err(); err != nil {
+			if streamed {
+				return &interruptedStreamError{err: err}
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("claude API error after %d attempt(s): %w", attempts, err)
+		if streamed {
+			return &message, attempts, err
+		}
+		return nil, attempts, err
+	}
+	return &message, attempts, nil
+}
+
+// interruptedStreamError is a stream failure after text was passed on. It
+// deliberately hides the cause from IsRetryable.
+type interruptedStreamError struct {
+	err error
+}
+
+func (e *interruptedStreamError) Error() string {
+	return "stream interrupted: " + e.err.Error()
+}
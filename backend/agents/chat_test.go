@@ -0,0 +1,103 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/pdf-viewer/backend/models"
+)
+
+// chatStream is a streamed answer in two text deltas
+var chatStream = []string{
+	`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5-20250929","content":[],"usage":{"input_tokens":1500,"output_tokens":1,"cache_read_input_tokens":1200}}}`,
+	`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"The late fee is 2% "}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"per month (page 3)."}}`,
+	`{"type":"content_block_stop","index":0}`,
+	`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":12}}`,
+	`{"type":"message_stop"}`,
+}
+
+func TestClaudeClient_Chat(t *testing.T) {
+	var request map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &request)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range chatStream {
+			var typed struct{ Type string }
+			json.Unmarshal([]byte(event), &typed)
+			io.WriteString(w, "event: "+typed.Type+"\ndata: "+event+"\n\n")
+		}
+	}))
+	defer server.Close()
+
+	client := NewClaudeClient(option.WithBaseURL(server.URL), option.WithAPIKey("test-key"))
+	history := []models.ChatTurn{
+		{Role: models.ChatRoleUser, Content: "Who is the supplier?"},
+		{Role: models.ChatRoleAssistant, Content: "Acme Supplies Ltd (page 1)."},
+	}
+	var streamed []string
+	answer, tokenUsage, err := client.Chat(context.Background(), []byte("%PDF-1.4"), history, "What's the late fee?", Options{}, func(text string) {
+		streamed = append(streamed, text)
+	})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	if request["stream"] != true {
+		t.Error("Expected a streaming request")
+	}
+	messages := request["messages"].([]interface{})
+	if len(messages) != 3 {
+		t.Fatalf("Expected the 2 earlier turns and the question, got %d messages", len(messages))
+	}
+	first := messages[0].(map[string]interface{})["content"].([]interface{})
+	if first[0].(map[string]interface{})["type"] != "document" {
+		t.Errorf("Expected the PDF with the first question, got %v", first[0])
+	}
+	if role := messages[1].(map[string]interface{})["role"]; role != "assistant" {
+		t.Errorf("Expected the earlier answer as an assistant turn, got %v", role)
+	}
+
+	if len(streamed) != 2 || strings.Join(streamed, "") != answer {
+		t.Errorf("Expected the answer in 2 pieces, got %q for %q", streamed, answer)
+	}
+	if answer != "The late fee is 2% per month (page 3)." {
+		t.Errorf("Unexpected answer: %q", answer)
+	}
+	if tokenUsage.InputTokens != 1500 || tokenUsage.OutputTokens != 12 || tokenUsage.CacheReadInputTokens != 1200 {
+		t.Errorf("Expected token usage from the stream, got %+v", tokenUsage)
+	}
+}
+
+func TestClaudeClient_ChatInterruptedStillReportsUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range chatStream[:3] {
+			var typed struct{ Type string }
+			json.Unmarshal([]byte(event), &typed)
+			io.WriteString(w, "event: "+typed.Type+"\ndata: "+event+"\n\n")
+		}
+		io.WriteString(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\n\n")
+	}))
+	defer server.Close()
+
+	client := NewClaudeClient(option.WithBaseURL(server.URL), option.WithAPIKey("test-key"))
+	answer, tokenUsage, err := client.Chat(context.Background(), []byte("%PDF-1.4"), nil, "What's the late fee?", Options{}, func(string) {})
+	if err == nil || !strings.Contains(err.Error(), "stream interrupted") {
+		t.Fatalf("Expected an interrupted stream error, got %v", err)
+	}
+	if answer != "The late fee is 2% " {
+		t.Errorf("Expected the answer so far, got %q", answer)
+	}
+	if tokenUsage == nil || tokenUsage.InputTokens != 1500 || tokenUsage.OutputTokens != 5 || tokenUsage.TotalCost == 0 {
+		t.Errorf("Expected the billed input and estimated output tokens, got %+v", tokenUsage)
+	}
+}
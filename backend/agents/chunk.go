@@ -0,0 +1,347 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/pdf"
+)
+
+// ChunkOptions controls how large PDFs are split for extraction
+type ChunkOptions struct {
+	MaxPages int // Pages per chunk
+	MaxBytes int // Size of a chunk's PDF; larger chunks are halved until they fit
+}
+
+// DefaultChunkOptions keeps chunks well inside the API's 100 page and 32MB
+// request limits and small enough for their extraction to fit in 4096
+// output tokens
+func DefaultChunkOptions() ChunkOptions {
+	return ChunkOptions{MaxPages: 20, MaxBytes: 20 << 20}
+}
+
+// Chunk is a page range of a document as a standalone PDF
+type Chunk struct {
+	StartPage int // 1-indexed, inclusive
+	EndPage   int
+	PDFData   []byte
+}
+
+// SplitIntoChunks splits a PDF into page-range chunks. A PDF within the
+// limits, or one that cannot be parsed, is returned as a single chunk of the
+// original data.
+func SplitIntoChunks(pdfData []byte, opts ChunkOptions) ([]Chunk, error) {
+	doc, err := pdf.Open(pdfData)
+	if err != nil {
+		return []Chunk{{StartPage: 1, PDFData: pdfData}}, nil
+	}
+	pageCount := doc.NumPages()
+	if (opts.MaxPages <= 0 || pageCount <= opts.MaxPages) && (opts.MaxBytes <= 0 || len(pdfData) <= opts.MaxBytes) {
+		return []Chunk{{StartPage: 1, EndPage: pageCount, PDFData: pdfData}}, nil
+	}
+
+	pagesPerChunk := opts.MaxPages
+	if pagesPerChunk <= 0 {
+		pagesPerChunk = pageCount
+	}
+
+	var chunks []Chunk
+	var split func(start, end int) error
+	split = func(start, end int) error {
+		pages := make([]int, 0, end-start+1)
+		for n := start; n <= end; n++ {
+			pages = append(pages, n)
+		}
+		data, err := doc.ExtractPages(pages)
+		if err != nil {
+			return fmt.Errorf("failed to split pages %d-%d: %w", start, end, err)
+		}
+		if opts.MaxBytes > 0 && len(data) > opts.MaxBytes && end > start {
+			mid := (start + end) / 2
+			if err := split(start, mid); err != nil {
+				return err
+			}
+			return split(mid+1, end)
+		}
+		chunks = append(chunks, Chunk{StartPage: start, EndPage: end, PDFData: data})
+		return nil
+	}
+
+	for start := 1; start <= pageCount; start += pagesPerChunk {
+		if err := split(start, min(start+pagesPerChunk-1, pageCount)); err != nil {
+			return nil, err
+		}
+	}
+	return chunks, nil
+}
+
+// ExtractChunked extracts data from a PDF chunk by chunk and merges the
+// results. Each chunk is extracted and validated with ExtractAndValidate;
+// its steps carry the chunk's page range. The merged extraction is validated
+// again, since required values may come from different chunks. A chunk that
+// fails is noted in the extraction's chunk list; an error is returned only
+// if every chunk fails or the context ends, with the steps of the calls
+// already made so they can still be recorded.
+func ExtractChunked(ctx context.Context, client Client, pdfData []byte, documentType, schema string, opts Options, maxRepairRounds int, chunkOpts ChunkOptions) (*models.Extraction, []ExtractionStep, error) {
+	chunks, err := SplitIntoChunks(pdfData, chunkOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(chunks) == 1 {
+		return ExtractAndValidate(ctx, client, chunks[0].PDFData, documentType, schema, opts, maxRepairRounds)
+	}
+
+	var steps []ExtractionStep
+	var results []*models.Extraction
+	var infos []models.ExtractionChunk
+	var lastErr error
+	repairRounds := 0
+	var repairErrors []string
+
+	for _, chunk := range chunks {
+		info := models.ExtractionChunk{StartPage: chunk.StartPage, EndPage: chunk.EndPage}
+		extraction, chunkSteps, err := ExtractAndValidate(ctx, client, chunk.PDFData, documentType, schema, opts, maxRepairRounds)
+		for i := range chunkSteps {
+			chunkSteps[i].StartPage, chunkSteps[i].EndPage = chunk.StartPage, chunk.EndPage
+		}
+		steps = append(steps, chunkSteps...)
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, steps, err
+			}
+			info.Error = err.Error()
+			lastErr = err
+		} else {
+			offsetPages(extraction, chunk.StartPage-1)
+			info.Fields = len(extraction.Fields)
+			if extraction.Validation != nil {
+				repairRounds += extraction.Validation.RepairRounds
+				if extraction.Validation.RepairError != "" {
+					repairErrors = append(repairErrors, fmt.Sprintf("pages %d-%d: %s", chunk.StartPage, chunk.EndPage, extraction.Validation.RepairError))
+				}
+			}
+			results = append(results, extraction)
+		}
+		infos = append(infos, info)
+	}
+
+	if len(results) == 0 {
+		return nil, steps, fmt.Errorf("all %d chunks failed: %w", len(chunks), lastErr)
+	}
+
+	merged := MergeExtractions(results, schema)
+	merged.Chunks = infos
+
+	report, err := NewValidationReport(merged, schema)
+	if err != nil {
+		report = &models.ValidationReport{Valid: true, RepairError: err.Error()}
+	}
+	report.RepairRounds = repairRounds
+	if len(repairErrors) > 0 {
+		report.RepairError = strings.Join(repairErrors, "; ")
+	}
+	merged.Validation = report
+	steps = append(steps, ExtractionStep{AgentType: StepValidation, Response: marshalStep(report)})
+	return merged, steps, nil
+}
+
+// offsetPages converts chunk-relative page numbers to document page numbers
+func offsetPages(extraction *models.Extraction, offset int) {
+	for i := range extraction.Fields {
+		if extraction.Fields[i].PageNumber > 0 {
+			extraction.Fields[i].PageNumber += offset
+		}
+	}
+}
+
+// MergeExtractions combines the extractions of consecutive chunks. Arrays
+// are concatenated, objects are merged property by property and scalars
+// take the value whose field has the highest confidence, the earlier chunk
+// winning ties. Fields referring into array properties of the schema are
+// all kept, with their indexes shifted past the elements of earlier chunks;
+// other fields keep the most confident entry per name. The merge is
+// Truncated if any chunk was.
+func MergeExtractions(extractions []*models.Extraction, schema string) *models.Extraction {
+	var parsed map[string]interface{}
+	json.Unmarshal([]byte(schema), &parsed)
+
+	merged := &models.Extraction{Data: map[string]interface{}{}}
+	m := &merger{chosen: map[string]float64{}}
+	var data interface{} = map[string]interface{}{}
+	fields := make([][]models.ExtractedField, len(extractions))
+	for i, extraction := range extractions {
+		if merged.SchemaUsed == "" {
+			merged.SchemaUsed = extraction.SchemaUsed
+		}
+		merged.Truncated = merged.Truncated || extraction.Truncated
+		fields[i] = make([]models.ExtractedField, len(extraction.Fields))
+		for j, field := range extraction.Fields {
+			field.Name = reindexField(field.Name, data)
+			fields[i][j] = field
+		}
+		data = m.merge(data, normalizeData(extraction.Data), "", fieldConfidences(extraction.Fields))
+	}
+	if d, ok := data.(map[string]interface{}); ok {
+		merged.Data = d
+	}
+
+	best := map[string]int{} // Field name to index in merged.Fields
+	for _, chunkFields := range fields {
+		for _, field := range chunkFields {
+			if isArrayField(field.Name, parsed) {
+				merged.Fields = append(merged.Fields, field)
+				continue
+			}
+			if i, ok := best[field.Name]; ok {
+				if field.Confidence > merged.Fields[i].Confidence {
+					merged.Fields[i] = field
+				}
+				continue
+			}
+			best[field.Name] = len(merged.Fields)
+			merged.Fields = append(merged.Fields, field)
+		}
+	}
+	return merged
+}
+
+// reindexField shifts the first array index of a field name, such as
+// "line_items[0].amount", by the number of elements data already holds at
+// that path, since the chunk's array is appended after them
+func reindexField(name string, data interface{}) string {
+	open := strings.Index(name, "[")
+	end := strings.Index(name, "]")
+	if open < 0 || end < open {
+		return name
+	}
+	index, err := strconv.Atoi(name[open+1 : end])
+	if err != nil {
+		return name
+	}
+
+	value := data
+	for _, key := range strings.Split(strings.TrimPrefix(name[:open], "data."), ".") {
+		object, _ := value.(map[string]interface{})
+		value = object[key]
+	}
+	array, _ := value.([]interface{})
+	if len(array) == 0 {
+		return name
+	}
+	return name[:open+1] + strconv.Itoa(index+len(array)) + name[end:]
+}
+
+// normalizeData round-trips data through JSON so nested values are plain
+// maps, slices and float64s
+func normalizeData(data map[string]interface{}) interface{} {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return data
+	}
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return data
+	}
+	return value
+}
+
+// merger merges chunk data, remembering the confidence each chosen scalar
+// was picked with
+type merger struct {
+	chosen map[string]float64 // Data path to confidence of the current value
+}
+
+// merge merges next into current at path. confidences are those of the
+// chunk next comes from.
+func (m *merger) merge(current, next interface{}, path string, confidences map[string]float64) interface{} {
+	if next == nil {
+		return current
+	}
+
+	switch n := next.(type) {
+	case []interface{}:
+		if c, ok := current.([]interface{}); ok {
+			return append(append([]interface{}{}, c...), n...)
+		}
+		if current == nil {
+			return n
+		}
+	case map[string]interface{}:
+		c, ok := current.(map[string]interface{})
+		if current == nil {
+			c, ok = map[string]interface{}{}, true
+		}
+		if ok {
+			out := make(map[string]interface{}, len(c)+len(n))
+			for key, value := range c {
+				out[key] = value
+			}
+			for key, value := range n {
+				out[key] = m.merge(out[key], value, joinPath(path, key), confidences)
+			}
+			return out
+		}
+	}
+
+	// Scalars, or values whose types differ between chunks
+	if current == nil || confidences[path] > m.chosen[path] {
+		m.chosen[path] = confidences[path]
+		return next
+	}
+	return current
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// fieldConfidences maps field names to their confidence. Names are matched
+// against data paths such as "vendor.name"; a leading "data." is ignored.
+func fieldConfidences(fields []models.ExtractedField) map[string]float64 {
+	confidences := make(map[string]float64, len(fields))
+	for _, field := range fields {
+		name := strings.TrimPrefix(field.Name, "data.")
+		if field.Confidence > confidences[name] {
+			confidences[name] = field.Confidence
+		}
+	}
+	return confidences
+}
+
+// isArrayField reports whether a field name refers into an array property
+// of the schema, such as "line_items[2].amount" or "transactions"
+func isArrayField(name string, schema map[string]interface{}) bool {
+	name = strings.TrimPrefix(name, "data.")
+	if strings.Contains(name, "[") {
+		return true
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	for _, key := range strings.Split(name, ".") {
+		prop, ok := properties[key].(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if schemaTypesContain(prop["type"], "array") {
+			return true
+		}
+		properties, _ = prop["properties"].(map[string]interface{})
+	}
+	return false
+}
+
+func schemaTypesContain(t interface{}, want string) bool {
+	for _, s := range schemaTypes(t) {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
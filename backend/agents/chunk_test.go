@@ -0,0 +1,224 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/pdf"
+)
+
+func numberedPages(n int) []string {
+	pages := make([]string, n)
+	for i := range pages {
+		pages[i] = fmt.Sprintf("Page %d", i+1)
+	}
+	return pages
+}
+
+func TestSplitIntoChunks(t *testing.T) {
+	data := pdf.GenerateTextPDF(numberedPages(7))
+
+	chunks, err := SplitIntoChunks(data, ChunkOptions{MaxPages: 3})
+	if err != nil {
+		t.Fatalf("SplitIntoChunks failed: %v", err)
+	}
+	expected := [][2]int{{1, 3}, {4, 6}, {7, 7}}
+	if len(chunks) != len(expected) {
+		t.Fatalf("Expected %d chunks, got %d", len(expected), len(chunks))
+	}
+	for i, chunk := range chunks {
+		if chunk.StartPage != expected[i][0] || chunk.EndPage != expected[i][1] {
+			t.Errorf("Chunk %d: expected pages %v, got %d-%d", i, expected[i], chunk.StartPage, chunk.EndPage)
+		}
+		texts, err := pdf.ExtractPageTexts(chunk.PDFData)
+		if err != nil || texts[0] != fmt.Sprintf("Page %d", chunk.StartPage) {
+			t.Errorf("Chunk %d: expected to start with page %d, got %q (%v)", i, chunk.StartPage, texts, err)
+		}
+	}
+}
+
+func TestSplitIntoChunks_SmallOrUnreadable(t *testing.T) {
+	data := pdf.GenerateTextPDF(numberedPages(2))
+	chunks, _ := SplitIntoChunks(data, DefaultChunkOptions())
+	if len(chunks) != 1 || &chunks[0].PDFData[0] != &data[0] || chunks[0].EndPage != 2 {
+		t.Errorf("Expected the original PDF as a single chunk, got %+v", chunks)
+	}
+
+	chunks, _ = SplitIntoChunks([]byte("%PDF-1.4 mock"), ChunkOptions{MaxPages: 1})
+	if len(chunks) != 1 || string(chunks[0].PDFData) != "%PDF-1.4 mock" {
+		t.Errorf("Expected unreadable data as a single chunk, got %+v", chunks)
+	}
+}
+
+func TestSplitIntoChunks_HalvesOversizedChunks(t *testing.T) {
+	data := pdf.GenerateTextPDF(numberedPages(4))
+	single, _ := pdf.SplitPages(data, 1, 1)
+
+	chunks, err := SplitIntoChunks(data, ChunkOptions{MaxPages: 4, MaxBytes: len(single) + 100})
+	if err != nil {
+		t.Fatalf("SplitIntoChunks failed: %v", err)
+	}
+	if len(chunks) != 4 {
+		t.Errorf("Expected oversized chunks to be halved down to single pages, got %d chunks", len(chunks))
+	}
+}
+
+func TestMergeExtractions(t *testing.T) {
+	first := &models.Extraction{
+		SchemaUsed: "invoice",
+		Data: map[string]interface{}{
+			"invoice_number": "INV-1",
+			"total":          90.0,
+			"vendor":         map[string]interface{}{"name": "Acme"},
+			"line_items":     []interface{}{map[string]interface{}{"description": "A"}},
+		},
+		Fields: []models.ExtractedField{
+			{Name: "invoice_number", Value: "INV-1", PageNumber: 1, Confidence: 0.9},
+			{Name: "total", Value: 90.0, PageNumber: 2, Confidence: 0.4},
+			{Name: "line_items[0].description", Value: "A", PageNumber: 2, Confidence: 0.9},
+		},
+	}
+	second := &models.Extraction{
+		SchemaUsed: "invoice",
+		Data: map[string]interface{}{
+			"invoice_number": "INV-2",
+			"total":          100.0,
+			"vendor":         map[string]interface{}{"name": nil, "email": "a@acme.test"},
+			"line_items":     []interface{}{map[string]interface{}{"description": "B"}},
+		},
+		Fields: []models.ExtractedField{
+			{Name: "invoice_number", Value: "INV-2", PageNumber: 21, Confidence: 0.5},
+			{Name: "total", Value: 100.0, PageNumber: 25, Confidence: 0.95},
+			{Name: "line_items[0].description", Value: "B", PageNumber: 22, Confidence: 0.9},
+		},
+	}
+
+	merged := MergeExtractions([]*models.Extraction{first, second}, GetSchemaForDocumentType("invoice"))
+
+	if merged.Data["invoice_number"] != "INV-1" {
+		t.Errorf("Expected the more confident invoice number, got %v", merged.Data["invoice_number"])
+	}
+	if merged.Data["total"] != 100.0 {
+		t.Errorf("Expected the more confident total, got %v", merged.Data["total"])
+	}
+	vendor := merged.Data["vendor"].(map[string]interface{})
+	if vendor["name"] != "Acme" || vendor["email"] != "a@acme.test" {
+		t.Errorf("Expected vendor objects to be merged, got %v", vendor)
+	}
+	if items := merged.Data["line_items"].([]interface{}); len(items) != 2 {
+		t.Errorf("Expected line items to be concatenated, got %v", items)
+	}
+
+	pages := map[string][]int{}
+	for _, field := range merged.Fields {
+		pages[field.Name] = append(pages[field.Name], field.PageNumber)
+	}
+	if fmt.Sprint(pages["total"]) != "[25]" || fmt.Sprint(pages["invoice_number"]) != "[1]" {
+		t.Errorf("Expected one field per scalar from the winning chunk, got %v", pages)
+	}
+	if fmt.Sprint(pages["line_items[0].description"]) != "[2]" || fmt.Sprint(pages["line_items[1].description"]) != "[22]" {
+		t.Errorf("Expected both array fields to be kept, got %v", pages)
+	}
+}
+
+func TestMergeExtractions_ReindexesArrayFields(t *testing.T) {
+	item := func(description string, amount float64) map[string]interface{} {
+		return map[string]interface{}{"description": description, "amount": amount}
+	}
+	first := &models.Extraction{
+		Data: map[string]interface{}{"line_items": []interface{}{item("A", 1), item("B", 2)}},
+		Fields: []models.ExtractedField{
+			{Name: "line_items[0].amount", Value: 1.0, Confidence: 0.9},
+			{Name: "line_items[1].amount", Value: 2.0, Confidence: 0.9},
+		},
+	}
+	second := &models.Extraction{
+		Data: map[string]interface{}{"line_items": []interface{}{item("C", 3), item("D", 4)}},
+		Fields: []models.ExtractedField{
+			{Name: "line_items[0].amount", Value: 3.0, Confidence: 0.9},
+			{Name: "data.line_items[1].amount", Value: 4.0, Confidence: 0.9},
+			{Name: "line_items", Value: 2.0, Confidence: 0.9},
+		},
+	}
+
+	merged := MergeExtractions([]*models.Extraction{first, second}, GetSchemaForDocumentType("invoice"))
+
+	var names []string
+	for _, field := range merged.Fields {
+		names = append(names, field.Name)
+	}
+	want := []string{"line_items[0].amount", "line_items[1].amount", "line_items[2].amount", "data.line_items[3].amount", "line_items"}
+	if fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Fatalf("Expected fields %v, got %v", want, names)
+	}
+	items := merged.Data["line_items"].([]interface{})
+	for i, field := range merged.Fields[:4] {
+		if amount := items[i].(map[string]interface{})["amount"]; amount != field.Value {
+			t.Errorf("Expected %s to name the item with amount %v, got %v", field.Name, field.Value, amount)
+		}
+	}
+}
+
+func TestExtractChunked(t *testing.T) {
+	data := pdf.GenerateTextPDF(numberedPages(5))
+
+	calls := 0
+	client := &MockClient{
+		ExtractFunc: func(ctx context.Context, pdfData []byte, documentType string, schema string, opts Options) (*models.Extraction, string, *models.TokenUsage, error) {
+			calls++
+			if calls == 2 {
+				return nil, "", nil, errors.New("overloaded")
+			}
+			texts, _ := pdf.ExtractPageTexts(pdfData)
+			return &models.Extraction{
+				SchemaUsed: documentType,
+				Data:       map[string]interface{}{"transactions": []interface{}{texts[0]}},
+				Fields:     []models.ExtractedField{{Name: "transactions[0]", Value: texts[0], PageNumber: 1, Confidence: 0.9}},
+			}, "extract prompt", &models.TokenUsage{Model: "test-model"}, nil
+		},
+	}
+
+	extraction, steps, err := ExtractChunked(context.Background(), client, data, "statement", GetSchemaForDocumentType("statement"), Options{}, 0, ChunkOptions{MaxPages: 2})
+	if err != nil {
+		t.Fatalf("ExtractChunked failed: %v", err)
+	}
+
+	if got := fmt.Sprint(extraction.Data["transactions"]); got != "[Page 1 Page 5]" {
+		t.Errorf("Expected transactions from chunks 1 and 3, got %s", got)
+	}
+	if len(extraction.Fields) != 2 || extraction.Fields[1].PageNumber != 5 {
+		t.Errorf("Expected page numbers offset to the document, got %+v", extraction.Fields)
+	}
+	if len(extraction.Chunks) != 3 || extraction.Chunks[1].Error == "" || extraction.Chunks[2].StartPage != 5 {
+		t.Errorf("Unexpected chunk records: %+v", extraction.Chunks)
+	}
+	if extraction.Validation == nil {
+		t.Error("Expected the merged extraction to be validated")
+	}
+
+	ranges := map[string]bool{}
+	for _, step := range steps {
+		if step.AgentType == StepExtraction {
+			ranges[fmt.Sprintf("%d-%d", step.StartPage, step.EndPage)] = true
+		}
+	}
+	if !ranges["1-2"] || !ranges["5-5"] {
+		t.Errorf("Expected extraction steps to carry their page ranges, got %v", ranges)
+	}
+}
+
+func TestExtractChunked_AllChunksFail(t *testing.T) {
+	client := &MockClient{
+		ExtractFunc: func(ctx context.Context, pdfData []byte, documentType string, schema string, opts Options) (*models.Extraction, string, *models.TokenUsage, error) {
+			return nil, "", nil, errors.New("boom")
+		},
+	}
+
+	_, _, err := ExtractChunked(context.Background(), client, pdf.GenerateTextPDF(numberedPages(3)), "other", GetSchemaForDocumentType("other"), Options{}, 0, ChunkOptions{MaxPages: 1})
+	if err == nil {
+		t.Error("Expected error when every chunk fails")
+	}
+}
@@ -0,0 +1,204 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/pdf-viewer/backend/models"
+)
+
+// StepCitations is the agent type of grounding steps
+const StepCitations = "citations"
+
+// Citer is implemented by clients that can locate extracted fields in a PDF
+// through a citations API. CiteFields returns the citations of each field,
+// in the order of fields, with 1-indexed page numbers.
+type Citer interface {
+	CiteFields(ctx context.Context, pdfData []byte, fields []models.ExtractedField, opts Options) ([][]models.Citation, string, *models.TokenUsage, error)
+}
+
+// Ensure ClaudeClient implements Citer interface
+var _ Citer = (*ClaudeClient)(nil)
+
+// BuildCitationPrompt creates the prompt asking the model to cite the
+// passage supporting each numbered field
+func BuildCitationPrompt(fields []models.ExtractedField) string {
+	var list strings.Builder
+	for i, field := range fields {
+		fmt.Fprintf(&list, "[%d] %s: %v", i+1, field.Name, field.Value)
+		if field.SourceText != "" {
+			fmt.Fprintf(&list, " (quoted as %q)", field.SourceText)
+		}
+		list.WriteByte('\n')
+	}
+
+	return fmt.Sprintf(`The following values were extracted from this PDF document:
+
+%s
+For each value, find the passage of the document that states it. Answer with
+one line per value: its marker, such as [1], followed by a short statement of
+the value that cites the passage. If a value does not appear in the document,
+write its marker followed by "not found" without a citation.`, list.String())
+}
+
+// citationMarker matches the field markers of a citation response
+var citationMarker = regexp.MustCompile(`\[(\d+)\]`)
+
+// parseCitationContent assigns the page citations of a response to the n
+// fields they were given for. The citations of a text block belong to the
+// first marker in the block or, when it has none, to the last marker seen.
+func parseCitationContent(content []anthropic.ContentBlockUnion, n int) [][]models.Citation {
+	citations := make([][]models.Citation, n)
+	current := -1
+	for _, block := range content {
+		if block.Type != "text" {
+			continue
+		}
+		markers := citationMarker.FindAllStringSubmatch(block.Text, -1)
+		target := current
+		if len(markers) > 0 {
+			target = markerIndex(markers[0][1])
+			current = markerIndex(markers[len(markers)-1][1])
+		}
+		if target < 0 || target >= n {
+			continue
+		}
+		for _, citation := range block.Citations {
+			if citation.Type != "page_location" {
+				continue
+			}
+			// The API's end page is exclusive
+			end := int(citation.EndPageNumber) - 1
+			if end < int(citation.StartPageNumber) {
+				end = int(citation.StartPageNumber)
+			}
+			citations[target] = append(citations[target], models.Citation{
+				StartPage: int(citation.StartPageNumber),
+				EndPage:   end,
+				CitedText: strings.TrimSpace(citation.CitedText),
+			})
+		}
+	}
+	return citations
+}
+
+func markerIndex(s string) int {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return -1
+	}
+	return i - 1
+}
+
+// CiteFields asks Claude to cite each field with citations enabled on the
+// document block. Citations are only returned in text, so no tools are sent;
+// the request therefore does not share the classification and extraction
+// cache prefix, but repeated grounding of a document reads its own.
+func (c *ClaudeClient) CiteFields(ctx context.Context, pdfData []byte, fields []models.ExtractedField, opts Options) ([][]models.Citation, string, *models.TokenUsage, error) {
+	prompt := BuildCitationPrompt(fields)
+	block := documentBlock(pdfData)
+	block.OfDocument.Citations = anthropic.CitationsConfigParam{Enabled: anthropic.Bool(true)}
+
+	params := anthropic.MessageNewParams{
+		Model:     anthropic.Model(opts.model()),
+		MaxTokens: extractionMaxTokens,
+		Messages: []anthropic.MessageParam{
+			anthropic.NewUserMessage(block, anthropic.NewTextBlock(prompt)),
+		},
+	}
+
+	message, stats, err := c.sendMessage(ctx, params, opts.ThinkingBudget)
+	if err != nil {
+		return nil, prompt, nil, err
+	}
+	return parseCitationContent(message.Content, len(fields)), prompt, usageFromMessage(opts.model(), message, stats), nil
+}
+
+// GroundExtraction locates every extracted field in the PDF with the
+// client's citations API, chunk by chunk for large documents. Fields are
+// updated in place with their citations; those without any are flagged
+// Uncited. A field is grounded in the chunk containing its claimed page, or
+// the first chunk when it claims none. Clients that don't implement Citer
+// leave the extraction unchanged and return no report. A failed chunk is
+// noted in the report and its fields are left unflagged.
+func GroundExtraction(ctx context.Context, client Client, pdfData []byte, extraction *models.Extraction, opts Options, chunkOpts ChunkOptions) (*models.GroundingReport, []ExtractionStep) {
+	citer, ok := client.(Citer)
+	if !ok || len(extraction.Fields) == 0 {
+		return nil, nil
+	}
+
+	report := &models.GroundingReport{}
+	chunks, err := SplitIntoChunks(pdfData, chunkOpts)
+	if err != nil {
+		report.Error = err.Error()
+		return report, nil
+	}
+
+	// Field indexes grouped by chunk
+	groups := make([][]int, len(chunks))
+	for i, field := range extraction.Fields {
+		chunk := 0
+		for j, c := range chunks {
+			if field.PageNumber >= c.StartPage && field.PageNumber <= c.EndPage {
+				chunk = j
+				break
+			}
+		}
+		groups[chunk] = append(groups[chunk], i)
+	}
+
+	var steps []ExtractionStep
+	var errors []string
+	for j, chunk := range chunks {
+		if len(groups[j]) == 0 {
+			continue
+		}
+		fields := make([]models.ExtractedField, len(groups[j]))
+		for k, i := range groups[j] {
+			fields[k] = extraction.Fields[i]
+			if len(chunks) > 1 && fields[k].PageNumber > 0 {
+				fields[k].PageNumber -= chunk.StartPage - 1
+			}
+		}
+
+		citations, prompt, tokenUsage, err := citer.CiteFields(ctx, chunk.PDFData, fields, opts)
+		if err != nil {
+			if len(chunks) > 1 {
+				err = fmt.Errorf("pages %d-%d: %w", chunk.StartPage, chunk.EndPage, err)
+			}
+			errors = append(errors, err.Error())
+			continue
+		}
+
+		step := ExtractionStep{AgentType: StepCitations, Prompt: prompt, Response: marshalStep(citations), TokenUsage: tokenUsage}
+		if len(chunks) > 1 {
+			step.StartPage, step.EndPage = chunk.StartPage, chunk.EndPage
+		}
+		steps = append(steps, step)
+
+		for k, i := range groups[j] {
+			field := &extraction.Fields[i]
+			field.Citations = nil
+			if k < len(citations) {
+				for _, citation := range citations[k] {
+					citation.StartPage += chunk.StartPage - 1
+					citation.EndPage += chunk.StartPage - 1
+					field.Citations = append(field.Citations, citation)
+				}
+			}
+			field.Uncited = len(field.Citations) == 0
+			if field.Uncited {
+				report.Uncited++
+			} else {
+				report.Cited++
+			}
+		}
+	}
+
+	report.Error = strings.Join(errors, "; ")
+	return report, steps
+}
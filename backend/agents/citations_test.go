@@ -0,0 +1,161 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/pdf"
+)
+
+const citationResponse = `{
+	"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5-20250929",
+	"content": [
+		{"type": "text", "text": "[1] "},
+		{"type": "text", "text": "The invoice number is INV-7",
+			"citations": [{"type": "page_location", "cited_text": "Invoice INV-7 ", "document_index": 0,
+				"document_title": null, "start_page_number": 1, "end_page_number": 2}]},
+		{"type": "text", "text": "\n[2] not found\n[3] The total is $40, spread over two pages",
+			"citations": [{"type": "page_location", "cited_text": "Total $40", "document_index": 0,
+				"document_title": null, "start_page_number": 2, "end_page_number": 4}]}
+	],
+	"usage": {"input_tokens": 1200, "output_tokens": 80}
+}`
+
+func TestParseCitationContent(t *testing.T) {
+	var message anthropic.Message
+	if err := json.Unmarshal([]byte(citationResponse), &message); err != nil {
+		t.Fatalf("Failed to decode message: %v", err)
+	}
+
+	citations := parseCitationContent(message.Content, 3)
+	if len(citations[0]) != 1 || citations[0][0] != (models.Citation{StartPage: 1, EndPage: 1, CitedText: "Invoice INV-7"}) {
+		t.Errorf("Expected field 1 cited on page 1, got %+v", citations[0])
+	}
+	// The second block starts with marker [2], so its citation belongs to field 2
+	if len(citations[1]) != 1 || citations[1][0].StartPage != 2 || citations[1][0].EndPage != 3 {
+		t.Errorf("Expected field 2 cited on pages 2-3, got %+v", citations[1])
+	}
+	if len(citations[2]) != 0 {
+		t.Errorf("Expected no citations for field 3, got %+v", citations[2])
+	}
+}
+
+func TestClaudeClient_CiteFields(t *testing.T) {
+	var request map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &request)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, citationResponse)
+	}))
+	defer server.Close()
+
+	client := NewClaudeClient(option.WithBaseURL(server.URL), option.WithAPIKey("test-key"))
+	fields := []models.ExtractedField{
+		{Name: "invoice_number", Value: "INV-7", SourceText: "Invoice INV-7"},
+		{Name: "due_date", Value: "2025-01-01"},
+	}
+	citations, prompt, tokenUsage, err := client.CiteFields(context.Background(), []byte("%PDF-1.4"), fields, Options{})
+	if err != nil {
+		t.Fatalf("CiteFields failed: %v", err)
+	}
+
+	if _, ok := request["tools"]; ok {
+		t.Errorf("Expected no tools in a citation request")
+	}
+	messages := request["messages"].([]interface{})
+	content := messages[0].(map[string]interface{})["content"].([]interface{})
+	document := content[0].(map[string]interface{})
+	if config, _ := document["citations"].(map[string]interface{}); config["enabled"] != true {
+		t.Errorf("Expected citations enabled on the document block, got %v", document["citations"])
+	}
+
+	if !strings.Contains(prompt, "[2] due_date: 2025-01-01") {
+		t.Errorf("Expected prompt to list numbered fields, got %s", prompt)
+	}
+	if len(citations) != 2 || len(citations[0]) != 1 {
+		t.Errorf("Expected a citation for the first field, got %+v", citations)
+	}
+	if tokenUsage == nil || tokenUsage.InputTokens != 1200 {
+		t.Errorf("Expected token usage from the response, got %+v", tokenUsage)
+	}
+}
+
+func TestGroundExtraction_FlagsUncitedFields(t *testing.T) {
+	extraction := &models.Extraction{Fields: []models.ExtractedField{
+		{Name: "total", SourceText: "$100.00", PageNumber: 1},
+		{Name: "notes", Value: "none"},
+	}}
+
+	report, steps := GroundExtraction(context.Background(), NewMockClient(), pdf.GenerateTextPDF([]string{"Total $100.00"}), extraction, Options{}, DefaultChunkOptions())
+	if report == nil || report.Cited != 1 || report.Uncited != 1 {
+		t.Fatalf("Expected 1 cited and 1 uncited field, got %+v", report)
+	}
+	if len(steps) != 1 || steps[0].AgentType != StepCitations {
+		t.Errorf("Expected a single citations step, got %+v", steps)
+	}
+	if len(extraction.Fields[0].Citations) != 1 || extraction.Fields[0].Uncited {
+		t.Errorf("Expected the first field to be cited, got %+v", extraction.Fields[0])
+	}
+	if !extraction.Fields[1].Uncited {
+		t.Errorf("Expected the second field to be flagged uncited")
+	}
+}
+
+func TestGroundExtraction_OffsetsChunkPages(t *testing.T) {
+	var requested [][]int
+	client := &MockClient{
+		CiteFunc: func(ctx context.Context, pdfData []byte, fields []models.ExtractedField, opts Options) ([][]models.Citation, string, *models.TokenUsage, error) {
+			pages := []int{}
+			citations := make([][]models.Citation, len(fields))
+			for i, field := range fields {
+				pages = append(pages, field.PageNumber)
+				citations[i] = []models.Citation{{StartPage: field.PageNumber, EndPage: field.PageNumber}}
+			}
+			requested = append(requested, pages)
+			if len(requested) == 2 {
+				return nil, "", nil, errors.New("overloaded")
+			}
+			return citations, "cite", &models.TokenUsage{}, nil
+		},
+	}
+	extraction := &models.Extraction{Fields: []models.ExtractedField{
+		{Name: "a", PageNumber: 3},
+		{Name: "b", PageNumber: 5},
+		{Name: "c"},
+	}}
+
+	report, steps := GroundExtraction(context.Background(), client, pdf.GenerateTextPDF(numberedPages(6)), extraction, Options{}, ChunkOptions{MaxPages: 4})
+	if len(requested) != 2 || len(requested[0]) != 2 || requested[0][0] != 3 || requested[1][0] != 1 {
+		t.Fatalf("Expected fields grouped by chunk with chunk-relative pages, got %v", requested)
+	}
+	if c := extraction.Fields[0].Citations; len(c) != 1 || c[0].StartPage != 3 {
+		t.Errorf("Expected citation on page 3, got %+v", c)
+	}
+	if extraction.Fields[1].Citations != nil || extraction.Fields[1].Uncited {
+		t.Errorf("Expected a field of a failed chunk to be left unflagged, got %+v", extraction.Fields[1])
+	}
+	if report.Cited != 2 || !strings.Contains(report.Error, "pages 5-6: overloaded") {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if len(steps) != 1 || steps[0].StartPage != 1 || steps[0].EndPage != 4 {
+		t.Errorf("Expected one step for pages 1-4, got %+v", steps)
+	}
+}
+
+func TestGroundExtraction_SkipsClientsWithoutCitations(t *testing.T) {
+	extraction := &models.Extraction{Fields: []models.ExtractedField{{Name: "total"}}}
+	report, steps := GroundExtraction(context.Background(), NewOllamaClient("http://localhost:0"), nil, extraction, Options{}, DefaultChunkOptions())
+	if report != nil || steps != nil || extraction.Fields[0].Uncited {
+		t.Errorf("Expected grounding to be skipped, got %+v", report)
+	}
+}
@@ -2,48 +2,102 @@ package agents
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
+	"strings"
 
 	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
 	"github.com/pdf-viewer/backend/models"
 )
 
 type ClaudeClient struct {
-	client *anthropic.Client
+	client      *anthropic.Client
+	RetryPolicy RetryPolicy
 }
 
-func NewClaudeClient() *ClaudeClient {
-	client := anthropic.NewClient()
-	return &ClaudeClient{client: &client}
+// NewClaudeClient creates a Claude client. Request options such as
+// option.WithBaseURL can be passed to point it at a different endpoint.
+// Retries are handled by RetryPolicy rather than the SDK.
+func NewClaudeClient(opts ...option.RequestOption) *ClaudeClient {
+	opts = append([]option.RequestOption{option.WithMaxRetries(0)}, opts...)
+	client := anthropic.NewClient(opts...)
+	return &ClaudeClient{client: &client, RetryPolicy: DefaultRetryPolicy()}
 }
 
-// BuildClassificationPrompt creates the prompt for document classification
-func BuildClassificationPrompt() string {
-	return `Analyze this PDF document and classify it.
+// NewClaudeClientFromEnv creates a Claude client configured by the SDK's
+// environment variables, such as ANTHROPIC_API_KEY, that sends requests with
+// httpClient, if not nil
+func NewClaudeClientFromEnv(httpClient *http.Client) (Client, error) {
+	if httpClient == nil {
+		return NewClaudeClient(), nil
+	}
+	return NewClaudeClient(option.WithHTTPClient(httpClient)), nil
+}
 
-Return a JSON object with the following structure:
-{
-  "document_type": "string - the primary type of document (e.g., 'invoice', 'contract', 'resume', 'report', 'letter', 'form', 'receipt', 'statement', 'manual', 'other')",
-  "confidence": number between 0 and 1,
-  "reasoning": "string - detailed explanation of why you classified it this way, including key indicators you found",
-  "subtypes": ["array of more specific classifications if applicable"],
-  "language": "string - primary language of the document"
+// sendMessage calls the Messages API, retrying transient failures according
+// to the client's RetryPolicy. A positive thinking budget enables extended
+// thinking. A response cut off at max_tokens is continued if it is plain
+// text, or sent again with a larger limit if it is a tool call; the usage of
+// every call is added to the returned message.
+func (c *ClaudeClient) sendMessage(ctx context.Context, params anthropic.MessageNewParams, thinkingBudget int) (*anthropic.Message, sendStats, error) {
+	params = withThinking(params, thinkingBudget)
+	message, attempts, err := c.createMessage(ctx, params)
+	stats := sendStats{attempts: attempts}
+	if err != nil {
+		return nil, stats, err
+	}
+
+	for message.StopReason == anthropic.StopReasonMaxTokens {
+		if stats.continuations == maxContinuations {
+			return nil, stats, fmt.Errorf("%w: still cut off at %d tokens after %d continuation(s)", ErrMaxTokens, params.MaxTokens, stats.continuations)
+		}
+		if next, ok := continuationParams(params, message); ok {
+			continuation, attempts, err := c.createMessage(ctx, next)
+			stats.attempts += attempts
+			if err != nil {
+				return nil, stats, err
+			}
+			stitchMessage(message, continuation)
+		} else {
+			limit, ok := largerLimit(int(params.MaxTokens))
+			if !ok {
+				return nil, stats, fmt.Errorf("%w: cut off at %d tokens", ErrMaxTokens, params.MaxTokens)
+			}
+			params.MaxTokens = int64(limit)
+			retried, attempts, err := c.createMessage(ctx, params)
+			stats.attempts += attempts
+			if err != nil {
+				return nil, stats, err
+			}
+			addUsage(retried, message)
+			message = retried
+		}
+		stats.continuations++
+	}
+	return message, stats, nil
 }
 
-Be thorough in your reasoning - explain what specific elements led to your classification.`
+// createMessage makes one Messages API call, retrying transient failures
+func (c *ClaudeClient) createMessage(ctx context.Context, params anthropic.MessageNewParams) (*anthropic.Message, int, error) {
+	var message *anthropic.Message
+	attempts, err := c.RetryPolicy.Do(ctx, func() error {
+		var err error
+		message, err = c.client.Messages.New(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, attempts, fmt.Errorf("claude API error after %d attempt(s): %w", attempts, err)
+	}
+	return message, attempts, nil
 }
 
 // ParseClassificationResponse parses the Claude response into a Classification
 func ParseClassificationResponse(responseText string) (*models.Classification, error) {
-	var classification models.Classification
-	jsonStr := extractJSON(responseText)
-	if err := json.Unmarshal([]byte(jsonStr), &classification); err != nil {
-		return nil, fmt.Errorf("failed to parse classification response: %w", err)
-	}
-	return &classification, nil
+	classification, _, err := parseResponse[models.Classification](responseText, ClassificationToolName)
+	return classification, err
 }
 
 // BuildExtractionPrompt creates the prompt for data extraction
@@ -78,154 +132,242 @@ Return a JSON object with this structure:
 Be precise with source_text - it should be the exact text that appears in the document.`, documentType, schema, documentType)
 }
 
+// BuildRepairPrompt creates the prompt asking the model to correct an
+// extraction that failed schema validation
+func BuildRepairPrompt(documentType, schema, previous string, validationErrors []models.ValidationError) string {
+	return buildRepairPrompt(BuildExtractionPrompt(documentType, schema), previous, validationErrors)
+}
+
+func buildRepairPrompt(extractionPrompt, previous string, validationErrors []models.ValidationError) string {
+	var problems strings.Builder
+	for _, e := range validationErrors {
+		fmt.Fprintf(&problems, "- %s: %s\n", e.Path, e.Message)
+	}
+
+	return fmt.Sprintf(`%s
+
+Your previous extraction did not conform to the schema:
+%s
+Validation errors:
+%s
+Re-read the document and return the complete corrected extraction. Fix every
+validation error; use null for information that is not in the document.`,
+		extractionPrompt, previous, problems.String())
+}
+
 // ParseExtractionResponse parses the Claude response into an Extraction
 func ParseExtractionResponse(responseText string) (*models.Extraction, error) {
-	var extraction models.Extraction
-	jsonStr := extractJSON(responseText)
-	if err := json.Unmarshal([]byte(jsonStr), &extraction); err != nil {
-		return nil, fmt.Errorf("failed to parse extraction response: %w", err)
-	}
-	return &extraction, nil
+	extraction, _, err := parseResponse[models.Extraction](responseText, ExtractionToolName)
+	return extraction, err
 }
 
-// ExtractTextFromResponse extracts the text content from Claude message blocks
+// ExtractTextFromResponse joins the text blocks of a Claude message. A
+// response can hold several, for example after thinking blocks or when
+// citations split the text.
 func ExtractTextFromResponse(content []anthropic.ContentBlockUnion) string {
+	var text strings.Builder
 	for _, block := range content {
 		if block.Type == "text" {
-			return block.Text
+			text.WriteString(block.Text)
 		}
 	}
-	return ""
+	return text.String()
 }
 
-func (c *ClaudeClient) ClassifyDocument(ctx context.Context, pdfData []byte) (*models.Classification, string, *models.TokenUsage, error) {
-	pdfBase64 := base64.StdEncoding.EncodeToString(pdfData)
-	prompt := BuildClassificationPrompt()
-	modelName := string(anthropic.ModelClaudeSonnet4_5_20250929)
+// ExtractRawResponse returns the output of a Claude message before parsing:
+// its text followed by the input of each tool call
+func ExtractRawResponse(content []anthropic.ContentBlockUnion) string {
+	var parts []string
+	if text := ExtractTextFromResponse(content); text != "" {
+		parts = append(parts, text)
+	}
+	for _, block := range content {
+		if block.Type == "tool_use" {
+			parts = append(parts, string(block.Input))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
 
-	message, err := c.client.Messages.New(ctx, anthropic.MessageNewParams{
-		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
-		MaxTokens: 1024,
-		Messages: []anthropic.MessageParam{
-			anthropic.NewUserMessage(
-				anthropic.NewDocumentBlock(anthropic.Base64PDFSourceParam{
-					Data: pdfBase64,
-				}),
-				anthropic.NewTextBlock(prompt),
-			),
-		},
-	})
+func (c *ClaudeClient) ClassifyDocument(ctx context.Context, pdfData []byte, opts Options) (*models.Classification, string, *models.TokenUsage, error) {
+	tool, err := BuildClassificationTool()
 	if err != nil {
-		return nil, prompt, nil, fmt.Errorf("claude API error: %w", err)
+		return nil, "", nil, err
 	}
+	return callTool[models.Classification](ctx, c, pdfData, opts.classificationPrompt(), tool, classificationMaxTokens, opts)
+}
 
-	responseText := ExtractTextFromResponse(message.Content)
-	classification, err := ParseClassificationResponse(responseText)
+func (c *ClaudeClient) ExtractData(ctx context.Context, pdfData []byte, documentType string, schema string, opts Options) (*models.Extraction, string, *models.TokenUsage, error) {
+	tool, err := BuildExtractionTool(documentType, schema)
 	if err != nil {
-		return nil, prompt, nil, err
+		return nil, "", nil, err
 	}
+	return callTool[models.Extraction](ctx, c, pdfData, opts.extractionPrompt(documentType, schema), tool, extractionMaxTokens, opts)
+}
 
-	// Extract token usage
-	inputTokens := int(message.Usage.InputTokens)
-	outputTokens := int(message.Usage.OutputTokens)
-	tokenUsage := &models.TokenUsage{
-		Model:        modelName,
-		InputTokens:  inputTokens,
-		OutputTokens: outputTokens,
-		TotalCost:    models.CalculateCost(inputTokens, outputTokens),
+func (c *ClaudeClient) RepairExtraction(ctx context.Context, pdfData []byte, documentType string, schema string, previous *models.Extraction, validationErrors []models.ValidationError, opts Options) (*models.Extraction, string, *models.TokenUsage, error) {
+	tool, err := BuildExtractionTool(documentType, schema)
+	if err != nil {
+		return nil, "", nil, err
 	}
 
-	return classification, prompt, tokenUsage, nil
+	previousJSON, err := json.MarshalIndent(previous, "", "  ")
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to encode previous extraction: %w", err)
+	}
+
+	prompt := opts.repairPrompt(documentType, schema, string(previousJSON), validationErrors)
+	return callTool[models.Extraction](ctx, c, pdfData, prompt, tool, extractionMaxTokens, opts)
+}
+
+func (c *ClaudeClient) SummarizeDocument(ctx context.Context, pdfData []byte, summary SummaryOptions, opts Options) (*models.Summary, string, *models.TokenUsage, error) {
+	return callTool[models.Summary](ctx, c, pdfData, BuildSummaryPrompt(summary), BuildSummaryTool(), summary.maxTokens(), opts)
+}
+
+func (c *ClaudeClient) TranslateTexts(ctx context.Context, pdfData []byte, texts []string, translation TranslationOptions, opts Options) (*TranslationResult, string, *models.TokenUsage, error) {
+	prompt := BuildTranslationPrompt(texts, translation)
+	return callTool[TranslationResult](ctx, c, pdfData, prompt, BuildTranslationTool(translation.TargetLanguage), translation.maxTokens(), opts)
+}
+
+func (c *ClaudeClient) FindPII(ctx context.Context, pdfData []byte, opts Options) (*PIIResult, string, *models.TokenUsage, error) {
+	return callTool[PIIResult](ctx, c, pdfData, BuildPIIPrompt(), BuildPIITool(), extractionMaxTokens, opts)
 }
 
-func (c *ClaudeClient) ExtractData(ctx context.Context, pdfData []byte, documentType string, schema string) (*models.Extraction, string, *models.TokenUsage, error) {
-	pdfBase64 := base64.StdEncoding.EncodeToString(pdfData)
-	prompt := BuildExtractionPrompt(documentType, schema)
-	modelName := string(anthropic.ModelClaudeSonnet4_5_20250929)
+func (c *ClaudeClient) ExtractTables(ctx context.Context, pdfData []byte, opts Options) (*models.TableExtraction, string, *models.TokenUsage, error) {
+	return callTool[models.TableExtraction](ctx, c, pdfData, BuildTablesPrompt(), BuildTablesTool(), tablesMaxTokens, opts)
+}
+
+func (c *ClaudeClient) SegmentBundle(ctx context.Context, pdfData []byte, opts Options) (*models.BundleReport, string, *models.TokenUsage, error) {
+	return callTool[models.BundleReport](ctx, c, pdfData, BuildBundlePrompt(opts.DocumentTypes), BuildBundleTool(), bundleMaxTokens, opts)
+}
 
-	message, err := c.client.Messages.New(ctx, anthropic.MessageNewParams{
-		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
-		MaxTokens: 4096,
+// CompareDocuments sends both versions as documents, the previous version
+// first, with the tool forced
+func (c *ClaudeClient) CompareDocuments(ctx context.Context, beforePDF []byte, afterPDF []byte, opts Options) (*models.Comparison, string, *models.TokenUsage, error) {
+	prompt := BuildComparisonPrompt()
+	tools, toolChoice := forceTool(BuildComparisonTool())
+	params := anthropic.MessageNewParams{
+		Model:      anthropic.Model(opts.model()),
+		MaxTokens:  comparisonMaxTokens,
+		Tools:      tools,
+		ToolChoice: toolChoice,
 		Messages: []anthropic.MessageParam{
 			anthropic.NewUserMessage(
-				anthropic.NewDocumentBlock(anthropic.Base64PDFSourceParam{
-					Data: pdfBase64,
-				}),
+				documentBlock(beforePDF),
+				documentBlock(afterPDF),
 				anthropic.NewTextBlock(prompt),
 			),
 		},
-	})
-	if err != nil {
-		return nil, prompt, nil, fmt.Errorf("claude API error: %w", err)
 	}
+	return sendTool[models.Comparison](ctx, c, params, prompt, ComparisonToolName, opts)
+}
+
+// callTool asks a prompt about a PDF with the tool forced and decodes the
+// tool call into a T
+func callTool[T any](ctx context.Context, c *ClaudeClient, pdfData []byte, prompt string, tool anthropic.ToolParam, maxTokens int, opts Options) (*T, string, *models.TokenUsage, error) {
+	params := forcedToolParams(pdfData, prompt, tool, opts.model(), int64(maxTokens))
+	return sendTool[T](ctx, c, params, prompt, tool.Name, opts)
+}
 
-	responseText := ExtractTextFromResponse(message.Content)
-	extraction, err := ParseExtractionResponse(responseText)
+// sendTool sends a request forced to answer through the named tool and
+// decodes the tool call into a T
+func sendTool[T any](ctx context.Context, c *ClaudeClient, params anthropic.MessageNewParams, prompt, toolName string, opts Options) (*T, string, *models.TokenUsage, error) {
+	message, stats, err := c.sendMessage(ctx, params, opts.ThinkingBudget)
 	if err != nil {
 		return nil, prompt, nil, err
 	}
 
-	// Extract token usage
-	inputTokens := int(message.Usage.InputTokens)
-	outputTokens := int(message.Usage.OutputTokens)
-	tokenUsage := &models.TokenUsage{
-		Model:        modelName,
-		InputTokens:  inputTokens,
-		OutputTokens: outputTokens,
-		TotalCost:    models.CalculateCost(inputTokens, outputTokens),
+	// A response that cannot be decoded was still billed
+	tokenUsage := usageFromMessage(opts.model(), message, stats)
+	result, repairs, err := decodeToolOrText[T](message.Content, toolName)
+	if err != nil {
+		return nil, prompt, tokenUsage, err
 	}
-
-	return extraction, prompt, tokenUsage, nil
+	tokenUsage.JSONRepairs = repairNames(repairs)
+	return result, prompt, tokenUsage, nil
 }
 
-// extractJSON attempts to extract JSON from a response that may contain markdown code blocks
-func extractJSON(text string) string {
-	// Try to find JSON in code blocks first
-	start := 0
-	if idx := findIndex(text, "```json"); idx != -1 {
-		start = idx + 7
-	} else if idx := findIndex(text, "```"); idx != -1 {
-		start = idx + 3
+// forcedToolParams builds a request asking a prompt about a PDF and forced
+// to answer through the given tool. The document block comes first, so
+// calls on the same PDF with the same tool read it from the prompt cache.
+func forcedToolParams(pdfData []byte, prompt string, tool anthropic.ToolParam, model string, maxTokens int64) anthropic.MessageNewParams {
+	tools, toolChoice := forceTool(tool)
+	return anthropic.MessageNewParams{
+		Model:      anthropic.Model(model),
+		MaxTokens:  maxTokens,
+		Tools:      tools,
+		ToolChoice: toolChoice,
+		Messages: []anthropic.MessageParam{
+			anthropic.NewUserMessage(
+				documentBlock(pdfData),
+				anthropic.NewTextBlock(prompt),
+			),
+		},
 	}
+}
 
-	end := len(text)
-	if start > 0 {
-		if idx := findIndex(text[start:], "```"); idx != -1 {
-			end = start + idx
-		}
+// agentParams builds the request of a StepClassification or StepExtraction
+// call, exactly as ClassifyDocument and ExtractData send it
+func (c *ClaudeClient) agentParams(pdfData []byte, agentType, documentType, schema string, opts Options) (anthropic.MessageNewParams, string, error) {
+	request, err := newAgentRequest(agentType, documentType, schema, opts)
+	if err != nil {
+		return anthropic.MessageNewParams{}, "", err
 	}
+	tool := newTool(request.toolName, request.description, request.inputSchema)
+	return forcedToolParams(pdfData, request.prompt, tool, opts.model(), int64(request.maxTokens)), request.prompt, nil
+}
 
-	result := text[start:end]
-
-	// Find the JSON object boundaries
-	braceStart := findIndex(result, "{")
-	if braceStart == -1 {
-		return result
+// CountTokens counts the input of a call with the count_tokens endpoint
+func (c *ClaudeClient) CountTokens(ctx context.Context, pdfData []byte, agentType string, documentType string, schema string, opts Options) (*TokenCount, error) {
+	params, _, err := c.agentParams(pdfData, agentType, documentType, schema, opts)
+	if err != nil {
+		return nil, err
 	}
 
-	// Find matching closing brace
-	depth := 0
-	for i := braceStart; i < len(result); i++ {
-		if result[i] == '{' {
-			depth++
-		} else if result[i] == '}' {
-			depth--
-			if depth == 0 {
-				return result[braceStart : i+1]
-			}
-		}
+	tools := make([]anthropic.MessageCountTokensToolUnionParam, len(params.Tools))
+	for i, tool := range params.Tools {
+		tools[i] = anthropic.MessageCountTokensToolUnionParam{OfTool: tool.OfTool}
 	}
 
-	return result[braceStart:]
+	var count *anthropic.MessageTokensCount
+	attempts, err := c.RetryPolicy.Do(ctx, func() error {
+		var err error
+		count, err = c.client.Messages.CountTokens(ctx, anthropic.MessageCountTokensParams{
+			Model:      params.Model,
+			Messages:   params.Messages,
+			Tools:      tools,
+			ToolChoice: params.ToolChoice,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("claude API error after %d attempt(s): %w", attempts, err)
+	}
+	return &TokenCount{InputTokens: int(count.InputTokens)}, nil
 }
 
-func findIndex(s, substr string) int {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return i
-		}
+// usageFromMessage converts the API usage of a message into TokenUsage
+func usageFromMessage(model string, message *anthropic.Message, stats sendStats) *models.TokenUsage {
+	tokenUsage := &models.TokenUsage{
+		Model:                    model,
+		InputTokens:              int(message.Usage.InputTokens),
+		OutputTokens:             int(message.Usage.OutputTokens),
+		CacheCreationInputTokens: int(message.Usage.CacheCreationInputTokens),
+		CacheReadInputTokens:     int(message.Usage.CacheReadInputTokens),
+		Attempts:                 stats.attempts,
+		Thinking:                 ExtractThinkingFromResponse(message.Content),
+		RawResponse:              ExtractRawResponse(message.Content),
+		StopReason:               string(message.StopReason),
+		Continuations:            stats.continuations,
+	}
+	if tokenUsage.Thinking != "" {
+		tokenUsage.ThinkingTokens = estimateThinkingTokens(message)
 	}
-	return -1
+	tokenUsage.TotalCost = models.CalculateUsageCost(*tokenUsage)
+	tokenUsage.Unpriced = !models.IsPriced(tokenUsage.Model)
+	tokenUsage.CacheSavings = models.CalculateCacheSavings(*tokenUsage)
+	tokenUsage.ThinkingCost = models.CalculateThinkingCost(*tokenUsage)
+	return tokenUsage
 }
 
 // GetAPIKey returns the Anthropic API key from environment
@@ -6,11 +6,53 @@ import (
 	"github.com/pdf-viewer/backend/models"
 )
 
+// Options holds per-request settings for an agent call
+type Options struct {
+	Model         string                // Model ID; empty uses DefaultModel
+	DocumentTypes []models.DocumentType // Classification taxonomy; empty uses DefaultDocumentTypes
+
+	// Rendered prompt templates replacing the built-in prompts; empty uses
+	// BuildClassificationPrompt and BuildExtractionPrompt
+	ClassificationPrompt string
+	ExtractionPrompt     string
+
+	// ThinkingBudget enables Claude's extended thinking with this many
+	// tokens to think in before answering; 0 disables it. Other providers
+	// ignore it.
+	ThinkingBudget int
+}
+
+// Output token limits of classification and extraction calls
+const (
+	classificationMaxTokens = 1024
+	extractionMaxTokens     = 4096
+)
+
 // Client defines the interface for document processing agents.
 // This allows for mocking in tests.
 type Client interface {
-	ClassifyDocument(ctx context.Context, pdfData []byte) (*models.Classification, string, *models.TokenUsage, error)
-	ExtractData(ctx context.Context, pdfData []byte, documentType string, schema string) (*models.Extraction, string, *models.TokenUsage, error)
+	ClassifyDocument(ctx context.Context, pdfData []byte, opts Options) (*models.Classification, string, *models.TokenUsage, error)
+	ExtractData(ctx context.Context, pdfData []byte, documentType string, schema string, opts Options) (*models.Extraction, string, *models.TokenUsage, error)
+	RepairExtraction(ctx context.Context, pdfData []byte, documentType string, schema string, previous *models.Extraction, validationErrors []models.ValidationError, opts Options) (*models.Extraction, string, *models.TokenUsage, error)
+	// SummarizeDocument summarizes the document at the length and in the
+	// style of the normalized summary options
+	SummarizeDocument(ctx context.Context, pdfData []byte, summary SummaryOptions, opts Options) (*models.Summary, string, *models.TokenUsage, error)
+	// TranslateTexts translates the numbered texts, and the document's full
+	// text if requested, into the target language
+	TranslateTexts(ctx context.Context, pdfData []byte, texts []string, translation TranslationOptions, opts Options) (*TranslationResult, string, *models.TokenUsage, error)
+	// FindPII asks the model for the personal information in the document
+	FindPII(ctx context.Context, pdfData []byte, opts Options) (*PIIResult, string, *models.TokenUsage, error)
+	// ExtractTables returns every table in the document as a cell grid
+	ExtractTables(ctx context.Context, pdfData []byte, opts Options) (*models.TableExtraction, string, *models.TokenUsage, error)
+	// SegmentBundle divides a PDF that may bundle several documents into
+	// classified page ranges
+	SegmentBundle(ctx context.Context, pdfData []byte, opts Options) (*models.BundleReport, string, *models.TokenUsage, error)
+	// CompareDocuments lists the clause changes from the previous to the
+	// revised version of a document
+	CompareDocuments(ctx context.Context, beforePDF []byte, afterPDF []byte, opts Options) (*models.Comparison, string, *models.TokenUsage, error)
+	// CountTokens returns the input tokens of the classification or
+	// extraction call that would be sent for the document
+	CountTokens(ctx context.Context, pdfData []byte, agentType string, documentType string, schema string, opts Options) (*TokenCount, error)
 }
 
 // Ensure ClaudeClient implements Client interface
@@ -31,3 +73,33 @@ func GetClient() Client {
 	}
 	return globalClient
 }
+
+// model returns the requested model or DefaultModel
+func (o Options) model() string {
+	if o.Model == "" {
+		return DefaultModel
+	}
+	return o.Model
+}
+
+// classificationPrompt returns the classification prompt to send
+func (o Options) classificationPrompt() string {
+	if o.ClassificationPrompt != "" {
+		return o.ClassificationPrompt
+	}
+	return BuildClassificationPrompt(o.DocumentTypes)
+}
+
+// extractionPrompt returns the extraction prompt to send
+func (o Options) extractionPrompt(documentType, schema string) string {
+	if o.ExtractionPrompt != "" {
+		return o.ExtractionPrompt
+	}
+	return BuildExtractionPrompt(documentType, schema)
+}
+
+// repairPrompt returns the repair prompt to send, built on the extraction
+// prompt
+func (o Options) repairPrompt(documentType, schema, previous string, validationErrors []models.ValidationError) string {
+	return buildRepairPrompt(o.extractionPrompt(documentType, schema), previous, validationErrors)
+}
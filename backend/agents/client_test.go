@@ -47,7 +47,7 @@ func TestMockClient_DefaultBehavior(t *testing.T) {
 	mock := NewMockClient()
 
 	// Test default ClassifyDocument
-	classification, prompt, tokenUsage, err := mock.ClassifyDocument(context.Background(), []byte("%PDF"))
+	classification, prompt, tokenUsage, err := mock.ClassifyDocument(context.Background(), []byte("%PDF"), Options{})
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -65,7 +65,7 @@ func TestMockClient_DefaultBehavior(t *testing.T) {
 	}
 
 	// Test default ExtractData
-	extraction, prompt, tokenUsage, err := mock.ExtractData(context.Background(), []byte("%PDF"), "invoice", "{}")
+	extraction, prompt, tokenUsage, err := mock.ExtractData(context.Background(), []byte("%PDF"), "invoice", "{}", Options{})
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -82,7 +82,7 @@ func TestMockClient_DefaultBehavior(t *testing.T) {
 
 func TestMockClient_CustomFunctions(t *testing.T) {
 	mock := &MockClient{
-		ClassifyFunc: func(ctx context.Context, pdfData []byte) (*models.Classification, string, *models.TokenUsage, error) {
+		ClassifyFunc: func(ctx context.Context, pdfData []byte, opts Options) (*models.Classification, string, *models.TokenUsage, error) {
 			return &models.Classification{
 				DocumentType: "custom",
 				Confidence:   1.0,
@@ -93,7 +93,7 @@ func TestMockClient_CustomFunctions(t *testing.T) {
 				TotalCost:    0.001,
 			}, nil
 		},
-		ExtractFunc: func(ctx context.Context, pdfData []byte, documentType string, schema string) (*models.Extraction, string, *models.TokenUsage, error) {
+		ExtractFunc: func(ctx context.Context, pdfData []byte, documentType string, schema string, opts Options) (*models.Extraction, string, *models.TokenUsage, error) {
 			return &models.Extraction{
 				SchemaUsed: "custom",
 				Data:       map[string]interface{}{"custom": true},
@@ -107,13 +107,13 @@ func TestMockClient_CustomFunctions(t *testing.T) {
 	}
 
 	// Test custom ClassifyDocument
-	classification, _, _, _ := mock.ClassifyDocument(context.Background(), nil)
+	classification, _, _, _ := mock.ClassifyDocument(context.Background(), nil, Options{})
 	if classification.DocumentType != "custom" {
 		t.Errorf("Expected 'custom', got '%s'", classification.DocumentType)
 	}
 
 	// Test custom ExtractData
-	extraction, _, _, _ := mock.ExtractData(context.Background(), nil, "", "")
+	extraction, _, _, _ := mock.ExtractData(context.Background(), nil, "", "", Options{})
 	if extraction.SchemaUsed != "custom" {
 		t.Errorf("Expected 'custom', got '%s'", extraction.SchemaUsed)
 	}
@@ -0,0 +1,268 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/pdf"
+)
+
+// StepComparison is the agent type of document comparison calls
+const StepComparison = "comparison"
+
+// ComparisonToolName is the tool a comparison is recorded with
+const ComparisonToolName = "record_comparison"
+
+// comparisonMaxTokens is the output token limit of a comparison, which
+// quotes the text of every changed clause twice
+const comparisonMaxTokens = 8192
+
+// contractType is the document type whose extracted fields are diffed
+const contractType = "contract"
+
+// highlightedContractFields are the contract terms whose change is flagged
+var highlightedContractFields = map[string]bool{
+	"governing_law":      true,
+	"termination_clause": true,
+	"contract_value":     true,
+	"effective_date":     true,
+	"expiration_date":    true,
+	"parties":            true,
+	"obligations":        true,
+}
+
+// ComparisonInputSchema returns the JSON schema of a comparison
+func ComparisonInputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"summary": map[string]interface{}{
+				"type":        "string",
+				"description": "Two or three sentences on what changed overall",
+			},
+			"changes": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"kind":        map[string]interface{}{"type": "string", "enum": []string{models.ChangeAdded, models.ChangeRemoved, models.ChangeModified}},
+						"clause":      map[string]interface{}{"type": "string", "description": "Heading, number or short name of the clause"},
+						"before_text": map[string]interface{}{"type": "string", "description": "The clause as worded in the previous version; empty if added"},
+						"after_text":  map[string]interface{}{"type": "string", "description": "The clause as worded in the revised version; empty if removed"},
+						"before_page": map[string]interface{}{"type": "integer", "description": "1-indexed page of the clause in the previous version; 0 if added"},
+						"after_page":  map[string]interface{}{"type": "integer", "description": "1-indexed page of the clause in the revised version; 0 if removed"},
+						"materiality": map[string]interface{}{"type": "string", "enum": []string{models.MaterialityHigh, models.MaterialityMedium, models.MaterialityLow}},
+						"explanation": map[string]interface{}{"type": "string", "description": "What the change means for the parties"},
+					},
+					"required": []string{"kind", "clause", "materiality"},
+				},
+			},
+		},
+		"required": []string{"summary", "changes"},
+	}
+}
+
+const comparisonToolDescription = "Record the changes between the two versions of the document."
+
+// BuildComparisonTool creates the tool whose input is a Comparison
+func BuildComparisonTool() anthropic.ToolParam {
+	return newTool(ComparisonToolName, comparisonToolDescription, ComparisonInputSchema())
+}
+
+// BuildComparisonPrompt creates the prompt for comparing two versions of a
+// document, sent after both documents
+func BuildComparisonPrompt() string {
+	return `You are given two versions of a document: the first is the previous version and the second is the revised version. Compare them clause by clause, as a lawyer preparing a redline would.
+
+List every substantive change:
+- "added": a clause only in the revised version
+- "removed": a clause only in the previous version
+- "modified": a clause in both versions whose wording or meaning changed
+
+Quote the clause text exactly as it appears in each version and give its 1-indexed page in each version. Ignore changes to formatting, page layout, numbering and typos that do not change the meaning.
+
+Rate the materiality of each change:
+- "high": changes rights, obligations, liability, payment, term, termination or governing law
+- "medium": changes procedure, notice, deadlines or definitions in a way that could matter
+- "low": clarifications and wording changes with little practical effect
+
+Return a JSON object with this structure:
+{
+  "summary": "what changed overall",
+  "changes": [
+    {"kind": "modified", "clause": "12. Termination", "before_text": "...", "after_text": "...", "before_page": 4, "after_page": 5, "materiality": "high", "explanation": "..."}
+  ]
+}`
+}
+
+// FormatComparisonText lays out the pages of both versions for providers
+// without native PDF input, numbering attached images across both
+func FormatComparisonText(before, after []PageInput) string {
+	var b strings.Builder
+	image := 0
+	b.WriteString("=== Previous version ===\n")
+	writePages(&b, before, &image)
+	b.WriteString("\n=== Revised version ===\n")
+	writePages(&b, after, &image)
+	return b.String()
+}
+
+// loadComparisonInput reads the pages of both versions and returns the text
+// and images to send
+func loadComparisonInput(beforePDF, afterPDF []byte, mode InputMode) (string, []pdf.Image, error) {
+	before, err := LoadPageInputs(beforePDF, mode)
+	if err != nil {
+		return "", nil, fmt.Errorf("previous version: %w", err)
+	}
+	after, err := LoadPageInputs(afterPDF, mode)
+	if err != nil {
+		return "", nil, fmt.Errorf("revised version: %w", err)
+	}
+	return FormatComparisonText(before, after), append(pageImages(before), pageImages(after)...), nil
+}
+
+// CompareVersions compares two versions of a document. The model lists the
+// clause changes; when both versions have contract extractions, the
+// extracted fields are diffed too and the key contract terms highlighted.
+func CompareVersions(ctx context.Context, client Client, before, after *models.Document, types []models.DocumentType, opts Options) (*models.Comparison, ExtractionStep, error) {
+	comparison, prompt, tokenUsage, err := client.CompareDocuments(ctx, before.PDFData, after.PDFData, opts)
+	step := ExtractionStep{AgentType: StepComparison, Prompt: prompt, TokenUsage: tokenUsage}
+	if err != nil {
+		return nil, step, err
+	}
+	step.Response = marshalStep(comparison)
+
+	normalizeChanges(comparison.Changes, pageCount(before.PDFData), pageCount(after.PDFData))
+	comparison.BeforeDocumentID = before.ID
+	comparison.AfterDocumentID = after.ID
+	if comparison.Changes == nil {
+		comparison.Changes = []models.ClauseChange{}
+	}
+	if IsContractType(extractedType(before), types) && IsContractType(extractedType(after), types) {
+		comparison.FieldChanges = DiffExtractions(before.Extraction, after.Extraction)
+	}
+	if tokenUsage != nil {
+		comparison.Model = tokenUsage.Model
+	}
+	return comparison, step, nil
+}
+
+// normalizeChanges fixes the kind and materiality the model reported and
+// clears page numbers outside the documents
+func normalizeChanges(changes []models.ClauseChange, beforePages, afterPages int) {
+	for i := range changes {
+		change := &changes[i]
+		change.Kind = strings.ToLower(strings.TrimSpace(change.Kind))
+		if change.Kind != models.ChangeAdded && change.Kind != models.ChangeRemoved && change.Kind != models.ChangeModified {
+			switch {
+			case change.BeforeText == "":
+				change.Kind = models.ChangeAdded
+			case change.AfterText == "":
+				change.Kind = models.ChangeRemoved
+			default:
+				change.Kind = models.ChangeModified
+			}
+		}
+		change.Materiality = strings.ToLower(strings.TrimSpace(change.Materiality))
+		if change.Materiality != models.MaterialityHigh && change.Materiality != models.MaterialityLow {
+			change.Materiality = models.MaterialityMedium
+		}
+
+		if change.Kind == models.ChangeAdded || change.BeforePage < 1 || change.BeforePage > beforePages {
+			change.BeforePage = 0
+		}
+		if change.Kind == models.ChangeRemoved || change.AfterPage < 1 || change.AfterPage > afterPages {
+			change.AfterPage = 0
+		}
+	}
+}
+
+// pageCount returns the number of pages of a PDF, or 0 if it can't be read
+func pageCount(pdfData []byte) int {
+	doc, err := pdf.Open(pdfData)
+	if err != nil {
+		return 0
+	}
+	return doc.NumPages()
+}
+
+// extractedType returns the document type of a document's extraction
+func extractedType(doc *models.Document) string {
+	if doc.Extraction == nil {
+		return ""
+	}
+	if doc.Extraction.SchemaUsed != "" {
+		return doc.Extraction.SchemaUsed
+	}
+	if doc.Classification != nil {
+		return doc.Classification.DocumentType
+	}
+	return ""
+}
+
+// IsContractType reports whether documents of the type are extracted with
+// the contract schema, directly or through a parent type
+func IsContractType(documentType string, types []models.DocumentType) bool {
+	return documentType != "" && SchemaForDocumentType(documentType, types) == documentSchemas[contractType]
+}
+
+// DiffExtractions lists the top-level extracted fields whose values differ
+// between two extractions, in field name order
+func DiffExtractions(before, after *models.Extraction) []models.FieldChange {
+	if before == nil || after == nil {
+		return nil
+	}
+
+	names := map[string]bool{}
+	for name := range before.Data {
+		names[name] = true
+	}
+	for name := range after.Data {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var changes []models.FieldChange
+	for _, name := range sorted {
+		was, now := before.Data[name], after.Data[name]
+		var kind string
+		switch {
+		case isEmptyValue(was) && isEmptyValue(now):
+			continue
+		case isEmptyValue(was):
+			kind = models.ChangeAdded
+		case isEmptyValue(now):
+			kind = models.ChangeRemoved
+		case reflect.DeepEqual(was, now):
+			continue
+		default:
+			kind = models.ChangeModified
+		}
+		changes = append(changes, models.FieldChange{Field: name, Kind: kind, Before: was, After: now, Highlight: highlightedContractFields[name]})
+	}
+	return changes
+}
+
+// isEmptyValue reports whether an extracted value is missing
+func isEmptyValue(v interface{}) bool {
+	switch value := v.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(value) == ""
+	case []interface{}:
+		return len(value) == 0
+	case map[string]interface{}:
+		return len(value) == 0
+	}
+	return false
+}
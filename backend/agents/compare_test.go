@@ -0,0 +1,118 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/pdf"
+)
+
+func TestClaudeClient_CompareDocuments(t *testing.T) {
+	var request map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &request)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{
+			"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5-20250929",
+			"content": [{"type": "tool_use", "id": "toolu_1", "name": "record_comparison",
+				"input": {"summary": "Notice period extended.", "changes": [{"kind": "modified", "clause": "Termination", "before_text": "30 days notice", "after_text": "90 days notice", "before_page": 1, "after_page": 1, "materiality": "high"}]}}],
+			"usage": {"input_tokens": 3600, "output_tokens": 90}
+		}`)
+	}))
+	defer server.Close()
+
+	client := NewClaudeClient(option.WithBaseURL(server.URL), option.WithAPIKey("test-key"))
+	comparison, _, _, err := client.CompareDocuments(context.Background(), []byte("%PDF-1.4 before"), []byte("%PDF-1.4 after"), Options{})
+	if err != nil {
+		t.Fatalf("CompareDocuments failed: %v", err)
+	}
+
+	content := request["messages"].([]interface{})[0].(map[string]interface{})["content"].([]interface{})
+	if len(content) != 3 || content[0].(map[string]interface{})["type"] != "document" || content[1].(map[string]interface{})["type"] != "document" {
+		t.Errorf("Expected both documents before the prompt, got %v", content)
+	}
+	if len(comparison.Changes) != 1 || comparison.Changes[0].AfterText != "90 days notice" {
+		t.Errorf("Unexpected comparison: %+v", comparison)
+	}
+}
+
+func TestFormatComparisonText(t *testing.T) {
+	before := []PageInput{{Number: 1, Text: "Term: 1 year"}, {Number: 2, Images: []pdf.Image{{}}}}
+	after := []PageInput{{Number: 1, Images: []pdf.Image{{}}}}
+	text := FormatComparisonText(before, after)
+	if strings.Index(text, "Previous version") > strings.Index(text, "Revised version") {
+		t.Errorf("Expected the previous version first, got %s", text)
+	}
+	if !strings.Contains(text, "[Attached image 2 is from this page]") {
+		t.Errorf("Expected images numbered across both versions, got %s", text)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	client := &MockClient{
+		CompareFunc: func(ctx context.Context, beforePDF []byte, afterPDF []byte, opts Options) (*models.Comparison, string, *models.TokenUsage, error) {
+			return &models.Comparison{Changes: []models.ClauseChange{
+				{Kind: "Added", Clause: "Non-compete", AfterText: "No competing work for 2 years", BeforePage: 1, AfterPage: 1, Materiality: "HIGH"},
+				{Kind: "", Clause: "Notices", BeforeText: "By mail", BeforePage: 7, Materiality: "unclear"},
+			}}, "comparison prompt", &models.TokenUsage{Model: "model"}, nil
+		},
+	}
+	before := &models.Document{ID: "v1", PDFData: pdf.GenerateTextPDF([]string{"v1"}), Extraction: &models.Extraction{
+		SchemaUsed: "contract",
+		Data:       map[string]interface{}{"governing_law": "New York", "contract_title": "MSA", "key_terms": []interface{}{"net 30"}},
+	}}
+	after := &models.Document{ID: "v2", PDFData: pdf.GenerateTextPDF([]string{"v2"}), Extraction: &models.Extraction{
+		SchemaUsed: "contract",
+		Data:       map[string]interface{}{"governing_law": "Delaware", "contract_title": "MSA", "termination_clause": "90 days notice"},
+	}}
+
+	comparison, step, err := CompareVersions(context.Background(), client, before, after, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to compare: %v", err)
+	}
+	if step.AgentType != StepComparison || comparison.BeforeDocumentID != "v1" || comparison.AfterDocumentID != "v2" {
+		t.Errorf("Unexpected step or document IDs: %+v %+v", step, comparison)
+	}
+	added, removed := comparison.Changes[0], comparison.Changes[1]
+	if added.Kind != models.ChangeAdded || added.Materiality != models.MaterialityHigh || added.BeforePage != 0 {
+		t.Errorf("Expected a normalized added clause, got %+v", added)
+	}
+	if removed.Kind != models.ChangeRemoved || removed.Materiality != models.MaterialityMedium || removed.BeforePage != 0 {
+		t.Errorf("Expected the removed clause inferred and its page outside the document cleared, got %+v", removed)
+	}
+
+	fields := map[string]models.FieldChange{}
+	for _, change := range comparison.FieldChanges {
+		fields[change.Field] = change
+	}
+	if len(fields) != 3 {
+		t.Fatalf("Expected 3 changed fields, got %+v", comparison.FieldChanges)
+	}
+	if law := fields["governing_law"]; law.Kind != models.ChangeModified || !law.Highlight || law.After != "Delaware" {
+		t.Errorf("Expected governing law highlighted as modified, got %+v", law)
+	}
+	if fields["termination_clause"].Kind != models.ChangeAdded || fields["key_terms"].Kind != models.ChangeRemoved || fields["key_terms"].Highlight {
+		t.Errorf("Unexpected field changes: %+v", fields)
+	}
+
+	after.Extraction.SchemaUsed = "invoice"
+	comparison, _, _ = CompareVersions(context.Background(), client, before, after, nil, Options{})
+	if comparison.FieldChanges != nil {
+		t.Errorf("Expected no field changes unless both are contracts, got %+v", comparison.FieldChanges)
+	}
+}
+
+func TestIsContractType(t *testing.T) {
+	types := []models.DocumentType{{Name: "lease", Parent: "contract"}}
+	if !IsContractType("contract", nil) || !IsContractType("lease", types) || IsContractType("invoice", types) || IsContractType("", types) {
+		t.Error("Expected contracts and their subtypes only")
+	}
+}
@@ -0,0 +1,134 @@
+package agents
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/pdf-viewer/backend/models"
+)
+
+// StepClassificationVote is the agent type of the individual samples of an
+// ensemble classification
+const StepClassificationVote = "classification_vote"
+
+// MaxEnsembleSamples bounds the classification calls of one ensemble
+const MaxEnsembleSamples = 10
+
+// EnsembleOptions configures an ensemble classification
+type EnsembleOptions struct {
+	Samples int      // Classification calls to make
+	Models  []string // Models the samples are spread over in turn; empty uses the Options model
+}
+
+// ClassifyEnsemble classifies a PDF with several concurrent samples and
+// returns the majority document type. Ties go to the type with the highest
+// total reported confidence. The returned classification is the most
+// confident winning vote, with its Confidence replaced by the share of
+// samples that agree and the votes recorded in Ensemble. A failed sample
+// counts against the agreement, so one answer out of five is not
+// unanimous. Each sample is returned as a StepClassificationVote step. An
+// error is returned only if every sample fails.
+func ClassifyEnsemble(ctx context.Context, client Client, pdfData []byte, opts Options, ensemble EnsembleOptions) (*models.Classification, []ExtractionStep, error) {
+	samples := max(ensemble.Samples, 1)
+	sampleModels := ensemble.Models
+	if len(sampleModels) == 0 {
+		sampleModels = []string{opts.Model}
+	}
+
+	type sample struct {
+		classification *models.Classification
+		prompt         string
+		tokenUsage     *models.TokenUsage
+		err            error
+	}
+	results := make([]sample, samples)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sampleOpts := opts
+			sampleOpts.Model = sampleModels[i%len(sampleModels)]
+			result := &results[i]
+			result.classification, result.prompt, result.tokenUsage, result.err = client.ClassifyDocument(ctx, pdfData, sampleOpts)
+		}(i)
+	}
+	wg.Wait()
+
+	report := &models.EnsembleReport{Samples: samples, Tally: map[string]int{}}
+	confidence := map[string]float64{}
+	var steps []ExtractionStep
+	var firstErr error
+	for i, result := range results {
+		vote := models.ClassificationVote{Model: sampleModels[i%len(sampleModels)]}
+		if result.err != nil {
+			vote.Error = result.err.Error()
+			report.Failed++
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			report.Votes = append(report.Votes, vote)
+			continue
+		}
+
+		documentType := canonicalDocumentType(result.classification.DocumentType, opts.DocumentTypes)
+		vote.DocumentType = documentType
+		vote.Confidence = result.classification.Confidence
+		if result.tokenUsage != nil && result.tokenUsage.Model != "" {
+			vote.Model = result.tokenUsage.Model
+		}
+		report.Votes = append(report.Votes, vote)
+		report.Tally[documentType]++
+		confidence[documentType] += result.classification.Confidence
+
+		steps = append(steps, ExtractionStep{AgentType: StepClassificationVote, Prompt: result.prompt, Response: marshalStep(result.classification), TokenUsage: result.tokenUsage})
+	}
+
+	answered := samples - report.Failed
+	if answered == 0 {
+		return nil, steps, firstErr
+	}
+
+	var winner string
+	for _, vote := range report.Votes {
+		if vote.Error != "" || vote.DocumentType == winner {
+			continue
+		}
+		if winner == "" || report.Tally[vote.DocumentType] > report.Tally[winner] ||
+			report.Tally[vote.DocumentType] == report.Tally[winner] && confidence[vote.DocumentType] > confidence[winner] {
+			winner = vote.DocumentType
+		}
+	}
+
+	// Report the reasoning of the most confident winning vote
+	var best *models.Classification
+	for _, result := range results {
+		if result.err == nil && canonicalDocumentType(result.classification.DocumentType, opts.DocumentTypes) == winner &&
+			(best == nil || result.classification.Confidence > best.Confidence) {
+			best = result.classification
+		}
+	}
+
+	report.Agreement = float64(report.Tally[winner]) / float64(samples)
+	report.ModelConfidence = confidence[winner] / float64(report.Tally[winner])
+	report.Disagreement = len(report.Tally) > 1
+
+	classification := *best
+	classification.DocumentType = winner
+	classification.Confidence = report.Agreement
+	classification.Ensemble = report
+	return &classification, steps, nil
+}
+
+// canonicalDocumentType makes votes for the same type compare equal: a type
+// of the taxonomy is named as registered, any other is normalized
+func canonicalDocumentType(documentType string, types []models.DocumentType) string {
+	if len(types) == 0 {
+		types = DefaultDocumentTypes()
+	}
+	if registered, ok := FindDocumentType(types, documentType); ok {
+		return registered.Name
+	}
+	return strings.ToLower(strings.TrimSpace(documentType))
+}
@@ -0,0 +1,123 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/pdf-viewer/backend/models"
+)
+
+// votingClient answers the classification samples of each model in turn
+// from a list of document types. An empty type fails the call.
+func votingClient(answers map[string][]string) *MockClient {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	return &MockClient{
+		ClassifyFunc: func(ctx context.Context, pdfData []byte, opts Options) (*models.Classification, string, *models.TokenUsage, error) {
+			mu.Lock()
+			documentType := answers[opts.Model][calls[opts.Model]]
+			calls[opts.Model]++
+			mu.Unlock()
+
+			if documentType == "" {
+				return nil, "", nil, errors.New("overloaded")
+			}
+			return &models.Classification{DocumentType: documentType, Confidence: 0.9, Reasoning: documentType + " reasoning"},
+				"prompt", &models.TokenUsage{Model: opts.Model, InputTokens: 100}, nil
+		},
+	}
+}
+
+func TestClassifyEnsemble_MajorityVote(t *testing.T) {
+	client := votingClient(map[string][]string{
+		"sonnet": {"invoice", "Invoice "},
+		"haiku":  {"receipt", "invoice"},
+	})
+
+	classification, steps, err := ClassifyEnsemble(context.Background(), client, []byte("%PDF-1.4"), Options{}, EnsembleOptions{Samples: 4, Models: []string{"sonnet", "haiku"}})
+	if err != nil {
+		t.Fatalf("ClassifyEnsemble failed: %v", err)
+	}
+
+	if classification.DocumentType != "invoice" || classification.Confidence != 0.75 {
+		t.Errorf("Expected invoice with 0.75 agreement, got %s at %f", classification.DocumentType, classification.Confidence)
+	}
+	if classification.Reasoning == "" || classification.Reasoning == "receipt reasoning" {
+		t.Errorf("Expected the reasoning of a winning vote, got %q", classification.Reasoning)
+	}
+
+	report := classification.Ensemble
+	if report == nil || report.Samples != 4 || len(report.Votes) != 4 || !report.Disagreement {
+		t.Fatalf("Expected a report of 4 disagreeing votes, got %+v", report)
+	}
+	if report.Tally["invoice"] != 3 || report.Tally["receipt"] != 1 {
+		t.Errorf("Unexpected tally %v", report.Tally)
+	}
+	if math.Abs(report.ModelConfidence-0.9) > 1e-9 {
+		t.Errorf("Expected model confidence 0.9, got %f", report.ModelConfidence)
+	}
+	if report.Votes[0].Model != "sonnet" || report.Votes[1].Model != "haiku" {
+		t.Errorf("Expected samples to alternate models, got %+v", report.Votes)
+	}
+	if len(steps) != 4 || steps[0].AgentType != StepClassificationVote || steps[0].TokenUsage == nil {
+		t.Errorf("Expected a vote step per sample, got %+v", steps)
+	}
+}
+
+func TestClassifyEnsemble_FailedSamplesDoNotVote(t *testing.T) {
+	client := votingClient(map[string][]string{"": {"contract", "", "contract"}})
+
+	classification, steps, err := ClassifyEnsemble(context.Background(), client, []byte("%PDF-1.4"), Options{}, EnsembleOptions{Samples: 3})
+	if err != nil {
+		t.Fatalf("ClassifyEnsemble failed: %v", err)
+	}
+	if math.Abs(classification.Confidence-2.0/3) > 1e-9 || classification.Ensemble.Failed != 1 || classification.Ensemble.Disagreement {
+		t.Errorf("Expected agreement 2/3 with one failure, got %+v", classification.Ensemble)
+	}
+	if len(steps) != 2 {
+		t.Errorf("Expected steps for the answered samples only, got %d", len(steps))
+	}
+}
+
+func TestClassifyEnsemble_FailuresLowerAgreement(t *testing.T) {
+	client := votingClient(map[string][]string{"": {"invoice", "", "", "", ""}})
+
+	classification, _, err := ClassifyEnsemble(context.Background(), client, []byte("%PDF-1.4"), Options{}, EnsembleOptions{Samples: 5})
+	if err != nil {
+		t.Fatalf("ClassifyEnsemble failed: %v", err)
+	}
+	if classification.DocumentType != "invoice" || math.Abs(classification.Confidence-0.2) > 1e-9 {
+		t.Errorf("Expected a single answer out of 5 to give 0.2 agreement, got %+v", classification)
+	}
+}
+
+func TestClassifyEnsemble_TieGoesToConfidence(t *testing.T) {
+	client := &MockClient{
+		ClassifyFunc: func(ctx context.Context, pdfData []byte, opts Options) (*models.Classification, string, *models.TokenUsage, error) {
+			if opts.Model == "a" {
+				return &models.Classification{DocumentType: "letter", Confidence: 0.6}, "prompt", nil, nil
+			}
+			return &models.Classification{DocumentType: "report", Confidence: 0.8}, "prompt", nil, nil
+		},
+	}
+
+	classification, _, err := ClassifyEnsemble(context.Background(), client, []byte("%PDF-1.4"), Options{}, EnsembleOptions{Samples: 2, Models: []string{"a", "b"}})
+	if err != nil {
+		t.Fatalf("ClassifyEnsemble failed: %v", err)
+	}
+	if classification.DocumentType != "report" || classification.Confidence != 0.5 {
+		t.Errorf("Expected the more confident report at 0.5 agreement, got %+v", classification)
+	}
+}
+
+func TestClassifyEnsemble_AllSamplesFail(t *testing.T) {
+	client := votingClient(map[string][]string{"": {"", ""}})
+
+	_, _, err := ClassifyEnsemble(context.Background(), client, []byte("%PDF-1.4"), Options{}, EnsembleOptions{Samples: 2})
+	if err == nil || err.Error() != "overloaded" {
+		t.Errorf("Expected the sample error, got %v", err)
+	}
+}
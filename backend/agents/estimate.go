@@ -0,0 +1,131 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pdf-viewer/backend/models"
+)
+
+// TokenCount is the input size of a call
+type TokenCount struct {
+	InputTokens int
+	Approximate bool // Estimated locally rather than counted by the provider
+}
+
+// approxTokensPerImage is the token cost assumed for a page image when
+// counting tokens locally
+const approxTokensPerImage = 1000
+
+// agentRequest is what a classification or extraction call sends besides
+// the document: the prompt and the tool its answer is recorded with
+type agentRequest struct {
+	prompt      string
+	toolName    string
+	description string
+	inputSchema map[string]interface{}
+	maxTokens   int
+}
+
+// newAgentRequest builds the request of a StepClassification or
+// StepExtraction call, exactly as ClassifyDocument and ExtractData send it
+func newAgentRequest(agentType, documentType, schema string, opts Options) (agentRequest, error) {
+	switch agentType {
+	case StepClassification:
+		input, err := ClassificationInputSchema()
+		if err != nil {
+			return agentRequest{}, err
+		}
+		return agentRequest{
+			prompt:      opts.classificationPrompt(),
+			toolName:    ClassificationToolName,
+			description: classificationToolDescription,
+			inputSchema: input,
+			maxTokens:   classificationMaxTokens,
+		}, nil
+	case StepExtraction:
+		input, err := ExtractionInputSchema(schema)
+		if err != nil {
+			return agentRequest{}, err
+		}
+		return agentRequest{
+			prompt:      opts.extractionPrompt(documentType, schema),
+			toolName:    ExtractionToolName,
+			description: extractionToolDescription(documentType),
+			inputSchema: input,
+			maxTokens:   extractionMaxTokens,
+		}, nil
+	}
+	return agentRequest{}, fmt.Errorf("unsupported agent type: %s", agentType)
+}
+
+// approximateTokens estimates the input of a call for providers without a
+// token counting endpoint: about four characters per token of page text,
+// prompt and schema, plus approxTokensPerImage per page image
+func approximateTokens(pdfData []byte, mode InputMode, request agentRequest) (*TokenCount, error) {
+	pages, err := LoadPageInputs(pdfData, mode)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := json.Marshal(request.inputSchema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode schema: %w", err)
+	}
+
+	chars := len(FormatPageText(pages)) + len(request.prompt) + len(request.description) + len(schema)
+	tokens := (chars+3)/4 + len(pageImages(pages))*approxTokensPerImage
+	return &TokenCount{InputTokens: tokens, Approximate: true}, nil
+}
+
+// EstimateCost estimates what running an agent on a document will cost.
+// Extraction is counted chunk by chunk as ExtractChunked sends it. The low
+// end of the range is every call's input at the regular input price. The
+// high end assumes each call writes its input to the prompt cache and uses
+// its whole output limit, every extraction chunk needs all of its repair
+// rounds and, for clients that ground fields, one citation call per chunk.
+func EstimateCost(ctx context.Context, client Client, pdfData []byte, agentType, documentType, schema string, opts Options) (*models.CostEstimate, error) {
+	request, err := newAgentRequest(agentType, documentType, schema, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := []Chunk{{StartPage: 1, PDFData: pdfData}}
+	if agentType == StepExtraction {
+		if chunks, err = SplitIntoChunks(pdfData, DefaultChunkOptions()); err != nil {
+			return nil, err
+		}
+	}
+
+	estimate := &models.CostEstimate{Model: opts.Model, AgentType: agentType, Calls: len(chunks)}
+	for _, chunk := range chunks {
+		count, err := client.CountTokens(ctx, chunk.PDFData, agentType, documentType, schema, opts)
+		if err != nil {
+			return nil, err
+		}
+		estimate.InputTokens += count.InputTokens
+		estimate.Approximate = estimate.Approximate || count.Approximate
+	}
+
+	estimate.MaxCalls = estimate.Calls
+	if agentType == StepExtraction {
+		estimate.MaxCalls *= 1 + DefaultMaxRepairRounds
+		if _, ok := client.(Citer); ok {
+			estimate.MaxCalls += len(chunks)
+		}
+	}
+	estimate.MaxOutputTokens = estimate.MaxCalls * request.maxTokens
+
+	pricing, ok := models.GetModelPricing(opts.Model)
+	if !ok {
+		return estimate, nil
+	}
+	estimate.Priced = true
+	estimate.MinCost = pricing.Cost(estimate.InputTokens, 0)
+
+	// Each repair or citation call resends roughly the same input
+	maxInput := estimate.InputTokens * estimate.MaxCalls / estimate.Calls
+	inputRate := max(pricing.InputPerMillion, pricing.CacheWritePerMillion)
+	estimate.MaxCost = float64(maxInput)*inputRate/1_000_000 + pricing.Cost(0, estimate.MaxOutputTokens)
+	return estimate, nil
+}
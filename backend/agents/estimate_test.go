@@ -0,0 +1,133 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/pdf-viewer/backend/pdf"
+)
+
+func TestClaudeClient_CountTokensSendsTheExtractionRequest(t *testing.T) {
+	var paths []string
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var parsed map[string]interface{}
+		json.Unmarshal(body, &parsed)
+		paths = append(paths, r.URL.Path)
+		bodies = append(bodies, parsed)
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/v1/messages/count_tokens" {
+			io.WriteString(w, `{"input_tokens": 12345}`)
+			return
+		}
+		io.WriteString(w, `{
+			"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5-20250929",
+			"content": [{"type": "tool_use", "id": "toolu_1", "name": "record_extraction",
+				"input": {"schema_used": "invoice", "data": {}, "fields": []}}],
+			"usage": {"input_tokens": 10, "output_tokens": 10}
+		}`)
+	}))
+	defer server.Close()
+
+	client := NewClaudeClient(option.WithBaseURL(server.URL), option.WithAPIKey("test-key"))
+	schema := GetSchemaForDocumentType("invoice")
+	pdfData := []byte("%PDF-1.4 contract")
+
+	count, err := client.CountTokens(context.Background(), pdfData, StepExtraction, "invoice", schema, Options{})
+	if err != nil {
+		t.Fatalf("CountTokens failed: %v", err)
+	}
+	if count.InputTokens != 12345 || count.Approximate {
+		t.Errorf("Expected exact count of 12345, got %+v", count)
+	}
+
+	if _, _, _, err := client.ExtractData(context.Background(), pdfData, "invoice", schema, Options{}); err != nil {
+		t.Fatalf("ExtractData failed: %v", err)
+	}
+
+	if len(paths) != 2 || paths[0] != "/v1/messages/count_tokens" {
+		t.Fatalf("Expected a count_tokens call, got %v", paths)
+	}
+	counted, sent := bodies[0], bodies[1]
+	for _, key := range []string{"model", "messages", "tools", "tool_choice"} {
+		if !reflect.DeepEqual(counted[key], sent[key]) {
+			t.Errorf("Expected counted %s to match the extraction request", key)
+		}
+	}
+}
+
+func TestEstimateCost_ChunkedExtraction(t *testing.T) {
+	var counted []int
+	client := &MockClient{
+		CountFunc: func(ctx context.Context, pdfData []byte, agentType string, documentType string, schema string, opts Options) (*TokenCount, error) {
+			texts, _ := pdf.ExtractPageTexts(pdfData)
+			counted = append(counted, len(texts))
+			return &TokenCount{InputTokens: 100_000}, nil
+		},
+	}
+
+	pdfData := pdf.GenerateTextPDF(numberedPages(45))
+	estimate, err := EstimateCost(context.Background(), client, pdfData, StepExtraction, "contract", GetSchemaForDocumentType("contract"), Options{Model: "claude-sonnet-4-5-20250929"})
+	if err != nil {
+		t.Fatalf("EstimateCost failed: %v", err)
+	}
+
+	if len(counted) != 3 || counted[0] != 20 || counted[2] != 5 {
+		t.Errorf("Expected a count per chunk of 20, 20 and 5 pages, got %v", counted)
+	}
+	if estimate.InputTokens != 300_000 || estimate.Calls != 3 {
+		t.Errorf("Expected 300000 tokens over 3 calls, got %+v", estimate)
+	}
+	// Every chunk may need two repairs and a citation call
+	if estimate.MaxCalls != 12 || estimate.MaxOutputTokens != 12*extractionMaxTokens {
+		t.Errorf("Expected 12 calls at most, got %+v", estimate)
+	}
+	if !estimate.Priced || math.Abs(estimate.MinCost-0.9) > 1e-9 {
+		t.Errorf("Expected min cost $0.90, got %+v", estimate)
+	}
+	// 1.2M tokens at the $3.75 cache write price plus 49152 output tokens at $15
+	if want := 4.5 + 49152*15.0/1_000_000; math.Abs(estimate.MaxCost-want) > 1e-9 {
+		t.Errorf("Expected max cost %f, got %f", want, estimate.MaxCost)
+	}
+}
+
+func TestEstimateCost_UnpricedModel(t *testing.T) {
+	estimate, err := EstimateCost(context.Background(), NewMockClient(), []byte("%PDF-1.4"), StepClassification, "", "", Options{Model: "local-model"})
+	if err != nil {
+		t.Fatalf("EstimateCost failed: %v", err)
+	}
+	if estimate.Priced || estimate.MaxCost != 0 || estimate.InputTokens != 1000 || estimate.MaxCalls != 1 {
+		t.Errorf("Expected an unpriced single-call estimate, got %+v", estimate)
+	}
+}
+
+func TestEstimateCost_RejectsUnknownAgentType(t *testing.T) {
+	_, err := EstimateCost(context.Background(), NewMockClient(), nil, "chat", "", "", Options{})
+	if err == nil || !strings.Contains(err.Error(), "unsupported agent type") {
+		t.Errorf("Expected unsupported agent type error, got %v", err)
+	}
+}
+
+func TestOpenAIClient_CountTokensApproximates(t *testing.T) {
+	client := NewOpenAIClient("http://localhost:0", "")
+	pdfData := pdf.GenerateTextPDF([]string{strings.Repeat("word ", 400)})
+
+	count, err := client.CountTokens(context.Background(), pdfData, StepClassification, "", "", Options{})
+	if err != nil {
+		t.Fatalf("CountTokens failed: %v", err)
+	}
+	prompt := len(BuildClassificationPrompt(nil)) / 4
+	if !count.Approximate || count.InputTokens < prompt+400 {
+		t.Errorf("Expected an approximate count above %d, got %+v", prompt+400, count)
+	}
+}
@@ -0,0 +1,62 @@
+package agents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// HTTPError is a non-2xx response from a provider's HTTP API
+type HTTPError struct {
+	Provider   string
+	StatusCode int
+	Header     http.Header
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s API returned status %d: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// maxErrorBody bounds how much of an error response is kept in HTTPError
+const maxErrorBody = 2048
+
+// postJSON sends body as JSON to url and decodes the JSON response into out.
+// Non-2xx responses are returned as *HTTPError so they can be retried.
+func postJSON(ctx context.Context, client *http.Client, provider, url string, header http.Header, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", provider, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", provider, err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return &HTTPError{Provider: provider, StatusCode: resp.StatusCode, Header: resp.Header, Body: string(bytes.TrimSpace(data))}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", provider, err)
+	}
+	return nil
+}
@@ -0,0 +1,641 @@
+package agents
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pdf-viewer/backend/models"
+)
+
+// JSONRepair names a fix RepairJSON applied to a model's JSON output
+type JSONRepair string
+
+const (
+	RepairExtracted         JSONRepair = "extracted"          // Removed code fences or prose around the JSON
+	RepairTrailingComma     JSONRepair = "trailing_comma"     // Removed a comma with nothing after it
+	RepairMissingComma      JSONRepair = "missing_comma"      // Inserted a comma between members or elements
+	RepairSingleQuotes      JSONRepair = "single_quotes"      // Double-quoted a single-quoted string
+	RepairUnquotedKey       JSONRepair = "unquoted_key"       // Quoted a bare object key
+	RepairUnquotedValue     JSONRepair = "unquoted_value"     // Quoted a bare word value
+	RepairUnescapedQuote    JSONRepair = "unescaped_quote"    // Escaped a quote inside a string
+	RepairInvalidEscape     JSONRepair = "invalid_escape"     // Escaped a backslash that started no valid escape
+	RepairControlCharacter  JSONRepair = "control_character"  // Escaped a raw newline, tab or other control character
+	RepairComment           JSONRepair = "comment"            // Removed a // or /* */ comment
+	RepairLiteral           JSONRepair = "literal"            // Replaced True, None, NaN and the like
+	RepairNumber            JSONRepair = "number"             // Fixed a number such as +1, .5, 1. or 007
+	RepairClosedString      JSONRepair = "closed_string"      // Closed a string cut off at the end of the text
+	RepairClosedContainer   JSONRepair = "closed_container"   // Closed objects and arrays cut off at the end of the text
+	RepairDroppedIncomplete JSONRepair = "dropped_incomplete" // Dropped a member or element cut off before its value
+)
+
+const (
+	// maxJSONDepth bounds the nesting RepairJSON follows
+	maxJSONDepth = 500
+
+	// maxJSONCandidates bounds the brackets in the text RepairJSON tries to
+	// parse from
+	maxJSONCandidates = 16
+)
+
+// errTruncated reports that the text ended where a value should have been
+var errTruncated = errors.New("unexpected end of JSON")
+
+// RepairJSON recovers a JSON value from a model's text response. It strips
+// code fences and the prose around the JSON, and fixes the mistakes models
+// make: trailing and missing commas, single quotes, unquoted keys and
+// values, unescaped quotes and control characters in strings, comments and
+// non-JSON literals. Output cut off at max_tokens is closed, dropping a
+// final member that has no value. It returns the JSON with the repairs
+// applied, in the order they were first needed; valid JSON is returned
+// unchanged with none. Objects are preferred over arrays when looking for
+// the JSON in prose.
+func RepairJSON(text string) (string, []JSONRepair, error) {
+	trimmed := strings.TrimSpace(text)
+	if trimmed != "" && json.Valid([]byte(trimmed)) {
+		return trimmed, nil, nil
+	}
+
+	var bodies []string
+	if trimmed != "" && trimmed[0] != '{' && trimmed[0] != '[' {
+		if body, ok := fencedBlock(text); ok {
+			bodies = append(bodies, body)
+		}
+	}
+	bodies = append(bodies, text)
+
+	var firstErr error
+	for _, body := range bodies {
+		for _, start := range jsonStarts(body) {
+			repaired, repairs, err := repairFrom(body, start)
+			if err == nil {
+				if len(body) != len(text) {
+					repairs = slices.Insert(repairs, 0, RepairExtracted)
+					repairs = slices.Compact(repairs)
+				}
+				return repaired, repairs, nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if firstErr == nil {
+		firstErr = fmt.Errorf("no JSON object found")
+	}
+	return "", nil, firstErr
+}
+
+// unmarshalResponse decodes the JSON in a model's text response into v,
+// repairing it first. It returns the repairs that were needed.
+func unmarshalResponse(text string, v interface{}) ([]JSONRepair, error) {
+	repaired, repairs, err := RepairJSON(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(repaired), v); err != nil {
+		if len(repairs) > 0 {
+			return nil, fmt.Errorf("%w (after repairs: %s)", err, joinRepairs(repairs))
+		}
+		return nil, err
+	}
+	return repairs, nil
+}
+
+// repairNames returns the names of repairs, as recorded on a TokenUsage
+func repairNames(repairs []JSONRepair) []string {
+	if len(repairs) == 0 {
+		return nil
+	}
+	names := make([]string, len(repairs))
+	for i, repair := range repairs {
+		names[i] = string(repair)
+	}
+	return names
+}
+
+func joinRepairs(repairs []JSONRepair) string {
+	return strings.Join(repairNames(repairs), ", ")
+}
+
+// TruncationRepaired reports whether a response had to be closed by
+// RepairJSON because it was cut off, so the end of its output is missing
+func TruncationRepaired(tokenUsage *models.TokenUsage) bool {
+	if tokenUsage == nil {
+		return false
+	}
+	for _, name := range tokenUsage.JSONRepairs {
+		switch JSONRepair(name) {
+		case RepairClosedString, RepairClosedContainer, RepairDroppedIncomplete:
+			return true
+		}
+	}
+	return false
+}
+
+// fencedBlock returns the contents of the first markdown code block, up to
+// the closing fence or the end of the text
+func fencedBlock(text string) (string, bool) {
+	start := strings.Index(text, "```")
+	if start == -1 {
+		return "", false
+	}
+	body := text[start+3:]
+	// Skip the language tag
+	tag := 0
+	for tag < len(body) && isLetter(body[tag]) {
+		tag++
+	}
+	body = body[tag:]
+	if end := strings.Index(body, "```"); end != -1 {
+		body = body[:end]
+	}
+	return body, true
+}
+
+// jsonStarts lists the offsets to try parsing text from: the start of the
+// text if the JSON begins there, then each '{', then each '['
+func jsonStarts(text string) []int {
+	var starts []int
+	trimmed := strings.TrimLeft(text, " \t\r\n")
+	if trimmed != "" && (trimmed[0] == '{' || trimmed[0] == '[') {
+		starts = append(starts, len(text)-len(trimmed))
+	}
+	for _, open := range []byte{'{', '['} {
+		for i := 0; i < len(text) && len(starts) < 2*maxJSONCandidates; i++ {
+			if text[i] == open && !slices.Contains(starts, i) {
+				starts = append(starts, i)
+			}
+		}
+	}
+	return starts
+}
+
+// repairFrom parses a value starting at offset start of text
+func repairFrom(text string, start int) (string, []JSONRepair, error) {
+	r := &jsonRepairer{text: text, pos: start}
+	value, err := r.value()
+	if err != nil {
+		if errors.Is(err, errTruncated) {
+			return "", nil, fmt.Errorf("no JSON value at offset %d", start)
+		}
+		return "", nil, err
+	}
+	end := r.pos
+	r.skipSpace()
+	if strings.TrimSpace(text[:start]) != "" || r.pos < len(text) {
+		r.repairs = slices.Insert(r.repairs, 0, RepairExtracted)
+	}
+	// Keep the original formatting of JSON that only needed extracting
+	if (len(r.repairs) == 0 || slices.Equal(r.repairs, []JSONRepair{RepairExtracted})) && json.Valid([]byte(text[start:end])) {
+		return text[start:end], r.repairs, nil
+	}
+	return value, r.repairs, nil
+}
+
+// jsonRepairer is a lenient recursive descent JSON parser that writes the
+// values it reads as valid JSON
+type jsonRepairer struct {
+	text    string
+	pos     int
+	depth   int
+	repairs []JSONRepair
+}
+
+func (r *jsonRepairer) note(repair JSONRepair) {
+	if !slices.Contains(r.repairs, repair) {
+		r.repairs = append(r.repairs, repair)
+	}
+}
+
+func (r *jsonRepairer) eof() bool {
+	return r.pos >= len(r.text)
+}
+
+// skipSpace skips whitespace and comments
+func (r *jsonRepairer) skipSpace() {
+	for !r.eof() {
+		switch c := r.text[r.pos]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			r.pos++
+		case strings.HasPrefix(r.text[r.pos:], "//"):
+			r.note(RepairComment)
+			if end := strings.IndexByte(r.text[r.pos:], '\n'); end != -1 {
+				r.pos += end + 1
+			} else {
+				r.pos = len(r.text)
+			}
+		case strings.HasPrefix(r.text[r.pos:], "/*"):
+			r.note(RepairComment)
+			if end := strings.Index(r.text[r.pos+2:], "*/"); end != -1 {
+				r.pos += end + 4
+			} else {
+				r.pos = len(r.text)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// value parses any value. It returns errTruncated if the text ends first.
+func (r *jsonRepairer) value() (string, error) {
+	r.skipSpace()
+	if r.eof() {
+		return "", errTruncated
+	}
+	switch c := r.text[r.pos]; {
+	case c == '{':
+		return r.object()
+	case c == '[':
+		return r.array()
+	case c == '"' || c == '\'':
+		s, closed := r.string()
+		if !closed {
+			r.note(RepairClosedString)
+		}
+		return s, nil
+	case c == '-' || c == '+' || c == '.' || isDigit(c):
+		return r.number()
+	case c == ',' || c == ':' || c == '}' || c == ']':
+		return "", fmt.Errorf("unexpected %q at offset %d", c, r.pos)
+	}
+	return r.word()
+}
+
+func (r *jsonRepairer) object() (string, error) {
+	if r.depth++; r.depth > maxJSONDepth {
+		return "", fmt.Errorf("JSON nested deeper than %d levels", maxJSONDepth)
+	}
+	defer func() { r.depth-- }()
+	r.pos++
+
+	var b strings.Builder
+	b.WriteByte('{')
+	members := 0
+	needComma, sawComma := false, false
+	for {
+		r.skipSpace()
+		if r.eof() {
+			r.note(RepairClosedContainer)
+			break
+		}
+		c := r.text[r.pos]
+		if c == '}' {
+			if sawComma {
+				r.note(RepairTrailingComma)
+			}
+			r.pos++
+			break
+		}
+		if c == ',' {
+			if !needComma {
+				r.note(RepairTrailingComma)
+			}
+			r.pos++
+			needComma, sawComma = false, true
+			continue
+		}
+		if needComma {
+			r.note(RepairMissingComma)
+		}
+
+		key, err := r.key()
+		if errors.Is(err, errTruncated) {
+			r.note(RepairDroppedIncomplete)
+			r.note(RepairClosedContainer)
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		r.skipSpace()
+		if r.eof() {
+			r.note(RepairDroppedIncomplete)
+			r.note(RepairClosedContainer)
+			break
+		}
+		if r.text[r.pos] != ':' {
+			return "", fmt.Errorf("expected ':' after object key at offset %d", r.pos)
+		}
+		r.pos++
+		value, err := r.value()
+		if errors.Is(err, errTruncated) {
+			r.note(RepairDroppedIncomplete)
+			r.note(RepairClosedContainer)
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if members > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(key)
+		b.WriteByte(':')
+		b.WriteString(value)
+		members++
+		needComma, sawComma = true, false
+	}
+	b.WriteByte('}')
+	return b.String(), nil
+}
+
+func (r *jsonRepairer) array() (string, error) {
+	if r.depth++; r.depth > maxJSONDepth {
+		return "", fmt.Errorf("JSON nested deeper than %d levels", maxJSONDepth)
+	}
+	defer func() { r.depth-- }()
+	r.pos++
+
+	var b strings.Builder
+	b.WriteByte('[')
+	elements := 0
+	needComma, sawComma := false, false
+	for {
+		r.skipSpace()
+		if r.eof() {
+			r.note(RepairClosedContainer)
+			break
+		}
+		c := r.text[r.pos]
+		if c == ']' {
+			if sawComma {
+				r.note(RepairTrailingComma)
+			}
+			r.pos++
+			break
+		}
+		if c == ',' {
+			if !needComma {
+				r.note(RepairTrailingComma)
+			}
+			r.pos++
+			needComma, sawComma = false, true
+			continue
+		}
+		if needComma {
+			r.note(RepairMissingComma)
+		}
+
+		value, err := r.value()
+		if errors.Is(err, errTruncated) {
+			r.note(RepairDroppedIncomplete)
+			r.note(RepairClosedContainer)
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if elements > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(value)
+		elements++
+		needComma, sawComma = true, false
+	}
+	b.WriteByte(']')
+	return b.String(), nil
+}
+
+// key parses an object key, quoted or bare. It returns errTruncated if the
+// text ends within it.
+func (r *jsonRepairer) key() (string, error) {
+	c := r.text[r.pos]
+	if c == '"' || c == '\'' {
+		key, closed := r.string()
+		if !closed {
+			return "", errTruncated
+		}
+		return key, nil
+	}
+	if !isKeyByte(c) {
+		return "", fmt.Errorf("expected object key at offset %d", r.pos)
+	}
+	start := r.pos
+	for !r.eof() && isKeyByte(r.text[r.pos]) {
+		r.pos++
+	}
+	if r.eof() {
+		return "", errTruncated
+	}
+	r.note(RepairUnquotedKey)
+	return jsonString(r.text[start:r.pos]), nil
+}
+
+// string parses a string delimited by double or single quotes. It returns
+// false if the text ends before the closing quote.
+func (r *jsonRepairer) string() (string, bool) {
+	quote := r.text[r.pos]
+	if quote == '\'' {
+		r.note(RepairSingleQuotes)
+	}
+	r.pos++
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for !r.eof() {
+		c := r.text[r.pos]
+		switch {
+		case c == quote:
+			r.pos++
+			if r.endsString() {
+				b.WriteByte('"')
+				return b.String(), true
+			}
+			r.note(RepairUnescapedQuote)
+			if quote == '"' {
+				b.WriteString(`\"`)
+			} else {
+				b.WriteByte(quote)
+			}
+		case c == '"':
+			// A double quote within a single-quoted string
+			r.pos++
+			b.WriteString(`\"`)
+		case c == '\\':
+			r.escape(&b, quote)
+		case c < 0x20:
+			r.pos++
+			r.note(RepairControlCharacter)
+			switch c {
+			case '\n':
+				b.WriteString(`\n`)
+			case '\r':
+				b.WriteString(`\r`)
+			case '\t':
+				b.WriteString(`\t`)
+			default:
+				fmt.Fprintf(&b, `\u%04x`, c)
+			}
+		default:
+			r.pos++
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String(), false
+}
+
+// endsString reports whether a quote just read closes its string: it does if
+// what follows could come after a string, and is otherwise taken to be an
+// unescaped quote within the text
+func (r *jsonRepairer) endsString() bool {
+	i := r.pos
+	for i < len(r.text) && (r.text[i] == ' ' || r.text[i] == '\t' || r.text[i] == '\r' || r.text[i] == '\n') {
+		i++
+	}
+	return i == len(r.text) || strings.IndexByte(",:}]\"'/", r.text[i]) != -1
+}
+
+// escape copies the escape sequence at the backslash, escaping the backslash
+// itself if it starts no valid sequence
+func (r *jsonRepairer) escape(b *strings.Builder, quote byte) {
+	if r.pos+1 >= len(r.text) {
+		// Cut off after the backslash
+		r.pos++
+		return
+	}
+	switch e := r.text[r.pos+1]; e {
+	case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+		b.WriteByte('\\')
+		b.WriteByte(e)
+		r.pos += 2
+	case '\'':
+		if quote != '\'' {
+			r.note(RepairInvalidEscape)
+		}
+		b.WriteByte('\'')
+		r.pos += 2
+	case 'u':
+		if r.pos+6 <= len(r.text) && isHex(r.text[r.pos+2:r.pos+6]) {
+			b.WriteString(r.text[r.pos : r.pos+6])
+			r.pos += 6
+			return
+		}
+		r.note(RepairInvalidEscape)
+		b.WriteString(`\\u`)
+		r.pos += 2
+	default:
+		r.note(RepairInvalidEscape)
+		b.WriteString(`\\`)
+		r.pos++
+	}
+}
+
+// number parses a number, fixing signs, leading zeros and missing digits.
+// Text that is not a number after all is read as a bare word.
+func (r *jsonRepairer) number() (string, error) {
+	start := r.pos
+	for !r.eof() && strings.IndexByte("+-0123456789.eE", r.text[r.pos]) != -1 {
+		r.pos++
+	}
+	token := r.text[start:r.pos]
+	if !r.eof() && !isDelimiter(r.text[r.pos]) {
+		// Such as a date or phone number written without quotes
+		r.pos = start
+		return r.word()
+	}
+	if json.Valid([]byte(token)) {
+		return token, nil
+	}
+
+	fixed := strings.TrimPrefix(token, "+")
+	negative := strings.HasPrefix(fixed, "-")
+	fixed = strings.TrimRight(strings.TrimPrefix(fixed, "-"), "+-.eE")
+	if strings.HasPrefix(fixed, ".") {
+		fixed = "0" + fixed
+	}
+	for len(fixed) > 1 && fixed[0] == '0' && isDigit(fixed[1]) {
+		fixed = fixed[1:]
+	}
+	if negative {
+		fixed = "-" + fixed
+	}
+	if fixed != "" && json.Valid([]byte(fixed)) {
+		r.note(RepairNumber)
+		return fixed, nil
+	}
+	if r.eof() {
+		return "", errTruncated
+	}
+	r.pos = start
+	return r.word()
+}
+
+// word parses a bare word: a literal such as true or None, or an unquoted
+// string value running to the end of the line or the next delimiter. A word
+// cut off by the end of the text returns errTruncated, unless it is a
+// complete literal.
+func (r *jsonRepairer) word() (string, error) {
+	start := r.pos
+	for !r.eof() && isLetter(r.text[r.pos]) {
+		r.pos++
+	}
+	if r.eof() || isDelimiter(r.text[r.pos]) {
+		switch word := r.text[start:r.pos]; strings.ToLower(word) {
+		case "true", "false", "null":
+			if word != strings.ToLower(word) {
+				r.note(RepairLiteral)
+			}
+			return strings.ToLower(word), nil
+		case "none", "nil", "undefined", "nan", "infinity":
+			r.note(RepairLiteral)
+			return "null", nil
+		}
+	}
+
+	r.pos = start
+	for !r.eof() && strings.IndexByte(",}]\n", r.text[r.pos]) == -1 {
+		r.pos++
+	}
+	if r.eof() {
+		return "", errTruncated
+	}
+	word := strings.TrimSpace(r.text[start:r.pos])
+	if word == "" {
+		return "", fmt.Errorf("unexpected %q at offset %d", r.text[start], start)
+	}
+	r.note(RepairUnquotedValue)
+	return jsonString(word), nil
+}
+
+// jsonString encodes s as a JSON string
+func jsonString(s string) string {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) && (s[i]|0x20 < 'a' || s[i]|0x20 > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// isKeyByte reports whether c can be part of an unquoted object key
+func isKeyByte(c byte) bool {
+	return isLetter(c) || isDigit(c) || c == '_' || c == '$' || c == '-' || c == '.' || c >= 0x80
+}
+
+// isDelimiter reports whether c can end a number or literal
+func isDelimiter(c byte) bool {
+	return strings.IndexByte(" \t\r\n,:}]/", c) != -1
+}
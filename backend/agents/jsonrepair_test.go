@@ -0,0 +1,176 @@
+package agents
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// jsonRepairCorpus holds model responses RepairJSON must recover, with the
+// JSON it should produce and the repairs it should report. It also seeds
+// the fuzz tests.
+var jsonRepairCorpus = []struct {
+	name    string
+	input   string
+	want    string
+	repairs []JSONRepair
+}{
+	{"valid", `{"key": "value"}`, `{"key": "value"}`, nil},
+	{"valid nested", `{"outer": {"inner": {"value": 123}}}`, `{"outer": {"inner": {"value": 123}}}`, nil},
+	{"valid array value", `{"items": [{"name": "item1"}, {"name": "item2"}]}`, `{"items": [{"name": "item1"}, {"name": "item2"}]}`, nil},
+	{"valid top-level array", `[{"a": 1}, {"b": 2}]`, `[{"a": 1}, {"b": 2}]`, nil},
+	{"json fence", "Here is the result:\n```json\n{\"key\": \"value\"}\n```\nEnd of response.", `{"key": "value"}`, []JSONRepair{RepairExtracted}},
+	{"generic fence", "```\n{\"key\": \"value\"}\n```", `{"key": "value"}`, []JSONRepair{RepairExtracted}},
+	{"unclosed fence", "```json\n{\"key\": \"value\"}", `{"key": "value"}`, []JSONRepair{RepairExtracted}},
+	{"prose", "Based on my analysis:\n\n{\n  \"document_type\": \"invoice\",\n  \"confidence\": 0.95\n}\n\nThis appears to be an invoice.", `{"document_type": "invoice", "confidence": 0.95}`, []JSONRepair{RepairExtracted}},
+	{"two objects", `{"first": 1} {"second": 2}`, `{"first": 1}`, []JSONRepair{RepairExtracted}},
+	{"braces in prose", `Using {curly} notation: {"a": 1}`, `{"a": 1}`, []JSONRepair{RepairExtracted}},
+	{"braces in string", `Result: {"note": "see {appendix}", "total": 5}`, `{"note": "see {appendix}", "total": 5}`, []JSONRepair{RepairExtracted}},
+	{"escaped quote and brace", `{"quote": "he said \"hi\" {", "n": 1,}`, `{"quote": "he said \"hi\" {", "n": 1}`, []JSONRepair{RepairTrailingComma}},
+	{"trailing commas", `{"items": [1, 2, 3,],}`, `{"items": [1, 2, 3]}`, []JSONRepair{RepairTrailingComma}},
+	{"double comma", `[1,, 2]`, `[1, 2]`, []JSONRepair{RepairTrailingComma}},
+	{"missing comma", "{\"a\": 1\n  \"b\": [1 2]}", `{"a": 1, "b": [1, 2]}`, []JSONRepair{RepairMissingComma}},
+	{"single quotes", `{'name': 'Acme', 'note': 'say "hi"'}`, `{"name": "Acme", "note": "say \"hi\""}`, []JSONRepair{RepairSingleQuotes}},
+	{"apostrophe", `{'name': 'O'Brien'}`, `{"name": "O'Brien"}`, []JSONRepair{RepairSingleQuotes, RepairUnescapedQuote}},
+	{"escaped apostrophe", `{'name': 'O\'Brien'}`, `{"name": "O'Brien"}`, []JSONRepair{RepairSingleQuotes}},
+	{"unescaped quote", `{"title": "The "Best" Widget"}`, `{"title": "The \"Best\" Widget"}`, []JSONRepair{RepairUnescapedQuote}},
+	{"unquoted keys", `{document_type: "invoice", confidence: 0.9}`, `{"document_type": "invoice", "confidence": 0.9}`, []JSONRepair{RepairUnquotedKey}},
+	{"unquoted values", "{\"currency\": USD, \"date\": 2024-01-15\n}", `{"currency": "USD", "date": "2024-01-15"}`, []JSONRepair{RepairUnquotedValue}},
+	{"python literals", `{"paid": True, "due": None, "ratio": NaN, "ok": false}`, `{"paid": true, "due": null, "ratio": null, "ok": false}`, []JSONRepair{RepairLiteral}},
+	{"numbers", `{"a": +1, "b": .5, "c": 007, "d": 1.}`, `{"a": 1, "b": 0.5, "c": 7, "d": 1}`, []JSONRepair{RepairNumber}},
+	{"comments", "{\n  // the type\n  \"type\": \"invoice\" /* sure */\n}", `{"type": "invoice"}`, []JSONRepair{RepairComment}},
+	{"control characters", "{\"text\": \"line one\nline two\ttab\"}", `{"text": "line one\nline two\ttab"}`, []JSONRepair{RepairControlCharacter}},
+	{"invalid escapes", `{"path": "C:\Users\x"}`, `{"path": "C:\\Users\\x"}`, []JSONRepair{RepairInvalidEscape}},
+	{"invalid unicode escape", `{"path": "C:\users"}`, `{"path": "C:\\users"}`, []JSONRepair{RepairInvalidEscape}},
+	{"unclosed object", `{"key": "value"`, `{"key": "value"}`, []JSONRepair{RepairClosedContainer}},
+	{"cut off in string", `{"line_items": [{"description": "Widget", "amount": 10}, {"description": "Gadg`, `{"line_items": [{"description": "Widget", "amount": 10}, {"description": "Gadg"}]}`, []JSONRepair{RepairClosedString, RepairClosedContainer}},
+	{"cut off after key", `{"a": 1, "b":`, `{"a": 1}`, []JSONRepair{RepairDroppedIncomplete, RepairClosedContainer}},
+	{"cut off in key", `{"a": 1, "descr`, `{"a": 1}`, []JSONRepair{RepairDroppedIncomplete, RepairClosedContainer}},
+	{"cut off after comma", `{"a": [1, 2,`, `{"a": [1, 2]}`, []JSONRepair{RepairClosedContainer}},
+	{"cut off in literal", `{"a": 1, "b": tr`, `{"a": 1}`, []JSONRepair{RepairDroppedIncomplete, RepairClosedContainer}},
+	{"cut off in number", `{"a": [1, 2.5e`, `{"a": [1, 2.5]}`, []JSONRepair{RepairNumber, RepairClosedContainer}},
+	{"cut off in escape", `{"a": "x\`, `{"a": "x"}`, []JSONRepair{RepairClosedString, RepairClosedContainer}},
+	{"fence and trailing comma", "```json\n{\"fields\": [{\"name\": \"total\",},],}\n```", `{"fields": [{"name": "total"}]}`, []JSONRepair{RepairExtracted, RepairTrailingComma}},
+}
+
+func TestRepairJSON_Corpus(t *testing.T) {
+	for _, tc := range jsonRepairCorpus {
+		t.Run(tc.name, func(t *testing.T) {
+			got, repairs, err := RepairJSON(tc.input)
+			if err != nil {
+				t.Fatalf("RepairJSON failed: %v", err)
+			}
+			if !json.Valid([]byte(got)) {
+				t.Fatalf("Expected valid JSON, got %s", got)
+			}
+			var gotValue, wantValue interface{}
+			json.Unmarshal([]byte(got), &gotValue)
+			json.Unmarshal([]byte(tc.want), &wantValue)
+			if !reflect.DeepEqual(gotValue, wantValue) {
+				t.Errorf("Expected %s, got %s", tc.want, got)
+			}
+			if !reflect.DeepEqual(repairs, tc.repairs) {
+				t.Errorf("Expected repairs %v, got %v", tc.repairs, repairs)
+			}
+		})
+	}
+}
+
+func TestRepairJSON_KeepsFormattingOfExtractedJSON(t *testing.T) {
+	got, _, err := RepairJSON("Here you go:\n```json\n{\n  \"key\": \"value\"\n}\n```")
+	if err != nil {
+		t.Fatalf("RepairJSON failed: %v", err)
+	}
+	if got != "{\n  \"key\": \"value\"\n}" {
+		t.Errorf("Expected the JSON as written, got %q", got)
+	}
+}
+
+func TestRepairJSON_NoJSON(t *testing.T) {
+	for _, input := range []string{"", "   ", "This is just plain text with no JSON", "{:}", strings.Repeat("[", 2*maxJSONDepth)} {
+		if got, _, err := RepairJSON(input); err == nil {
+			t.Errorf("Expected an error for %.20q, got %s", input, got)
+		}
+	}
+}
+
+func TestUnmarshalResponse_ReportsRepairs(t *testing.T) {
+	var classification struct {
+		DocumentType string  `json:"document_type"`
+		Confidence   float64 `json:"confidence"`
+	}
+	repairs, err := unmarshalResponse("```json\n{document_type: 'invoice', confidence: 0.9,}\n```", &classification)
+	if err != nil {
+		t.Fatalf("unmarshalResponse failed: %v", err)
+	}
+	if classification.DocumentType != "invoice" || classification.Confidence != 0.9 {
+		t.Errorf("Unexpected classification: %+v", classification)
+	}
+	if want := []JSONRepair{RepairExtracted, RepairUnquotedKey, RepairSingleQuotes, RepairTrailingComma}; !reflect.DeepEqual(repairs, want) {
+		t.Errorf("Expected repairs %v, got %v", want, repairs)
+	}
+
+	_, err = unmarshalResponse(`{"confidence": 'high',}`, &classification)
+	if err == nil || !strings.Contains(err.Error(), "after repairs: single_quotes, trailing_comma") {
+		t.Errorf("Expected the repairs in the error, got %v", err)
+	}
+}
+
+func TestParseExtractionResponse_TruncatedLineItems(t *testing.T) {
+	response := `{"schema_used": "invoice", "data": {"invoice_number": "INV-1", "line_items": [{"description": "Widget", "amount": 10}, {"description": "Gad`
+
+	extraction, err := ParseExtractionResponse(response)
+	if err != nil {
+		t.Fatalf("Expected truncated extraction to parse, got %v", err)
+	}
+	items, _ := extraction.Data["line_items"].([]interface{})
+	if extraction.Data["invoice_number"] != "INV-1" || len(items) != 2 {
+		t.Errorf("Expected the recovered fields and line items, got %+v", extraction.Data)
+	}
+}
+
+// FuzzRepairJSON checks that whatever RepairJSON returns is valid JSON that
+// needs no further repair, and that valid JSON is returned unchanged
+func FuzzRepairJSON(f *testing.F) {
+	for _, tc := range jsonRepairCorpus {
+		f.Add(tc.input)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		got, repairs, err := RepairJSON(input)
+		if err != nil {
+			return
+		}
+		if !json.Valid([]byte(got)) {
+			t.Fatalf("RepairJSON(%q) = %q, which is not valid JSON", input, got)
+		}
+		if again, more, err := RepairJSON(got); err != nil || again != got || len(more) != 0 {
+			t.Fatalf("Repairing %q again gave %q with %v (%v)", got, again, more, err)
+		}
+		if trimmed := strings.TrimSpace(input); json.Valid([]byte(trimmed)) && (got != trimmed || len(repairs) != 0) {
+			t.Fatalf("Expected valid JSON %q unchanged, got %q with %v", trimmed, got, repairs)
+		}
+	})
+}
+
+// FuzzRepairJSON_Truncated checks that valid JSON cut off anywhere, as at
+// max_tokens, is always recovered
+func FuzzRepairJSON_Truncated(f *testing.F) {
+	for _, tc := range jsonRepairCorpus {
+		f.Add(tc.want, uint(len(tc.want)/2))
+	}
+	f.Fuzz(func(t *testing.T, input string, cut uint) {
+		input = strings.TrimSpace(input)
+		if !strings.HasPrefix(input, "{") || !json.Valid([]byte(input)) {
+			return
+		}
+		prefix := input[:1+int(cut%uint(len(input)))]
+		got, _, err := RepairJSON(prefix)
+		if err != nil {
+			t.Fatalf("RepairJSON(%q) failed: %v", prefix, err)
+		}
+		if !json.Valid([]byte(got)) {
+			t.Fatalf("RepairJSON(%q) = %q, which is not valid JSON", prefix, got)
+		}
+	})
+}
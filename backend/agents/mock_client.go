@@ -8,16 +8,28 @@ import (
 
 // MockClient is a test mock for the Client interface
 type MockClient struct {
-	ClassifyFunc func(ctx context.Context, pdfData []byte) (*models.Classification, string, *models.TokenUsage, error)
-	ExtractFunc  func(ctx context.Context, pdfData []byte, documentType string, schema string) (*models.Extraction, string, *models.TokenUsage, error)
+	ClassifyFunc  func(ctx context.Context, pdfData []byte, opts Options) (*models.Classification, string, *models.TokenUsage, error)
+	ExtractFunc   func(ctx context.Context, pdfData []byte, documentType string, schema string, opts Options) (*models.Extraction, string, *models.TokenUsage, error)
+	RepairFunc    func(ctx context.Context, pdfData []byte, documentType string, schema string, previous *models.Extraction, validationErrors []models.ValidationError, opts Options) (*models.Extraction, string, *models.TokenUsage, error)
+	SummarizeFunc func(ctx context.Context, pdfData []byte, summary SummaryOptions, opts Options) (*models.Summary, string, *models.TokenUsage, error)
+	TranslateFunc func(ctx context.Context, pdfData []byte, texts []string, translation TranslationOptions, opts Options) (*TranslationResult, string, *models.TokenUsage, error)
+	PIIFunc       func(ctx context.Context, pdfData []byte, opts Options) (*PIIResult, string, *models.TokenUsage, error)
+	TablesFunc    func(ctx context.Context, pdfData []byte, opts Options) (*models.TableExtraction, string, *models.TokenUsage, error)
+	BundleFunc    func(ctx context.Context, pdfData []byte, opts Options) (*models.BundleReport, string, *models.TokenUsage, error)
+	CompareFunc   func(ctx context.Context, beforePDF []byte, afterPDF []byte, opts Options) (*models.Comparison, string, *models.TokenUsage, error)
+	CountFunc     func(ctx context.Context, pdfData []byte, agentType string, documentType string, schema string, opts Options) (*TokenCount, error)
+	CiteFunc      func(ctx context.Context, pdfData []byte, fields []models.ExtractedField, opts Options) ([][]models.Citation, string, *models.TokenUsage, error)
+	ChatFunc      func(ctx context.Context, pdfData []byte, history []models.ChatTurn, question string, opts Options, onText func(string)) (string, *models.TokenUsage, error)
 }
 
 // Ensure MockClient implements Client interface
 var _ Client = (*MockClient)(nil)
+var _ Citer = (*MockClient)(nil)
+var _ Chatter = (*MockClient)(nil)
 
-func (m *MockClient) ClassifyDocument(ctx context.Context, pdfData []byte) (*models.Classification, string, *models.TokenUsage, error) {
+func (m *MockClient) ClassifyDocument(ctx context.Context, pdfData []byte, opts Options) (*models.Classification, string, *models.TokenUsage, error) {
 	if m.ClassifyFunc != nil {
-		return m.ClassifyFunc(ctx, pdfData)
+		return m.ClassifyFunc(ctx, pdfData, opts)
 	}
 	return &models.Classification{
 		DocumentType: "invoice",
@@ -32,9 +44,9 @@ func (m *MockClient) ClassifyDocument(ctx context.Context, pdfData []byte) (*mod
 	}, nil
 }
 
-func (m *MockClient) ExtractData(ctx context.Context, pdfData []byte, documentType string, schema string) (*models.Extraction, string, *models.TokenUsage, error) {
+func (m *MockClient) ExtractData(ctx context.Context, pdfData []byte, documentType string, schema string, opts Options) (*models.Extraction, string, *models.TokenUsage, error) {
 	if m.ExtractFunc != nil {
-		return m.ExtractFunc(ctx, pdfData, documentType, schema)
+		return m.ExtractFunc(ctx, pdfData, documentType, schema, opts)
 	}
 	return &models.Extraction{
 		SchemaUsed: documentType,
@@ -58,6 +70,148 @@ func (m *MockClient) ExtractData(ctx context.Context, pdfData []byte, documentTy
 	}, nil
 }
 
+func (m *MockClient) RepairExtraction(ctx context.Context, pdfData []byte, documentType string, schema string, previous *models.Extraction, validationErrors []models.ValidationError, opts Options) (*models.Extraction, string, *models.TokenUsage, error) {
+	if m.RepairFunc != nil {
+		return m.RepairFunc(ctx, pdfData, documentType, schema, previous, validationErrors, opts)
+	}
+	return previous, "mock repair prompt", &models.TokenUsage{
+		Model:        "claude-sonnet-4-5-20250929",
+		InputTokens:  2500,
+		OutputTokens: 500,
+		TotalCost:    0.015,
+	}, nil
+}
+
+func (m *MockClient) SummarizeDocument(ctx context.Context, pdfData []byte, summary SummaryOptions, opts Options) (*models.Summary, string, *models.TokenUsage, error) {
+	if m.SummarizeFunc != nil {
+		return m.SummarizeFunc(ctx, pdfData, summary, opts)
+	}
+	result := &models.Summary{Text: "Mock summary"}
+	if summary.Length == SummarySections {
+		result.Sections = []models.SummarySection{{Title: "Mock section", StartPage: 1, EndPage: 1, Summary: "Mock section summary"}}
+	}
+	return result, "mock summary prompt", &models.TokenUsage{
+		Model:        "claude-sonnet-4-5-20250929",
+		InputTokens:  2000,
+		OutputTokens: 150,
+		TotalCost:    0.00825,
+	}, nil
+}
+
+func (m *MockClient) TranslateTexts(ctx context.Context, pdfData []byte, texts []string, translation TranslationOptions, opts Options) (*TranslationResult, string, *models.TokenUsage, error) {
+	if m.TranslateFunc != nil {
+		return m.TranslateFunc(ctx, pdfData, texts, translation, opts)
+	}
+	result := &TranslationResult{SourceLanguage: "de"}
+	for i, text := range texts {
+		result.Texts = append(result.Texts, TranslatedText{Index: i + 1, Translation: "Translated " + text})
+	}
+	if translation.FullText {
+		result.Pages = []models.TranslatedPage{{PageNumber: 1, Text: "Mock translated page"}}
+	}
+	return result, "mock translation prompt", &models.TokenUsage{
+		Model:        "claude-sonnet-4-5-20250929",
+		InputTokens:  2000,
+		OutputTokens: 300,
+		TotalCost:    0.0105,
+	}, nil
+}
+
+func (m *MockClient) FindPII(ctx context.Context, pdfData []byte, opts Options) (*PIIResult, string, *models.TokenUsage, error) {
+	if m.PIIFunc != nil {
+		return m.PIIFunc(ctx, pdfData, opts)
+	}
+	return &PIIResult{}, "mock PII prompt", &models.TokenUsage{
+		Model:        "claude-sonnet-4-5-20250929",
+		InputTokens:  2000,
+		OutputTokens: 100,
+		TotalCost:    0.0075,
+	}, nil
+}
+
+func (m *MockClient) ExtractTables(ctx context.Context, pdfData []byte, opts Options) (*models.TableExtraction, string, *models.TokenUsage, error) {
+	if m.TablesFunc != nil {
+		return m.TablesFunc(ctx, pdfData, opts)
+	}
+	return &models.TableExtraction{Tables: []models.Table{{
+		PageNumber: 1,
+		Caption:    "Mock table",
+		HeaderRows: 1,
+		Cells:      [][]string{{"Item", "Amount"}, {"Mock item", "100.00"}},
+	}}}, "mock tables prompt", &models.TokenUsage{
+		Model:        "claude-sonnet-4-5-20250929",
+		InputTokens:  2000,
+		OutputTokens: 400,
+		TotalCost:    0.012,
+	}, nil
+}
+
+// SegmentBundle returns a single segment of the first page by default
+func (m *MockClient) SegmentBundle(ctx context.Context, pdfData []byte, opts Options) (*models.BundleReport, string, *models.TokenUsage, error) {
+	if m.BundleFunc != nil {
+		return m.BundleFunc(ctx, pdfData, opts)
+	}
+	return &models.BundleReport{Segments: []models.BundleSegment{{StartPage: 1, EndPage: 1, DocumentType: "invoice", Confidence: 0.9}}}, "mock bundle prompt", &models.TokenUsage{
+		Model:        "claude-sonnet-4-5-20250929",
+		InputTokens:  3000,
+		OutputTokens: 150,
+		TotalCost:    0.01125,
+	}, nil
+}
+
+func (m *MockClient) CompareDocuments(ctx context.Context, beforePDF []byte, afterPDF []byte, opts Options) (*models.Comparison, string, *models.TokenUsage, error) {
+	if m.CompareFunc != nil {
+		return m.CompareFunc(ctx, beforePDF, afterPDF, opts)
+	}
+	return &models.Comparison{Summary: "Mock comparison", Changes: []models.ClauseChange{}}, "mock comparison prompt", &models.TokenUsage{
+		Model:        "claude-sonnet-4-5-20250929",
+		InputTokens:  4000,
+		OutputTokens: 500,
+		TotalCost:    0.0195,
+	}, nil
+}
+
+func (m *MockClient) CountTokens(ctx context.Context, pdfData []byte, agentType string, documentType string, schema string, opts Options) (*TokenCount, error) {
+	if m.CountFunc != nil {
+		return m.CountFunc(ctx, pdfData, agentType, documentType, schema, opts)
+	}
+	return &TokenCount{InputTokens: 1000}, nil
+}
+
+// CiteFields cites each field's source text on its claimed page by default
+func (m *MockClient) CiteFields(ctx context.Context, pdfData []byte, fields []models.ExtractedField, opts Options) ([][]models.Citation, string, *models.TokenUsage, error) {
+	if m.CiteFunc != nil {
+		return m.CiteFunc(ctx, pdfData, fields, opts)
+	}
+	citations := make([][]models.Citation, len(fields))
+	for i, field := range fields {
+		if field.SourceText != "" && field.PageNumber > 0 {
+			citations[i] = []models.Citation{{StartPage: field.PageNumber, EndPage: field.PageNumber, CitedText: field.SourceText}}
+		}
+	}
+	return citations, "mock citation prompt", &models.TokenUsage{
+		Model:        "claude-sonnet-4-5-20250929",
+		InputTokens:  2000,
+		OutputTokens: 200,
+		TotalCost:    0.009,
+	}, nil
+}
+
+// Chat streams a fixed answer in two pieces by default
+func (m *MockClient) Chat(ctx context.Context, pdfData []byte, history []models.ChatTurn, question string, opts Options, onText func(string)) (string, *models.TokenUsage, error) {
+	if m.ChatFunc != nil {
+		return m.ChatFunc(ctx, pdfData, history, question, opts, onText)
+	}
+	onText("Mock ")
+	onText("answer")
+	return "Mock answer", &models.TokenUsage{
+		Model:        "claude-sonnet-4-5-20250929",
+		InputTokens:  1500,
+		OutputTokens: 50,
+		TotalCost:    0.00525,
+	}, nil
+}
+
 // NewMockClient creates a new mock client with default behavior
 func NewMockClient() *MockClient {
 	return &MockClient{}
@@ -0,0 +1,141 @@
+package agents
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/pdf-viewer/backend/models"
+)
+
+// DefaultModel is used when neither the request nor the server configuration picks a model
+const DefaultModel = string(anthropic.ModelClaudeSonnet4_5_20250929)
+
+// ModelConfig holds the server-wide model defaults per agent type and the
+// models requests are allowed to select
+type ModelConfig struct {
+	Defaults map[string]string // Agent type (e.g. "classification") to model ID
+	Fallback string            // Model for agent types without a default; empty means DefaultModel
+	Allowed  []string          // Models a request may ask for
+	// Provider is the LLM_PROVIDER serving the calls. Requests for models
+	// it cannot run are refused even if they are allowed.
+	Provider    string
+	ServesModel func(model string) bool // nil serves every model
+}
+
+// LoadModelConfigFromEnv builds a ModelConfig from environment variables:
+// CLASSIFICATION_MODEL, EXTRACTION_MODEL, DEFAULT_MODEL and ALLOWED_MODELS
+// (comma separated). DEFAULT_MODEL defaults to the LLM_PROVIDER's default
+// model. Without ALLOWED_MODELS every model with known pricing that the
+// provider can run is allowed, as is the fallback model.
+func LoadModelConfigFromEnv() *ModelConfig {
+	config := &ModelConfig{Defaults: map[string]string{}}
+
+	config.Provider = strings.ToLower(strings.TrimSpace(os.Getenv("LLM_PROVIDER")))
+	if config.Provider == "" {
+		config.Provider = ProviderAnthropic
+	}
+	if provider, err := GetProvider(config.Provider); err == nil {
+		config.Fallback = provider.DefaultModel
+		config.ServesModel = provider.ServesModel
+	}
+	for _, model := range models.PricedModels() {
+		if config.Serves(model) {
+			config.Allowed = append(config.Allowed, model)
+		}
+	}
+	if model := strings.TrimSpace(os.Getenv("DEFAULT_MODEL")); model != "" {
+		config.Fallback = model
+	}
+	if config.Fallback != "" && !config.IsAllowed(config.Fallback) {
+		config.Allowed = append(config.Allowed, config.Fallback)
+	}
+
+	for agentType, envVar := range map[string]string{
+		"classification": "CLASSIFICATION_MODEL",
+		"extraction":     "EXTRACTION_MODEL",
+	} {
+		if model := strings.TrimSpace(os.Getenv(envVar)); model != "" {
+			config.Defaults[agentType] = model
+		}
+	}
+
+	if allowed := os.Getenv("ALLOWED_MODELS"); allowed != "" {
+		config.Allowed = nil
+		for _, model := range strings.Split(allowed, ",") {
+			if model = strings.TrimSpace(model); model != "" {
+				config.Allowed = append(config.Allowed, model)
+			}
+		}
+	}
+
+	return config
+}
+
+// Resolve returns the model to use for an agent call. An empty requested
+// model falls back to the agent type's default, then Fallback, then
+// DefaultModel.
+func (c *ModelConfig) Resolve(agentType, requested string) (string, error) {
+	if requested == "" {
+		if model, ok := c.Defaults[agentType]; ok {
+			return model, nil
+		}
+		if c.Fallback != "" {
+			return c.Fallback, nil
+		}
+		return DefaultModel, nil
+	}
+
+	if !c.IsAllowed(requested) {
+		return "", fmt.Errorf("model not allowed: %s", requested)
+	}
+	if !c.Serves(requested) {
+		return "", fmt.Errorf("model %s is not served by the %s provider", requested, c.Provider)
+	}
+	return requested, nil
+}
+
+// Serves reports whether the provider can run a model
+func (c *ModelConfig) Serves(model string) bool {
+	return c.ServesModel == nil || c.ServesModel(model)
+}
+
+// Validate checks that the provider can run the configured default models
+func (c *ModelConfig) Validate() error {
+	for _, model := range append([]string{c.Fallback}, slices.Sorted(maps.Values(c.Defaults))...) {
+		if model != "" && !c.Serves(model) {
+			return fmt.Errorf("default model %s is not served by the %s provider", model, c.Provider)
+		}
+	}
+	return nil
+}
+
+// IsAllowed reports whether requests may select the given model
+func (c *ModelConfig) IsAllowed(model string) bool {
+	for _, allowed := range c.Allowed {
+		if allowed == model {
+			return true
+		}
+	}
+	return false
+}
+
+// Global model configuration
+var globalModelConfig *ModelConfig
+
+// SetModelConfig sets the global model configuration (useful for testing)
+func SetModelConfig(c *ModelConfig) {
+	globalModelConfig = c
+}
+
+// GetModelConfig returns the global model configuration, loading it from the
+// environment if not set
+func GetModelConfig() *ModelConfig {
+	if globalModelConfig == nil {
+		globalModelConfig = LoadModelConfigFromEnv()
+	}
+	return globalModelConfig
+}
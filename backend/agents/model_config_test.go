@@ -0,0 +1,105 @@
+package agents
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestModelConfig_Resolve(t *testing.T) {
+	config := &ModelConfig{
+		Defaults: map[string]string{"extraction": "claude-opus-4-5"},
+		Allowed:  []string{"claude-haiku-4-5", "claude-opus-4-5"},
+	}
+
+	tests := []struct {
+		agentType string
+		requested string
+		expected  string
+		wantErr   bool
+	}{
+		{"classification", "", DefaultModel, false},
+		{"extraction", "", "claude-opus-4-5", false},
+		{"classification", "claude-haiku-4-5", "claude-haiku-4-5", false},
+		{"classification", "gpt-4", "", true},
+	}
+
+	for _, tc := range tests {
+		model, err := config.Resolve(tc.agentType, tc.requested)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("Resolve(%q, %q) error = %v, wantErr %v", tc.agentType, tc.requested, err, tc.wantErr)
+			continue
+		}
+		if model != tc.expected {
+			t.Errorf("Resolve(%q, %q) = %q, expected %q", tc.agentType, tc.requested, model, tc.expected)
+		}
+	}
+}
+
+func TestLoadModelConfigFromEnv(t *testing.T) {
+	t.Setenv("CLASSIFICATION_MODEL", "claude-haiku-4-5")
+	t.Setenv("EXTRACTION_MODEL", "")
+	t.Setenv("ALLOWED_MODELS", "claude-haiku-4-5, claude-sonnet-4-5")
+
+	config := LoadModelConfigFromEnv()
+
+	if config.Defaults["classification"] != "claude-haiku-4-5" {
+		t.Errorf("Expected classification default from env, got '%s'", config.Defaults["classification"])
+	}
+	if _, ok := config.Defaults["extraction"]; ok {
+		t.Error("Expected no extraction default")
+	}
+	if len(config.Allowed) != 2 || !config.IsAllowed("claude-sonnet-4-5") {
+		t.Errorf("Expected allowlist from env, got %v", config.Allowed)
+	}
+}
+
+func TestLoadModelConfigFromEnv_DefaultsToPricedModels(t *testing.T) {
+	t.Setenv("ALLOWED_MODELS", "")
+
+	config := LoadModelConfigFromEnv()
+
+	for _, model := range []string{"claude-haiku-4-5", "claude-sonnet-4-5-20250929", "claude-opus-4-5"} {
+		if !config.IsAllowed(model) {
+			t.Errorf("Expected %s to be allowed by default", model)
+		}
+	}
+}
+
+func TestGetModelConfig_LoadsDefault(t *testing.T) {
+	SetModelConfig(nil)
+	defer SetModelConfig(nil)
+
+	if GetModelConfig() == nil {
+		t.Error("GetModelConfig should return a default configuration")
+	}
+}
+
+func TestLoadModelConfigFromEnv_ModelsOfTheProvider(t *testing.T) {
+	t.Setenv("ALLOWED_MODELS", "")
+	t.Setenv("DEFAULT_MODEL", "")
+	t.Setenv("LLM_PROVIDER", "openai")
+
+	config := LoadModelConfigFromEnv()
+	if config.IsAllowed("claude-sonnet-4-5") || !config.IsAllowed("gpt-4.1") {
+		t.Errorf("Expected only the priced models of the provider to be allowed, got %v", config.Allowed)
+	}
+
+	// An allowlist cannot route a model to a provider that cannot run it
+	t.Setenv("ALLOWED_MODELS", "claude-sonnet-4-5,gpt-4.1")
+	config = LoadModelConfigFromEnv()
+	if _, err := config.Resolve("extraction", "claude-sonnet-4-5"); err == nil || !strings.Contains(err.Error(), "not served by the openai provider") {
+		t.Errorf("Expected a Claude model to be refused by the openai provider, got %v", err)
+	}
+	if model, err := config.Resolve("extraction", "gpt-4.1"); err != nil || model != "gpt-4.1" {
+		t.Errorf("Expected gpt-4.1, got %q (%v)", model, err)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected the provider's default model to validate, got %v", err)
+	}
+
+	t.Setenv("LLM_PROVIDER", "ollama")
+	t.Setenv("EXTRACTION_MODEL", "gpt-4.1")
+	if err := LoadModelConfigFromEnv().Validate(); err == nil || !strings.Contains(err.Error(), "gpt-4.1") {
+		t.Errorf("Expected a hosted default model to be refused by ollama, got %v", err)
+	}
+}
@@ -0,0 +1,249 @@
+package agents
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/pdf"
+)
+
+// DefaultOllamaModel is used by OllamaClient when no model is requested
+const DefaultOllamaModel = "llama3.2-vision"
+
+// OllamaClient implements Client against a local Ollama server. The page
+// text and images are sent in place of the PDF, and the response is
+// constrained to the tool's JSON schema with Ollama's structured outputs.
+// Local models have no pricing unless one is registered for them.
+type OllamaClient struct {
+	BaseURL      string // e.g. http://localhost:11434
+	DefaultModel string
+	InputMode    InputMode
+	RetryPolicy  RetryPolicy
+	HTTPClient   *http.Client
+}
+
+// NewOllamaClient creates a client for the Ollama server at baseURL
+func NewOllamaClient(baseURL string) *OllamaClient {
+	return &OllamaClient{
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		DefaultModel: DefaultOllamaModel,
+		InputMode:    InputAuto,
+		RetryPolicy:  DefaultRetryPolicy(),
+		HTTPClient:   http.DefaultClient,
+	}
+}
+
+// NewOllamaClientFromEnv creates an OllamaClient configured by OLLAMA_HOST
+// and PDF_INPUT_MODE that sends requests with httpClient, if not nil
+func NewOllamaClientFromEnv(httpClient *http.Client) (Client, error) {
+	baseURL := os.Getenv("OLLAMA_HOST")
+	if baseURL == "" {
+		baseURL = "http://localhost:11434"
+	}
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "http://" + baseURL
+	}
+	mode, err := ParseInputMode(os.Getenv("PDF_INPUT_MODE"))
+	if err != nil {
+		return nil, err
+	}
+	client := NewOllamaClient(baseURL)
+	client.InputMode = mode
+	if httpClient != nil {
+		client.HTTPClient = httpClient
+	}
+	return client, nil
+}
+
+// Ensure OllamaClient implements Client interface
+var _ Client = (*OllamaClient)(nil)
+
+type ollamaRequest struct {
+	Model    string                 `json:"model"`
+	Messages []ollamaMessage        `json:"messages"`
+	Format   map[string]interface{} `json:"format,omitempty"`
+	Stream   bool                   `json:"stream"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"` // Base64 encoded
+}
+
+type ollamaResponse struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	DoneReason      string `json:"done_reason"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+}
+
+func (c *OllamaClient) model(opts Options) string {
+	if opts.Model != "" {
+		return opts.Model
+	}
+	return c.DefaultModel
+}
+
+func (c *OllamaClient) ClassifyDocument(ctx context.Context, pdfData []byte, opts Options) (*models.Classification, string, *models.TokenUsage, error) {
+	schema, err := ClassificationInputSchema()
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return chatTool[models.Classification](ctx, c, pdfData, opts.classificationPrompt(), ClassificationToolName, schema, classificationMaxTokens, opts)
+}
+
+func (c *OllamaClient) ExtractData(ctx context.Context, pdfData []byte, documentType string, schema string, opts Options) (*models.Extraction, string, *models.TokenUsage, error) {
+	return c.extract(ctx, pdfData, schema, opts.extractionPrompt(documentType, schema), opts)
+}
+
+func (c *OllamaClient) RepairExtraction(ctx context.Context, pdfData []byte, documentType string, schema string, previous *models.Extraction, validationErrors []models.ValidationError, opts Options) (*models.Extraction, string, *models.TokenUsage, error) {
+	previousJSON, err := json.MarshalIndent(previous, "", "  ")
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to encode previous extraction: %w", err)
+	}
+	return c.extract(ctx, pdfData, schema, opts.repairPrompt(documentType, schema, string(previousJSON), validationErrors), opts)
+}
+
+func (c *OllamaClient) extract(ctx context.Context, pdfData []byte, schema, prompt string, opts Options) (*models.Extraction, string, *models.TokenUsage, error) {
+	format, err := ExtractionInputSchema(schema)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return chatTool[models.Extraction](ctx, c, pdfData, prompt, ExtractionToolName, format, extractionMaxTokens, opts)
+}
+
+func (c *OllamaClient) SummarizeDocument(ctx context.Context, pdfData []byte, summary SummaryOptions, opts Options) (*models.Summary, string, *models.TokenUsage, error) {
+	return chatTool[models.Summary](ctx, c, pdfData, BuildSummaryPrompt(summary), SummaryToolName, SummaryInputSchema(), summary.maxTokens(), opts)
+}
+
+func (c *OllamaClient) TranslateTexts(ctx context.Context, pdfData []byte, texts []string, translation TranslationOptions, opts Options) (*TranslationResult, string, *models.TokenUsage, error) {
+	prompt := BuildTranslationPrompt(texts, translation)
+	return chatTool[TranslationResult](ctx, c, pdfData, prompt, TranslationToolName, TranslationInputSchema(), translation.maxTokens(), opts)
+}
+
+func (c *OllamaClient) FindPII(ctx context.Context, pdfData []byte, opts Options) (*PIIResult, string, *models.TokenUsage, error) {
+	return chatTool[PIIResult](ctx, c, pdfData, BuildPIIPrompt(), PIIToolName, PIIInputSchema(), extractionMaxTokens, opts)
+}
+
+func (c *OllamaClient) ExtractTables(ctx context.Context, pdfData []byte, opts Options) (*models.TableExtraction, string, *models.TokenUsage, error) {
+	return chatTool[models.TableExtraction](ctx, c, pdfData, BuildTablesPrompt(), TablesToolName, TablesInputSchema(), tablesMaxTokens, opts)
+}
+
+func (c *OllamaClient) SegmentBundle(ctx context.Context, pdfData []byte, opts Options) (*models.BundleReport, string, *models.TokenUsage, error) {
+	return chatTool[models.BundleReport](ctx, c, pdfData, BuildBundlePrompt(opts.DocumentTypes), BundleToolName, BundleInputSchema(), bundleMaxTokens, opts)
+}
+
+func (c *OllamaClient) CompareDocuments(ctx context.Context, beforePDF []byte, afterPDF []byte, opts Options) (*models.Comparison, string, *models.TokenUsage, error) {
+	prompt := BuildComparisonPrompt()
+	text, images, err := loadComparisonInput(beforePDF, afterPDF, c.InputMode)
+	if err != nil {
+		return nil, prompt, nil, err
+	}
+	output, tokenUsage, err := c.chatContent(ctx, text, images, prompt, ComparisonInputSchema(), c.model(opts), comparisonMaxTokens)
+	if err != nil {
+		return nil, prompt, nil, err
+	}
+
+	result, repairs, err := parseResponse[models.Comparison](output, ComparisonToolName)
+	if err != nil {
+		return nil, prompt, nil, err
+	}
+	tokenUsage.JSONRepairs = repairNames(repairs)
+	return result, prompt, tokenUsage, nil
+}
+
+// chatTool asks a prompt about a PDF with the output constrained to the
+// format of the named tool's result and parses it into a T
+func chatTool[T any](ctx context.Context, c *OllamaClient, pdfData []byte, prompt, toolName string, format map[string]interface{}, maxTokens int, opts Options) (*T, string, *models.TokenUsage, error) {
+	output, tokenUsage, err := c.chat(ctx, pdfData, prompt, format, c.model(opts), maxTokens)
+	if err != nil {
+		return nil, prompt, nil, err
+	}
+
+	// A response that cannot be parsed was still billed
+	result, repairs, err := parseResponse[T](output, toolName)
+	if err != nil {
+		return nil, prompt, tokenUsage, err
+	}
+	tokenUsage.JSONRepairs = repairNames(repairs)
+	return result, prompt, tokenUsage, nil
+}
+
+// CountTokens approximates the input of a call, since the API has no token
+// counting endpoint
+func (c *OllamaClient) CountTokens(ctx context.Context, pdfData []byte, agentType string, documentType string, schema string, opts Options) (*TokenCount, error) {
+	request, err := newAgentRequest(agentType, documentType, schema, opts)
+	if err != nil {
+		return nil, err
+	}
+	return approximateTokens(pdfData, c.InputMode, request)
+}
+
+// chat sends the document pages and prompt and returns the response text,
+// which the format schema constrains to JSON
+func (c *OllamaClient) chat(ctx context.Context, pdfData []byte, prompt string, format map[string]interface{}, model string, maxTokens int) (string, *models.TokenUsage, error) {
+	pages, err := LoadPageInputs(pdfData, c.InputMode)
+	if err != nil {
+		return "", nil, err
+	}
+	return c.chatContent(ctx, FormatPageText(pages), pageImages(pages), prompt, format, model, maxTokens)
+}
+
+// chatContent sends the document text and images followed by the prompt
+func (c *OllamaClient) chatContent(ctx context.Context, text string, images []pdf.Image, prompt string, format map[string]interface{}, model string, maxTokens int) (string, *models.TokenUsage, error) {
+	message := ollamaMessage{Role: "user", Content: text + "\n" + prompt}
+	for _, img := range images {
+		message.Images = append(message.Images, base64.StdEncoding.EncodeToString(img.Data))
+	}
+
+	// A response cut off at num_predict is sent again with a larger limit:
+	// the format schema applies to the whole response, so it cannot be
+	// continued
+	var response ollamaResponse
+	tokenUsage := &models.TokenUsage{Model: model}
+	for {
+		request := ollamaRequest{
+			Model:    model,
+			Messages: []ollamaMessage{message},
+			Format:   format,
+			Options:  map[string]interface{}{"num_predict": maxTokens, "temperature": 0},
+		}
+
+		response = ollamaResponse{}
+		attempts, err := c.RetryPolicy.Do(ctx, func() error {
+			return postJSON(ctx, c.HTTPClient, "ollama", c.BaseURL+"/api/chat", nil, request, &response)
+		})
+		tokenUsage.Attempts += attempts
+		if err != nil {
+			return "", nil, fmt.Errorf("ollama API error after %d attempt(s): %w", attempts, err)
+		}
+
+		tokenUsage.InputTokens += response.PromptEvalCount
+		tokenUsage.OutputTokens += response.EvalCount
+		if response.DoneReason != "length" {
+			break
+		}
+		limit, ok := largerLimit(maxTokens)
+		if !ok || tokenUsage.Continuations == maxContinuations {
+			return "", nil, fmt.Errorf("%w: cut off at %d tokens", ErrMaxTokens, maxTokens)
+		}
+		maxTokens = limit
+		tokenUsage.Continuations++
+	}
+
+	tokenUsage.RawResponse = response.Message.Content
+	tokenUsage.StopReason = response.DoneReason
+	tokenUsage.TotalCost = models.CalculateUsageCost(*tokenUsage)
+	tokenUsage.Unpriced = !models.IsPriced(tokenUsage.Model)
+	return response.Message.Content, tokenUsage, nil
+}
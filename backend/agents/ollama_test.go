@@ -0,0 +1,152 @@
+package agents
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pdf-viewer/backend/pdf"
+)
+
+// scannedPDF returns a one-page PDF whose only content is a JPEG image
+func scannedPDF(jpeg []byte) []byte {
+	b := pdf.NewBuilder()
+	catalog := b.Reserve()
+	pages := b.Reserve()
+	image := b.Add(&pdf.Stream{Dict: pdf.Dict{
+		"Type": pdf.Name("XObject"), "Subtype": pdf.Name("Image"), "Width": int64(800), "Height": int64(1000),
+		"ColorSpace": pdf.Name("DeviceRGB"), "BitsPerComponent": int64(8), "Filter": pdf.Name("DCTDecode"),
+	}, Data: jpeg})
+	contents := b.Add(&pdf.Stream{Dict: pdf.Dict{}, Data: []byte("q 612 0 0 792 0 0 cm /Im0 Do Q")})
+	page := b.Add(pdf.Dict{
+		"Type": pdf.Name("Page"), "Parent": pages, "Contents": contents,
+		"Resources": pdf.Dict{"XObject": pdf.Dict{"Im0": image}},
+	})
+	b.Set(pages, pdf.Dict{"Type": pdf.Name("Pages"), "Kids": pdf.Array{page}, "Count": int64(1)})
+	b.Set(catalog, pdf.Dict{"Type": pdf.Name("Catalog"), "Pages": pages})
+	return b.Bytes(catalog)
+}
+
+func TestOllamaClient_SendsImagesForScannedPages(t *testing.T) {
+	var request ollamaRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &request)
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{
+			"model": "llava", "done": true,
+			"message": {"role": "assistant", "content": "{\"document_type\": \"receipt\", \"confidence\": 0.8, \"reasoning\": \"Store receipt\"}"},
+			"prompt_eval_count": 900, "eval_count": 60
+		}`)
+	}))
+	defer server.Close()
+
+	jpeg := []byte("\xff\xd8\xff\xe0 scanned page \xff\xd9")
+	client := NewOllamaClient(server.URL)
+
+	classification, _, usage, err := client.ClassifyDocument(context.Background(), scannedPDF(jpeg), Options{Model: "llava"})
+	if err != nil {
+		t.Fatalf("ClassifyDocument failed: %v", err)
+	}
+	if classification.DocumentType != "receipt" {
+		t.Errorf("Expected receipt, got '%s'", classification.DocumentType)
+	}
+
+	if request.Model != "llava" || request.Stream {
+		t.Errorf("Unexpected request: model=%s stream=%v", request.Model, request.Stream)
+	}
+	if request.Format["type"] != "object" {
+		t.Errorf("Expected JSON schema format, got %v", request.Format)
+	}
+	if len(request.Messages) != 1 || len(request.Messages[0].Images) != 1 {
+		t.Fatalf("Expected one message with one image, got %+v", request.Messages)
+	}
+	if !strings.Contains(request.Messages[0].Content, "[Attached image 1 is from this page]") {
+		t.Errorf("Expected image note in prompt, got %s", request.Messages[0].Content)
+	}
+	if decoded, _ := base64.StdEncoding.DecodeString(request.Messages[0].Images[0]); !bytes.Equal(decoded, jpeg) {
+		t.Error("Expected the page's JPEG to be sent base64 encoded")
+	}
+
+	if usage.InputTokens != 900 || usage.OutputTokens != 60 || usage.TotalCost != 0 || !usage.Unpriced {
+		t.Errorf("Expected unpriced local usage, got %+v", usage)
+	}
+}
+
+func TestOllamaClient_ExtractData(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"message": {"content": "{\"schema_used\": \"invoice\", \"data\": {\"invoice_number\": \"A-1\"}, \"fields\": [{\"name\": \"invoice_number\", \"value\": \"A-1\", \"source_text\": \"Invoice A-1\", \"page_number\": 1, \"confidence\": 0.9}]}"}, "prompt_eval_count": 10, "eval_count": 20}`)
+	}))
+	defer server.Close()
+
+	client := NewOllamaClient(server.URL)
+	extraction, _, usage, err := client.ExtractData(context.Background(), pdf.GenerateTextPDF([]string{"Invoice A-1"}), "invoice", GetSchemaForDocumentType("invoice"), Options{})
+	if err != nil {
+		t.Fatalf("ExtractData failed: %v", err)
+	}
+	if len(extraction.Fields) != 1 || extraction.Fields[0].SourceText != "Invoice A-1" {
+		t.Errorf("Unexpected extraction: %+v", extraction)
+	}
+	if usage.Model != DefaultOllamaModel {
+		t.Errorf("Expected default model, got '%s'", usage.Model)
+	}
+}
+
+func TestLoadPageInputs_Modes(t *testing.T) {
+	jpeg := []byte("\xff\xd8 image \xff\xd9")
+
+	pages, err := LoadPageInputs(scannedPDF(jpeg), InputAuto)
+	if err != nil || len(pages) != 1 || len(pages[0].Images) != 1 {
+		t.Errorf("Expected auto mode to use the image of a scanned page, got %+v (%v)", pages, err)
+	}
+
+	if _, err := LoadPageInputs(scannedPDF(jpeg), InputText); err == nil {
+		t.Error("Expected text mode to fail on a scanned PDF")
+	}
+
+	pages, err = LoadPageInputs(pdf.GenerateTextPDF([]string{"hello"}), InputAuto)
+	if err != nil || pages[0].Text != "hello" || len(pages[0].Images) != 0 {
+		t.Errorf("Expected text only for a text page, got %+v (%v)", pages, err)
+	}
+
+	if _, err := ParseInputMode("pixels"); err == nil {
+		t.Error("Expected error for unknown input mode")
+	}
+}
+
+func TestOllamaClient_ExtractDataMarksTruncatedResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"message": {"content": "{\"schema_used\": \"invoice\", \"data\": {\"invoice_number\": \"A-1\", \"line_items\": [{\"description\": \"Wid"}, "prompt_eval_count": 10, "eval_count": 20}`)
+	}))
+	defer server.Close()
+
+	client := NewOllamaClient(server.URL)
+	_, _, usage, err := client.ExtractData(context.Background(), pdf.GenerateTextPDF([]string{"Invoice A-1"}), "invoice", GetSchemaForDocumentType("invoice"), Options{})
+	if err != nil {
+		t.Fatalf("ExtractData failed: %v", err)
+	}
+	if want := []string{"closed_string", "closed_container"}; !reflect.DeepEqual(usage.JSONRepairs, want) {
+		t.Errorf("Expected repairs %v on the usage, got %v", want, usage.JSONRepairs)
+	}
+
+	extraction, steps, err := ExtractAndValidate(context.Background(), client, pdf.GenerateTextPDF([]string{"Invoice A-1"}), "invoice", GetSchemaForDocumentType("invoice"), Options{}, 0)
+	if err != nil {
+		t.Fatalf("ExtractAndValidate failed: %v", err)
+	}
+	if !extraction.Truncated || !TruncationRepaired(steps[0].TokenUsage) {
+		t.Errorf("Expected the extraction marked truncated, got %+v", extraction)
+	}
+}
@@ -0,0 +1,314 @@
+package agents
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/pdf"
+)
+
+// DefaultOpenAIModel is used by OpenAIClient when no model is requested
+const DefaultOpenAIModel = "gpt-4.1-mini"
+
+// OpenAIClient implements Client against an OpenAI-compatible chat
+// completions API. Such APIs don't accept PDFs, so the page text (and,
+// depending on InputMode, page images) is sent instead. Structured output
+// uses a forced function call.
+type OpenAIClient struct {
+	BaseURL      string // e.g. https://api.openai.com/v1
+	APIKey       string
+	DefaultModel string
+	InputMode    InputMode
+	RetryPolicy  RetryPolicy
+	HTTPClient   *http.Client
+}
+
+// NewOpenAIClient creates a client for the chat completions API at baseURL
+func NewOpenAIClient(baseURL, apiKey string) *OpenAIClient {
+	return &OpenAIClient{
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		APIKey:       apiKey,
+		DefaultModel: DefaultOpenAIModel,
+		InputMode:    InputAuto,
+		RetryPolicy:  DefaultRetryPolicy(),
+		HTTPClient:   http.DefaultClient,
+	}
+}
+
+// NewOpenAIClientFromEnv creates an OpenAIClient configured by OPENAI_BASE_URL,
+// OPENAI_API_KEY and PDF_INPUT_MODE that sends requests with httpClient, if
+// not nil
+func NewOpenAIClientFromEnv(httpClient *http.Client) (Client, error) {
+	baseURL := os.Getenv("OPENAI_BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	mode, err := ParseInputMode(os.Getenv("PDF_INPUT_MODE"))
+	if err != nil {
+		return nil, err
+	}
+	client := NewOpenAIClient(baseURL, os.Getenv("OPENAI_API_KEY"))
+	client.InputMode = mode
+	if httpClient != nil {
+		client.HTTPClient = httpClient
+	}
+	return client, nil
+}
+
+// Ensure OpenAIClient implements Client interface
+var _ Client = (*OpenAIClient)(nil)
+
+type openAIRequest struct {
+	Model      string          `json:"model"`
+	Messages   []openAIMessage `json:"messages"`
+	Tools      []openAITool    `json:"tools,omitempty"`
+	ToolChoice interface{}     `json:"tool_choice,omitempty"`
+	MaxTokens  int             `json:"max_tokens,omitempty"`
+}
+
+type openAIMessage struct {
+	Role    string              `json:"role"`
+	Content []openAIContentPart `json:"content"`
+}
+
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+type openAITool struct {
+	Type     string         `json:"type"`
+	Function openAIFunction `json:"function"`
+}
+
+type openAIFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens        int `json:"prompt_tokens"`
+		CompletionTokens    int `json:"completion_tokens"`
+		PromptTokensDetails struct {
+			CachedTokens int `json:"cached_tokens"`
+		} `json:"prompt_tokens_details"`
+	} `json:"usage"`
+}
+
+func (c *OpenAIClient) model(opts Options) string {
+	if opts.Model != "" {
+		return opts.Model
+	}
+	return c.DefaultModel
+}
+
+func (c *OpenAIClient) ClassifyDocument(ctx context.Context, pdfData []byte, opts Options) (*models.Classification, string, *models.TokenUsage, error) {
+	schema, err := ClassificationInputSchema()
+	if err != nil {
+		return nil, "", nil, err
+	}
+	fn := openAIFunction{Name: ClassificationToolName, Description: classificationToolDescription, Parameters: schema}
+	return completeTool[models.Classification](ctx, c, pdfData, opts.classificationPrompt(), fn, classificationMaxTokens, opts)
+}
+
+func (c *OpenAIClient) ExtractData(ctx context.Context, pdfData []byte, documentType string, schema string, opts Options) (*models.Extraction, string, *models.TokenUsage, error) {
+	return c.extract(ctx, pdfData, documentType, schema, opts.extractionPrompt(documentType, schema), opts)
+}
+
+func (c *OpenAIClient) RepairExtraction(ctx context.Context, pdfData []byte, documentType string, schema string, previous *models.Extraction, validationErrors []models.ValidationError, opts Options) (*models.Extraction, string, *models.TokenUsage, error) {
+	previousJSON, err := json.MarshalIndent(previous, "", "  ")
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to encode previous extraction: %w", err)
+	}
+	return c.extract(ctx, pdfData, documentType, schema, opts.repairPrompt(documentType, schema, string(previousJSON), validationErrors), opts)
+}
+
+func (c *OpenAIClient) extract(ctx context.Context, pdfData []byte, documentType, schema, prompt string, opts Options) (*models.Extraction, string, *models.TokenUsage, error) {
+	input, err := ExtractionInputSchema(schema)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	fn := openAIFunction{Name: ExtractionToolName, Description: extractionToolDescription(documentType), Parameters: input}
+	return completeTool[models.Extraction](ctx, c, pdfData, prompt, fn, extractionMaxTokens, opts)
+}
+
+func (c *OpenAIClient) SummarizeDocument(ctx context.Context, pdfData []byte, summary SummaryOptions, opts Options) (*models.Summary, string, *models.TokenUsage, error) {
+	fn := openAIFunction{Name: SummaryToolName, Description: summaryToolDescription, Parameters: SummaryInputSchema()}
+	return completeTool[models.Summary](ctx, c, pdfData, BuildSummaryPrompt(summary), fn, summary.maxTokens(), opts)
+}
+
+func (c *OpenAIClient) TranslateTexts(ctx context.Context, pdfData []byte, texts []string, translation TranslationOptions, opts Options) (*TranslationResult, string, *models.TokenUsage, error) {
+	fn := openAIFunction{Name: TranslationToolName, Description: translationToolDescription(translation.TargetLanguage), Parameters: TranslationInputSchema()}
+	return completeTool[TranslationResult](ctx, c, pdfData, BuildTranslationPrompt(texts, translation), fn, translation.maxTokens(), opts)
+}
+
+func (c *OpenAIClient) FindPII(ctx context.Context, pdfData []byte, opts Options) (*PIIResult, string, *models.TokenUsage, error) {
+	fn := openAIFunction{Name: PIIToolName, Description: piiToolDescription, Parameters: PIIInputSchema()}
+	return completeTool[PIIResult](ctx, c, pdfData, BuildPIIPrompt(), fn, extractionMaxTokens, opts)
+}
+
+func (c *OpenAIClient) ExtractTables(ctx context.Context, pdfData []byte, opts Options) (*models.TableExtraction, string, *models.TokenUsage, error) {
+	fn := openAIFunction{Name: TablesToolName, Description: tablesToolDescription, Parameters: TablesInputSchema()}
+	return completeTool[models.TableExtraction](ctx, c, pdfData, BuildTablesPrompt(), fn, tablesMaxTokens, opts)
+}
+
+func (c *OpenAIClient) SegmentBundle(ctx context.Context, pdfData []byte, opts Options) (*models.BundleReport, string, *models.TokenUsage, error) {
+	fn := openAIFunction{Name: BundleToolName, Description: bundleToolDescription, Parameters: BundleInputSchema()}
+	return completeTool[models.BundleReport](ctx, c, pdfData, BuildBundlePrompt(opts.DocumentTypes), fn, bundleMaxTokens, opts)
+}
+
+func (c *OpenAIClient) CompareDocuments(ctx context.Context, beforePDF []byte, afterPDF []byte, opts Options) (*models.Comparison, string, *models.TokenUsage, error) {
+	prompt := BuildComparisonPrompt()
+	text, images, err := loadComparisonInput(beforePDF, afterPDF, c.InputMode)
+	if err != nil {
+		return nil, prompt, nil, err
+	}
+	output, tokenUsage, err := c.completeContent(ctx, text, images, prompt, openAIFunction{Name: ComparisonToolName, Description: comparisonToolDescription, Parameters: ComparisonInputSchema()}, c.model(opts), comparisonMaxTokens)
+	if err != nil {
+		return nil, prompt, nil, err
+	}
+
+	result, repairs, err := parseResponse[models.Comparison](output, ComparisonToolName)
+	if err != nil {
+		return nil, prompt, nil, err
+	}
+	tokenUsage.JSONRepairs = repairNames(repairs)
+	return result, prompt, tokenUsage, nil
+}
+
+// completeTool asks a prompt about a PDF with the function forced and
+// parses its arguments into a T
+func completeTool[T any](ctx context.Context, c *OpenAIClient, pdfData []byte, prompt string, fn openAIFunction, maxTokens int, opts Options) (*T, string, *models.TokenUsage, error) {
+	output, tokenUsage, err := c.complete(ctx, pdfData, prompt, fn, c.model(opts), maxTokens)
+	if err != nil {
+		return nil, prompt, nil, err
+	}
+
+	// A response that cannot be parsed was still billed
+	result, repairs, err := parseResponse[T](output, fn.Name)
+	if err != nil {
+		return nil, prompt, tokenUsage, err
+	}
+	tokenUsage.JSONRepairs = repairNames(repairs)
+	return result, prompt, tokenUsage, nil
+}
+
+// CountTokens approximates the input of a call, since the API has no token
+// counting endpoint
+func (c *OpenAIClient) CountTokens(ctx context.Context, pdfData []byte, agentType string, documentType string, schema string, opts Options) (*TokenCount, error) {
+	request, err := newAgentRequest(agentType, documentType, schema, opts)
+	if err != nil {
+		return nil, err
+	}
+	return approximateTokens(pdfData, c.InputMode, request)
+}
+
+// complete sends the document pages and prompt, forcing a call to function.
+// It returns the function arguments, or the message text from servers that
+// ignore tools.
+func (c *OpenAIClient) complete(ctx context.Context, pdfData []byte, prompt string, function openAIFunction, model string, maxTokens int) (string, *models.TokenUsage, error) {
+	pages, err := LoadPageInputs(pdfData, c.InputMode)
+	if err != nil {
+		return "", nil, err
+	}
+	return c.completeContent(ctx, FormatPageText(pages), pageImages(pages), prompt, function, model, maxTokens)
+}
+
+// completeContent sends the document text and images followed by the prompt
+func (c *OpenAIClient) completeContent(ctx context.Context, text string, images []pdf.Image, prompt string, function openAIFunction, model string, maxTokens int) (string, *models.TokenUsage, error) {
+	content := []openAIContentPart{{Type: "text", Text: text}}
+	for _, img := range images {
+		url := "data:" + img.MediaType + ";base64," + base64.StdEncoding.EncodeToString(img.Data)
+		content = append(content, openAIContentPart{Type: "image_url", ImageURL: &openAIImageURL{URL: url}})
+	}
+	content = append(content, openAIContentPart{Type: "text", Text: prompt})
+
+	request := openAIRequest{
+		Model:     model,
+		Messages:  []openAIMessage{{Role: "user", Content: content}},
+		Tools:     []openAITool{{Type: "function", Function: function}},
+		MaxTokens: maxTokens,
+		ToolChoice: map[string]interface{}{
+			"type":     "function",
+			"function": map[string]string{"name": function.Name},
+		},
+	}
+
+	header := http.Header{}
+	if c.APIKey != "" {
+		header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	// A response cut off at max_tokens is sent again with a larger limit,
+	// since a function call cannot be continued
+	var response openAIResponse
+	tokenUsage := &models.TokenUsage{Model: model}
+	for {
+		response = openAIResponse{}
+		attempts, err := c.RetryPolicy.Do(ctx, func() error {
+			return postJSON(ctx, c.HTTPClient, "openai", c.BaseURL+"/chat/completions", header, request, &response)
+		})
+		tokenUsage.Attempts += attempts
+		if err != nil {
+			return "", nil, fmt.Errorf("openai API error after %d attempt(s): %w", attempts, err)
+		}
+		if len(response.Choices) == 0 {
+			return "", nil, fmt.Errorf("openai API returned no choices")
+		}
+
+		cached := response.Usage.PromptTokensDetails.CachedTokens
+		tokenUsage.InputTokens += response.Usage.PromptTokens - cached
+		tokenUsage.OutputTokens += response.Usage.CompletionTokens
+		tokenUsage.CacheReadInputTokens += cached
+		if response.Choices[0].FinishReason != "length" {
+			break
+		}
+		limit, ok := largerLimit(request.MaxTokens)
+		if !ok || tokenUsage.Continuations == maxContinuations {
+			return "", nil, fmt.Errorf("%w: cut off at %d tokens", ErrMaxTokens, request.MaxTokens)
+		}
+		request.MaxTokens = limit
+		tokenUsage.Continuations++
+	}
+
+	message := response.Choices[0].Message
+	output := message.Content
+	for _, call := range message.ToolCalls {
+		if call.Function.Name == function.Name {
+			output = call.Function.Arguments
+			break
+		}
+	}
+
+	tokenUsage.RawResponse = output
+	tokenUsage.StopReason = response.Choices[0].FinishReason
+	tokenUsage.TotalCost = models.CalculateUsageCost(*tokenUsage)
+	tokenUsage.Unpriced = !models.IsPriced(tokenUsage.Model)
+	tokenUsage.CacheSavings = models.CalculateCacheSavings(*tokenUsage)
+	return output, tokenUsage, nil
+}
@@ -0,0 +1,140 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pdf-viewer/backend/pdf"
+)
+
+func TestOpenAIClient_ClassifyDocument(t *testing.T) {
+	var request map[string]interface{}
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		auth = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &request)
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{
+			"choices": [{"message": {"role": "assistant", "content": null, "tool_calls": [{
+				"id": "call_1", "type": "function",
+				"function": {"name": "record_classification", "arguments": "{\"document_type\": \"invoice\", \"confidence\": 0.9, \"reasoning\": \"Has an invoice number\"}"}
+			}]}}],
+			"usage": {"prompt_tokens": 1200, "completion_tokens": 100, "prompt_tokens_details": {"cached_tokens": 200}}
+		}`)
+	}))
+	defer server.Close()
+
+	client := NewOpenAIClient(server.URL+"/v1/", "sk-test")
+	pdfData := pdf.GenerateTextPDF([]string{"INVOICE #1234", "Total due: $50"})
+
+	classification, prompt, usage, err := client.ClassifyDocument(context.Background(), pdfData, Options{Model: "gpt-4o-mini"})
+	if err != nil {
+		t.Fatalf("ClassifyDocument failed: %v", err)
+	}
+	if classification.DocumentType != "invoice" {
+		t.Errorf("Expected invoice, got '%s'", classification.DocumentType)
+	}
+	if prompt != BuildClassificationPrompt(nil) {
+		t.Error("Expected the classification prompt to be returned")
+	}
+	if auth != "Bearer sk-test" {
+		t.Errorf("Expected bearer auth, got '%s'", auth)
+	}
+
+	if request["model"] != "gpt-4o-mini" {
+		t.Errorf("Expected requested model, got %v", request["model"])
+	}
+	toolChoice, _ := request["tool_choice"].(map[string]interface{})
+	function, _ := toolChoice["function"].(map[string]interface{})
+	if function["name"] != ClassificationToolName {
+		t.Errorf("Expected forced classification function, got %v", request["tool_choice"])
+	}
+	messages, _ := request["messages"].([]interface{})
+	encoded, _ := json.Marshal(messages)
+	if !strings.Contains(string(encoded), "--- Page 2 ---") || !strings.Contains(string(encoded), "Total due: $50") {
+		t.Errorf("Expected page text in the message, got %s", encoded)
+	}
+
+	if usage.InputTokens != 1000 || usage.CacheReadInputTokens != 200 || usage.OutputTokens != 100 {
+		t.Errorf("Unexpected usage: %+v", usage)
+	}
+	// gpt-4o-mini: 1000 * 0.15 + 200 * 0.075 + 100 * 0.60 per million
+	if expected := (1000*0.15 + 200*0.075 + 100*0.60) / 1_000_000; usage.TotalCost < expected*0.999 || usage.TotalCost > expected*1.001 {
+		t.Errorf("Expected cost %f, got %f", expected, usage.TotalCost)
+	}
+}
+
+func TestOpenAIClient_ExtractDataFromContent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Some compatible servers ignore tools and answer in the message text
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{
+			"choices": [{"message": {"role": "assistant", "content": "`+"```json\\n{\\\"schema_used\\\": \\\"invoice\\\", \\\"data\\\": {\\\"total\\\": 50}, \\\"fields\\\": []}\\n```"+`"}}],
+			"usage": {"prompt_tokens": 10, "completion_tokens": 5}
+		}`)
+	}))
+	defer server.Close()
+
+	client := NewOpenAIClient(server.URL, "")
+	extraction, _, usage, err := client.ExtractData(context.Background(), pdf.GenerateTextPDF([]string{"Total: 50"}), "invoice", GetSchemaForDocumentType("invoice"), Options{})
+	if err != nil {
+		t.Fatalf("ExtractData failed: %v", err)
+	}
+	if extraction.Data["total"] != 50.0 {
+		t.Errorf("Expected total 50, got %v", extraction.Data["total"])
+	}
+	if usage.Model != DefaultOpenAIModel {
+		t.Errorf("Expected default model, got '%s'", usage.Model)
+	}
+}
+
+func TestOpenAIClient_RetriesServerErrors(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After-Ms", "1")
+			http.Error(w, `{"error": {"message": "rate limited"}}`, http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices": [{"message": {"content": "{\"document_type\": \"memo\", \"confidence\": 0.5, \"reasoning\": \"r\"}"}}], "usage": {}}`)
+	}))
+	defer server.Close()
+
+	client := NewOpenAIClient(server.URL, "")
+	_, _, usage, err := client.ClassifyDocument(context.Background(), pdf.GenerateTextPDF([]string{"Memo"}), Options{})
+	if err != nil {
+		t.Fatalf("ClassifyDocument failed: %v", err)
+	}
+	if usage.Attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", usage.Attempts)
+	}
+
+	client.RetryPolicy.MaxAttempts = 1
+	calls = 0
+	_, _, _, err = client.ClassifyDocument(context.Background(), pdf.GenerateTextPDF([]string{"Memo"}), Options{})
+	if err == nil || !IsRetryable(err) {
+		t.Errorf("Expected retryable error, got %v", err)
+	}
+}
+
+func TestOpenAIClient_ScannedPDFInTextMode(t *testing.T) {
+	client := NewOpenAIClient("http://127.0.0.1:0", "")
+	client.InputMode = InputText
+
+	_, _, _, err := client.ClassifyDocument(context.Background(), pdf.GenerateTextPDF([]string{""}), Options{})
+	if err == nil || !strings.Contains(err.Error(), "no text or images") {
+		t.Errorf("Expected error for PDF without text, got %v", err)
+	}
+}
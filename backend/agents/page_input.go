@@ -0,0 +1,100 @@
+package agents
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pdf-viewer/backend/pdf"
+)
+
+// InputMode selects what providers without native PDF input receive
+type InputMode string
+
+const (
+	InputText   InputMode = "text"   // The text layer of every page
+	InputImages InputMode = "images" // The images embedded in every page
+	InputAuto   InputMode = "auto"   // Text, plus images for pages without text
+)
+
+// ParseInputMode converts a configuration value to an InputMode, defaulting to InputAuto
+func ParseInputMode(s string) (InputMode, error) {
+	switch mode := InputMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return InputAuto, nil
+	case InputText, InputImages, InputAuto:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown PDF input mode: %s", s)
+}
+
+// PageInput is the content of one page for providers without native PDF input
+type PageInput struct {
+	Number int
+	Text   string
+	Images []pdf.Image
+}
+
+// LoadPageInputs reads the text and images of each page of a PDF according
+// to mode. It fails if nothing usable can be sent, such as a scan in text mode.
+func LoadPageInputs(pdfData []byte, mode InputMode) ([]PageInput, error) {
+	doc, err := pdf.Open(pdfData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF: %w", err)
+	}
+
+	pages := make([]PageInput, 0, doc.NumPages())
+	empty := true
+	for _, page := range doc.Pages() {
+		input := PageInput{Number: page.Number}
+		if mode != InputImages {
+			if input.Text, err = doc.PageText(page); err != nil {
+				return nil, fmt.Errorf("failed to read PDF text: %w", err)
+			}
+		}
+		if mode == InputImages || (mode == InputAuto && input.Text == "") {
+			input.Images = doc.PageImages(page)
+		}
+		empty = empty && input.Text == "" && len(input.Images) == 0
+		pages = append(pages, input)
+	}
+
+	if empty {
+		return nil, fmt.Errorf("PDF has no text or images usable in %s input mode", mode)
+	}
+	return pages, nil
+}
+
+// FormatPageText lays out the text of each page under a page marker so the
+// model can report 1-indexed page numbers. Pages sent as images are noted.
+func FormatPageText(pages []PageInput) string {
+	var b strings.Builder
+	b.WriteString("The document's content follows, page by page.\n")
+	image := 0
+	writePages(&b, pages, &image)
+	return b.String()
+}
+
+// writePages writes each page under its marker, continuing the numbering of
+// attached images from *image
+func writePages(b *strings.Builder, pages []PageInput, image *int) {
+	for _, page := range pages {
+		fmt.Fprintf(b, "\n--- Page %d ---\n", page.Number)
+		if page.Text != "" {
+			b.WriteString(page.Text)
+			b.WriteByte('\n')
+		}
+		for range page.Images {
+			*image++
+			fmt.Fprintf(b, "[Attached image %d is from this page]\n", *image)
+		}
+	}
+}
+
+// pageImages returns the images of all pages in order
+func pageImages(pages []PageInput) []pdf.Image {
+	var images []pdf.Image
+	for _, page := range pages {
+		images = append(images, page.Images...)
+	}
+	return images
+}
@@ -0,0 +1,383 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/pdf"
+)
+
+// StepPII is the agent type of PII detection calls
+const StepPII = "pii"
+
+// PIIToolName is the tool PII findings are recorded with
+const PIIToolName = "record_pii"
+
+// ErrNoTextLayer is returned for documents whose text cannot be read, such
+// as scans
+var ErrNoTextLayer = errors.New("document has no readable text layer")
+
+// RedactionOptions selects the detection passes of a redaction
+type RedactionOptions struct {
+	// Agent also asks the model for PII the detectors cannot recognize,
+	// such as names and home addresses
+	Agent bool
+}
+
+// PIIResult is a model's answer to a PII detection call
+type PIIResult struct {
+	Findings []PIIFinding `json:"findings"`
+}
+
+// PIIFinding is a piece of PII quoted by the model
+type PIIFinding struct {
+	Type       string `json:"type"`
+	Text       string `json:"text"`        // Exactly as written in the document
+	PageNumber int    `json:"page_number"` // 1-indexed
+}
+
+// piiPattern is a regular expression detector. Matches are kept only if
+// valid accepts them; a checksum validator marks its matches as such.
+type piiPattern struct {
+	piiType  string
+	pattern  *regexp.Regexp
+	group    int // Submatch holding the PII; 0 for the whole match
+	valid    func(string) bool
+	checksum bool
+}
+
+var piiPatterns = []piiPattern{
+	{piiType: models.PIISSN, pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), valid: validSSN},
+	{piiType: models.PIISSN, pattern: regexp.MustCompile(`(?i)\b(?:SSN|social security(?: number| no\.?)?)\s*[:#]?\s*(\d{9}|\d{3} \d{2} \d{4})\b`), group: 1, valid: validSSN},
+	{piiType: models.PIICreditCard, pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), valid: luhn, checksum: true},
+	{piiType: models.PIIIBAN, pattern: regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?\b`), valid: validIBAN, checksum: true},
+	{piiType: models.PIIRoutingNumber, pattern: regexp.MustCompile(`(?i)\b(?:routing|ABA|RTN)(?: number| no\.?| #)?\s*[:#]?\s*(\d{9})\b`), group: 1, valid: validRoutingNumber, checksum: true},
+	{piiType: models.PIIAccountNumber, pattern: regexp.MustCompile(`(?i)\b(?:account|acct)\.?(?: number| no\.?| #)?\s*[:#]?\s*(\d[\d -]{4,22}\d)\b`), group: 1, valid: func(s string) bool { return len(digits(s)) >= 6 }},
+	{piiType: models.PIIEmail, pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+	{piiType: models.PIIPhone, pattern: regexp.MustCompile(`(?:\+1[ .-]?)?(?:\(\d{3}\) ?|\b\d{3}[ .-])\d{3}[ .-]\d{4}\b`)},
+	{piiType: models.PIIPhone, pattern: regexp.MustCompile(`\+\d{1,3}(?:[ .-]?\(?\d{1,4}\)?)(?:[ .-]?\d{2,4}){2,4}\b`)},
+	{piiType: models.PIIDateOfBirth, pattern: regexp.MustCompile(`(?i)\b(?:date of birth|birth date|DOB|born(?: on)?)\s*:?\s*(\d{1,2}[/.-]\d{1,2}[/.-]\d{2,4}|\d{4}-\d{2}-\d{2}|[a-z]+ \d{1,2},? \d{4}|\d{1,2} [a-z]+ \d{4})`), group: 1},
+	{piiType: models.PIIAddress, pattern: regexp.MustCompile(`\b\d{1,6}(?: [A-Z][A-Za-z0-9.'-]*){1,4} (?:Street|St|Avenue|Ave|Road|Rd|Boulevard|Blvd|Lane|Ln|Drive|Dr|Court|Ct|Way|Place|Pl|Terrace|Circle)\b\.?(?:,? (?:Apt|Suite|Unit|#) ?[A-Za-z0-9-]+)?`)},
+}
+
+// detectorRank orders detectors when two find the same span
+var detectorRank = map[string]int{models.DetectorChecksum: 2, models.DetectorRegex: 1, models.DetectorAgent: 0}
+
+// DetectPII finds PII in page texts with the regular expression and
+// checksum detectors. Spans contained in another span are dropped.
+func DetectPII(pages []string) []models.PIISpan {
+	var spans []models.PIISpan
+	for i, text := range pages {
+		for _, p := range piiPatterns {
+			for _, m := range p.pattern.FindAllStringSubmatchIndex(text, -1) {
+				start, end := m[2*p.group], m[2*p.group+1]
+				if start < 0 || (p.valid != nil && !p.valid(text[start:end])) {
+					continue
+				}
+				detector := models.DetectorRegex
+				if p.checksum {
+					detector = models.DetectorChecksum
+				}
+				spans = append(spans, newPIISpan(p.piiType, text, i+1, start, end, detector))
+			}
+		}
+	}
+	return mergePIISpans(spans)
+}
+
+// LocatePII finds the model's findings in the page texts. A finding is
+// searched on its page first, then on every page; whitespace may differ
+// from the text layer. Findings that cannot be found are dropped.
+func LocatePII(pages []string, findings []PIIFinding) []models.PIISpan {
+	var spans []models.PIISpan
+	for _, finding := range findings {
+		words := strings.Fields(finding.Text)
+		if len(words) == 0 {
+			continue
+		}
+		for i, word := range words {
+			words[i] = regexp.QuoteMeta(word)
+		}
+		pattern := regexp.MustCompile(strings.Join(words, `\s+`))
+
+		search := []int{finding.PageNumber}
+		if finding.PageNumber < 1 || finding.PageNumber > len(pages) || !pattern.MatchString(pages[finding.PageNumber-1]) {
+			search = search[:0]
+			for n := 1; n <= len(pages); n++ {
+				search = append(search, n)
+			}
+		}
+		for _, n := range search {
+			for _, m := range pattern.FindAllStringIndex(pages[n-1], -1) {
+				spans = append(spans, newPIISpan(finding.Type, pages[n-1], n, m[0], m[1], models.DetectorAgent))
+			}
+		}
+	}
+	return spans
+}
+
+func newPIISpan(piiType, text string, page, start, end int, detector string) models.PIISpan {
+	return models.PIISpan{Type: piiType, Masked: maskPII(text[start:end]), PageNumber: page, Start: start, End: end, Detector: detector}
+}
+
+// mergePIISpans sorts spans by position and drops those within a kept span
+// of the page. Of two spans of the same text, the more reliable detector's
+// is kept.
+func mergePIISpans(spans []models.PIISpan) []models.PIISpan {
+	sort.SliceStable(spans, func(i, j int) bool {
+		a, b := spans[i], spans[j]
+		if a.PageNumber != b.PageNumber {
+			return a.PageNumber < b.PageNumber
+		}
+		if a.Start != b.Start {
+			return a.Start < b.Start
+		}
+		if a.End != b.End {
+			return a.End > b.End
+		}
+		return detectorRank[a.Detector] > detectorRank[b.Detector]
+	})
+
+	var merged []models.PIISpan
+	for _, span := range spans {
+		contained := false
+		for i := len(merged) - 1; i >= 0 && merged[i].PageNumber == span.PageNumber; i-- {
+			if merged[i].Start <= span.Start && span.End <= merged[i].End {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			merged = append(merged, span)
+		}
+	}
+	return merged
+}
+
+// RedactPII finds the PII of a PDF and builds a copy with it removed. The
+// agent pass, if requested, is returned as a step. Documents without a
+// text layer cannot be redacted and return ErrNoTextLayer.
+func RedactPII(ctx context.Context, client Client, pdfData []byte, redaction RedactionOptions, opts Options) ([]byte, *models.RedactionReport, []ExtractionStep, error) {
+	pages, err := pdf.ExtractPageTexts(pdfData)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: %v", ErrNoTextLayer, err)
+	}
+	if strings.TrimSpace(strings.Join(pages, "")) == "" {
+		return nil, nil, nil, ErrNoTextLayer
+	}
+
+	spans := DetectPII(pages)
+	var steps []ExtractionStep
+	if redaction.Agent {
+		result, prompt, tokenUsage, err := client.FindPII(ctx, pdfData, opts)
+		// The step goes to the prompt history, which must not keep the PII
+		// the model quoted
+		if tokenUsage != nil {
+			tokenUsage.RawResponse, tokenUsage.Thinking = "", ""
+		}
+		step := ExtractionStep{AgentType: StepPII, Prompt: prompt, TokenUsage: tokenUsage}
+		if err != nil {
+			return nil, nil, append(steps, step), err
+		}
+		step.Response = marshalStep(maskFindings(result))
+		steps = append(steps, step)
+		spans = mergePIISpans(append(spans, LocatePII(pages, result.Findings)...))
+	}
+
+	redactions := make([]pdf.Redaction, len(spans))
+	for i, span := range spans {
+		redactions[i] = pdf.Redaction{PageNumber: span.PageNumber, Start: span.Start, End: span.End}
+	}
+	redacted, removed, err := pdf.Redact(pdfData, redactions)
+	if err != nil {
+		return nil, nil, steps, fmt.Errorf("failed to redact PDF: %w", err)
+	}
+
+	report := &models.RedactionReport{Spans: spans, Counts: map[string]int{}, AgentPass: redaction.Agent}
+	for i := range report.Spans {
+		report.Spans[i].Redacted = removed[i]
+		report.Counts[report.Spans[i].Type]++
+		if !removed[i] {
+			report.Unredacted++
+		}
+	}
+	if report.Spans == nil {
+		report.Spans = []models.PIISpan{}
+	}
+	return redacted, report, steps, nil
+}
+
+// maskFindings returns a copy of a PII result with the quoted text masked
+func maskFindings(result *PIIResult) *PIIResult {
+	masked := &PIIResult{Findings: make([]PIIFinding, len(result.Findings))}
+	for i, finding := range result.Findings {
+		finding.Text = maskPII(finding.Text)
+		masked.Findings[i] = finding
+	}
+	return masked
+}
+
+// maskPII masks the letters and digits of a value, keeping the last four of
+// long values and the first of short ones so reviewers can tell spans apart
+func maskPII(value string) string {
+	runes := []rune(value)
+	var positions []int
+	for i, r := range runes {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			positions = append(positions, i)
+		}
+	}
+	keep := map[int]bool{}
+	switch {
+	case len(positions) >= 8:
+		for _, i := range positions[len(positions)-4:] {
+			keep[i] = true
+		}
+	case len(positions) > 1:
+		keep[positions[0]] = true
+	}
+	for _, i := range positions {
+		if !keep[i] {
+			runes[i] = '*'
+		}
+	}
+	return string(runes)
+}
+
+func digits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// validSSN rejects numbers the Social Security Administration never issues
+func validSSN(s string) bool {
+	d := digits(s)
+	if len(d) != 9 {
+		return false
+	}
+	area, group, serial := d[:3], d[3:5], d[5:]
+	return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+}
+
+// luhn checks the check digit of payment card numbers
+func luhn(s string) bool {
+	d := digits(s)
+	if len(d) < 13 || len(d) > 19 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < len(d); i++ {
+		n := int(d[len(d)-1-i] - '0')
+		if i%2 == 1 {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+	}
+	return sum%10 == 0
+}
+
+// validIBAN checks the ISO 13616 mod-97 check digits
+func validIBAN(s string) bool {
+	iban := strings.ReplaceAll(s, " ", "")
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	rearranged := iban[4:] + iban[:4]
+	remainder := 0
+	for _, r := range rearranged {
+		switch {
+		case r >= '0' && r <= '9':
+			remainder = (remainder*10 + int(r-'0')) % 97
+		case r >= 'A' && r <= 'Z':
+			remainder = (remainder*100 + int(r-'A') + 10) % 97
+		default:
+			return false
+		}
+	}
+	return remainder == 1
+}
+
+// validRoutingNumber checks the check digit of ABA routing numbers
+func validRoutingNumber(s string) bool {
+	d := digits(s)
+	if len(d) != 9 {
+		return false
+	}
+	weights := [9]int{3, 7, 1, 3, 7, 1, 3, 7, 1}
+	sum := 0
+	for i := range d {
+		sum += int(d[i]-'0') * weights[i]
+	}
+	return sum%10 == 0 && d != "000000000"
+}
+
+// PIIInputSchema returns the JSON schema of a PIIResult
+func PIIInputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"findings": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"type": map[string]interface{}{
+							"type": "string",
+							"enum": []string{models.PIIPersonName, models.PIIAddress, models.PIIDateOfBirth, models.PIIPhone, models.PIIEmail, models.PIISSN, models.PIIAccountNumber},
+						},
+						"text":        map[string]interface{}{"type": "string", "description": "The PII exactly as written in the document"},
+						"page_number": map[string]interface{}{"type": "integer", "description": "1-indexed page number"},
+					},
+					"required": []string{"type", "text", "page_number"},
+				},
+			},
+		},
+		"required": []string{"findings"},
+	}
+}
+
+const piiToolDescription = "Record the personal information found in the PDF document."
+
+// BuildPIITool creates the tool whose input is a PIIResult
+func BuildPIITool() anthropic.ToolParam {
+	return newTool(PIIToolName, piiToolDescription, PIIInputSchema())
+}
+
+// BuildPIIPrompt creates the prompt asking the model for PII in a document
+func BuildPIIPrompt() string {
+	return `Find the personal information in this PDF document that identifies or
+locates a private individual, so it can be redacted:
+
+- person_name: names of private individuals, such as an applicant or account holder
+- address: home or mailing addresses of individuals, each line as written
+- date_of_birth: dates of birth
+- phone, email: personal phone numbers and email addresses
+- ssn: social security and other national identification numbers
+- account_number: bank, card, policy and customer account numbers
+
+Quote each occurrence exactly as written, with the page it is on. Do not
+include names and addresses of companies, public officials acting in office,
+or other information that is not personal.
+
+Return a JSON object with this structure:
+{
+  "findings": [
+    {"type": "person_name", "text": "Jane Roe", "page_number": 1}
+  ]
+}`
+}
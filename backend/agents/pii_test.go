@@ -0,0 +1,122 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/pdf"
+)
+
+func TestDetectPII(t *testing.T) {
+	page := strings.Join([]string{
+		"Jane Roe, 42 Elm Street, Springfield",
+		"SSN: 123-45-6789 DOB: 04/12/1980",
+		"jane.roe@example.com (555) 867-5309",
+		"Card 4111 1111 1111 1111 IBAN DE89 3704 0044 0532 0130 00",
+		"Routing number 021000021 Account No. 0012345678",
+		"Invoice 4111 1111 1111 1112 Order 000-12-3456",
+	}, "\n")
+
+	found := map[string][]string{}
+	for _, span := range DetectPII([]string{page}) {
+		found[span.Type] = append(found[span.Type], page[span.Start:span.End])
+	}
+	expected := map[string]string{
+		models.PIIAddress:       "42 Elm Street",
+		models.PIISSN:           "123-45-6789",
+		models.PIIDateOfBirth:   "04/12/1980",
+		models.PIIEmail:         "jane.roe@example.com",
+		models.PIIPhone:         "(555) 867-5309",
+		models.PIICreditCard:    "4111 1111 1111 1111",
+		models.PIIIBAN:          "DE89 3704 0044 0532 0130 00",
+		models.PIIRoutingNumber: "021000021",
+		models.PIIAccountNumber: "0012345678",
+	}
+	for piiType, text := range expected {
+		if len(found[piiType]) != 1 || found[piiType][0] != text {
+			t.Errorf("Expected %s %q, got %q", piiType, text, found[piiType])
+		}
+	}
+	if len(found) != len(expected) {
+		t.Errorf("Expected only the valid numbers to be found, got %v", found)
+	}
+}
+
+func TestMaskPII(t *testing.T) {
+	tests := map[string]string{
+		"123-45-6789": "***-**-6789",
+		"Jane Roe":    "J*** ***",
+		"42":          "4*",
+	}
+	for value, expected := range tests {
+		if got := maskPII(value); got != expected {
+			t.Errorf("maskPII(%q): expected %q, got %q", value, expected, got)
+		}
+	}
+}
+
+func TestLocatePII(t *testing.T) {
+	pages := []string{"Applicant: Jane\nRoe", "Reference: Jane Roe"}
+	spans := LocatePII(pages, []PIIFinding{
+		{Type: models.PIIPersonName, Text: "Jane Roe", PageNumber: 1},
+		{Type: models.PIIPersonName, Text: "Jane Roe", PageNumber: 3}, // Wrong page: searched everywhere
+		{Type: models.PIIAddress, Text: "1 Main St", PageNumber: 1},   // Not in the text
+	})
+	if len(spans) != 3 {
+		t.Fatalf("Expected 3 spans, got %+v", spans)
+	}
+	if spans[0].PageNumber != 1 || pages[0][spans[0].Start:spans[0].End] != "Jane\nRoe" || spans[0].Detector != models.DetectorAgent {
+		t.Errorf("Expected the name across a line break, got %+v", spans[0])
+	}
+}
+
+func TestRedactPII(t *testing.T) {
+	data := pdf.GenerateTextPDF([]string{"Applicant Jane Roe\nSSN 123-45-6789", "Contact jane@example.com"})
+	client := &MockClient{
+		PIIFunc: func(ctx context.Context, pdfData []byte, opts Options) (*PIIResult, string, *models.TokenUsage, error) {
+			return &PIIResult{Findings: []PIIFinding{
+				{Type: models.PIIPersonName, Text: "Jane Roe", PageNumber: 1},
+				{Type: models.PIISSN, Text: "123-45-6789", PageNumber: 1},
+			}}, "pii prompt", &models.TokenUsage{Model: "model", RawResponse: `{"findings": [{"text": "Jane Roe"}]}`}, nil
+		},
+	}
+
+	redacted, report, steps, err := RedactPII(context.Background(), client, data, RedactionOptions{Agent: true}, Options{})
+	if err != nil {
+		t.Fatalf("Failed to redact: %v", err)
+	}
+	if len(steps) != 1 || steps[0].AgentType != StepPII {
+		t.Fatalf("Expected the agent pass step, got %+v", steps)
+	}
+	if strings.Contains(steps[0].Response, "Jane Roe") || strings.Contains(steps[0].Response, "123-45-6789") || steps[0].TokenUsage.RawResponse != "" {
+		t.Errorf("Expected the step to keep only masked PII, got %s", steps[0].Response)
+	}
+	if len(report.Spans) != 3 || report.Unredacted != 0 || !report.AgentPass {
+		t.Errorf("Expected 3 redacted spans, got %+v", report)
+	}
+	if report.Counts[models.PIISSN] != 1 {
+		t.Errorf("Expected the SSN found by both passes to count once, got %v", report.Counts)
+	}
+	for _, span := range report.Spans {
+		if span.Type == models.PIISSN && span.Detector != models.DetectorRegex {
+			t.Errorf("Expected the detector's span to be kept over the agent's, got %+v", span)
+		}
+	}
+
+	texts, _ := pdf.ExtractPageTexts(redacted)
+	for _, value := range []string{"Jane Roe", "123-45-6789", "jane@example.com"} {
+		if strings.Contains(strings.Join(texts, "\n"), value) {
+			t.Errorf("Expected %q to be removed, got %q", value, texts)
+		}
+	}
+	if !strings.HasPrefix(texts[0], "Applicant") {
+		t.Errorf("Expected the rest of the text to stay, got %q", texts[0])
+	}
+
+	if _, _, _, err := RedactPII(context.Background(), client, pdf.GenerateTextPDF([]string{""}), RedactionOptions{}, Options{}); !errors.Is(err, ErrNoTextLayer) {
+		t.Error("Expected an error for a document without a text layer")
+	}
+}
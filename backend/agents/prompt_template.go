@@ -0,0 +1,113 @@
+package agents
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/pdf-viewer/backend/models"
+)
+
+// PromptData holds the variables a prompt template can use
+type PromptData struct {
+	DocumentType     string                // Type being extracted; empty for classification
+	Schema           string                // Extraction schema; empty for classification
+	DocumentTypes    []models.DocumentType // Classification taxonomy
+	DocumentTypeList string                // DocumentTypes formatted as in the built-in classification prompt
+}
+
+// NewPromptData returns the template variables of a classification or
+// extraction call
+func NewPromptData(documentType, schema string, types []models.DocumentType) PromptData {
+	if len(types) == 0 {
+		types = DefaultDocumentTypes()
+	}
+	return PromptData{
+		DocumentType:     documentType,
+		Schema:           schema,
+		DocumentTypes:    types,
+		DocumentTypeList: FormatDocumentTypes(types),
+	}
+}
+
+// promptTemplateFuncs are the functions available to prompt templates
+var promptTemplateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// RenderPromptTemplate executes a prompt template against data. Unknown
+// variables are an error rather than rendering as "<no value>".
+func RenderPromptTemplate(source string, data PromptData) (string, error) {
+	tmpl, err := template.New("prompt").Funcs(promptTemplateFuncs).Option("missingkey=error").Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("invalid prompt template: %w", err)
+	}
+	var prompt strings.Builder
+	if err := tmpl.Execute(&prompt, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt template: %w", err)
+	}
+	return prompt.String(), nil
+}
+
+// promptTemplateID matches the IDs a prompt template can be saved under
+var promptTemplateID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// ValidatePromptTemplate checks a template before it is saved by rendering
+// it against sample data of its agent type
+func ValidatePromptTemplate(promptTemplate models.PromptTemplate) error {
+	if !promptTemplateID.MatchString(promptTemplate.ID) {
+		return fmt.Errorf("invalid prompt template id '%s': use up to 64 letters, digits, '.', '-' or '_'", promptTemplate.ID)
+	}
+	if promptTemplate.Weight < 0 {
+		return fmt.Errorf("weight must not be negative")
+	}
+
+	var data PromptData
+	switch promptTemplate.AgentType {
+	case StepClassification:
+		data = NewPromptData("", "", nil)
+	case StepExtraction:
+		data = NewPromptData("invoice", GetSchemaForDocumentType("invoice"), nil)
+	default:
+		return fmt.Errorf("unsupported agent type '%s': use %s or %s", promptTemplate.AgentType, StepClassification, StepExtraction)
+	}
+
+	prompt, err := RenderPromptTemplate(promptTemplate.Template, data)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(prompt) == "" {
+		return fmt.Errorf("prompt template renders an empty prompt")
+	}
+	return nil
+}
+
+// SelectPromptTemplate picks one of the template versions with probability
+// proportional to its weight, for r uniform in [0, 1). It returns nil when
+// no version has a positive weight, meaning the built-in prompt is used.
+func SelectPromptTemplate(templates []*models.PromptTemplate, r float64) *models.PromptTemplate {
+	total := 0
+	for _, promptTemplate := range templates {
+		total += max(promptTemplate.Weight, 0)
+	}
+	if total == 0 {
+		return nil
+	}
+
+	target := r * float64(total)
+	var last *models.PromptTemplate
+	for _, promptTemplate := range templates {
+		if promptTemplate.Weight <= 0 {
+			continue
+		}
+		last = promptTemplate
+		target -= float64(promptTemplate.Weight)
+		if target < 0 {
+			return promptTemplate
+		}
+	}
+	return last
+}
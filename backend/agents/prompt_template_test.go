@@ -0,0 +1,81 @@
+package agents
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pdf-viewer/backend/models"
+)
+
+func TestRenderPromptTemplate(t *testing.T) {
+	data := NewPromptData("invoice", `{"type": "object"}`, testTaxonomy())
+
+	prompt, err := RenderPromptTemplate("Extract a {{upper .DocumentType}} using {{.Schema}}", data)
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	if prompt != `Extract a INVOICE using {"type": "object"}` {
+		t.Errorf("Unexpected prompt: %s", prompt)
+	}
+
+	prompt, err = RenderPromptTemplate("Pick one of:\n{{.DocumentTypeList}}", data)
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	if !strings.Contains(prompt, "- W-9 (a kind of form)") {
+		t.Errorf("Expected the taxonomy in the prompt, got %s", prompt)
+	}
+
+	if _, err := RenderPromptTemplate("{{.Pages}}", data); err == nil {
+		t.Error("Expected an unknown variable to be an error")
+	}
+	if _, err := RenderPromptTemplate("{{.DocumentType", data); err == nil {
+		t.Error("Expected a parse error")
+	}
+}
+
+func TestValidatePromptTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template models.PromptTemplate
+		wantErr  string
+	}{
+		{"classification", models.PromptTemplate{ID: "brief", AgentType: "classification", Template: "Classify as one of {{range .DocumentTypes}}{{.Name}} {{end}}"}, ""},
+		{"extraction", models.PromptTemplate{ID: "concise.v2", AgentType: "extraction", Template: "Extract {{.DocumentType}}: {{.Schema}}"}, ""},
+		{"bad id", models.PromptTemplate{ID: "a/b", AgentType: "extraction", Template: "Extract"}, "invalid prompt template id"},
+		{"agent type", models.PromptTemplate{ID: "x", AgentType: "repair", Template: "Fix"}, "unsupported agent type"},
+		{"unknown variable", models.PromptTemplate{ID: "x", AgentType: "classification", Template: "{{.Schema.Fields}}"}, "render"},
+		{"empty", models.PromptTemplate{ID: "x", AgentType: "extraction", Template: "{{/* nothing */}}"}, "empty prompt"},
+		{"negative weight", models.PromptTemplate{ID: "x", AgentType: "extraction", Template: "Extract", Weight: -1}, "negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePromptTemplate(tt.template)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestSelectPromptTemplate_SplitsByWeight(t *testing.T) {
+	a := &models.PromptTemplate{ID: "a", Version: 1, Weight: 1}
+	off := &models.PromptTemplate{ID: "a", Version: 2, Weight: 0}
+	b := &models.PromptTemplate{ID: "b", Version: 1, Weight: 3}
+	templates := []*models.PromptTemplate{a, off, b}
+
+	counts := map[*models.PromptTemplate]int{}
+	for i := 0; i < 100; i++ {
+		counts[SelectPromptTemplate(templates, float64(i)/100)]++
+	}
+	if counts[a] != 25 || counts[b] != 75 || counts[off] != 0 {
+		t.Errorf("Expected a 25/75 split, got a=%d b=%d off=%d", counts[a], counts[b], counts[off])
+	}
+
+	if SelectPromptTemplate([]*models.PromptTemplate{off}, 0.5) != nil {
+		t.Error("Expected no template when every weight is 0")
+	}
+}
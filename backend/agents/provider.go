@@ -0,0 +1,122 @@
+package agents
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Provider names accepted by LLM_PROVIDER
+const (
+	ProviderAnthropic = "anthropic"
+	ProviderOpenAI    = "openai"
+	ProviderOllama    = "ollama"
+)
+
+// Provider describes an LLM backend that can serve the agents
+type Provider struct {
+	DefaultModel string // Used when neither the request nor the configuration picks a model
+	// ServesModel reports whether the provider can run a model; nil means
+	// any model
+	ServesModel func(model string) bool
+	// NewClient creates a client configured from the environment that sends
+	// its requests with httpClient, or the default HTTP client if it is nil
+	NewClient func(httpClient *http.Client) (Client, error)
+}
+
+var (
+	providers = map[string]Provider{
+		ProviderAnthropic: {
+			DefaultModel: DefaultModel,
+			ServesModel:  isClaudeModel,
+			NewClient:    NewClaudeClientFromEnv,
+		},
+		ProviderOpenAI: {
+			DefaultModel: DefaultOpenAIModel,
+			// OpenAI-compatible servers host models under any name
+			ServesModel: func(model string) bool { return !isClaudeModel(model) },
+			NewClient:   NewOpenAIClientFromEnv,
+		},
+		ProviderOllama: {
+			DefaultModel: DefaultOllamaModel,
+			ServesModel:  func(model string) bool { return !isClaudeModel(model) && !isOpenAIModel(model) },
+			NewClient:    NewOllamaClientFromEnv,
+		},
+	}
+	providersMu sync.RWMutex
+)
+
+// openAIModelPrefixes start the IDs of the models only OpenAI hosts
+var openAIModelPrefixes = []string{"gpt-3.5", "gpt-4", "gpt-5", "o1", "o3", "o4"}
+
+func isClaudeModel(model string) bool {
+	return strings.HasPrefix(model, "claude-")
+}
+
+func isOpenAIModel(model string) bool {
+	for _, prefix := range openAIModelPrefixes {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Serves reports whether the provider can run a model
+func (p Provider) Serves(model string) bool {
+	return p.ServesModel == nil || p.ServesModel(model)
+}
+
+// RegisterProvider adds or replaces a provider
+func RegisterProvider(name string, provider Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[name] = provider
+}
+
+// GetProvider returns the named provider. An empty name is the Anthropic provider.
+func GetProvider(name string) (Provider, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = ProviderAnthropic
+	}
+
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	provider, ok := providers[name]
+	if !ok {
+		return Provider{}, fmt.Errorf("unknown LLM provider '%s' (available: %s)", name, strings.Join(providerNames(), ", "))
+	}
+	return provider, nil
+}
+
+// providerNames returns the registered provider names, sorted. The caller holds providersMu.
+func providerNames() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewClientFromEnv creates the client of the provider named by LLM_PROVIDER.
+// With AGENT_CASSETTE its requests go through a cassette, which records the
+// provider's responses or replays them offline (see NewCassetteFromEnv).
+func NewClientFromEnv() (Client, error) {
+	provider, err := GetProvider(os.Getenv("LLM_PROVIDER"))
+	if err != nil {
+		return nil, err
+	}
+	cassette, err := NewCassetteFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if cassette == nil {
+		return provider.NewClient(nil)
+	}
+	return provider.NewClient(cassette.HTTPClient())
+}
@@ -0,0 +1,107 @@
+package agents
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGetProvider(t *testing.T) {
+	for name, expected := range map[string]string{
+		"":          DefaultModel,
+		"anthropic": DefaultModel,
+		"OpenAI":    DefaultOpenAIModel,
+		"ollama":    DefaultOllamaModel,
+	} {
+		provider, err := GetProvider(name)
+		if err != nil {
+			t.Errorf("GetProvider(%q) returned error: %v", name, err)
+			continue
+		}
+		if provider.DefaultModel != expected {
+			t.Errorf("GetProvider(%q) default model = %s, expected %s", name, provider.DefaultModel, expected)
+		}
+	}
+
+	if _, err := GetProvider("bogus"); err == nil {
+		t.Error("Expected error for unknown provider")
+	}
+}
+
+func TestProvider_Serves(t *testing.T) {
+	tests := []struct {
+		provider string
+		model    string
+		serves   bool
+	}{
+		{"anthropic", "claude-haiku-4-5", true},
+		{"anthropic", "gpt-4.1", false},
+		{"openai", "gpt-4.1", true},
+		{"openai", "qwen2.5-72b-instruct", true},
+		{"openai", "claude-haiku-4-5", false},
+		{"ollama", "llama3.2", true},
+		{"ollama", "gpt-oss:20b", true},
+		{"ollama", "gpt-4o", false},
+		{"ollama", "claude-haiku-4-5", false},
+	}
+	for _, tc := range tests {
+		provider, _ := GetProvider(tc.provider)
+		if provider.Serves(tc.model) != tc.serves {
+			t.Errorf("Expected %s serving %s to be %v", tc.provider, tc.model, tc.serves)
+		}
+	}
+}
+
+func TestNewClientFromEnv(t *testing.T) {
+	tests := []struct {
+		provider string
+		check    func(Client) bool
+	}{
+		{"", func(c Client) bool { _, ok := c.(*ClaudeClient); return ok }},
+		{"openai", func(c Client) bool { _, ok := c.(*OpenAIClient); return ok }},
+		{"ollama", func(c Client) bool { _, ok := c.(*OllamaClient); return ok }},
+	}
+
+	for _, tc := range tests {
+		t.Setenv("LLM_PROVIDER", tc.provider)
+		client, err := NewClientFromEnv()
+		if err != nil {
+			t.Errorf("NewClientFromEnv with %q returned error: %v", tc.provider, err)
+			continue
+		}
+		if !tc.check(client) {
+			t.Errorf("NewClientFromEnv with %q returned %T", tc.provider, client)
+		}
+	}
+
+	t.Setenv("LLM_PROVIDER", "openai")
+	t.Setenv("PDF_INPUT_MODE", "pixels")
+	if _, err := NewClientFromEnv(); err == nil {
+		t.Error("Expected error for invalid PDF_INPUT_MODE")
+	}
+}
+
+func TestRegisterProvider(t *testing.T) {
+	RegisterProvider("mock", Provider{
+		DefaultModel: "mock-model",
+		NewClient:    func(*http.Client) (Client, error) { return &MockClient{}, nil },
+	})
+
+	t.Setenv("LLM_PROVIDER", "mock")
+	client, err := NewClientFromEnv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := client.(*MockClient); !ok {
+		t.Errorf("Expected MockClient, got %T", client)
+	}
+
+	t.Setenv("DEFAULT_MODEL", "")
+	t.Setenv("ALLOWED_MODELS", "")
+	config := LoadModelConfigFromEnv()
+	if model, _ := config.Resolve("classification", ""); model != "mock-model" {
+		t.Errorf("Expected provider default model, got '%s'", model)
+	}
+	if !config.IsAllowed("mock-model") {
+		t.Error("Expected provider default model to be allowed")
+	}
+}
@@ -0,0 +1,88 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pdf-viewer/backend/models"
+)
+
+// DefaultMaxRepairRounds bounds the repair prompts sent for one extraction
+const DefaultMaxRepairRounds = 2
+
+// Agent types of the steps recorded while classifying and extracting
+const (
+	StepClassification = "classification"
+	StepExtraction     = "extraction"
+	StepValidation     = "validation"
+	StepRepair         = "extraction_repair"
+	StepVerification   = "verification"
+)
+
+// ExtractionStep is one model call or validation performed while extracting
+type ExtractionStep struct {
+	AgentType  string
+	Prompt     string
+	Response   string
+	TokenUsage *models.TokenUsage // nil for validation steps
+	StartPage  int                // Page range of the chunk the step ran on; 0 for the whole document
+	EndPage    int
+	Error      string // Why a billed call failed, if it did
+}
+
+// ExtractAndValidate extracts data from a PDF, validates it against the
+// schema and, while it is invalid, asks the model to repair it for at most
+// maxRepairRounds rounds. The returned extraction carries the final
+// validation report, and is marked Truncated if the response it was parsed
+// from was cut off. Every call and validation is returned as a step so the
+// caller can record it, including failed calls that were billed. An error
+// is returned only if the first extraction fails; a failed repair keeps the
+// last extraction and notes the error in the report.
+func ExtractAndValidate(ctx context.Context, client Client, pdfData []byte, documentType, schema string, opts Options, maxRepairRounds int) (*models.Extraction, []ExtractionStep, error) {
+	extraction, prompt, tokenUsage, err := client.ExtractData(ctx, pdfData, documentType, schema, opts)
+	if err != nil {
+		return nil, appendFailedStep(nil, StepExtraction, prompt, tokenUsage, err), err
+	}
+	extraction.Truncated = TruncationRepaired(tokenUsage)
+	steps := []ExtractionStep{{AgentType: StepExtraction, Prompt: prompt, Response: marshalStep(extraction), TokenUsage: tokenUsage}}
+
+	for round := 0; ; round++ {
+		report, err := NewValidationReport(extraction, schema)
+		if err != nil {
+			// The schema itself is unusable, so there is nothing to validate against
+			report = &models.ValidationReport{Valid: true, RepairError: err.Error()}
+		}
+		report.RepairRounds = round
+		steps = append(steps, ExtractionStep{AgentType: StepValidation, Response: marshalStep(report)})
+
+		if report.Valid || round >= maxRepairRounds {
+			extraction.Validation = report
+			return extraction, steps, nil
+		}
+
+		repaired, prompt, tokenUsage, err := client.RepairExtraction(ctx, pdfData, documentType, schema, extraction, report.Errors, opts)
+		if err != nil {
+			report.RepairError = err.Error()
+			extraction.Validation = report
+			return extraction, appendFailedStep(steps, StepRepair, prompt, tokenUsage, err), nil
+		}
+		repaired.Truncated = TruncationRepaired(tokenUsage)
+		steps = append(steps, ExtractionStep{AgentType: StepRepair, Prompt: prompt, Response: marshalStep(repaired), TokenUsage: tokenUsage})
+		extraction = repaired
+	}
+}
+
+// appendFailedStep records a model call that failed after it was billed,
+// such as one whose response could not be parsed. Calls that failed without
+// usage cost nothing and are not recorded.
+func appendFailedStep(steps []ExtractionStep, agentType, prompt string, tokenUsage *models.TokenUsage, err error) []ExtractionStep {
+	if tokenUsage == nil {
+		return steps
+	}
+	return append(steps, ExtractionStep{AgentType: agentType, Prompt: prompt, TokenUsage: tokenUsage, Error: err.Error()})
+}
+
+func marshalStep(v interface{}) string {
+	b, _ := json.MarshalIndent(v, "", "  ")
+	return string(b)
+}
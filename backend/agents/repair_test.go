@@ -0,0 +1,146 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pdf-viewer/backend/models"
+)
+
+func invalidInvoiceExtraction(ctx context.Context, pdfData []byte, documentType string, schema string, opts Options) (*models.Extraction, string, *models.TokenUsage, error) {
+	return &models.Extraction{
+		SchemaUsed: documentType,
+		Data:       map[string]interface{}{"total": "$100.00"},
+	}, "extract prompt", &models.TokenUsage{Model: "test-model", InputTokens: 100}, nil
+}
+
+func TestExtractAndValidate_ValidOnFirstTry(t *testing.T) {
+	client := NewMockClient()
+
+	extraction, steps, err := ExtractAndValidate(context.Background(), client, []byte("%PDF"), "invoice", GetSchemaForDocumentType("invoice"), Options{}, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if extraction.Validation == nil || !extraction.Validation.Valid {
+		t.Errorf("Expected valid report, got %+v", extraction.Validation)
+	}
+	if len(steps) != 2 || steps[0].AgentType != StepExtraction || steps[1].AgentType != StepValidation {
+		t.Errorf("Expected extraction and validation steps, got %+v", steps)
+	}
+}
+
+func TestExtractAndValidate_RepairsInvalidData(t *testing.T) {
+	var repairErrors []models.ValidationError
+	client := &MockClient{
+		ExtractFunc: invalidInvoiceExtraction,
+		RepairFunc: func(ctx context.Context, pdfData []byte, documentType string, schema string, previous *models.Extraction, validationErrors []models.ValidationError, opts Options) (*models.Extraction, string, *models.TokenUsage, error) {
+			repairErrors = validationErrors
+			return &models.Extraction{
+				SchemaUsed: documentType,
+				Data:       map[string]interface{}{"total": 100.0},
+			}, "repair prompt", &models.TokenUsage{Model: "test-model", InputTokens: 200}, nil
+		},
+	}
+
+	extraction, steps, err := ExtractAndValidate(context.Background(), client, []byte("%PDF"), "invoice", GetSchemaForDocumentType("invoice"), Options{}, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if extraction.Data["total"] != 100.0 {
+		t.Errorf("Expected repaired data, got %v", extraction.Data)
+	}
+	if !extraction.Validation.Valid || extraction.Validation.RepairRounds != 1 {
+		t.Errorf("Expected valid report after 1 repair round, got %+v", extraction.Validation)
+	}
+	if len(repairErrors) != 1 || repairErrors[0].Path != "$.total" {
+		t.Errorf("Expected repair to receive validation errors, got %v", repairErrors)
+	}
+
+	expected := []string{StepExtraction, StepValidation, StepRepair, StepValidation}
+	if len(steps) != len(expected) {
+		t.Fatalf("Expected %d steps, got %d", len(expected), len(steps))
+	}
+	for i, agentType := range expected {
+		if steps[i].AgentType != agentType {
+			t.Errorf("Step %d: expected %s, got %s", i, agentType, steps[i].AgentType)
+		}
+	}
+	if steps[1].TokenUsage != nil {
+		t.Error("Validation steps should not carry token usage")
+	}
+}
+
+func TestExtractAndValidate_StopsAfterMaxRounds(t *testing.T) {
+	repairs := 0
+	client := &MockClient{
+		ExtractFunc: invalidInvoiceExtraction,
+		RepairFunc: func(ctx context.Context, pdfData []byte, documentType string, schema string, previous *models.Extraction, validationErrors []models.ValidationError, opts Options) (*models.Extraction, string, *models.TokenUsage, error) {
+			repairs++
+			return previous, "repair prompt", &models.TokenUsage{}, nil
+		},
+	}
+
+	extraction, _, err := ExtractAndValidate(context.Background(), client, []byte("%PDF"), "invoice", GetSchemaForDocumentType("invoice"), Options{}, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if repairs != 2 {
+		t.Errorf("Expected 2 repair rounds, got %d", repairs)
+	}
+	if extraction.Validation.Valid || extraction.Validation.RepairRounds != 2 {
+		t.Errorf("Expected invalid report after 2 rounds, got %+v", extraction.Validation)
+	}
+}
+
+func TestExtractAndValidate_RepairFailureKeepsExtraction(t *testing.T) {
+	client := &MockClient{
+		ExtractFunc: invalidInvoiceExtraction,
+		RepairFunc: func(ctx context.Context, pdfData []byte, documentType string, schema string, previous *models.Extraction, validationErrors []models.ValidationError, opts Options) (*models.Extraction, string, *models.TokenUsage, error) {
+			return nil, "repair prompt", &models.TokenUsage{Model: "test-model", OutputTokens: 50}, errors.New("failed to parse response")
+		},
+	}
+
+	extraction, steps, err := ExtractAndValidate(context.Background(), client, []byte("%PDF"), "invoice", GetSchemaForDocumentType("invoice"), Options{}, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if extraction.Validation.RepairError != "failed to parse response" {
+		t.Errorf("Expected repair error in report, got %+v", extraction.Validation)
+	}
+	if last := steps[len(steps)-1]; last.AgentType != StepRepair || last.TokenUsage == nil || last.Error != "failed to parse response" {
+		t.Errorf("Expected the billed repair call to be a step, got %+v", last)
+	}
+}
+
+func TestExtractAndValidate_ExtractionError(t *testing.T) {
+	client := &MockClient{
+		ExtractFunc: func(ctx context.Context, pdfData []byte, documentType string, schema string, opts Options) (*models.Extraction, string, *models.TokenUsage, error) {
+			return nil, "extract prompt", &models.TokenUsage{Model: "test-model", InputTokens: 1000}, errors.New("extraction failed")
+		},
+	}
+
+	_, steps, err := ExtractAndValidate(context.Background(), client, nil, "invoice", "{}", Options{}, 2)
+	if err == nil {
+		t.Error("Expected extraction error to be returned")
+	}
+	if len(steps) != 1 || steps[0].AgentType != StepExtraction || steps[0].TokenUsage.InputTokens != 1000 || steps[0].Error != "extraction failed" {
+		t.Errorf("Expected the billed extraction call to be a step, got %+v", steps)
+	}
+}
+
+func TestBuildRepairPrompt(t *testing.T) {
+	prompt := BuildRepairPrompt("invoice", `{"type": "object"}`, `{"data": {"total": "abc"}}`, []models.ValidationError{
+		{Path: "$.total", Message: "expected number, got string"},
+	})
+
+	for _, expected := range []string{"invoice", `{"data": {"total": "abc"}}`, "$.total: expected number, got string"} {
+		if !strings.Contains(prompt, expected) {
+			t.Errorf("Expected repair prompt to contain %q", expected)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// RetryPolicy controls how failed model API calls are retried
+type RetryPolicy struct {
+	MaxAttempts   int           // Total attempts including the first call
+	BaseDelay     time.Duration // Delay before the first retry
+	MaxDelay      time.Duration // Upper bound for the exponential backoff
+	MaxRetryAfter time.Duration // Longest retry-after the policy will honor
+	Jitter        float64       // Fraction of the delay randomized (0-1)
+}
+
+// DefaultRetryPolicy returns the retry policy used by the provider clients
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:   4,
+		BaseDelay:     500 * time.Millisecond,
+		MaxDelay:      8 * time.Second,
+		MaxRetryAfter: time.Minute,
+		Jitter:        0.25,
+	}
+}
+
+// Do calls fn until it succeeds, returns a fatal error, the attempts are
+// exhausted, or ctx is cancelled. It returns the number of attempts made.
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) (int, error) {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil {
+			return attempt, nil
+		}
+		if attempt >= maxAttempts || !IsRetryable(err) {
+			return attempt, err
+		}
+
+		delay, ok := RetryAfter(err)
+		if !ok || delay < 0 {
+			delay = p.backoff(attempt)
+		} else if p.MaxRetryAfter > 0 && delay > p.MaxRetryAfter {
+			// The API asked us to wait longer than we are willing to
+			return attempt, err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the jittered exponential delay before the given retry
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		delay -= delay * p.Jitter * rand.Float64()
+	}
+	return time.Duration(delay)
+}
+
+// IsRetryable reports whether an error from a model API is transient.
+// Rate limits (429), overloaded (529), timeouts and server errors are
+// retryable; invalid requests, authentication failures and cancelled
+// contexts are not.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	statusCode, header, ok := responseStatus(err)
+	if !ok {
+		// A request missing from a cassette stays missing
+		var miss *CassetteMissError
+		if errors.As(err, &miss) {
+			return false
+		}
+		// No response from the API, e.g. a dropped connection
+		var netErr net.Error
+		return errors.As(err, &netErr)
+	}
+
+	switch header.Get("x-should-retry") {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	switch statusCode {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+		return true
+	}
+	return statusCode >= http.StatusInternalServerError
+}
+
+// RetryAfter returns the delay requested by the API's retry-after-ms or
+// retry-after response headers, if any
+func RetryAfter(err error) (time.Duration, bool) {
+	_, header, ok := responseStatus(err)
+	if !ok {
+		return 0, false
+	}
+
+	if v := header.Get("Retry-After-Ms"); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Duration(ms * float64(time.Millisecond)), true
+		}
+	}
+	if v := header.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Duration(seconds * float64(time.Second)), true
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return time.Until(t), true
+		}
+	}
+	return 0, false
+}
+
+// responseStatus returns the HTTP status and headers of an API error from
+// the Claude SDK or one of the HTTP providers
+func responseStatus(err error) (int, http.Header, bool) {
+	var apiErr *anthropic.Error
+	if errors.As(err, &apiErr) {
+		header := http.Header{}
+		if apiErr.Response != nil {
+			header = apiErr.Response.Header
+		}
+		return apiErr.StatusCode, header, true
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		header := httpErr.Header
+		if header == nil {
+			header = http.Header{}
+		}
+		return httpErr.StatusCode, header, true
+	}
+	return 0, nil, false
+}
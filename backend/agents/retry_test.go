@@ -0,0 +1,247 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+const classificationMessageJSON = `{
+	"id": "msg_1",
+	"type": "message",
+	"role": "assistant",
+	"model": "claude-sonnet-4-5-20250929",
+	"stop_reason": "tool_use",
+	"content": [{
+		"type": "tool_use",
+		"id": "toolu_1",
+		"name": "record_classification",
+		"input": {"document_type": "invoice", "confidence": 0.9, "reasoning": "Invoice number present"}
+	}],
+	"usage": {"input_tokens": 100, "output_tokens": 10}
+}`
+
+// newFlakyServer returns a Messages API stand-in that answers with the given
+// statuses in order and succeeds once they are used up
+func newFlakyServer(t *testing.T, headers http.Header, statuses ...int) (*httptest.Server, *int32) {
+	t.Helper()
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(atomic.AddInt32(&calls, 1))
+		w.Header().Set("Content-Type", "application/json")
+		if call <= len(statuses) {
+			for key, values := range headers {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(statuses[call-1])
+			io.WriteString(w, `{"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}`)
+			return
+		}
+		io.WriteString(w, classificationMessageJSON)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func newTestClaudeClient(server *httptest.Server) *ClaudeClient {
+	client := NewClaudeClient(option.WithBaseURL(server.URL), option.WithAPIKey("test-key"))
+	client.RetryPolicy = RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    5 * time.Millisecond,
+		Jitter:      0.5,
+	}
+	return client
+}
+
+func TestClaudeClient_RetriesTransientErrors(t *testing.T) {
+	server, calls := newFlakyServer(t, nil, http.StatusTooManyRequests, 529)
+	client := newTestClaudeClient(server)
+
+	classification, _, tokenUsage, err := client.ClassifyDocument(context.Background(), []byte("%PDF"), Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if classification.DocumentType != "invoice" {
+		t.Errorf("Expected 'invoice', got '%s'", classification.DocumentType)
+	}
+	if tokenUsage.Attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", tokenUsage.Attempts)
+	}
+	if atomic.LoadInt32(calls) != 3 {
+		t.Errorf("Expected 3 calls to the API, got %d", *calls)
+	}
+}
+
+func TestClaudeClient_DoesNotRetryFatalErrors(t *testing.T) {
+	server, calls := newFlakyServer(t, nil, http.StatusBadRequest)
+	client := newTestClaudeClient(server)
+
+	_, _, _, err := client.ClassifyDocument(context.Background(), []byte("%PDF"), Options{})
+	if err == nil {
+		t.Fatal("Expected error for bad request")
+	}
+	if IsRetryable(err) {
+		t.Error("Bad request should not be retryable")
+	}
+	if atomic.LoadInt32(calls) != 1 {
+		t.Errorf("Expected a single call, got %d", *calls)
+	}
+}
+
+func TestClaudeClient_GivesUpAfterMaxAttempts(t *testing.T) {
+	server, calls := newFlakyServer(t, nil, 529, 529, 529, 529)
+	client := newTestClaudeClient(server)
+
+	_, _, _, err := client.ClassifyDocument(context.Background(), []byte("%PDF"), Options{})
+	if err == nil {
+		t.Fatal("Expected error after exhausting retries")
+	}
+	if !IsRetryable(err) {
+		t.Error("Overloaded error should remain retryable")
+	}
+	if atomic.LoadInt32(calls) != 3 {
+		t.Errorf("Expected 3 calls, got %d", *calls)
+	}
+}
+
+func TestClaudeClient_HonorsRetryAfter(t *testing.T) {
+	headers := http.Header{"Retry-After-Ms": []string{"50"}}
+	server, _ := newFlakyServer(t, headers, http.StatusTooManyRequests)
+	client := newTestClaudeClient(server)
+
+	start := time.Now()
+	_, _, tokenUsage, err := client.ClassifyDocument(context.Background(), []byte("%PDF"), Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected to wait for retry-after, returned after %v", elapsed)
+	}
+	if tokenUsage.Attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", tokenUsage.Attempts)
+	}
+}
+
+func TestClaudeClient_StopsWhenContextCancelled(t *testing.T) {
+	headers := http.Header{"Retry-After": []string{"30"}}
+	server, calls := newFlakyServer(t, headers, http.StatusTooManyRequests, http.StatusTooManyRequests)
+	client := newTestClaudeClient(server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, _, err := client.ClassifyDocument(ctx, []byte("%PDF"), Options{})
+	if err == nil {
+		t.Fatal("Expected error when context is cancelled")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Retry should stop on cancellation, took %v", elapsed)
+	}
+	if atomic.LoadInt32(calls) != 1 {
+		t.Errorf("Expected a single call, got %d", *calls)
+	}
+}
+
+func TestRetryPolicy_Do_ReturnsAttempts(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond}
+	transient := &netTimeoutError{}
+
+	calls := 0
+	attempts, err := policy.Do(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return transient
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Jitter: 0.25}
+
+	for attempt := 1; attempt <= 6; attempt++ {
+		delay := policy.backoff(attempt)
+		expected := 100 * time.Millisecond << (attempt - 1)
+		if expected > time.Second {
+			expected = time.Second
+		}
+		if delay > expected || delay < expected*3/4 {
+			t.Errorf("Attempt %d: delay %v outside [%v, %v]", attempt, delay, expected*3/4, expected)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"nil", nil, false},
+		{"plain error", errors.New("failed to parse"), false},
+		{"network error", &netTimeoutError{}, true},
+		{"cancelled", context.Canceled, false},
+		{"rate limited", apiError(http.StatusTooManyRequests, nil), true},
+		{"overloaded", apiError(529, nil), true},
+		{"server error", apiError(http.StatusInternalServerError, nil), true},
+		{"bad request", apiError(http.StatusBadRequest, nil), false},
+		{"unauthorized", apiError(http.StatusUnauthorized, nil), false},
+		{"should retry header", apiError(http.StatusBadRequest, http.Header{"X-Should-Retry": []string{"true"}}), true},
+	}
+
+	for _, tc := range tests {
+		if got := IsRetryable(tc.err); got != tc.expected {
+			t.Errorf("%s: IsRetryable = %v, expected %v", tc.name, got, tc.expected)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if d, ok := RetryAfter(apiError(429, http.Header{"Retry-After-Ms": []string{"1500"}})); !ok || d != 1500*time.Millisecond {
+		t.Errorf("Expected 1.5s from retry-after-ms, got %v (%v)", d, ok)
+	}
+	if d, ok := RetryAfter(apiError(429, http.Header{"Retry-After": []string{"2"}})); !ok || d != 2*time.Second {
+		t.Errorf("Expected 2s from retry-after, got %v (%v)", d, ok)
+	}
+	if _, ok := RetryAfter(apiError(429, nil)); ok {
+		t.Error("Expected no delay without headers")
+	}
+	if _, ok := RetryAfter(errors.New("other")); ok {
+		t.Error("Expected no delay for non-API errors")
+	}
+}
+
+func apiError(status int, header http.Header) error {
+	if header == nil {
+		header = http.Header{}
+	}
+	req, _ := http.NewRequest(http.MethodPost, "https://api.anthropic.com/v1/messages", nil)
+	return &anthropic.Error{
+		StatusCode: status,
+		Request:    req,
+		Response:   &http.Response{StatusCode: status, Header: header},
+	}
+}
+
+// netTimeoutError is a minimal net.Error standing in for a dropped connection
+type netTimeoutError struct{}
+
+func (e *netTimeoutError) Error() string   { return "connection reset" }
+func (e *netTimeoutError) Timeout() bool   { return true }
+func (e *netTimeoutError) Temporary() bool { return true }
@@ -1,9 +1,26 @@
 package agents
 
-// GetSchemaForDocumentType returns the JSON schema for extracting data from a document type
-func GetSchemaForDocumentType(documentType string) string {
-	schemas := map[string]string{
-		"invoice": `{
+// ClassificationSchema is the JSON schema describing a Classification. It is
+// used as the input_schema of the classification tool.
+const ClassificationSchema = `{
+  "type": "object",
+  "properties": {
+    "document_type": { "type": "string", "description": "The primary type of document, one of the document types listed in the prompt" },
+    "confidence": { "type": "number", "minimum": 0, "maximum": 1, "description": "Confidence in the classification between 0 and 1" },
+    "reasoning": { "type": "string", "description": "Detailed explanation of why the document was classified this way, including key indicators found" },
+    "subtypes": {
+      "type": "array",
+      "items": { "type": "string" },
+      "description": "More specific classifications if applicable"
+    },
+    "language": { "type": "string", "description": "Primary language of the document" }
+  },
+  "required": ["document_type", "confidence", "reasoning"]
+}`
+
+// documentSchemas holds the extraction schemas of the built-in document types
+var documentSchemas = map[string]string{
+	"invoice": `{
   "type": "object",
   "properties": {
     "invoice_number": { "type": "string", "description": "Invoice ID or number" },
@@ -46,7 +63,7 @@ func GetSchemaForDocumentType(documentType string) string {
     "payment_terms": { "type": "string" }
   }
 }`,
-		"contract": `{
+	"contract": `{
   "type": "object",
   "properties": {
     "contract_title": { "type": "string", "description": "Title or name of the contract" },
@@ -83,7 +100,7 @@ func GetSchemaForDocumentType(documentType string) string {
     "governing_law": { "type": "string" }
   }
 }`,
-		"resume": `{
+	"resume": `{
   "type": "object",
   "properties": {
     "name": { "type": "string" },
@@ -127,7 +144,7 @@ func GetSchemaForDocumentType(documentType string) string {
     }
   }
 }`,
-		"receipt": `{
+	"receipt": `{
   "type": "object",
   "properties": {
     "merchant_name": { "type": "string" },
@@ -152,7 +169,7 @@ func GetSchemaForDocumentType(documentType string) string {
     "currency": { "type": "string" }
   }
 }`,
-		"letter": `{
+	"letter": `{
   "type": "object",
   "properties": {
     "date": { "type": "string" },
@@ -179,9 +196,11 @@ func GetSchemaForDocumentType(documentType string) string {
     "letter_type": { "type": "string", "description": "e.g., 'formal', 'business', 'personal'" }
   }
 }`,
-	}
+}
 
-	if schema, ok := schemas[documentType]; ok {
+// GetSchemaForDocumentType returns the JSON schema for extracting data from a document type
+func GetSchemaForDocumentType(documentType string) string {
+	if schema, ok := documentSchemas[documentType]; ok {
 		return schema
 	}
 
@@ -212,19 +231,3 @@ func GetSchemaForDocumentType(documentType string) string {
   }
 }`
 }
-
-// GetAvailableDocumentTypes returns all supported document types
-func GetAvailableDocumentTypes() []string {
-	return []string{
-		"invoice",
-		"contract",
-		"resume",
-		"receipt",
-		"letter",
-		"report",
-		"form",
-		"statement",
-		"manual",
-		"other",
-	}
-}
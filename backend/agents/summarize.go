@@ -0,0 +1,130 @@
+package agents
+
+import (
+	"fmt"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// StepSummary is the agent type of summarization calls
+const StepSummary = "summary"
+
+// SummaryToolName is the tool a summary is recorded with
+const SummaryToolName = "record_summary"
+
+// Summary lengths
+const (
+	SummaryOneLine   = "one_line"
+	SummaryParagraph = "paragraph"
+	SummarySections  = "sections"
+)
+
+// Summary styles
+const (
+	SummaryProse   = "prose"
+	SummaryBullets = "bullets"
+)
+
+// SummaryOptions selects the length and style of a summary
+type SummaryOptions struct {
+	Length string // SummaryOneLine, SummaryParagraph or SummarySections; empty means SummaryParagraph
+	Style  string // SummaryProse or SummaryBullets; empty means SummaryProse
+}
+
+// summaryMaxTokens is the output token limit of each summary length
+var summaryMaxTokens = map[string]int{
+	SummaryOneLine:   256,
+	SummaryParagraph: 1024,
+	SummarySections:  extractionMaxTokens,
+}
+
+// summaryInstructions describes each summary length to the model
+var summaryInstructions = map[string]string{
+	SummaryOneLine:   "Write a single sentence of at most 30 words saying what the document is and its key point. Leave sections empty.",
+	SummaryParagraph: "Write one paragraph of 80 to 150 words covering the purpose of the document, the parties or subjects involved, and the key facts, figures and dates. Leave sections empty.",
+	SummarySections:  "Divide the document into its logical sections, following its own headings where it has them. For each section give a title, the first and last page it covers (1-indexed) and a summary of 1 to 4 sentences. Also write a short overview of the whole document as text.",
+}
+
+// styleInstructions describes each summary style to the model
+var styleInstructions = map[string]string{
+	SummaryProse:   "Write in plain prose.",
+	SummaryBullets: `Write each summary as a list of short bullet points, one per line, each starting with "- ".`,
+}
+
+// Normalize fills in the defaults and checks the length and style
+func (o SummaryOptions) Normalize() (SummaryOptions, error) {
+	if o.Length == "" {
+		o.Length = SummaryParagraph
+	}
+	if o.Style == "" {
+		o.Style = SummaryProse
+	}
+	if _, ok := summaryInstructions[o.Length]; !ok {
+		return o, fmt.Errorf("unknown summary length '%s' (available: %s, %s, %s)", o.Length, SummaryOneLine, SummaryParagraph, SummarySections)
+	}
+	if _, ok := styleInstructions[o.Style]; !ok {
+		return o, fmt.Errorf("unknown summary style '%s' (available: %s, %s)", o.Style, SummaryProse, SummaryBullets)
+	}
+	return o, nil
+}
+
+// maxTokens returns the output token limit of the summary length
+func (o SummaryOptions) maxTokens() int {
+	if limit, ok := summaryMaxTokens[o.Length]; ok {
+		return limit
+	}
+	return summaryMaxTokens[SummaryParagraph]
+}
+
+// SummaryInputSchema returns the JSON schema of a summary
+func SummaryInputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"text": map[string]interface{}{
+				"type":        "string",
+				"description": "The summary, or for section-by-section summaries an overview of the whole document",
+			},
+			"sections": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"title":      map[string]interface{}{"type": "string"},
+						"start_page": map[string]interface{}{"type": "integer", "description": "1-indexed first page of the section"},
+						"end_page":   map[string]interface{}{"type": "integer", "description": "1-indexed last page of the section"},
+						"summary":    map[string]interface{}{"type": "string"},
+					},
+					"required": []string{"title", "start_page", "end_page", "summary"},
+				},
+			},
+		},
+		"required": []string{"text"},
+	}
+}
+
+const summaryToolDescription = "Record the summary of the PDF document."
+
+// BuildSummaryTool creates the tool whose input is a Summary
+func BuildSummaryTool() anthropic.ToolParam {
+	return newTool(SummaryToolName, summaryToolDescription, SummaryInputSchema())
+}
+
+// BuildSummaryPrompt creates the prompt for summarizing a document. The
+// options must be normalized.
+func BuildSummaryPrompt(summary SummaryOptions) string {
+	return fmt.Sprintf(`Summarize this PDF document for a reviewer who has not read it.
+
+%s
+%s
+
+Only state what the document says; do not add opinions or outside information.
+
+Return a JSON object with this structure:
+{
+  "text": "the summary",
+  "sections": [
+    {"title": "section title", "start_page": 1, "end_page": 2, "summary": "section summary"}
+  ]
+}`, summaryInstructions[summary.Length], styleInstructions[summary.Style])
+}
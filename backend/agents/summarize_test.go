@@ -0,0 +1,83 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/pdf-viewer/backend/models"
+)
+
+func TestSummaryOptions_Normalize(t *testing.T) {
+	summary, err := SummaryOptions{}.Normalize()
+	if err != nil || summary.Length != SummaryParagraph || summary.Style != SummaryProse {
+		t.Errorf("Expected a prose paragraph by default, got %+v (%v)", summary, err)
+	}
+	if _, err := (SummaryOptions{Length: "page"}).Normalize(); err == nil || !strings.Contains(err.Error(), "unknown summary length") {
+		t.Errorf("Expected an unknown length error, got %v", err)
+	}
+	if _, err := (SummaryOptions{Style: "haiku"}).Normalize(); err == nil || !strings.Contains(err.Error(), "unknown summary style") {
+		t.Errorf("Expected an unknown style error, got %v", err)
+	}
+}
+
+func TestBuildSummaryPrompt(t *testing.T) {
+	prompt := BuildSummaryPrompt(SummaryOptions{Length: SummarySections, Style: SummaryBullets})
+	if !strings.Contains(prompt, "first and last page") || !strings.Contains(prompt, "bullet points") {
+		t.Errorf("Expected section and bullet instructions, got %s", prompt)
+	}
+}
+
+func TestParseResponse_Summary(t *testing.T) {
+	summary, _, err := parseResponse[models.Summary]("```json\n{\"text\": \"A lease.\", \"sections\": [{\"title\": \"Rent\", \"start_page\": 2, \"end_page\": 3, \"summary\": \"1,200 EUR monthly.\"}]}\n```", SummaryToolName)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if summary.Text != "A lease." || len(summary.Sections) != 1 || summary.Sections[0].EndPage != 3 {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+}
+
+func TestClaudeClient_SummarizeDocument(t *testing.T) {
+	var request map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &request)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{
+			"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5-20250929",
+			"content": [{"type": "tool_use", "id": "toolu_1", "name": "record_summary",
+				"input": {"text": "An invoice from Acme for 1,250 EUR."}}],
+			"usage": {"input_tokens": 1800, "output_tokens": 40}
+		}`)
+	}))
+	defer server.Close()
+
+	client := NewClaudeClient(option.WithBaseURL(server.URL), option.WithAPIKey("test-key"))
+	summary, prompt, tokenUsage, err := client.SummarizeDocument(context.Background(), []byte("%PDF-1.4"), SummaryOptions{Length: SummaryOneLine, Style: SummaryProse}, Options{})
+	if err != nil {
+		t.Fatalf("SummarizeDocument failed: %v", err)
+	}
+
+	choice := request["tool_choice"].(map[string]interface{})
+	if choice["type"] != "tool" || choice["name"] != SummaryToolName {
+		t.Errorf("Expected the summary tool to be forced, got %v", choice)
+	}
+	if request["max_tokens"] != float64(summaryMaxTokens[SummaryOneLine]) {
+		t.Errorf("Expected the one-line token limit, got %v", request["max_tokens"])
+	}
+	if !strings.Contains(prompt, "single sentence") {
+		t.Errorf("Expected the one-line instructions, got %s", prompt)
+	}
+	if summary.Text != "An invoice from Acme for 1,250 EUR." {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+	if tokenUsage.InputTokens != 1800 {
+		t.Errorf("Expected token usage from the response, got %+v", tokenUsage)
+	}
+}
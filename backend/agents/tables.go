@@ -0,0 +1,199 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/pdf-viewer/backend/models"
+)
+
+// StepTables is the agent type of table extraction calls
+const StepTables = "tables"
+
+// TablesToolName is the tool extracted tables are recorded with
+const TablesToolName = "record_tables"
+
+// tablesMaxTokens is the output token limit of a table extraction call,
+// which repeats every cell of every table in its page range
+const tablesMaxTokens = 16384
+
+// TablesInputSchema returns the JSON schema of a table extraction
+func TablesInputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"tables": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"page_number": map[string]interface{}{"type": "integer", "description": "1-indexed page the table starts on"},
+						"caption":     map[string]interface{}{"type": "string", "description": "The table's title or caption, if it has one"},
+						"header_rows": map[string]interface{}{"type": "integer", "description": "Number of leading rows that are column headers"},
+						"cells": map[string]interface{}{
+							"type":        "array",
+							"description": "Rows of cell texts, header rows first",
+							"items": map[string]interface{}{
+								"type":  "array",
+								"items": map[string]interface{}{"type": "string"},
+							},
+						},
+						"merged_cells": map[string]interface{}{
+							"type": "array",
+							"items": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"row":      map[string]interface{}{"type": "integer", "description": "0-indexed row of the top-left cell"},
+									"column":   map[string]interface{}{"type": "integer", "description": "0-indexed column of the top-left cell"},
+									"row_span": map[string]interface{}{"type": "integer"},
+									"col_span": map[string]interface{}{"type": "integer"},
+								},
+								"required": []string{"row", "column", "row_span", "col_span"},
+							},
+						},
+					},
+					"required": []string{"page_number", "header_rows", "cells"},
+				},
+			},
+		},
+		"required": []string{"tables"},
+	}
+}
+
+const tablesToolDescription = "Record every table in the PDF document."
+
+// BuildTablesTool creates the tool whose input is a TableExtraction
+func BuildTablesTool() anthropic.ToolParam {
+	return newTool(TablesToolName, tablesToolDescription, TablesInputSchema())
+}
+
+// BuildTablesPrompt creates the prompt for extracting the tables of a document
+func BuildTablesPrompt() string {
+	return `Extract every table in this PDF document, in reading order.
+
+For each table:
+- Give the 1-indexed page it starts on. A table continued on the next pages is one table.
+- Give its caption or title, if it has one.
+- Give every row of cells, including the header rows, as they appear. Every row must have one entry per column; use an empty string for empty cells.
+- Give the number of leading rows that are column headers.
+- For each cell spanning several rows or columns, give the row and column of its top-left cell (0-indexed) and its spans. Put the text in the top-left cell and leave the other cells it covers empty.
+
+Copy cell text exactly, including units and currency symbols. Do not treat lists, forms or page layout as tables.
+
+Return a JSON object with this structure:
+{
+  "tables": [
+    {
+      "page_number": 1,
+      "caption": "Table 1: Prices",
+      "header_rows": 1,
+      "cells": [["Item", "Price"], ["Widget", "$10.00"]],
+      "merged_cells": [{"row": 0, "column": 0, "row_span": 1, "col_span": 2}]
+    }
+  ]
+}`
+}
+
+// ExtractTables extracts the tables of a document page range by page range,
+// as the output repeats every cell, and normalizes their grids. A table
+// continued across the end of a range is returned as one table per range.
+// Every call is returned as a step, with its range when the document was
+// split; on error, the steps of the ranges that succeeded are returned.
+func ExtractTables(ctx context.Context, client Client, pdfData []byte, opts Options, chunkOpts ChunkOptions) (*models.TableExtraction, []ExtractionStep, error) {
+	chunks, err := SplitIntoChunks(pdfData, chunkOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var steps []ExtractionStep
+	extraction := &models.TableExtraction{}
+	for _, chunk := range chunks {
+		tables, prompt, tokenUsage, err := client.ExtractTables(ctx, chunk.PDFData, opts)
+		if err != nil {
+			if len(chunks) > 1 {
+				err = fmt.Errorf("pages %d-%d: %w", chunk.StartPage, chunk.EndPage, err)
+			}
+			return nil, steps, err
+		}
+		step := ExtractionStep{AgentType: StepTables, Prompt: prompt, Response: marshalStep(tables), TokenUsage: tokenUsage}
+		if len(chunks) > 1 {
+			step.StartPage, step.EndPage = chunk.StartPage, chunk.EndPage
+			for i := range tables.Tables {
+				table := &tables.Tables[i]
+				if table.PageNumber >= 1 && table.PageNumber <= chunk.EndPage-chunk.StartPage+1 {
+					table.PageNumber += chunk.StartPage - 1
+				} else {
+					table.PageNumber = 0
+				}
+			}
+		}
+		steps = append(steps, step)
+
+		extraction.Tables = append(extraction.Tables, tables.Tables...)
+		if tokenUsage != nil {
+			extraction.Model = tokenUsage.Model
+		}
+	}
+
+	extraction.Tables = NormalizeTables(extraction.Tables, pageCount(pdfData))
+	return extraction, steps, nil
+}
+
+// NormalizeTables numbers the tables and makes each grid rectangular. Empty
+// tables are dropped, merged cells are clipped to the grid and the cells
+// they cover cleared, and page numbers outside the document are cleared.
+func NormalizeTables(tables []models.Table, pages int) []models.Table {
+	normalized := make([]models.Table, 0, len(tables))
+	for _, table := range tables {
+		width := 0
+		for _, row := range table.Cells {
+			width = max(width, len(row))
+		}
+		if width == 0 {
+			continue
+		}
+		for i, row := range table.Cells {
+			for len(row) < width {
+				row = append(row, "")
+			}
+			for j := range row {
+				row[j] = strings.TrimSpace(row[j])
+			}
+			table.Cells[i] = row
+		}
+		height := len(table.Cells)
+
+		table.HeaderRows = min(max(table.HeaderRows, 0), height)
+		if table.PageNumber < 1 || table.PageNumber > pages {
+			table.PageNumber = 0
+		}
+		table.Caption = strings.TrimSpace(table.Caption)
+
+		var merged []models.MergedCell
+		for _, cell := range table.MergedCells {
+			if cell.Row < 0 || cell.Row >= height || cell.Column < 0 || cell.Column >= width {
+				continue
+			}
+			cell.RowSpan = min(max(cell.RowSpan, 1), height-cell.Row)
+			cell.ColSpan = min(max(cell.ColSpan, 1), width-cell.Column)
+			if cell.RowSpan == 1 && cell.ColSpan == 1 {
+				continue
+			}
+			for r := cell.Row; r < cell.Row+cell.RowSpan; r++ {
+				for c := cell.Column; c < cell.Column+cell.ColSpan; c++ {
+					if r != cell.Row || c != cell.Column {
+						table.Cells[r][c] = ""
+					}
+				}
+			}
+			merged = append(merged, cell)
+		}
+		table.MergedCells = merged
+
+		table.Index = len(normalized) + 1
+		normalized = append(normalized, table)
+	}
+	return normalized
+}
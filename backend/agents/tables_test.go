@@ -0,0 +1,118 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/pdf"
+)
+
+func TestParseResponse_Tables(t *testing.T) {
+	tables, _, err := parseResponse[models.TableExtraction]("```json\n{\"tables\": [{\"page_number\": 1, \"header_rows\": 1, \"cells\": [[\"Item\", \"Price\"], [\"Widget\", \"$10.00\"]]}]}\n```", TablesToolName)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if len(tables.Tables) != 1 || tables.Tables[0].Cells[1][1] != "$10.00" {
+		t.Errorf("Unexpected tables: %+v", tables)
+	}
+}
+
+func TestNormalizeTables(t *testing.T) {
+	tables := NormalizeTables([]models.Table{
+		{PageNumber: 9, Cells: nil},
+		{
+			PageNumber: 2,
+			HeaderRows: 5,
+			Cells:      [][]string{{"Quarter", "Revenue", ""}, {"Q1", " 100 ", "120"}, {"Q2"}},
+			MergedCells: []models.MergedCell{
+				{Row: 0, Column: 1, RowSpan: 1, ColSpan: 2},
+				{Row: 1, Column: 2, RowSpan: 4, ColSpan: 1}, // Clipped to the grid
+				{Row: 2, Column: 0, RowSpan: 1, ColSpan: 1}, // Not merged
+				{Row: 7, Column: 0, RowSpan: 2, ColSpan: 1}, // Outside the grid
+			},
+		},
+	}, 3)
+
+	if len(tables) != 1 {
+		t.Fatalf("Expected the empty table dropped, got %+v", tables)
+	}
+	table := tables[0]
+	if table.Index != 1 || table.PageNumber != 2 || table.HeaderRows != 3 {
+		t.Errorf("Expected the table numbered and header rows clamped, got %+v", table)
+	}
+	if len(table.Cells[2]) != 3 || table.Cells[1][1] != "100" {
+		t.Errorf("Expected a rectangular trimmed grid, got %q", table.Cells)
+	}
+	if len(table.MergedCells) != 2 || table.MergedCells[1].RowSpan != 2 || table.Cells[2][2] != "" {
+		t.Errorf("Expected valid merges clipped to the grid, got %+v", table.MergedCells)
+	}
+
+	csv, err := table.CSV()
+	if err != nil || string(csv) != "Quarter,Revenue,\nQ1,100,120\nQ2,,\n" {
+		t.Errorf("Unexpected CSV %q (%v)", csv, err)
+	}
+}
+
+func TestExtractTables(t *testing.T) {
+	tables, steps, err := ExtractTables(context.Background(), &MockClient{}, pdf.GenerateTextPDF([]string{"Item Amount"}), Options{}, DefaultChunkOptions())
+	if err != nil {
+		t.Fatalf("Failed to extract tables: %v", err)
+	}
+	if len(steps) != 1 || steps[0].AgentType != StepTables || steps[0].Response == "" || steps[0].StartPage != 0 {
+		t.Errorf("Expected the tables step, got %+v", steps)
+	}
+	if len(tables.Tables) != 1 || tables.Tables[0].Index != 1 || tables.Tables[0].PageNumber != 1 || tables.Model == "" {
+		t.Errorf("Unexpected tables: %+v", tables)
+	}
+}
+
+func TestExtractTables_ByPageRange(t *testing.T) {
+	var calls int
+	client := &MockClient{
+		TablesFunc: func(ctx context.Context, pdfData []byte, opts Options) (*models.TableExtraction, string, *models.TokenUsage, error) {
+			calls++
+			if calls == 3 {
+				return nil, "", nil, errors.New("overloaded")
+			}
+			// Page 9 is outside every range
+			return &models.TableExtraction{Tables: []models.Table{
+				{PageNumber: 2, Cells: [][]string{{fmt.Sprintf("Range %d", calls)}}},
+				{PageNumber: 9, Cells: [][]string{{"Stray"}}},
+			}}, "prompt", &models.TokenUsage{Model: "model"}, nil
+		},
+	}
+	pdfData := pdf.GenerateTextPDF([]string{"1", "2", "3", "4", "5"})
+
+	tables, steps, err := ExtractTables(context.Background(), client, pdfData, Options{}, ChunkOptions{MaxPages: 3})
+	if err != nil {
+		t.Fatalf("Failed to extract tables: %v", err)
+	}
+	if len(steps) != 2 || steps[1].StartPage != 4 || steps[1].EndPage != 5 {
+		t.Errorf("Expected a step per page range, got %+v", steps)
+	}
+	if len(tables.Tables) != 4 || tables.Tables[2].PageNumber != 5 || tables.Tables[2].Index != 3 || tables.Tables[3].PageNumber != 0 {
+		t.Errorf("Expected the tables of both ranges numbered in document pages, got %+v", tables.Tables)
+	}
+
+	_, steps, err = ExtractTables(context.Background(), client, pdfData, Options{}, ChunkOptions{MaxPages: 3})
+	if err == nil || err.Error() != "pages 1-3: overloaded" || len(steps) != 0 {
+		t.Errorf("Expected the failed range in the error, got %v with %d steps", err, len(steps))
+	}
+}
+
+func TestTableCSV_EscapesFormulas(t *testing.T) {
+	table := models.Table{Cells: [][]string{{"Item", "Formula"}, {"=HYPERLINK(\"http://evil\")", "-2+3"}, {"@SUM(A1)", "+A1"}, {"Plain", "a=b"}}}
+	csv, err := table.CSV()
+	if err != nil || string(csv) != "Item,Formula\n\"'=HYPERLINK(\"\"http://evil\"\")\",'-2+3\n'@SUM(A1),'+A1\nPlain,a=b\n" {
+		t.Errorf("Expected formula cells prefixed with a quote, got %q (%v)", csv, err)
+	}
+
+	amounts := models.Table{Cells: [][]string{{"-12.50", "+3%", "-$1,200.00", "-12,50 €", "- 7"}}}
+	csv, err = amounts.CSV()
+	if err != nil || string(csv) != "-12.50,+3%,\"-$1,200.00\",\"-12,50 €\",- 7\n" {
+		t.Errorf("Expected signed numbers kept as they are, got %q (%v)", csv, err)
+	}
+}
@@ -0,0 +1,228 @@
+package agents
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/pdf-viewer/backend/models"
+)
+
+// OtherDocumentType is the catch-all type unknown classifications are
+// remapped to
+const OtherDocumentType = "other"
+
+// DefaultDocumentTypes returns the built-in classification taxonomy
+func DefaultDocumentTypes() []models.DocumentType {
+	types := []models.DocumentType{
+		{Name: "invoice", Description: "A bill requesting payment for goods or services", Indicators: []string{"invoice number", "amount due", "payment terms"}},
+		{Name: "contract", Description: "A legal agreement between parties", Indicators: []string{"named parties", "terms and conditions", "signature blocks"}},
+		{Name: "resume", Description: "A summary of a person's work history and skills", Indicators: []string{"work experience", "education", "contact details"}},
+		{Name: "receipt", Description: "Proof of a completed payment", Indicators: []string{"amount paid", "payment method", "merchant name"}},
+		{Name: "letter", Description: "Correspondence addressed to a recipient", Indicators: []string{"salutation", "closing", "sender and recipient addresses"}},
+		{Name: "report", Description: "A structured account of findings or results", Indicators: []string{"sections", "findings", "conclusions"}},
+		{Name: "form", Description: "A document with fields to be filled in", Indicators: []string{"labeled fields", "checkboxes", "signature line"}},
+		{Name: "statement", Description: "A periodic account summary, such as from a bank", Indicators: []string{"statement period", "opening and closing balance", "transactions"}},
+		{Name: "manual", Description: "Instructions for using or maintaining something", Indicators: []string{"numbered steps", "table of contents", "warnings"}},
+		{Name: OtherDocumentType, Description: "Any document that fits none of the other types"},
+	}
+	for i := range types {
+		types[i].BuiltIn = true
+	}
+	return types
+}
+
+// GetAvailableDocumentTypes returns the names of the built-in document types
+func GetAvailableDocumentTypes() []string {
+	var names []string
+	for _, documentType := range DefaultDocumentTypes() {
+		names = append(names, documentType.Name)
+	}
+	return names
+}
+
+// MergeDocumentTypes returns the built-in document types followed by the
+// registered ones
+func MergeDocumentTypes(registered []*models.DocumentType) []models.DocumentType {
+	types := DefaultDocumentTypes()
+	for _, documentType := range registered {
+		types = append(types, *documentType)
+	}
+	return types
+}
+
+// normalizeDocumentType makes spellings of the same type compare equal, so
+// "Purchase Order", "purchase-order" and "purchase_order" match
+func normalizeDocumentType(documentType string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, documentType)
+}
+
+// FindDocumentType returns the type of the taxonomy whose name matches, in
+// any spelling
+func FindDocumentType(types []models.DocumentType, name string) (models.DocumentType, bool) {
+	normalized := normalizeDocumentType(name)
+	if normalized == "" {
+		return models.DocumentType{}, false
+	}
+	for _, documentType := range types {
+		if normalizeDocumentType(documentType.Name) == normalized {
+			return documentType, true
+		}
+	}
+	return models.DocumentType{}, false
+}
+
+// documentTypeName matches the names a document type can be registered under
+var documentTypeName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 _-]{0,63}$`)
+
+// ValidateDocumentType checks a document type before it is added to the
+// taxonomy: its name must be new in any spelling and its parent, if any,
+// must already be in the taxonomy
+func ValidateDocumentType(documentType models.DocumentType, types []models.DocumentType) error {
+	if !documentTypeName.MatchString(documentType.Name) {
+		return fmt.Errorf("invalid document type name '%s': use up to 64 letters, digits, spaces, '-' or '_'", documentType.Name)
+	}
+	if existing, ok := FindDocumentType(types, documentType.Name); ok && existing.Name != documentType.Name {
+		return fmt.Errorf("document type '%s' conflicts with '%s'", documentType.Name, existing.Name)
+	}
+	if documentType.Parent == "" {
+		return nil
+	}
+
+	parent, ok := FindDocumentType(types, documentType.Parent)
+	if !ok {
+		return fmt.Errorf("unknown parent document type '%s'", documentType.Parent)
+	}
+	// Walk up from the parent; reaching the type itself would make a cycle
+	for seen := 0; seen <= len(types); seen++ {
+		if parent.Name == documentType.Name {
+			return fmt.Errorf("document type '%s' cannot be its own ancestor", documentType.Name)
+		}
+		if parent, ok = FindDocumentType(types, parent.Parent); !ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("document type '%s' has a cyclic parent chain", documentType.Name)
+}
+
+// BuildClassificationPrompt creates the prompt for document classification
+// from the taxonomy. No types means DefaultDocumentTypes.
+func BuildClassificationPrompt(types []models.DocumentType) string {
+	return fmt.Sprintf(`Analyze this PDF document and classify it as one of the following document types:
+
+%s
+Return a JSON object with the following structure:
+{
+  "document_type": "string - the name of the matching document type above, exactly as listed; prefer the most specific type that fits",
+  "confidence": number between 0 and 1,
+  "reasoning": "string - detailed explanation of why you classified it this way, including key indicators you found",
+  "subtypes": ["array of more specific classifications if applicable"],
+  "language": "string - primary language of the document"
+}
+
+Be thorough in your reasoning - explain what specific elements led to your classification.`, FormatDocumentTypes(types))
+}
+
+// FormatDocumentTypes lists the taxonomy one type per line, with its parent,
+// description and indicators, and the catch-all type last. No types means
+// DefaultDocumentTypes.
+func FormatDocumentTypes(types []models.DocumentType) string {
+	if len(types) == 0 {
+		types = DefaultDocumentTypes()
+	}
+
+	var list strings.Builder
+	var other *models.DocumentType
+	for i, documentType := range types {
+		if documentType.Name == OtherDocumentType {
+			other = &types[i]
+			continue
+		}
+		writeDocumentType(&list, documentType)
+	}
+	if other != nil {
+		writeDocumentType(&list, *other)
+	}
+	return list.String()
+}
+
+func writeDocumentType(list *strings.Builder, documentType models.DocumentType) {
+	fmt.Fprintf(list, "- %s", documentType.Name)
+	if documentType.Parent != "" {
+		fmt.Fprintf(list, " (a kind of %s)", documentType.Parent)
+	}
+	if documentType.Description != "" {
+		fmt.Fprintf(list, ": %s", documentType.Description)
+	}
+	if len(documentType.Indicators) > 0 {
+		fmt.Fprintf(list, ". Indicators: %s", strings.Join(documentType.Indicators, "; "))
+	}
+	list.WriteByte('\n')
+}
+
+// UnknownDocumentTypeError is returned when a classification names a type
+// outside the taxonomy and it may not be remapped
+type UnknownDocumentTypeError struct {
+	DocumentType string
+}
+
+func (e *UnknownDocumentTypeError) Error() string {
+	return fmt.Sprintf("model returned document type '%s', which is not in the taxonomy", e.DocumentType)
+}
+
+// ApplyTaxonomy resolves a classification's document type against the
+// taxonomy. A type spelled differently from its registered name is renamed.
+// A type outside the taxonomy is replaced by the first registered subtype,
+// or else by OtherDocumentType, keeping the model's answer in UnmappedType.
+// In strict mode, or when there is nothing to remap to, an unknown type is
+// an UnknownDocumentTypeError.
+func ApplyTaxonomy(classification *models.Classification, types []models.DocumentType, strict bool) error {
+	if len(types) == 0 {
+		types = DefaultDocumentTypes()
+	}
+
+	if documentType, ok := FindDocumentType(types, classification.DocumentType); ok {
+		classification.DocumentType = documentType.Name
+		return nil
+	}
+	if strict {
+		return &UnknownDocumentTypeError{DocumentType: classification.DocumentType}
+	}
+
+	for _, subtype := range classification.Subtypes {
+		if documentType, ok := FindDocumentType(types, subtype); ok {
+			classification.UnmappedType = classification.DocumentType
+			classification.DocumentType = documentType.Name
+			return nil
+		}
+	}
+	if documentType, ok := FindDocumentType(types, OtherDocumentType); ok {
+		classification.UnmappedType = classification.DocumentType
+		classification.DocumentType = documentType.Name
+		return nil
+	}
+	return &UnknownDocumentTypeError{DocumentType: classification.DocumentType}
+}
+
+// SchemaForDocumentType returns the extraction schema of a document type.
+// Types without a schema of their own use their nearest ancestor's.
+func SchemaForDocumentType(documentType string, types []models.DocumentType) string {
+	name := documentType
+	for seen := 0; seen <= len(types); seen++ {
+		if schema, ok := documentSchemas[name]; ok {
+			return schema
+		}
+		registered, ok := FindDocumentType(types, name)
+		if !ok || registered.Parent == "" {
+			break
+		}
+		name = registered.Parent
+	}
+	return GetSchemaForDocumentType(documentType)
+}
@@ -0,0 +1,127 @@
+package agents
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pdf-viewer/backend/models"
+)
+
+func testTaxonomy() []models.DocumentType {
+	return MergeDocumentTypes([]*models.DocumentType{
+		{Name: "purchase_order", Description: "A buyer's order for goods", Indicators: []string{"PO number", "ship-to address"}, Parent: "form"},
+		{Name: "W-9", Description: "US taxpayer identification request", Parent: "form"},
+		{Name: "freight_invoice", Description: "An invoice for shipping", Parent: "invoice"},
+	})
+}
+
+func TestBuildClassificationPrompt_ListsTaxonomy(t *testing.T) {
+	prompt := BuildClassificationPrompt(testTaxonomy())
+
+	for _, want := range []string{
+		"- purchase_order (a kind of form): A buyer's order for goods. Indicators: PO number; ship-to address\n",
+		"- W-9 (a kind of form): US taxpayer identification request\n",
+		"- invoice: A bill requesting payment",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Expected prompt to contain %q", want)
+		}
+	}
+	if strings.Index(prompt, "- other:") < strings.Index(prompt, "- freight_invoice") {
+		t.Error("Expected the catch-all type to be listed last")
+	}
+	if BuildClassificationPrompt(nil) != BuildClassificationPrompt(DefaultDocumentTypes()) {
+		t.Error("Expected no types to mean the built-in taxonomy")
+	}
+}
+
+func TestApplyTaxonomy(t *testing.T) {
+	tests := []struct {
+		name         string
+		documentType string
+		subtypes     []string
+		strict       bool
+		wantType     string
+		wantUnmapped string
+		wantErr      bool
+	}{
+		{"registered", "purchase_order", nil, false, "purchase_order", "", false},
+		{"other spelling", "Purchase Order", nil, true, "purchase_order", "", false},
+		{"punctuation", "w9", nil, false, "W-9", "", false},
+		{"registered subtype", "shipping document", []string{"Freight Invoice"}, false, "freight_invoice", "shipping document", false},
+		{"unknown", "bill of lading", nil, false, "other", "bill of lading", false},
+		{"unknown strict", "bill of lading", nil, true, "bill of lading", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			classification := &models.Classification{DocumentType: tt.documentType, Subtypes: tt.subtypes}
+			err := ApplyTaxonomy(classification, testTaxonomy(), tt.strict)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if classification.DocumentType != tt.wantType || classification.UnmappedType != tt.wantUnmapped {
+				t.Errorf("Expected %s (unmapped %q), got %s (unmapped %q)", tt.wantType, tt.wantUnmapped, classification.DocumentType, classification.UnmappedType)
+			}
+		})
+	}
+}
+
+func TestApplyTaxonomy_RejectsWithoutOther(t *testing.T) {
+	types := []models.DocumentType{{Name: "invoice"}}
+	err := ApplyTaxonomy(&models.Classification{DocumentType: "letter"}, types, false)
+	if _, ok := err.(*UnknownDocumentTypeError); !ok {
+		t.Errorf("Expected UnknownDocumentTypeError, got %v", err)
+	}
+}
+
+func TestValidateDocumentType(t *testing.T) {
+	types := testTaxonomy()
+	tests := []struct {
+		name         string
+		documentType models.DocumentType
+		wantErr      string
+	}{
+		{"new type", models.DocumentType{Name: "bill_of_lading", Parent: "Form"}, ""},
+		{"update", models.DocumentType{Name: "purchase_order", Parent: "form"}, ""},
+		{"bad name", models.DocumentType{Name: "po/2"}, "invalid document type name"},
+		{"other spelling", models.DocumentType{Name: "Purchase Order"}, "conflicts with 'purchase_order'"},
+		{"unknown parent", models.DocumentType{Name: "bill_of_lading", Parent: "shipping"}, "unknown parent"},
+		{"own parent", models.DocumentType{Name: "purchase_order", Parent: "purchase_order"}, "own ancestor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateDocumentType(tt.documentType, types)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateDocumentType_RejectsCycle(t *testing.T) {
+	types := MergeDocumentTypes([]*models.DocumentType{
+		{Name: "a", Parent: "form"},
+		{Name: "b", Parent: "a"},
+	})
+	err := ValidateDocumentType(models.DocumentType{Name: "a", Parent: "b"}, types)
+	if err == nil || !strings.Contains(err.Error(), "own ancestor") {
+		t.Errorf("Expected a cycle error, got %v", err)
+	}
+}
+
+func TestSchemaForDocumentType_UsesParentSchema(t *testing.T) {
+	types := append(testTaxonomy(), models.DocumentType{Name: "ocean_freight_invoice", Parent: "freight_invoice"})
+
+	if got := SchemaForDocumentType("freight_invoice", types); got != GetSchemaForDocumentType("invoice") {
+		t.Error("Expected freight_invoice to use the invoice schema")
+	}
+	if got := SchemaForDocumentType("ocean_freight_invoice", types); got != GetSchemaForDocumentType("invoice") {
+		t.Error("Expected ocean_freight_invoice to use its grandparent's schema")
+	}
+	if got := SchemaForDocumentType("unregistered", types); got != GetSchemaForDocumentType("unregistered") {
+		t.Error("Expected an unknown type to use the default schema")
+	}
+}
@@ -0,0 +1,84 @@
+package agents
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// Limits of a request's extended thinking budget. The API requires at least
+// MinThinkingBudget tokens.
+const (
+	MinThinkingBudget = 1024
+	MaxThinkingBudget = 16000
+)
+
+// ValidateThinkingBudget checks a requested thinking budget; 0 disables thinking
+func ValidateThinkingBudget(budget int) error {
+	if budget != 0 && (budget < MinThinkingBudget || budget > MaxThinkingBudget) {
+		return fmt.Errorf("thinking_budget must be 0 or between %d and %d tokens", MinThinkingBudget, MaxThinkingBudget)
+	}
+	return nil
+}
+
+// toolInstruction asks the model to answer through the named tool
+const toolInstruction = "Record your answer by calling the %s tool."
+
+// withThinking enables extended thinking with the given token budget, or
+// returns params unchanged if it is not positive. The budget counts toward
+// max_tokens, so it is added to the output limit, up to maxRequestTokens.
+// Thinking cannot be combined with a forced tool choice, so the tool is
+// offered with tool_choice auto instead and the prompt asks for it.
+func withThinking(params anthropic.MessageNewParams, budget int) anthropic.MessageNewParams {
+	if budget <= 0 {
+		return params
+	}
+	params.Thinking = anthropic.ThinkingConfigParamOfEnabled(int64(budget))
+	params.MaxTokens = min(params.MaxTokens+int64(budget), maxRequestTokens)
+
+	forced := params.ToolChoice.OfTool
+	if forced == nil && params.ToolChoice.OfAny == nil {
+		return params
+	}
+	params.ToolChoice = anthropic.ToolChoiceUnionParam{OfAuto: &anthropic.ToolChoiceAutoParam{}}
+	if forced != nil && len(params.Messages) > 0 {
+		messages := append([]anthropic.MessageParam(nil), params.Messages...)
+		last := &messages[len(messages)-1]
+		last.Content = append(append([]anthropic.ContentBlockParamUnion(nil), last.Content...), anthropic.NewTextBlock(fmt.Sprintf(toolInstruction, forced.Name)))
+		params.Messages = messages
+	}
+	return params
+}
+
+// ExtractThinkingFromResponse joins the thinking blocks of a Claude message.
+// Redacted thinking, which is encrypted, is marked but not included.
+func ExtractThinkingFromResponse(content []anthropic.ContentBlockUnion) string {
+	var parts []string
+	for _, block := range content {
+		switch block.Type {
+		case "thinking":
+			parts = append(parts, block.Thinking)
+		case "redacted_thinking":
+			parts = append(parts, "[redacted thinking]")
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// estimateThinkingTokens estimates the output tokens a message spent
+// thinking. The API reports only the total, and the thinking text returned
+// may be a summary of what was billed, so the visible output (text and tool
+// calls, at about four characters a token) is subtracted from the total.
+func estimateThinkingTokens(message *anthropic.Message) int {
+	visible := 0
+	for _, block := range message.Content {
+		switch block.Type {
+		case "text":
+			visible += len(block.Text)
+		case "tool_use":
+			visible += len(block.Name) + len(block.Input)
+		}
+	}
+	return max(int(message.Usage.OutputTokens)-(visible+3)/4, 0)
+}
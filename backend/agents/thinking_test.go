@@ -0,0 +1,118 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+func TestValidateThinkingBudget(t *testing.T) {
+	for _, budget := range []int{0, MinThinkingBudget, 8000, MaxThinkingBudget} {
+		if err := ValidateThinkingBudget(budget); err != nil {
+			t.Errorf("Expected budget %d to be valid, got %v", budget, err)
+		}
+	}
+	for _, budget := range []int{-1, 100, MaxThinkingBudget + 1} {
+		if err := ValidateThinkingBudget(budget); err == nil {
+			t.Errorf("Expected budget %d to be rejected", budget)
+		}
+	}
+}
+
+func TestWithThinking_NoBudget(t *testing.T) {
+	params := forcedToolParams([]byte("%PDF-1.4"), "prompt", BuildTablesTool(), "claude-sonnet-4-5", 4096)
+
+	got := withThinking(params, 0)
+	if got.Thinking.OfEnabled != nil || got.MaxTokens != 4096 || got.ToolChoice.OfTool == nil {
+		t.Error("Expected params to be unchanged without a thinking budget")
+	}
+}
+
+func TestWithThinking_MaxBudgetWithinNonStreamingLimit(t *testing.T) {
+	params := forcedToolParams([]byte("%PDF-1.4"), "prompt", BuildTablesTool(), DefaultModel, tablesMaxTokens)
+
+	got := withThinking(params, MaxThinkingBudget)
+	if got.MaxTokens <= MaxThinkingBudget {
+		t.Errorf("Expected max_tokens above the budget, got %d", got.MaxTokens)
+	}
+	if _, err := anthropic.CalculateNonStreamingTimeout(int(got.MaxTokens), got.Model, nil); err != nil {
+		t.Errorf("Expected max_tokens %d to be allowed without streaming: %v", got.MaxTokens, err)
+	}
+}
+
+func TestWithThinking_ReplacesForcedToolChoice(t *testing.T) {
+	params := forcedToolParams([]byte("%PDF-1.4"), "prompt", BuildTablesTool(), "claude-sonnet-4-5", 4096)
+
+	got := withThinking(params, 2048)
+	if got.Thinking.OfEnabled == nil || got.Thinking.OfEnabled.BudgetTokens != 2048 {
+		t.Fatalf("Expected thinking enabled with 2048 tokens, got %+v", got.Thinking)
+	}
+	if got.MaxTokens != 4096+2048 {
+		t.Errorf("Expected max_tokens to include the budget, got %d", got.MaxTokens)
+	}
+	if got.ToolChoice.OfAuto == nil || got.ToolChoice.OfTool != nil {
+		t.Error("Expected tool_choice auto with thinking")
+	}
+
+	content := got.Messages[len(got.Messages)-1].Content
+	last := content[len(content)-1]
+	if last.OfText == nil || !strings.Contains(last.OfText.Text, TablesToolName) {
+		t.Error("Expected the prompt to ask for the tool")
+	}
+	if len(params.Messages[0].Content) != 2 {
+		t.Error("Expected the original params to be left unchanged")
+	}
+}
+
+func TestClaudeClient_KeepsThinking(t *testing.T) {
+	var request map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &request)
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{
+			"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5-20250929",
+			"content": [
+				{"type": "thinking", "thinking": "The header says INVOICE and there is a total due.", "signature": "sig"},
+				{"type": "redacted_thinking", "data": "encrypted"},
+				{"type": "tool_use", "id": "toolu_1", "name": "record_classification",
+					"input": {"document_type": "invoice", "confidence": 0.9, "reasoning": "Invoice"}}
+			],
+			"stop_reason": "tool_use",
+			"usage": {"input_tokens": 1000, "output_tokens": 2000}
+		}`)
+	}))
+	defer server.Close()
+
+	client := NewClaudeClient(option.WithBaseURL(server.URL), option.WithAPIKey("test-key"))
+	classification, _, usage, err := client.ClassifyDocument(context.Background(), []byte("%PDF-1.4"), Options{ThinkingBudget: 4000})
+	if err != nil {
+		t.Fatalf("Classification failed: %v", err)
+	}
+	if classification.DocumentType != "invoice" {
+		t.Errorf("Expected invoice, got %s", classification.DocumentType)
+	}
+
+	thinking, _ := request["thinking"].(map[string]interface{})
+	if thinking["type"] != "enabled" || thinking["budget_tokens"] != float64(4000) {
+		t.Errorf("Expected thinking enabled with 4000 tokens, got %v", request["thinking"])
+	}
+
+	if usage.Thinking != "The header says INVOICE and there is a total due.\n\n[redacted thinking]" {
+		t.Errorf("Unexpected thinking %q", usage.Thinking)
+	}
+	if usage.ThinkingTokens <= 0 || usage.ThinkingTokens >= usage.OutputTokens {
+		t.Errorf("Expected thinking tokens within the %d output tokens, got %d", usage.OutputTokens, usage.ThinkingTokens)
+	}
+	if usage.ThinkingCost <= 0 || usage.ThinkingCost >= usage.TotalCost {
+		t.Errorf("Expected thinking cost within the total cost %f, got %f", usage.TotalCost, usage.ThinkingCost)
+	}
+}
@@ -0,0 +1,171 @@
+package agents
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// Tool names used to force structured output from Claude
+const (
+	ClassificationToolName = "record_classification"
+	ExtractionToolName     = "record_extraction"
+)
+
+// ClassificationInputSchema returns the JSON schema of a classification
+func ClassificationInputSchema() (map[string]interface{}, error) {
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(ClassificationSchema), &schema); err != nil {
+		return nil, fmt.Errorf("invalid classification schema: %w", err)
+	}
+	return schema, nil
+}
+
+// ExtractionInputSchema returns the JSON schema of an extraction whose data
+// property is constrained by the given document schema
+func ExtractionInputSchema(schema string) (map[string]interface{}, error) {
+	var dataSchema map[string]interface{}
+	if err := json.Unmarshal([]byte(schema), &dataSchema); err != nil {
+		return nil, fmt.Errorf("invalid extraction schema: %w", err)
+	}
+
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"schema_used": map[string]interface{}{
+				"type":        "string",
+				"description": "The document type whose schema was used",
+			},
+			"data": dataSchema,
+			"fields": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name":        map[string]interface{}{"type": "string"},
+						"value":       map[string]interface{}{"description": "The extracted value"},
+						"source_text": map[string]interface{}{"type": "string", "description": "Exact text from the document"},
+						"page_number": map[string]interface{}{"type": "integer", "description": "1-indexed page number"},
+						"confidence":  map[string]interface{}{"type": "number", "minimum": 0, "maximum": 1},
+					},
+					"required": []string{"name", "value", "source_text", "page_number", "confidence"},
+				},
+			},
+		},
+		"required": []string{"schema_used", "data", "fields"},
+	}, nil
+}
+
+// Tool descriptions shared by every provider
+const classificationToolDescription = "Record the classification of the PDF document."
+
+func extractionToolDescription(documentType string) string {
+	return fmt.Sprintf("Record the data extracted from the %s document.", documentType)
+}
+
+// BuildClassificationTool creates the tool whose input is a Classification
+func BuildClassificationTool() (anthropic.ToolParam, error) {
+	schema, err := ClassificationInputSchema()
+	if err != nil {
+		return anthropic.ToolParam{}, err
+	}
+	return newTool(ClassificationToolName, classificationToolDescription, schema), nil
+}
+
+// BuildExtractionTool creates the tool whose input is an Extraction.
+// The data property is constrained by the given document schema.
+func BuildExtractionTool(documentType, schema string) (anthropic.ToolParam, error) {
+	input, err := ExtractionInputSchema(schema)
+	if err != nil {
+		return anthropic.ToolParam{}, err
+	}
+	return newTool(ExtractionToolName, extractionToolDescription(documentType), input), nil
+}
+
+// newTool converts a parsed JSON schema into a tool definition
+func newTool(name, description string, schema map[string]interface{}) anthropic.ToolParam {
+	inputSchema := anthropic.ToolInputSchemaParam{
+		Properties:  schema["properties"],
+		ExtraFields: map[string]any{},
+	}
+	for key, value := range schema {
+		switch key {
+		case "type", "properties":
+		case "required":
+			inputSchema.Required = toStringSlice(value)
+		default:
+			inputSchema.ExtraFields[key] = value
+		}
+	}
+
+	return anthropic.ToolParam{
+		Name:        name,
+		Description: anthropic.String(description),
+		InputSchema: inputSchema,
+	}
+}
+
+func toStringSlice(v interface{}) []string {
+	switch values := v.(type) {
+	case []string:
+		return values
+	case []interface{}:
+		result := make([]string, 0, len(values))
+		for _, value := range values {
+			if s, ok := value.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// forceTool builds the tools and tool_choice parameters that make Claude
+// respond by calling the given tool
+func forceTool(tool anthropic.ToolParam) ([]anthropic.ToolUnionParam, anthropic.ToolChoiceUnionParam) {
+	return []anthropic.ToolUnionParam{{OfTool: &tool}}, anthropic.ToolChoiceParamOfTool(tool.Name)
+}
+
+// FindToolInput returns the input of the first tool_use block calling the named tool
+func FindToolInput(content []anthropic.ContentBlockUnion, name string) (json.RawMessage, bool) {
+	for _, block := range content {
+		if block.Type == "tool_use" && block.Name == name {
+			return block.Input, true
+		}
+	}
+	return nil, false
+}
+
+// decodeToolOrText decodes the call of the named tool into a T, falling
+// back to parsing the text response for models that don't support tools.
+// It returns the repairs the text response needed.
+func decodeToolOrText[T any](content []anthropic.ContentBlockUnion, toolName string) (*T, []JSONRepair, error) {
+	if input, ok := FindToolInput(content, toolName); ok {
+		var result T
+		if err := json.Unmarshal(input, &result); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode %s tool input: %w", toolResult(toolName), err)
+		}
+		return &result, nil, nil
+	}
+	return parseResponse[T](ExtractTextFromResponse(content), toolName)
+}
+
+// parseResponse parses a text response into the T the named tool records,
+// for models that answer in text rather than through the tool. It returns
+// the repairs the JSON needed.
+func parseResponse[T any](responseText, toolName string) (*T, []JSONRepair, error) {
+	var result T
+	repairs, err := unmarshalResponse(responseText, &result)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s response: %w", toolResult(toolName), err)
+	}
+	return &result, repairs, nil
+}
+
+// toolResult names what a tool records, such as "summary" for record_summary
+func toolResult(toolName string) string {
+	return strings.TrimPrefix(toolName, "record_")
+}
@@ -0,0 +1,218 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/pdf-viewer/backend/models"
+)
+
+func TestBuildClassificationTool(t *testing.T) {
+	tool, err := BuildClassificationTool()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if tool.Name != ClassificationToolName {
+		t.Errorf("Expected tool name '%s', got '%s'", ClassificationToolName, tool.Name)
+	}
+
+	props, ok := tool.InputSchema.Properties.(map[string]interface{})
+	if !ok {
+		t.Fatal("Expected properties in input schema")
+	}
+	for _, field := range []string{"document_type", "confidence", "reasoning", "subtypes", "language"} {
+		if _, exists := props[field]; !exists {
+			t.Errorf("Expected property '%s' in classification tool", field)
+		}
+	}
+	if len(tool.InputSchema.Required) != 3 {
+		t.Errorf("Expected 3 required properties, got %v", tool.InputSchema.Required)
+	}
+}
+
+func TestBuildExtractionTool_WrapsDocumentSchema(t *testing.T) {
+	tool, err := BuildExtractionTool("invoice", GetSchemaForDocumentType("invoice"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if tool.Name != ExtractionToolName {
+		t.Errorf("Expected tool name '%s', got '%s'", ExtractionToolName, tool.Name)
+	}
+
+	props := tool.InputSchema.Properties.(map[string]interface{})
+	data, ok := props["data"].(map[string]interface{})
+	if !ok {
+		t.Fatal("Expected data property in extraction tool")
+	}
+	dataProps := data["properties"].(map[string]interface{})
+	if _, exists := dataProps["invoice_number"]; !exists {
+		t.Error("Expected invoice schema to be embedded in data property")
+	}
+	if _, exists := props["fields"]; !exists {
+		t.Error("Expected fields property in extraction tool")
+	}
+}
+
+func TestBuildExtractionTool_InvalidSchema(t *testing.T) {
+	if _, err := BuildExtractionTool("invoice", "not json"); err == nil {
+		t.Error("Expected error for invalid schema")
+	}
+}
+
+func TestBuildExtractionTool_MarshalsSchema(t *testing.T) {
+	tool, err := BuildExtractionTool("contract", GetSchemaForDocumentType("contract"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := json.Marshal(tool)
+	if err != nil {
+		t.Fatalf("Failed to marshal tool: %v", err)
+	}
+
+	var parsed map[string]interface{}
+	json.Unmarshal(data, &parsed)
+	schema := parsed["input_schema"].(map[string]interface{})
+	if schema["type"] != "object" {
+		t.Errorf("Expected input_schema type 'object', got %v", schema["type"])
+	}
+}
+
+func TestFindToolInput(t *testing.T) {
+	content := []anthropic.ContentBlockUnion{
+		{Type: "text", Text: "Some preamble"},
+		{Type: "tool_use", Name: "other_tool", Input: json.RawMessage(`{"a": 1}`)},
+		{Type: "tool_use", Name: ClassificationToolName, Input: json.RawMessage(`{"document_type": "invoice"}`)},
+	}
+
+	input, ok := FindToolInput(content, ClassificationToolName)
+	if !ok {
+		t.Fatal("Expected to find tool input")
+	}
+	if string(input) != `{"document_type": "invoice"}` {
+		t.Errorf("Unexpected tool input: %s", input)
+	}
+
+	if _, ok := FindToolInput(content, ExtractionToolName); ok {
+		t.Error("Expected no input for missing tool")
+	}
+}
+
+func TestDecodeToolOrText_ToolUse(t *testing.T) {
+	content := []anthropic.ContentBlockUnion{
+		{Type: "tool_use", Name: ClassificationToolName, Input: json.RawMessage(`{
+			"document_type": "invoice",
+			"confidence": 0.9,
+			"reasoning": "Contains {braces} and ` + "```" + ` fences"
+		}`)},
+	}
+
+	classification, _, err := decodeToolOrText[models.Classification](content, ClassificationToolName)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if classification.DocumentType != "invoice" {
+		t.Errorf("Expected 'invoice', got '%s'", classification.DocumentType)
+	}
+	if classification.Reasoning != "Contains {braces} and ``` fences" {
+		t.Errorf("Reasoning was not decoded intact: '%s'", classification.Reasoning)
+	}
+}
+
+func TestDecodeToolOrText_FallsBackToText(t *testing.T) {
+	content := []anthropic.ContentBlockUnion{
+		{Type: "text", Text: `{"document_type": "letter", "confidence": 0.8}`},
+	}
+
+	classification, _, err := decodeToolOrText[models.Classification](content, ClassificationToolName)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if classification.DocumentType != "letter" {
+		t.Errorf("Expected 'letter', got '%s'", classification.DocumentType)
+	}
+}
+
+func TestDecodeToolOrText_Extraction(t *testing.T) {
+	content := []anthropic.ContentBlockUnion{
+		{Type: "tool_use", Name: ExtractionToolName, Input: json.RawMessage(`{
+			"schema_used": "invoice",
+			"data": {"total": 100},
+			"fields": [{"name": "total", "value": 100, "source_text": "$100.00", "page_number": 1, "confidence": 0.9}]
+		}`)},
+	}
+
+	extraction, _, err := decodeToolOrText[models.Extraction](content, ExtractionToolName)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if extraction.SchemaUsed != "invoice" {
+		t.Errorf("Expected 'invoice', got '%s'", extraction.SchemaUsed)
+	}
+	if len(extraction.Fields) != 1 {
+		t.Errorf("Expected 1 field, got %d", len(extraction.Fields))
+	}
+}
+
+func TestClaudeClient_ClassifyDocument_ForcesToolUse(t *testing.T) {
+	var requestBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &requestBody)
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{
+			"id": "msg_1",
+			"type": "message",
+			"role": "assistant",
+			"model": "claude-sonnet-4-5-20250929",
+			"stop_reason": "tool_use",
+			"content": [{
+				"type": "tool_use",
+				"id": "toolu_1",
+				"name": "record_classification",
+				"input": {"document_type": "invoice", "confidence": 0.97, "reasoning": "Has an invoice number"}
+			}],
+			"usage": {"input_tokens": 1000, "output_tokens": 100}
+		}`)
+	}))
+	defer server.Close()
+
+	client := NewClaudeClient(option.WithBaseURL(server.URL), option.WithAPIKey("test-key"))
+	classification, _, tokenUsage, err := client.ClassifyDocument(context.Background(), []byte("%PDF-1.4"), Options{Model: "claude-haiku-4-5"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if classification.DocumentType != "invoice" {
+		t.Errorf("Expected 'invoice', got '%s'", classification.DocumentType)
+	}
+	if tokenUsage.InputTokens != 1000 {
+		t.Errorf("Expected 1000 input tokens, got %d", tokenUsage.InputTokens)
+	}
+	if requestBody["model"] != "claude-haiku-4-5" {
+		t.Errorf("Expected requested model to be sent, got %v", requestBody["model"])
+	}
+	if tokenUsage.Model != "claude-haiku-4-5" || tokenUsage.TotalCost != models.CalculateCostForModel("claude-haiku-4-5", 1000, 100) {
+		t.Errorf("Expected Haiku pricing, got %s $%f", tokenUsage.Model, tokenUsage.TotalCost)
+	}
+
+	toolChoice, ok := requestBody["tool_choice"].(map[string]interface{})
+	if !ok {
+		t.Fatal("Expected tool_choice in request")
+	}
+	if toolChoice["type"] != "tool" || toolChoice["name"] != ClassificationToolName {
+		t.Errorf("Expected forced tool choice, got %v", toolChoice)
+	}
+	if tools, ok := requestBody["tools"].([]interface{}); !ok || len(tools) != 1 {
+		t.Errorf("Expected one tool in request, got %v", requestBody["tools"])
+	}
+}
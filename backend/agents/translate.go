@@ -0,0 +1,303 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/pdf-viewer/backend/models"
+)
+
+// StepTranslation is the agent type of translation calls
+const StepTranslation = "translation"
+
+// TranslationToolName is the tool a translation is recorded with
+const TranslationToolName = "record_translation"
+
+// Output token limits of translation calls. A full-text translation is
+// about as long as the page range it covers; the limit stays below what the
+// API accepts without streaming.
+const (
+	translationMaxTokens         = 4096
+	fullTextTranslationMaxTokens = 16384
+)
+
+// TranslationOptions selects what to translate and into which language
+type TranslationOptions struct {
+	TargetLanguage string // A language name or code, such as "English" or "en"
+	FullText       bool   // Also translate the whole text, page by page
+}
+
+// TranslationResult is a model's answer to a translation call
+type TranslationResult struct {
+	SourceLanguage string                  `json:"source_language"`
+	Texts          []TranslatedText        `json:"texts"`
+	Pages          []models.TranslatedPage `json:"pages,omitempty"`
+}
+
+// TranslatedText is the translation of the numbered text of a translation
+// prompt
+type TranslatedText struct {
+	Index       int    `json:"index"` // 1-indexed, as numbered in the prompt
+	Translation string `json:"translation"`
+}
+
+// languageName matches the target languages a translation accepts
+var languageName = regexp.MustCompile(`^[\p{L}][\p{L} ()_-]{0,39}$`)
+
+// ValidateTranslationOptions checks the target language
+func ValidateTranslationOptions(translation TranslationOptions) error {
+	if !languageName.MatchString(translation.TargetLanguage) {
+		return fmt.Errorf("invalid target language '%s': use a language name or code such as English or en", translation.TargetLanguage)
+	}
+	return nil
+}
+
+func (o TranslationOptions) maxTokens() int {
+	if o.FullText {
+		return fullTextTranslationMaxTokens
+	}
+	return translationMaxTokens
+}
+
+// TranslationInputSchema returns the JSON schema of a TranslationResult
+func TranslationInputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"source_language": map[string]interface{}{
+				"type":        "string",
+				"description": "Primary language of the document",
+			},
+			"texts": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"index":       map[string]interface{}{"type": "integer", "description": "Number of the text in the list"},
+						"translation": map[string]interface{}{"type": "string"},
+					},
+					"required": []string{"index", "translation"},
+				},
+			},
+			"pages": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"page_number": map[string]interface{}{"type": "integer", "description": "1-indexed page number"},
+						"text":        map[string]interface{}{"type": "string"},
+					},
+					"required": []string{"page_number", "text"},
+				},
+			},
+		},
+		"required": []string{"source_language", "texts"},
+	}
+}
+
+func translationToolDescription(targetLanguage string) string {
+	return fmt.Sprintf("Record the translation into %s.", targetLanguage)
+}
+
+// BuildTranslationTool creates the tool whose input is a TranslationResult
+func BuildTranslationTool(targetLanguage string) anthropic.ToolParam {
+	return newTool(TranslationToolName, translationToolDescription(targetLanguage), TranslationInputSchema())
+}
+
+// BuildTranslationPrompt creates the prompt asking the model to translate
+// the numbered texts, extracted from the document, and optionally the whole
+// document
+func BuildTranslationPrompt(texts []string, translation TranslationOptions) string {
+	var list strings.Builder
+	for i, text := range texts {
+		fmt.Fprintf(&list, "[%d] %q\n", i+1, text)
+	}
+	if len(texts) == 0 {
+		list.WriteString("(none)\n")
+	}
+
+	fullText := "Leave pages empty."
+	if translation.FullText {
+		fullText = "Also translate the full text of the document, page by page, as pages. Keep the\nstructure of each page: headings, lists and table rows on their own lines."
+	}
+
+	return fmt.Sprintf(`Translate text from this PDF document into %s.
+
+The following texts were extracted from the document:
+%s
+Translate each text, answering with its number as index. Use the document for
+context, such as whether a word is a company name or a product. Keep names of
+people and companies, addresses, identifiers, numbers, amounts and dates as
+written; translate only words. A text already in %s is returned unchanged.
+
+%s
+
+Return a JSON object with this structure:
+{
+  "source_language": "language of the document",
+  "texts": [{"index": 1, "translation": "translated text"}],
+  "pages": [{"page_number": 1, "text": "translated page text"}]
+}`, translation.TargetLanguage, list.String(), translation.TargetLanguage, fullText)
+}
+
+// TranslateExtraction translates the string values of an extraction, and
+// the document's full text if requested. Each distinct string is translated
+// once. The extraction may be nil to translate only the full text. Strings
+// the model leaves out keep their original. A document that fits in one
+// chunk is translated with one call; a larger one has its strings
+// translated with one call on its first page range, as the whole document
+// would exceed the model's limits, and its full text page range by page
+// range, as the output of a full-text translation grows with the pages it
+// covers.
+// Every call is returned as a step, those of page ranges with their range;
+// on error, the steps of the calls that succeeded are returned.
+func TranslateExtraction(ctx context.Context, client Client, pdfData []byte, extraction *models.Extraction, translation TranslationOptions, opts Options, chunkOpts ChunkOptions) (*models.Translation, []ExtractionStep, error) {
+	var values []models.TranslatedValue
+	var fields []models.TranslatedField
+	if extraction != nil {
+		collectStrings(extraction.Data, "$", &values)
+		for _, field := range extraction.Fields {
+			if value, ok := field.Value.(string); ok && strings.TrimSpace(value) != "" {
+				fields = append(fields, models.TranslatedField{Name: field.Name, Original: value, SourceText: field.SourceText, PageNumber: field.PageNumber})
+			}
+		}
+	}
+
+	// Number each distinct string
+	index := map[string]int{}
+	var texts []string
+	add := func(text string) {
+		if _, ok := index[text]; !ok {
+			index[text] = len(texts)
+			texts = append(texts, text)
+		}
+	}
+	for _, value := range values {
+		add(value.Original)
+	}
+	for _, field := range fields {
+		add(field.Original)
+	}
+
+	chunks, err := SplitIntoChunks(pdfData, chunkOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var steps []ExtractionStep
+	result := &TranslationResult{}
+	if len(chunks) == 1 || len(texts) > 0 {
+		textsOnly := translation
+		textsOnly.FullText = len(chunks) == 1 && translation.FullText
+		translated, prompt, tokenUsage, err := client.TranslateTexts(ctx, chunks[0].PDFData, texts, textsOnly, opts)
+		if err != nil {
+			return nil, steps, err
+		}
+		step := ExtractionStep{AgentType: StepTranslation, Prompt: prompt, Response: marshalStep(translated), TokenUsage: tokenUsage}
+		if len(chunks) > 1 {
+			step.StartPage, step.EndPage = chunks[0].StartPage, chunks[0].EndPage
+		}
+		steps = append(steps, step)
+		result = translated
+	}
+	if len(chunks) > 1 && translation.FullText {
+		for _, chunk := range chunks {
+			translated, prompt, tokenUsage, err := client.TranslateTexts(ctx, chunk.PDFData, nil, translation, opts)
+			if err != nil {
+				return nil, steps, fmt.Errorf("pages %d-%d: %w", chunk.StartPage, chunk.EndPage, err)
+			}
+			steps = append(steps, ExtractionStep{AgentType: StepTranslation, Prompt: prompt, Response: marshalStep(translated), TokenUsage: tokenUsage, StartPage: chunk.StartPage, EndPage: chunk.EndPage})
+
+			if result.SourceLanguage == "" {
+				result.SourceLanguage = translated.SourceLanguage
+			}
+			for _, page := range translated.Pages {
+				page.PageNumber += chunk.StartPage - 1
+				result.Pages = append(result.Pages, page)
+			}
+		}
+	}
+
+	translated := make([]string, len(texts))
+	copy(translated, texts)
+	for _, text := range result.Texts {
+		if text.Index >= 1 && text.Index <= len(texts) && text.Translation != "" {
+			translated[text.Index-1] = text.Translation
+		}
+	}
+
+	output := &models.Translation{
+		TargetLanguage: translation.TargetLanguage,
+		SourceLanguage: result.SourceLanguage,
+		Values:         values,
+		Fields:         fields,
+		Pages:          result.Pages,
+	}
+	for i := range output.Values {
+		output.Values[i].Translated = translated[index[output.Values[i].Original]]
+	}
+	for i := range output.Fields {
+		output.Fields[i].Translated = translated[index[output.Fields[i].Original]]
+	}
+	if extraction != nil && extraction.Data != nil {
+		byPath := map[string]string{}
+		for _, value := range output.Values {
+			byPath[value.Path] = value.Translated
+		}
+		output.Data, _ = replaceStrings(extraction.Data, "$", byPath).(map[string]interface{})
+	}
+	return output, steps, nil
+}
+
+// collectStrings appends the non-blank strings of a decoded JSON value with
+// their paths, visiting object keys in order
+func collectStrings(value interface{}, path string, values *[]models.TranslatedValue) {
+	switch v := value.(type) {
+	case string:
+		if strings.TrimSpace(v) != "" {
+			*values = append(*values, models.TranslatedValue{Path: path, Original: v})
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			collectStrings(v[key], path+"."+key, values)
+		}
+	case []interface{}:
+		for i, item := range v {
+			collectStrings(item, fmt.Sprintf("%s[%d]", path, i), values)
+		}
+	}
+}
+
+// replaceStrings returns a copy of a decoded JSON value with the strings at
+// the given paths replaced
+func replaceStrings(value interface{}, path string, byPath map[string]string) interface{} {
+	switch v := value.(type) {
+	case string:
+		if translated, ok := byPath[path]; ok {
+			return translated
+		}
+		return v
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			copied[key] = replaceStrings(item, path+"."+key, byPath)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = replaceStrings(item, fmt.Sprintf("%s[%d]", path, i), byPath)
+		}
+		return copied
+	}
+	return value
+}
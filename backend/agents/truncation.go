@@ -0,0 +1,82 @@
+package agents
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// ErrMaxTokens is returned when a response is still cut off at max_tokens
+// after continuing it and retrying with larger limits
+var ErrMaxTokens = errors.New("response exceeded the output token limit")
+
+const (
+	// maxContinuations is the number of extra calls made for a response that
+	// hit max_tokens, whether continuing it or retrying with a larger limit
+	maxContinuations = 3
+
+	// maxRequestTokens is the largest max_tokens sent. The SDK refuses
+	// larger non-streaming requests, which it expects to outlast its timeout.
+	maxRequestTokens = 21000
+)
+
+// sendStats counts the calls behind a message
+type sendStats struct {
+	attempts      int // API calls made, including retries
+	continuations int // Extra calls made because the output hit max_tokens
+}
+
+// largerLimit doubles a max_tokens limit up to maxRequestTokens. It returns
+// false if the limit cannot grow.
+func largerLimit(maxTokens int) (int, bool) {
+	if maxTokens >= maxRequestTokens {
+		return maxTokens, false
+	}
+	return min(2*maxTokens, maxRequestTokens), true
+}
+
+// continuationParams asks the model to carry on from where a message was
+// cut off, by prefilling the assistant turn with its text. Only plain text
+// can be continued: a truncated tool call or thinking cannot be prefilled,
+// so false is returned for those.
+func continuationParams(params anthropic.MessageNewParams, message *anthropic.Message) (anthropic.MessageNewParams, bool) {
+	if params.Thinking.OfEnabled != nil || len(message.Content) == 0 {
+		return params, false
+	}
+	var prefill []anthropic.ContentBlockParamUnion
+	for _, block := range message.Content {
+		if block.Type != "text" {
+			return params, false
+		}
+		prefill = append(prefill, anthropic.NewTextBlock(block.Text))
+	}
+	// The API rejects a final assistant turn that ends in whitespace
+	last := prefill[len(prefill)-1].OfText
+	last.Text = strings.TrimRight(last.Text, " \t\r\n")
+	if last.Text == "" {
+		return params, false
+	}
+
+	params.Messages = append(append([]anthropic.MessageParam(nil), params.Messages...), anthropic.NewAssistantMessage(prefill...))
+	return params, true
+}
+
+// stitchMessage appends the continuation of a truncated message to it, with
+// the trailing whitespace the prefill dropped removed from the message
+func stitchMessage(message, continuation *anthropic.Message) {
+	last := &message.Content[len(message.Content)-1]
+	last.Text = strings.TrimRight(last.Text, " \t\r\n")
+	message.Content = append(message.Content, continuation.Content...)
+	message.StopReason = continuation.StopReason
+	addUsage(message, continuation)
+}
+
+// addUsage adds the tokens of another call, such as a discarded truncated
+// response, to a message's usage
+func addUsage(message, other *anthropic.Message) {
+	message.Usage.InputTokens += other.Usage.InputTokens
+	message.Usage.OutputTokens += other.Usage.OutputTokens
+	message.Usage.CacheCreationInputTokens += other.Usage.CacheCreationInputTokens
+	message.Usage.CacheReadInputTokens += other.Usage.CacheReadInputTokens
+}
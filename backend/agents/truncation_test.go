@@ -0,0 +1,184 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/pdf-viewer/backend/pdf"
+)
+
+// claudeServer answers the nth request with responses[n], repeating the
+// last, and records the decoded requests
+func claudeServer(t *testing.T, responses ...string) (*httptest.Server, *[]map[string]interface{}) {
+	var requests []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var parsed map[string]interface{}
+		json.Unmarshal(body, &parsed)
+		requests = append(requests, parsed)
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, responses[min(len(requests), len(responses))-1])
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestLargerLimit(t *testing.T) {
+	if limit, ok := largerLimit(4096); !ok || limit != 8192 {
+		t.Errorf("Expected 8192, got %d (%v)", limit, ok)
+	}
+	if limit, ok := largerLimit(16384); !ok || limit != maxRequestTokens {
+		t.Errorf("Expected %d, got %d (%v)", maxRequestTokens, limit, ok)
+	}
+	if _, ok := largerLimit(maxRequestTokens); ok {
+		t.Error("Expected the limit not to grow past maxRequestTokens")
+	}
+}
+
+func TestClaudeClient_RetriesTruncatedToolCallWithLargerLimit(t *testing.T) {
+	server, requests := claudeServer(t, `{
+		"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5-20250929",
+		"content": [{"type": "tool_use", "id": "toolu_1", "name": "record_extraction", "input": {}}],
+		"stop_reason": "max_tokens",
+		"usage": {"input_tokens": 1000, "output_tokens": 4096}
+	}`, `{
+		"id": "msg_2", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5-20250929",
+		"content": [{"type": "tool_use", "id": "toolu_2", "name": "record_extraction",
+			"input": {"schema_used": "invoice", "data": {"line_items": [{"description": "Widget"}]}, "fields": []}}],
+		"stop_reason": "tool_use",
+		"usage": {"input_tokens": 1000, "output_tokens": 5000}
+	}`)
+
+	client := NewClaudeClient(option.WithBaseURL(server.URL), option.WithAPIKey("test-key"))
+	extraction, _, usage, err := client.ExtractData(context.Background(), []byte("%PDF-1.4"), "invoice", GetSchemaForDocumentType("invoice"), Options{})
+	if err != nil {
+		t.Fatalf("Extraction failed: %v", err)
+	}
+	if extraction.Data["line_items"] == nil {
+		t.Errorf("Expected the retried extraction, got %+v", extraction.Data)
+	}
+
+	if len(*requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(*requests))
+	}
+	if first, second := (*requests)[0]["max_tokens"], (*requests)[1]["max_tokens"]; second != first.(float64)*2 {
+		t.Errorf("Expected max_tokens to double from %v, got %v", first, second)
+	}
+
+	if usage.Continuations != 1 || usage.Attempts != 2 || usage.StopReason != "tool_use" {
+		t.Errorf("Expected 1 continuation over 2 attempts ending in tool_use, got %d/%d/%s", usage.Continuations, usage.Attempts, usage.StopReason)
+	}
+	if usage.InputTokens != 2000 || usage.OutputTokens != 9096 {
+		t.Errorf("Expected the usage of both calls, got %d/%d", usage.InputTokens, usage.OutputTokens)
+	}
+	if usage.RawResponse == "" || usage.RawResponse[0] != '{' {
+		t.Errorf("Expected the raw tool input, got %q", usage.RawResponse)
+	}
+}
+
+func TestClaudeClient_ContinuesTruncatedText(t *testing.T) {
+	server, requests := claudeServer(t, `{
+		"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5-20250929",
+		"content": [{"type": "text", "text": "{\"document_type\": \"invoice\", \"confidence\": 0.9, \n"}],
+		"stop_reason": "max_tokens",
+		"usage": {"input_tokens": 1000, "output_tokens": 20}
+	}`, `{
+		"id": "msg_2", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5-20250929",
+		"content": [{"type": "text", "text": " \"reasoning\": \"Has an invoice number\"}"}],
+		"stop_reason": "end_turn",
+		"usage": {"input_tokens": 1020, "output_tokens": 10}
+	}`)
+
+	client := NewClaudeClient(option.WithBaseURL(server.URL), option.WithAPIKey("test-key"))
+	classification, _, usage, err := client.ClassifyDocument(context.Background(), []byte("%PDF-1.4"), Options{})
+	if err != nil {
+		t.Fatalf("Classification failed: %v", err)
+	}
+	if classification.DocumentType != "invoice" || classification.Reasoning != "Has an invoice number" {
+		t.Errorf("Expected the stitched classification, got %+v", classification)
+	}
+
+	messages, _ := (*requests)[1]["messages"].([]interface{})
+	last, _ := messages[len(messages)-1].(map[string]interface{})
+	content, _ := last["content"].([]interface{})
+	prefill, _ := content[0].(map[string]interface{})
+	if last["role"] != "assistant" || prefill["text"] != `{"document_type": "invoice", "confidence": 0.9,` {
+		t.Errorf("Expected the truncated text as an assistant prefill without trailing whitespace, got %v", last)
+	}
+
+	expected := `{"document_type": "invoice", "confidence": 0.9, "reasoning": "Has an invoice number"}`
+	if usage.RawResponse != expected {
+		t.Errorf("Expected stitched raw response %q, got %q", expected, usage.RawResponse)
+	}
+	if usage.Continuations != 1 || usage.StopReason != "end_turn" || usage.OutputTokens != 30 {
+		t.Errorf("Unexpected usage: %+v", usage)
+	}
+}
+
+func TestClaudeClient_GivesUpOnTruncation(t *testing.T) {
+	server, requests := claudeServer(t, `{
+		"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5-20250929",
+		"content": [{"type": "tool_use", "id": "toolu_1", "name": "record_tables", "input": {}}],
+		"stop_reason": "max_tokens",
+		"usage": {"input_tokens": 1000, "output_tokens": 16384}
+	}`)
+
+	client := NewClaudeClient(option.WithBaseURL(server.URL), option.WithAPIKey("test-key"))
+	_, _, _, err := client.ExtractTables(context.Background(), []byte("%PDF-1.4"), Options{})
+	if !errors.Is(err, ErrMaxTokens) {
+		t.Fatalf("Expected ErrMaxTokens, got %v", err)
+	}
+	if len(*requests) != 2 {
+		t.Errorf("Expected one retry at the largest limit, got %d requests", len(*requests))
+	}
+}
+
+func TestOpenAIClient_RetriesTruncatedResponse(t *testing.T) {
+	var limits []float64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&request)
+		limits = append(limits, request["max_tokens"].(float64))
+
+		w.Header().Set("Content-Type", "application/json")
+		if len(limits) == 1 {
+			io.WriteString(w, `{
+				"choices": [{"message": {"role": "assistant", "tool_calls": [{"id": "call_1", "type": "function",
+					"function": {"name": "record_classification", "arguments": "{\"document_type\": \"inv"}}]}, "finish_reason": "length"}],
+				"usage": {"prompt_tokens": 1000, "completion_tokens": 1024}
+			}`)
+			return
+		}
+		io.WriteString(w, `{
+			"choices": [{"message": {"role": "assistant", "tool_calls": [{"id": "call_2", "type": "function",
+				"function": {"name": "record_classification", "arguments": "{\"document_type\": \"invoice\", \"confidence\": 0.9}"}}]}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 1000, "completion_tokens": 30}
+		}`)
+	}))
+	defer server.Close()
+
+	client := NewOpenAIClient(server.URL+"/v1/", "sk-test")
+	classification, _, usage, err := client.ClassifyDocument(context.Background(), pdf.GenerateTextPDF([]string{"INVOICE #1234"}), Options{Model: "gpt-4o-mini"})
+	if err != nil {
+		t.Fatalf("ClassifyDocument failed: %v", err)
+	}
+	if classification.DocumentType != "invoice" {
+		t.Errorf("Expected invoice, got '%s'", classification.DocumentType)
+	}
+	if len(limits) != 2 || limits[1] != 2*limits[0] {
+		t.Errorf("Expected a retry with double the limit, got %v", limits)
+	}
+	if usage.Continuations != 1 || usage.StopReason != "stop" || usage.OutputTokens != 1054 {
+		t.Errorf("Unexpected usage: %+v", usage)
+	}
+	if usage.RawResponse != `{"document_type": "invoice", "confidence": 0.9}` {
+		t.Errorf("Expected the raw function arguments, got %q", usage.RawResponse)
+	}
+}
@@ -0,0 +1,176 @@
+package agents
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+
+	"github.com/pdf-viewer/backend/models"
+)
+
+// ValidateExtractionData checks extracted data against a JSON schema.
+// It supports the keywords used by the document schemas: type, properties,
+// required, items, enum, minimum, maximum and additionalProperties. A null
+// value is accepted for any optional property, since the model uses it for
+// information that is absent from the document, but for a required one only
+// if its schema allows "null".
+func ValidateExtractionData(data map[string]interface{}, schema string) ([]models.ValidationError, error) {
+	var parsed map[string]interface{}
+	if err := json.Unmarshal([]byte(schema), &parsed); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	// Normalize the data through JSON so numbers are float64 and nested
+	// values are maps and slices
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode data: %w", err)
+	}
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, fmt.Errorf("failed to decode data: %w", err)
+	}
+
+	var errs []models.ValidationError
+	validateValue(value, parsed, "$", &errs)
+	return errs, nil
+}
+
+// NewValidationReport runs ValidateExtractionData and summarizes the result
+func NewValidationReport(extraction *models.Extraction, schema string) (*models.ValidationReport, error) {
+	errs, err := ValidateExtractionData(extraction.Data, schema)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ValidationReport{Valid: len(errs) == 0, Errors: errs}, nil
+}
+
+func validateValue(value interface{}, schema map[string]interface{}, path string, errs *[]models.ValidationError) {
+	addError := func(format string, args ...interface{}) {
+		*errs = append(*errs, models.ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 && !matchesAnyType(value, types) {
+		addError("expected %s, got %s", joinTypes(types), jsonType(value))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if fmt.Sprint(allowed) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			addError("value %v is not one of %v", value, enum)
+		}
+	}
+
+	switch v := value.(type) {
+	case float64:
+		if minimum, ok := schema["minimum"].(float64); ok && v < minimum {
+			addError("value %v is less than minimum %v", v, minimum)
+		}
+		if maximum, ok := schema["maximum"].(float64); ok && v > maximum {
+			addError("value %v is greater than maximum %v", v, maximum)
+		}
+
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		required := map[string]bool{}
+		for _, name := range toStringSlice(schema["required"]) {
+			required[name] = true
+			if _, ok := v[name]; !ok {
+				*errs = append(*errs, models.ValidationError{Path: path + "." + name, Message: "required property is missing"})
+			}
+		}
+
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			childPath := path + "." + key
+			propSchema, ok := properties[key].(map[string]interface{})
+			if !ok {
+				if schema["additionalProperties"] == false {
+					*errs = append(*errs, models.ValidationError{Path: childPath, Message: "property is not allowed by the schema"})
+				}
+				continue
+			}
+			if v[key] == nil {
+				if required[key] && !slices.Contains(schemaTypes(propSchema["type"]), "null") {
+					*errs = append(*errs, models.ValidationError{Path: childPath, Message: "required property is null"})
+				}
+				continue
+			}
+			validateValue(v[key], propSchema, childPath, errs)
+		}
+
+	case []interface{}:
+		itemSchema, ok := schema["items"].(map[string]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range v {
+			validateValue(item, itemSchema, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+func schemaTypes(t interface{}) []string {
+	switch v := t.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		return toStringSlice(v)
+	}
+	return nil
+}
+
+func matchesAnyType(value interface{}, types []string) bool {
+	for _, t := range types {
+		switch t {
+		case "integer":
+			if n, ok := value.(float64); ok && n == math.Trunc(n) {
+				return true
+			}
+		default:
+			if jsonType(value) == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func joinTypes(types []string) string {
+	if len(types) == 1 {
+		return types[0]
+	}
+	return fmt.Sprintf("one of %v", types)
+}
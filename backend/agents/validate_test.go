@@ -0,0 +1,124 @@
+package agents
+
+import (
+	"testing"
+
+	"github.com/pdf-viewer/backend/models"
+)
+
+func TestValidateExtractionData_Valid(t *testing.T) {
+	data := map[string]interface{}{
+		"invoice_number": "INV-001",
+		"total":          100.5,
+		"vendor":         map[string]interface{}{"name": "Acme"},
+		"line_items": []interface{}{
+			map[string]interface{}{"description": "Widget", "quantity": 2, "amount": 50.25},
+		},
+		"due_date": nil,
+	}
+
+	errs, err := ValidateExtractionData(data, GetSchemaForDocumentType("invoice"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(errs) != 0 {
+		t.Errorf("Expected no validation errors, got %v", errs)
+	}
+}
+
+func TestValidateExtractionData_TypeErrors(t *testing.T) {
+	data := map[string]interface{}{
+		"total":  "one hundred",
+		"vendor": "Acme",
+		"line_items": []interface{}{
+			map[string]interface{}{"quantity": "two"},
+		},
+	}
+
+	errs, err := ValidateExtractionData(data, GetSchemaForDocumentType("invoice"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	paths := map[string]bool{}
+	for _, e := range errs {
+		paths[e.Path] = true
+	}
+	for _, expected := range []string{"$.total", "$.vendor", "$.line_items[0].quantity"} {
+		if !paths[expected] {
+			t.Errorf("Expected validation error at %s, got %v", expected, errs)
+		}
+	}
+}
+
+func TestValidateExtractionData_Keywords(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"count": { "type": "integer", "minimum": 1, "maximum": 10 },
+			"status": { "type": "string", "enum": ["open", "closed"] },
+			"tags": { "type": "array", "items": { "type": "string" } },
+			"closed_on": { "type": ["string", "null"] }
+		},
+		"required": ["count", "status", "closed_on"],
+		"additionalProperties": false
+	}`
+
+	tests := []struct {
+		name     string
+		data     map[string]interface{}
+		expected int
+	}{
+		{"valid", map[string]interface{}{"count": 3, "status": "open", "closed_on": nil}, 0},
+		{"not integer", map[string]interface{}{"count": 2.5, "status": "open", "closed_on": nil}, 1},
+		{"below minimum", map[string]interface{}{"count": 0, "status": "open", "closed_on": nil}, 1},
+		{"above maximum", map[string]interface{}{"count": 11, "status": "open", "closed_on": nil}, 1},
+		{"bad enum", map[string]interface{}{"count": 1, "status": "pending", "closed_on": nil}, 1},
+		{"missing required", map[string]interface{}{"count": 1, "closed_on": nil}, 1},
+		{"null required", map[string]interface{}{"count": nil, "status": "open", "closed_on": nil}, 1},
+		{"optional null", map[string]interface{}{"count": 1, "status": "open", "closed_on": nil, "tags": nil}, 0},
+		{"extra property", map[string]interface{}{"count": 1, "status": "open", "closed_on": nil, "extra": true}, 1},
+		{"bad array item", map[string]interface{}{"count": 1, "status": "open", "closed_on": nil, "tags": []interface{}{"a", 2}}, 1},
+	}
+
+	for _, tc := range tests {
+		errs, err := ValidateExtractionData(tc.data, schema)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if len(errs) != tc.expected {
+			t.Errorf("%s: expected %d errors, got %v", tc.name, tc.expected, errs)
+		}
+	}
+}
+
+func TestValidateExtractionData_NilData(t *testing.T) {
+	errs, err := ValidateExtractionData(nil, GetSchemaForDocumentType("invoice"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(errs) != 1 {
+		t.Errorf("Expected missing data to be reported, got %v", errs)
+	}
+}
+
+func TestValidateExtractionData_InvalidSchema(t *testing.T) {
+	if _, err := ValidateExtractionData(map[string]interface{}{}, "not json"); err == nil {
+		t.Error("Expected error for invalid schema")
+	}
+}
+
+func TestNewValidationReport(t *testing.T) {
+	extraction := &models.Extraction{Data: map[string]interface{}{"total": "abc"}}
+
+	report, err := NewValidationReport(extraction, GetSchemaForDocumentType("receipt"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if report.Valid {
+		t.Error("Expected report to be invalid")
+	}
+	if len(report.Errors) != 1 || report.Errors[0].Path != "$.total" {
+		t.Errorf("Unexpected errors: %v", report.Errors)
+	}
+}
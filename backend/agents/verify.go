@@ -0,0 +1,238 @@
+package agents
+
+import (
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/pdf"
+)
+
+// MinFuzzySimilarity is the lowest similarity accepted as a fuzzy match
+const MinFuzzySimilarity = 0.85
+
+// maxFuzzyRunes bounds the length of source text compared approximately,
+// keeping the edit distance search cheap for long quotes
+const maxFuzzyRunes = 300
+
+// gramLength is the length of the n-grams that rule out pages before the
+// edit distance search
+const gramLength = 3
+
+// VerifyExtraction checks each field's SourceText against the text layer of
+// the PDF. Fields are updated in place with their verification status and
+// the summary is returned. Documents without a text layer, such as scans,
+// cannot be verified; the report then carries an error and fields are left
+// unverified.
+func VerifyExtraction(extraction *models.Extraction, pdfData []byte) *models.VerificationReport {
+	report := &models.VerificationReport{}
+
+	texts, err := pdf.ExtractPageTexts(pdfData)
+	if err != nil {
+		report.Error = "failed to read PDF text layer: " + err.Error()
+		return report
+	}
+	pages := &textPages{texts: make([]string, len(texts)), grams: make([]map[string]bool, len(texts))}
+	hasText := false
+	for i, text := range texts {
+		pages.texts[i] = normalizeText(text)
+		hasText = hasText || pages.texts[i] != ""
+	}
+	if !hasText {
+		report.Error = "document has no text layer"
+		return report
+	}
+
+	for i := range extraction.Fields {
+		field := &extraction.Fields[i]
+		field.Verification, field.MatchedPage, field.MatchScore = verifySourceText(field.SourceText, field.PageNumber, pages)
+		switch field.Verification {
+		case models.VerificationExact:
+			report.Exact++
+		case models.VerificationFuzzy:
+			report.Fuzzy++
+		case models.VerificationWrongPage:
+			report.WrongPage++
+		case models.VerificationNotFound:
+			report.NotFound++
+		}
+	}
+
+	// A quote on the wrong page exists but cites the document incorrectly,
+	// so it counts as half a hallucination
+	if total := len(extraction.Fields); total > 0 {
+		report.HallucinationScore = (float64(report.NotFound) + 0.5*float64(report.WrongPage)) / float64(total)
+	}
+	return report
+}
+
+// verifySourceText finds quote in the normalized page texts, preferring the
+// claimed page (1-indexed). It returns the status, the page matched and the
+// similarity of the match.
+func verifySourceText(quote string, claimedPage int, pages *textPages) (string, int, float64) {
+	needle := normalizeText(quote)
+	if needle == "" {
+		return models.VerificationNotFound, 0, 0
+	}
+
+	claimed := claimedPage >= 1 && claimedPage <= len(pages.texts)
+	if claimed {
+		if strings.Contains(pages.texts[claimedPage-1], needle) {
+			return models.VerificationExact, claimedPage, 1
+		}
+		if score := fuzzyContains(pages.texts[claimedPage-1], needle); score >= MinFuzzySimilarity {
+			return models.VerificationFuzzy, claimedPage, score
+		}
+	}
+
+	for i, page := range pages.texts {
+		if (!claimed || i != claimedPage-1) && strings.Contains(page, needle) {
+			return models.VerificationWrongPage, i + 1, 1
+		}
+	}
+	bestPage, bestScore := 0, 0.0
+	for i, page := range pages.texts {
+		if (claimed && i == claimedPage-1) || !pages.mayContain(i, needle) {
+			continue
+		}
+		if score := fuzzyContains(page, needle); score > bestScore {
+			bestPage, bestScore = i+1, score
+		}
+	}
+	if bestScore >= MinFuzzySimilarity {
+		return models.VerificationWrongPage, bestPage, bestScore
+	}
+	return models.VerificationNotFound, 0, 0
+}
+
+// textPages holds the normalized page texts of a document, and the n-grams
+// of each page once the fuzzy search first needs them
+type textPages struct {
+	texts []string
+	grams []map[string]bool
+}
+
+// mayContain reports whether page i can hold a fuzzy match of needle. By the
+// q-gram lemma, text within d edits of a needle shares at least
+// len-q+1-q*d of its n-grams, so a page sharing fewer cannot match. Counting
+// them is far cheaper than the edit distance search and rules out most
+// pages of a long document.
+func (p *textPages) mayContain(i int, needle string) bool {
+	n := []rune(needle)
+	if len(n) > maxFuzzyRunes {
+		n = n[:maxFuzzyRunes]
+	}
+	maxEdits := int(math.Ceil((1 - MinFuzzySimilarity) * float64(len(n))))
+	required := len(n) - gramLength + 1 - gramLength*maxEdits
+	if required <= 0 {
+		return true
+	}
+
+	if p.grams[i] == nil {
+		p.grams[i] = ngrams([]rune(p.texts[i]))
+	}
+	shared := 0
+	for k := 0; k+gramLength <= len(n); k++ {
+		if p.grams[i][string(n[k:k+gramLength])] {
+			shared++
+		}
+	}
+	return shared >= required
+}
+
+// ngrams returns the set of n-grams of text
+func ngrams(text []rune) map[string]bool {
+	grams := make(map[string]bool, len(text))
+	for k := 0; k+gramLength <= len(text); k++ {
+		grams[string(text[k:k+gramLength])] = true
+	}
+	return grams
+}
+
+// normalizeText prepares text for matching: ligatures are expanded, quotes
+// and dashes unified, words hyphenated across line breaks rejoined, case
+// folded and whitespace collapsed
+func normalizeText(s string) string {
+	s = textReplacer.Replace(s)
+
+	runes := []rune(s)
+	var b strings.Builder
+	b.Grow(len(s))
+	space := false
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		// "hyphen-\nation" becomes "hyphenation"
+		if r == '-' && i > 0 && unicode.IsLetter(runes[i-1]) {
+			j := i + 1
+			for j < len(runes) && (runes[j] == ' ' || runes[j] == '\t' || runes[j] == '\r') {
+				j++
+			}
+			if j < len(runes) && runes[j] == '\n' {
+				for j < len(runes) && unicode.IsSpace(runes[j]) {
+					j++
+				}
+				if j < len(runes) && unicode.IsLower(runes[j]) {
+					i = j - 1
+					continue
+				}
+			}
+		}
+
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+var textReplacer = strings.NewReplacer(
+	"\u00ad", "", // Soft hyphen
+	"\u00a0", " ", // No-break space
+	"ﬁ", "fi", "ﬂ", "fl", "ﬀ", "ff", "ﬃ", "ffi", "ﬄ", "ffl",
+	"‘", "'", "’", "'", "‚", "'", "“", "\"", "”", "\"", "„", "\"",
+	"‐", "-", "‑", "-", "‒", "-", "–", "-", "—", "-", "−", "-",
+	"…", "...",
+)
+
+// fuzzyContains returns the best similarity between needle and any substring
+// of haystack, computed from the approximate substring edit distance
+func fuzzyContains(haystack, needle string) float64 {
+	n := []rune(needle)
+	if len(n) > maxFuzzyRunes {
+		n = n[:maxFuzzyRunes]
+	}
+	h := []rune(haystack)
+	if len(n) == 0 || len(h) == 0 {
+		return 0
+	}
+
+	// Sellers' algorithm: a match may start anywhere in haystack, so the
+	// first row is all zeros and the answer is the minimum of the last row
+	prev := make([]int, len(h)+1)
+	curr := make([]int, len(h)+1)
+	for i := 1; i <= len(n); i++ {
+		curr[0] = i
+		for j := 1; j <= len(h); j++ {
+			cost := 1
+			if n[i-1] == h[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j-1]+cost, prev[j]+1, curr[j-1]+1)
+		}
+		prev, curr = curr, prev
+	}
+
+	best := len(n)
+	for _, d := range prev {
+		best = min(best, d)
+	}
+	return 1 - float64(best)/float64(len(n))
+}
@@ -0,0 +1,134 @@
+package agents
+
+import (
+	"testing"
+
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/pdf"
+)
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"  Hello\n\tWorld  ", "hello world"},
+		{"pay-\nment terms", "payment terms"},
+		{"pay-\n  ment", "payment"},
+		{"Net-\n30", "net- 30"},
+		{"“Quoted” – text’s", "\"quoted\" - text's"},
+		{"ﬁnal o­rder", "final order"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeText(tt.input); got != tt.expected {
+			t.Errorf("normalizeText(%q) = %q, expected %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestFuzzyContains(t *testing.T) {
+	if score := fuzzyContains("the total amount due is 1,250.00 usd", "total amount due"); score != 1 {
+		t.Errorf("Expected exact substring score 1, got %f", score)
+	}
+	if score := fuzzyContains("the total amount due is 1,250.00 usd", "total amont due"); score < MinFuzzySimilarity {
+		t.Errorf("Expected one missing letter to be a fuzzy match, got %f", score)
+	}
+	if score := fuzzyContains("the total amount due is 1,250.00 usd", "shipping address"); score >= MinFuzzySimilarity {
+		t.Errorf("Expected unrelated text not to match, got %f", score)
+	}
+}
+
+func TestTextPages_MayContain(t *testing.T) {
+	pages := &textPages{
+		texts: []string{"the total amount due is 1,250.00 usd", "shipping address: 12 harbour road"},
+		grams: make([]map[string]bool, 2),
+	}
+	needle := "total amont due is 1,250.00"
+	if !pages.mayContain(0, needle) {
+		t.Error("Expected the page with a fuzzy match to be searched")
+	}
+	if pages.mayContain(1, needle) {
+		t.Error("Expected the unrelated page to be ruled out")
+	}
+	if !pages.mayContain(1, "due") {
+		t.Error("Expected needles too short to filter to be searched")
+	}
+}
+
+func TestVerifyExtraction_FuzzyMatchOnAFarPage(t *testing.T) {
+	texts := make([]string, 100)
+	for i := range texts {
+		texts[i] = "Schedule of payments, continued on the next page."
+	}
+	texts[87] = "The lessee shall pay a security deposit of 2,400.00 EUR."
+	extraction := &models.Extraction{Fields: []models.ExtractedField{
+		{Name: "deposit", SourceText: "lessee shall pay a securty deposit of 2,400.00 EUR", PageNumber: 1},
+	}}
+
+	VerifyExtraction(extraction, pdf.GenerateTextPDF(texts))
+	if field := extraction.Fields[0]; field.Verification != models.VerificationWrongPage || field.MatchedPage != 88 {
+		t.Errorf("Expected a fuzzy match on page 88, got %s on page %d", field.Verification, field.MatchedPage)
+	}
+}
+
+func TestVerifyExtraction(t *testing.T) {
+	pdfData := pdf.GenerateTextPDF([]string{
+		"ACME Corporation\nInvoice Number: INV-2024-001\nPayment is due within thirty days of re-\nceipt.",
+		"Total Amount Due: $1,250.00",
+	})
+	extraction := &models.Extraction{Fields: []models.ExtractedField{
+		{Name: "vendor", SourceText: "ACME  Corporation", PageNumber: 1},
+		{Name: "terms", SourceText: "Payment is due within thirty days of receipt", PageNumber: 1},
+		{Name: "number", SourceText: "Invoice Numbr: INV-2024-001", PageNumber: 1},
+		{Name: "total", SourceText: "Total Amount Due: $1,250.00", PageNumber: 1},
+		{Name: "po", SourceText: "Purchase Order 7781", PageNumber: 2},
+		{Name: "empty", SourceText: "", PageNumber: 1},
+	}}
+
+	report := VerifyExtraction(extraction, pdfData)
+	if report.Error != "" {
+		t.Fatalf("Unexpected error: %s", report.Error)
+	}
+
+	expected := map[string]string{
+		"vendor": models.VerificationExact,
+		"terms":  models.VerificationExact,
+		"number": models.VerificationFuzzy,
+		"total":  models.VerificationWrongPage,
+		"po":     models.VerificationNotFound,
+		"empty":  models.VerificationNotFound,
+	}
+	for _, field := range extraction.Fields {
+		if field.Verification != expected[field.Name] {
+			t.Errorf("Field %s: expected %s, got %s", field.Name, expected[field.Name], field.Verification)
+		}
+	}
+	if extraction.Fields[3].MatchedPage != 2 {
+		t.Errorf("Expected total to be matched on page 2, got %d", extraction.Fields[3].MatchedPage)
+	}
+
+	if report.Exact != 2 || report.Fuzzy != 1 || report.WrongPage != 1 || report.NotFound != 2 {
+		t.Errorf("Unexpected counts: %+v", report)
+	}
+	if expectedScore := 2.5 / 6; report.HallucinationScore != expectedScore {
+		t.Errorf("Expected hallucination score %f, got %f", expectedScore, report.HallucinationScore)
+	}
+}
+
+func TestVerifyExtraction_NoTextLayer(t *testing.T) {
+	extraction := &models.Extraction{Fields: []models.ExtractedField{{Name: "total", SourceText: "100"}}}
+
+	report := VerifyExtraction(extraction, []byte("%PDF-1.4 not really a pdf"))
+	if report.Error == "" {
+		t.Error("Expected error for unreadable PDF")
+	}
+	if extraction.Fields[0].Verification != "" {
+		t.Errorf("Expected field to be left unverified, got %s", extraction.Fields[0].Verification)
+	}
+
+	report = VerifyExtraction(extraction, pdf.GenerateTextPDF([]string{""}))
+	if report.Error != "document has no text layer" {
+		t.Errorf("Expected no text layer error, got '%s'", report.Error)
+	}
+}
@@ -5,10 +5,10 @@ go 1.24.3
 require (
 	github.com/anthropics/anthropic-sdk-go v1.22.1
 	github.com/google/uuid v1.6.0
+	github.com/mattn/go-sqlite3 v1.14.34
 )
 
 require (
-	github.com/mattn/go-sqlite3 v1.14.34 // indirect
 	github.com/tidwall/gjson v1.18.0 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.1 // indirect
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/store"
+)
+
+type SubmitBatchRequest struct {
+	DocumentIDs  []string `json:"document_ids"`
+	AgentType    string   `json:"agent_type,omitempty"`    // "classification" (default) or "extraction"
+	DocumentType string   `json:"document_type,omitempty"` // Extraction: override each document's classification
+	Model        string   `json:"model,omitempty"`         // Override the server default model
+}
+
+// SubmitBatch submits classification or extraction of the given documents
+// as one batch, sent as several message batches if it exceeds the
+// provider's limits. Results are written back by GetBatch once the batch
+// has ended.
+func SubmitBatch(w http.ResponseWriter, r *http.Request) {
+	var req SubmitBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.DocumentIDs) == 0 {
+		http.Error(w, "document_ids required", http.StatusBadRequest)
+		return
+	}
+	if req.AgentType == "" {
+		req.AgentType = agents.StepClassification
+	}
+	if req.AgentType != agents.StepClassification && req.AgentType != agents.StepExtraction {
+		http.Error(w, "agent_type must be classification or extraction", http.StatusBadRequest)
+		return
+	}
+
+	client, ok := agents.GetClient().(agents.BatchClient)
+	if !ok {
+		http.Error(w, "Batch processing is not supported by the configured provider", http.StatusNotImplemented)
+		return
+	}
+
+	model, err := agents.GetModelConfig().Resolve(req.AgentType, req.Model)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	types, err := loadDocumentTypes()
+	if err != nil {
+		http.Error(w, "Failed to list document types: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	batch := &models.Batch{
+		ID:        uuid.New().String(),
+		AgentType: req.AgentType,
+		Model:     model,
+		CreatedAt: time.Now(),
+	}
+	items := make([]agents.BatchItem, len(req.DocumentIDs))
+	for i, id := range req.DocumentIDs {
+		doc, err := store.Get().GetDocument(id)
+		if err != nil {
+			http.Error(w, "Document not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+
+		item := agents.BatchItem{CustomID: fmt.Sprintf("req-%d", i), AgentType: req.AgentType, PDFData: doc.PDFData}
+		if req.AgentType == agents.StepExtraction {
+			item.DocumentType = req.DocumentType
+			if item.DocumentType == "" {
+				if doc.Classification == nil {
+					http.Error(w, "Document "+doc.ID+" must be classified first or document_type must be provided", http.StatusBadRequest)
+					return
+				}
+				item.DocumentType = doc.Classification.DocumentType
+			}
+			item.Schema = agents.SchemaForDocumentType(item.DocumentType, types)
+		}
+		items[i] = item
+		batch.Requests = append(batch.Requests, models.BatchRequest{
+			CustomID:     item.CustomID,
+			DocumentID:   doc.ID,
+			DocumentType: item.DocumentType,
+			Status:       "pending",
+		})
+	}
+
+	statuses, prompts, err := client.SubmitBatch(r.Context(), items, agents.Options{Model: model, DocumentTypes: types})
+	if err != nil {
+		writeAgentError(w, "Batch submission failed: ", err)
+		return
+	}
+	providerBatchIDs := make(map[string]string, len(items))
+	for _, status := range statuses {
+		batch.ProviderBatchIDs = append(batch.ProviderBatchIDs, status.ID)
+		for _, customID := range status.CustomIDs {
+			providerBatchIDs[customID] = status.ID
+		}
+	}
+	for i := range batch.Requests {
+		batch.Requests[i].Prompt = prompts[i]
+		batch.Requests[i].ProviderBatchID = providerBatchIDs[batch.Requests[i].CustomID]
+	}
+	batch.Status, batch.Counts = combineBatchStatuses(statuses)
+
+	if err := store.Get().SaveBatch(batch); err != nil {
+		http.Error(w, "Failed to save batch: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(batch)
+}
+
+// batchLocks holds a mutex per batch ID, so that concurrent checks of a
+// batch write its results back only once
+var batchLocks sync.Map
+
+func lockBatch(id string) (unlock func()) {
+	mu, _ := batchLocks.LoadOrStore(id, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// GetBatch reports the status of a batch. The first check after the batch
+// has ended writes its results back to the documents and prompt history.
+func GetBatch(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	defer lockBatch(id)()
+
+	batch, err := store.Get().GetBatch(id)
+	if err != nil {
+		http.Error(w, "Batch not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if batch.Status != models.BatchCompleted {
+		client, ok := agents.GetClient().(agents.BatchClient)
+		if !ok {
+			http.Error(w, "Batch processing is not supported by the configured provider", http.StatusNotImplemented)
+			return
+		}
+
+		statuses := make([]*agents.BatchStatus, len(batch.ProviderBatchIDs))
+		for i, providerBatchID := range batch.ProviderBatchIDs {
+			if statuses[i], err = client.GetBatch(r.Context(), providerBatchID); err != nil {
+				writeAgentError(w, "Batch status check failed: ", err)
+				return
+			}
+		}
+		batch.Status, batch.Counts = combineBatchStatuses(statuses)
+
+		if batch.Status == models.BatchEnded {
+			if err := writeBatchResults(r.Context(), client, batch); err != nil {
+				writeAgentError(w, "Failed to read batch results: ", err)
+				return
+			}
+		}
+		if err := store.Get().SaveBatch(batch); err != nil {
+			http.Error(w, "Failed to save batch: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batch)
+}
+
+// combineBatchStatuses reports the provider batches of a batch as one: in
+// progress or canceling until all of them have ended, with their counts
+// summed
+func combineBatchStatuses(statuses []*agents.BatchStatus) (string, models.BatchCounts) {
+	status := models.BatchEnded
+	var counts models.BatchCounts
+	for _, s := range statuses {
+		switch {
+		case s.Status == models.BatchInProgress:
+			status = models.BatchInProgress
+		case s.Status == models.BatchCanceling && status == models.BatchEnded:
+			status = models.BatchCanceling
+		}
+		counts.Processing += s.Counts.Processing
+		counts.Succeeded += s.Counts.Succeeded
+		counts.Errored += s.Counts.Errored
+		counts.Canceled += s.Counts.Canceled
+		counts.Expired += s.Counts.Expired
+	}
+	return status, counts
+}
+
+// writeBatchResults saves the results of an ended batch to its documents
+// and prompt history and marks the batch completed
+func writeBatchResults(ctx context.Context, client agents.BatchClient, batch *models.Batch) error {
+	items := make(map[string][]agents.BatchItem, len(batch.ProviderBatchIDs))
+	requests := make(map[string]*models.BatchRequest, len(batch.Requests))
+	for i := range batch.Requests {
+		req := &batch.Requests[i]
+		item := agents.BatchItem{CustomID: req.CustomID, AgentType: batch.AgentType, DocumentType: req.DocumentType}
+		items[req.ProviderBatchID] = append(items[req.ProviderBatchID], item)
+		requests[req.CustomID] = req
+	}
+
+	var results []agents.BatchResult
+	for _, providerBatchID := range batch.ProviderBatchIDs {
+		batchResults, err := client.GetBatchResults(ctx, providerBatchID, items[providerBatchID], agents.Options{Model: batch.Model})
+		if err != nil {
+			return err
+		}
+		results = append(results, batchResults...)
+	}
+	types, err := loadDocumentTypes()
+	if err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		req, ok := requests[result.CustomID]
+		if !ok {
+			continue
+		}
+		req.Status, req.Error = result.Status, result.Error
+		billed := result.TokenUsage != nil
+		if billed {
+			batch.TotalCost += result.TokenUsage.TotalCost
+			batch.Unpriced = batch.Unpriced || result.TokenUsage.Unpriced
+		}
+
+		var schema string
+		if batch.AgentType == agents.StepExtraction {
+			schema = agents.SchemaForDocumentType(req.DocumentType, types)
+		}
+		var response string
+		if result.Status == "succeeded" {
+			var err error
+			if response, err = writeBatchResult(req, result, schema, types); err != nil {
+				req.Status, req.Error = "errored", err.Error()
+			}
+		} else if !billed {
+			continue
+		}
+
+		// Every billed result gets a prompt record, even one that failed,
+		// so the cost of the batch matches the prompt history
+		promptRecord := newPromptRecord(req.DocumentID, batch.AgentType, req.Prompt, response, result.TokenUsage)
+		promptRecord.Schema = schema
+		promptRecord.BatchID = batch.ID
+		promptRecord.Error = req.Error
+		store.Get().SavePrompt(promptRecord)
+		req.PromptID = promptRecord.ID
+	}
+
+	now := time.Now()
+	batch.Status = models.BatchCompleted
+	batch.EndedAt = &now
+	return nil
+}
+
+// writeBatchResult saves the classification or extraction of a succeeded
+// result to its document and returns it as the prompt response
+func writeBatchResult(req *models.BatchRequest, result agents.BatchResult, schema string, types []models.DocumentType) (string, error) {
+	doc, err := store.Get().GetDocument(req.DocumentID)
+	if err != nil {
+		return "", err
+	}
+
+	var response string
+	if result.Classification != nil {
+		if err := agents.ApplyTaxonomy(result.Classification, types, false); err != nil {
+			return "", err
+		}
+		doc.Classification = result.Classification
+		response = toJSON(result.Classification)
+	} else {
+		extraction := result.Extraction
+		report, err := agents.NewValidationReport(extraction, schema)
+		if err != nil {
+			report = &models.ValidationReport{Valid: true, RepairError: err.Error()}
+		}
+		extraction.Validation = report
+		extraction.Verification = agents.VerifyExtraction(extraction, doc.PDFData)
+		doc.Extraction = extraction
+		response = toJSON(extraction)
+	}
+	if err := store.Get().SaveDocument(doc); err != nil {
+		return response, err
+	}
+	return response, nil
+}
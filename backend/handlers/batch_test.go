@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/store"
+)
+
+// newFakeBatchServer serves a Message Batch that ends on its second status
+// check. req-0 succeeds and req-1 expires.
+func newFakeBatchServer() *httptest.Server {
+	checks := 0
+	batch := func(status string) string {
+		return `{"id": "msgbatch_1", "type": "message_batch", "processing_status": "` + status + `",
+			"request_counts": {"processing": 0, "succeeded": 1, "errored": 0, "canceled": 0, "expired": 1},
+			"created_at": "2025-01-01T00:00:00Z", "expires_at": "2025-01-02T00:00:00Z"}`
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/messages/batches":
+			io.WriteString(w, batch("in_progress"))
+		case "/v1/messages/batches/msgbatch_1":
+			checks++
+			if checks < 2 {
+				io.WriteString(w, batch("in_progress"))
+				return
+			}
+			io.WriteString(w, batch("ended"))
+		case "/v1/messages/batches/msgbatch_1/results":
+			io.WriteString(w, `{"custom_id": "req-0", "result": {"type": "succeeded", "message": {"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5-20250929", "content": [{"type": "tool_use", "id": "toolu_1", "name": "record_classification", "input": {"document_type": "receipt", "confidence": 0.8, "reasoning": "Receipt"}}], "usage": {"input_tokens": 1000, "output_tokens": 200}}}}
+{"custom_id": "req-1", "result": {"type": "expired"}}
+`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func getBatch(t *testing.T, id string) *models.Batch {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/batches/"+id, nil)
+	req.SetPathValue("id", id)
+	rr := httptest.NewRecorder()
+	GetBatch(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var batch models.Batch
+	json.NewDecoder(rr.Body).Decode(&batch)
+	return &batch
+}
+
+func TestBatch_SubmitAndWriteBackResults(t *testing.T) {
+	server := newFakeBatchServer()
+	defer server.Close()
+	agents.SetClient(agents.NewClaudeClient(option.WithBaseURL(server.URL), option.WithAPIKey("test-key")))
+	defer agents.SetClient(nil)
+
+	for _, id := range []string{"batch-doc-1", "batch-doc-2"} {
+		store.Get().SaveDocument(&models.Document{ID: id, Filename: id + ".pdf", PDFData: []byte("%PDF-1.4 " + id), CreatedAt: time.Now()})
+	}
+
+	body, _ := json.Marshal(SubmitBatchRequest{DocumentIDs: []string{"batch-doc-1", "batch-doc-2"}, Model: "claude-sonnet-4-5-20250929"})
+	req := httptest.NewRequest(http.MethodPost, "/api/batches", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	SubmitBatch(rr, req)
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var submitted models.Batch
+	json.NewDecoder(rr.Body).Decode(&submitted)
+	if len(submitted.ProviderBatchIDs) != 1 || submitted.Requests[0].ProviderBatchID != "msgbatch_1" || submitted.Status != models.BatchInProgress || len(submitted.Requests) != 2 {
+		t.Fatalf("Unexpected submitted batch %+v", submitted)
+	}
+
+	if batch := getBatch(t, submitted.ID); batch.Status != models.BatchInProgress {
+		t.Errorf("Expected batch in progress on first check, got %s", batch.Status)
+	}
+
+	batch := getBatch(t, submitted.ID)
+	if batch.Status != models.BatchCompleted || batch.EndedAt == nil {
+		t.Fatalf("Expected completed batch, got %+v", batch)
+	}
+	if batch.Requests[0].Status != "succeeded" || batch.Requests[0].PromptID == "" {
+		t.Errorf("Expected first request to succeed with a prompt record, got %+v", batch.Requests[0])
+	}
+	if batch.Requests[1].Status != "expired" || batch.Requests[1].Error == "" {
+		t.Errorf("Expected second request to expire, got %+v", batch.Requests[1])
+	}
+
+	doc, _ := store.Get().GetDocument("batch-doc-1")
+	if doc.Classification == nil || doc.Classification.DocumentType != "receipt" {
+		t.Errorf("Expected classification written back, got %+v", doc.Classification)
+	}
+	prompt, err := store.Get().GetPrompt(batch.Requests[0].PromptID)
+	if err != nil {
+		t.Fatalf("Expected prompt record: %v", err)
+	}
+	if prompt.BatchID != batch.ID || prompt.AgentType != "classification" || prompt.Prompt == "" {
+		t.Errorf("Unexpected prompt record %+v", prompt)
+	}
+	if math.Abs(prompt.TotalCost-0.003) > 1e-9 || math.Abs(batch.TotalCost-0.003) > 1e-9 {
+		t.Errorf("Expected batch-discounted cost 0.003, got %f (batch %f)", prompt.TotalCost, batch.TotalCost)
+	}
+
+	// Completed batches are served from the store
+	server.Close()
+	if again := getBatch(t, submitted.ID); again.Status != models.BatchCompleted {
+		t.Errorf("Expected completed batch from store, got %s", again.Status)
+	}
+}
+
+// splitBatchClient sends each item in its own provider batch, which has
+// ended by the first status check
+type splitBatchClient struct {
+	*agents.MockClient
+	mu          sync.Mutex
+	resultCalls int
+}
+
+func (c *splitBatchClient) SubmitBatch(ctx context.Context, items []agents.BatchItem, opts agents.Options) ([]*agents.BatchStatus, []string, error) {
+	var statuses []*agents.BatchStatus
+	prompts := make([]string, len(items))
+	for i, item := range items {
+		statuses = append(statuses, &agents.BatchStatus{
+			ID:        "msgbatch_" + item.CustomID,
+			Status:    models.BatchInProgress,
+			Counts:    models.BatchCounts{Processing: 1},
+			CustomIDs: []string{item.CustomID},
+		})
+		prompts[i] = "Classify"
+	}
+	return statuses, prompts, nil
+}
+
+func (c *splitBatchClient) GetBatch(ctx context.Context, batchID string) (*agents.BatchStatus, error) {
+	return &agents.BatchStatus{ID: batchID, Status: models.BatchEnded, Counts: models.BatchCounts{Succeeded: 1}}, nil
+}
+
+func (c *splitBatchClient) GetBatchResults(ctx context.Context, batchID string, items []agents.BatchItem, opts agents.Options) ([]agents.BatchResult, error) {
+	c.mu.Lock()
+	c.resultCalls++
+	c.mu.Unlock()
+	// Give concurrent status checks time to overlap
+	time.Sleep(10 * time.Millisecond)
+
+	var results []agents.BatchResult
+	for _, item := range items {
+		results = append(results, agents.BatchResult{
+			CustomID:       item.CustomID,
+			Status:         "succeeded",
+			Classification: &models.Classification{DocumentType: "invoice", Confidence: 0.9},
+			TokenUsage:     &models.TokenUsage{TotalCost: 0.001},
+		})
+	}
+	return results, nil
+}
+
+func TestBatch_SplitAcrossProviderBatchesWritesBackOnce(t *testing.T) {
+	client := &splitBatchClient{MockClient: agents.NewMockClient()}
+	agents.SetClient(client)
+	defer agents.SetClient(nil)
+
+	ids := []string{"split-doc-1", "split-doc-2"}
+	for _, id := range ids {
+		store.Get().SaveDocument(&models.Document{ID: id, PDFData: []byte("%PDF-1.4 " + id), CreatedAt: time.Now()})
+	}
+	body, _ := json.Marshal(SubmitBatchRequest{DocumentIDs: ids})
+	rr := httptest.NewRecorder()
+	SubmitBatch(rr, httptest.NewRequest(http.MethodPost, "/api/batches", bytes.NewReader(body)))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var submitted models.Batch
+	json.NewDecoder(rr.Body).Decode(&submitted)
+	if len(submitted.ProviderBatchIDs) != 2 || submitted.Requests[1].ProviderBatchID != "msgbatch_req-1" || submitted.Counts.Processing != 2 {
+		t.Fatalf("Expected two provider batches, got %+v", submitted)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/api/batches/"+submitted.ID, nil)
+			req.SetPathValue("id", submitted.ID)
+			GetBatch(httptest.NewRecorder(), req)
+		}()
+	}
+	wg.Wait()
+
+	if client.resultCalls != 2 {
+		t.Errorf("Expected the results of each provider batch read once, got %d reads", client.resultCalls)
+	}
+	batch := getBatch(t, submitted.ID)
+	if batch.Status != models.BatchCompleted || batch.Counts.Succeeded != 2 || math.Abs(batch.TotalCost-0.002) > 1e-9 {
+		t.Errorf("Expected a completed batch with both results, got %+v", batch)
+	}
+	for _, id := range ids {
+		prompts, _ := store.Get().GetPromptsByDocument(id)
+		if len(prompts) != 1 {
+			t.Errorf("Expected one prompt record for %s, got %d", id, len(prompts))
+		}
+	}
+}
+
+// failingBatchClient returns a result whose response could not be decoded
+// and a succeeded result, both billed
+type failingBatchClient struct {
+	*splitBatchClient
+}
+
+func (c *failingBatchClient) GetBatchResults(ctx context.Context, batchID string, items []agents.BatchItem, opts agents.Options) ([]agents.BatchResult, error) {
+	result := agents.BatchResult{CustomID: items[0].CustomID, TokenUsage: &models.TokenUsage{TotalCost: 0.001, RawResponse: "not json"}}
+	if result.CustomID == "req-0" {
+		result.Status, result.Error = "errored", "failed to parse response"
+	} else {
+		result.Status = "succeeded"
+		result.Classification = &models.Classification{DocumentType: "invoice", Confidence: 0.9}
+	}
+	return []agents.BatchResult{result}, nil
+}
+
+func TestBatch_RecordsEveryBilledResult(t *testing.T) {
+	agents.SetClient(&failingBatchClient{&splitBatchClient{MockClient: agents.NewMockClient()}})
+	defer agents.SetClient(nil)
+
+	ids := []string{"billed-doc-1", "billed-doc-2"}
+	for _, id := range ids {
+		store.Get().SaveDocument(&models.Document{ID: id, PDFData: []byte("%PDF-1.4 " + id), CreatedAt: time.Now()})
+	}
+	body, _ := json.Marshal(SubmitBatchRequest{DocumentIDs: ids})
+	rr := httptest.NewRecorder()
+	SubmitBatch(rr, httptest.NewRequest(http.MethodPost, "/api/batches", bytes.NewReader(body)))
+	var submitted models.Batch
+	json.NewDecoder(rr.Body).Decode(&submitted)
+
+	// The second document is deleted before its result is written back
+	store.Get().DeleteDocument(ids[1])
+	batch := getBatch(t, submitted.ID)
+
+	var recorded float64
+	for i, req := range batch.Requests {
+		if req.Status != "errored" || req.PromptID == "" {
+			t.Errorf("Expected request %d to fail with a prompt record, got %+v", i, req)
+			continue
+		}
+		prompt, _ := store.Get().GetPrompt(req.PromptID)
+		if prompt.Error != req.Error || prompt.RawResponse != "not json" {
+			t.Errorf("Expected the error and raw response recorded, got %+v", prompt)
+		}
+		recorded += prompt.TotalCost
+	}
+	if math.Abs(batch.TotalCost-0.002) > 1e-9 || math.Abs(recorded-batch.TotalCost) > 1e-9 {
+		t.Errorf("Expected the prompt records to add up to the batch cost %f, got %f", batch.TotalCost, recorded)
+	}
+}
+
+func TestSubmitBatch_ExtractionRequiresDocumentType(t *testing.T) {
+	server := newFakeBatchServer()
+	defer server.Close()
+	agents.SetClient(agents.NewClaudeClient(option.WithBaseURL(server.URL), option.WithAPIKey("test-key")))
+	defer agents.SetClient(nil)
+
+	store.Get().SaveDocument(&models.Document{ID: "batch-unclassified", PDFData: []byte("%PDF-1.4")})
+
+	body, _ := json.Marshal(SubmitBatchRequest{DocumentIDs: []string{"batch-unclassified"}, AgentType: "extraction"})
+	req := httptest.NewRequest(http.MethodPost, "/api/batches", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	SubmitBatch(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rr.Code)
+	}
+}
+
+func TestSubmitBatch_UnsupportedProvider(t *testing.T) {
+	agents.SetClient(agents.NewMockClient())
+	defer agents.SetClient(nil)
+
+	body, _ := json.Marshal(SubmitBatchRequest{DocumentIDs: []string{"doc"}})
+	req := httptest.NewRequest(http.MethodPost, "/api/batches", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	SubmitBatch(rr, req)
+
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status 501, got %d", rr.Code)
+	}
+}
+
+func TestGetBatch_NotFound(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/batches/missing", nil)
+	req.SetPathValue("id", "missing")
+	rr := httptest.NewRecorder()
+	GetBatch(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/pdf"
+	"github.com/pdf-viewer/backend/store"
+)
+
+type SegmentBundleRequest struct {
+	Model   string `json:"model,omitempty"`   // Override the server default model
+	Replace bool   `json:"replace,omitempty"` // Delete the documents of an earlier split
+}
+
+type BundleResponse struct {
+	DocumentID string               `json:"document_id"`
+	Bundle     *models.BundleReport `json:"bundle"`
+	PromptID   string               `json:"prompt_id,omitempty"`
+	// Children are the documents split from the bundle, in page order
+	Children []UploadResponse `json:"children,omitempty"`
+}
+
+type SplitBundleRequest struct {
+	// Segments overrides the proposed segments, for example after a reviewer
+	// moved a boundary; empty splits at the proposed boundaries
+	Segments []models.BundleSegment `json:"segments,omitempty"`
+	Replace  bool                   `json:"replace,omitempty"` // Delete the documents of an earlier split
+}
+
+// SegmentBundle classifies the page ranges of a PDF that may bundle several
+// documents and stores the proposed document boundaries on it. A bundle
+// already split is only segmented again with Replace, which deletes the
+// documents of the earlier split.
+func SegmentBundle(w http.ResponseWriter, r *http.Request) {
+	var req SegmentBundleRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	model, err := agents.GetModelConfig().Resolve(agents.StepBundle, req.Model)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	doc, err := store.Get().GetDocument(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Document not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	previous := splitChildren(doc.Bundle)
+	if len(previous) > 0 && !req.Replace {
+		writeSplitConflict(w, previous)
+		return
+	}
+
+	types, err := loadDocumentTypes()
+	if err != nil {
+		http.Error(w, "Failed to list document types: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bundle, step, err := agents.SegmentBundle(r.Context(), agents.GetClient(), doc.PDFData, agents.Options{Model: model, DocumentTypes: types})
+	if err != nil {
+		writeAgentError(w, "Bundle segmentation failed: ", err)
+		return
+	}
+	bundle.CreatedAt = time.Now()
+
+	// Save prompt record with token usage
+	promptRecord := newPromptRecord(doc.ID, agents.StepBundle, step.Prompt, step.Response, step.TokenUsage)
+	store.Get().SavePrompt(promptRecord)
+
+	// Save proposed boundaries to document, checking again under the lock
+	// for a split made during the call
+	defer lockBundle(doc.ID)()
+	if doc, err = store.Get().GetDocument(doc.ID); err != nil {
+		http.Error(w, "Document not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	previous = splitChildren(doc.Bundle)
+	if len(previous) > 0 && !req.Replace {
+		writeSplitConflict(w, previous)
+		return
+	}
+	if err := store.Get().SaveBundle(doc.ID, bundle); err != nil {
+		http.Error(w, "Failed to save bundle: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	deleteDocuments(previous)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BundleResponse{DocumentID: doc.ID, Bundle: bundle, PromptID: promptRecord.ID})
+}
+
+// SplitBundle splits a bundle into one child document per segment, each
+// with its own PDF and a link to the bundle. The children are ordinary
+// documents to classify and extract like any upload. A bundle already split
+// is only split again with Replace, which deletes the children of the
+// previous split once the new ones are saved.
+func SplitBundle(w http.ResponseWriter, r *http.Request) {
+	var req SplitBundleRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	id := r.PathValue("id")
+	defer lockBundle(id)()
+	doc, err := store.Get().GetDocument(id)
+	if err != nil {
+		http.Error(w, "Document not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	segments := req.Segments
+	if len(segments) == 0 {
+		if doc.Bundle == nil {
+			http.Error(w, "Document must be segmented first or segments must be provided", http.StatusBadRequest)
+			return
+		}
+		segments = doc.Bundle.Segments
+	}
+	previous := splitChildren(doc.Bundle)
+	if len(previous) > 0 && !req.Replace {
+		writeSplitConflict(w, previous)
+		return
+	}
+
+	source, err := pdf.Open(doc.PDFData)
+	if err != nil {
+		http.Error(w, "Failed to read PDF: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err := agents.ValidateSegments(segments, source.NumPages()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Build every child before saving any, so a failure leaves no orphans
+	base := strings.TrimSuffix(doc.Filename, filepath.Ext(doc.Filename))
+	now := time.Now()
+	children := make([]*models.Document, len(segments))
+	for i, segment := range segments {
+		pages := make([]int, 0, segment.EndPage-segment.StartPage+1)
+		for n := segment.StartPage; n <= segment.EndPage; n++ {
+			pages = append(pages, n)
+		}
+		data, err := source.ExtractPages(pages)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to split pages %d-%d: %s", segment.StartPage, segment.EndPage, err), http.StatusUnprocessableEntity)
+			return
+		}
+		children[i] = &models.Document{
+			ID:          uuid.New().String(),
+			Filename:    fmt.Sprintf("%s-pages-%d-%d.pdf", base, segment.StartPage, segment.EndPage),
+			ContentType: "application/pdf",
+			Size:        int64(len(data)),
+			PDFData:     data,
+			ParentID:    doc.ID,
+			CreatedAt:   now,
+		}
+	}
+
+	// Save the new children, then point the bundle at them, and only then
+	// delete the previous children, so a failure never leaves the bundle
+	// pointing at deleted documents
+	response := BundleResponse{DocumentID: doc.ID}
+	split := make([]models.BundleSegment, len(segments))
+	for i, child := range children {
+		if err := store.Get().SaveDocument(child); err != nil {
+			deleteDocuments(childIDs(split[:i]))
+			http.Error(w, "Failed to save document: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		split[i] = segments[i]
+		split[i].ChildID = child.ID
+		response.Children = append(response.Children, UploadResponse{ID: child.ID, Filename: child.Filename, Size: child.Size})
+	}
+
+	// Save the split segments with their children to the bundle
+	bundle := models.BundleReport{CreatedAt: now}
+	if doc.Bundle != nil {
+		bundle = *doc.Bundle
+	}
+	bundle.Segments = split
+	bundle.SplitAt = &now
+	if err := store.Get().SaveBundle(doc.ID, &bundle); err != nil {
+		deleteDocuments(childIDs(split))
+		http.Error(w, "Failed to save bundle: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	deleteDocuments(previous)
+	response.Bundle = &bundle
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// bundleLocks holds a mutex per document ID, so that concurrent splits and
+// segmentations of a bundle each see the children of the one before
+var bundleLocks sync.Map
+
+func lockBundle(id string) (unlock func()) {
+	mu, _ := bundleLocks.LoadOrStore(id, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// splitChildren returns the IDs of the documents split from a bundle
+func splitChildren(bundle *models.BundleReport) []string {
+	if bundle == nil {
+		return nil
+	}
+	return childIDs(bundle.Segments)
+}
+
+// childIDs returns the IDs of the child documents of segments
+func childIDs(segments []models.BundleSegment) []string {
+	var ids []string
+	for _, segment := range segments {
+		if segment.ChildID != "" {
+			ids = append(ids, segment.ChildID)
+		}
+	}
+	return ids
+}
+
+// writeSplitConflict refuses to replace the children of a split, which may
+// already have been classified or extracted, without Replace
+func writeSplitConflict(w http.ResponseWriter, children []string) {
+	http.Error(w, fmt.Sprintf("Bundle is already split into %d documents; set replace to delete them", len(children)), http.StatusConflict)
+}
+
+// deleteDocuments removes the children of a replaced split, or those saved
+// by a split that failed
+func deleteDocuments(ids []string) {
+	for _, id := range ids {
+		store.Get().DeleteDocument(id)
+	}
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/pdf"
+	"github.com/pdf-viewer/backend/store"
+)
+
+func segmentBundle(id string, req SegmentBundleRequest) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(req)
+	r := httptest.NewRequest(http.MethodPost, "/api/documents/"+id+"/bundle", bytes.NewReader(body))
+	r.SetPathValue("id", id)
+	rr := httptest.NewRecorder()
+	SegmentBundle(rr, r)
+	return rr
+}
+
+func splitBundle(id string, req SplitBundleRequest) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(req)
+	r := httptest.NewRequest(http.MethodPost, "/api/documents/"+id+"/split", bytes.NewReader(body))
+	r.SetPathValue("id", id)
+	rr := httptest.NewRecorder()
+	SplitBundle(rr, r)
+	return rr
+}
+
+func TestSplitBundle_ChildrenAreProcessedIndependently(t *testing.T) {
+	agents.SetClient(&agents.MockClient{
+		BundleFunc: func(ctx context.Context, pdfData []byte, opts agents.Options) (*models.BundleReport, string, *models.TokenUsage, error) {
+			return &models.BundleReport{Segments: []models.BundleSegment{
+				{StartPage: 1, EndPage: 2, DocumentType: "invoice", Confidence: 0.9},
+				{StartPage: 3, EndPage: 3, DocumentType: "receipt", Confidence: 0.8},
+			}}, "bundle prompt", &models.TokenUsage{Model: "claude-sonnet-4-5-20250929", InputTokens: 3000, OutputTokens: 100, TotalCost: 0.0105}, nil
+		},
+		ClassifyFunc: func(ctx context.Context, pdfData []byte, opts agents.Options) (*models.Classification, string, *models.TokenUsage, error) {
+			texts, _ := pdf.ExtractPageTexts(pdfData)
+			if len(texts) != 1 || !strings.Contains(texts[0], "Receipt") {
+				t.Errorf("Expected only the receipt page, got %q", texts)
+			}
+			return &models.Classification{DocumentType: "receipt", Confidence: 0.9}, "classify prompt", &models.TokenUsage{Model: "claude-sonnet-4-5-20250929"}, nil
+		},
+	})
+	defer agents.SetClient(nil)
+
+	doc := &models.Document{ID: "bundle-doc", Filename: "scan.pdf", PDFData: pdf.GenerateTextPDF([]string{"Invoice 1", "Invoice 1 page 2", "Receipt"})}
+	store.Get().SaveDocument(doc)
+
+	if rr := splitBundle(doc.ID, SplitBundleRequest{}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 before segmentation, got %d", rr.Code)
+	}
+
+	rr := segmentBundle(doc.ID, SegmentBundleRequest{})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var proposed BundleResponse
+	json.NewDecoder(rr.Body).Decode(&proposed)
+	if len(proposed.Bundle.Segments) != 2 || proposed.PromptID == "" {
+		t.Errorf("Expected 2 proposed segments, got %+v", proposed)
+	}
+
+	rr = splitBundle(doc.ID, SplitBundleRequest{})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var split BundleResponse
+	json.NewDecoder(rr.Body).Decode(&split)
+	if len(split.Children) != 2 || split.Children[1].Filename != "scan-pages-3-3.pdf" || split.Bundle.SplitAt == nil {
+		t.Fatalf("Expected 2 children, got %+v", split)
+	}
+
+	child, err := store.Get().GetDocument(split.Children[1].ID)
+	if err != nil || child.ParentID != doc.ID || split.Bundle.Segments[1].ChildID != child.ID {
+		t.Fatalf("Expected the child linked to its bundle, got %+v (%v)", child, err)
+	}
+	body, _ := json.Marshal(ClassifyRequest{DocumentID: child.ID})
+	rr = httptest.NewRecorder()
+	ClassifyDocument(rr, httptest.NewRequest(http.MethodPost, "/api/classify", bytes.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected the child to be classified, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Splitting again at other boundaries replaces the children, but only
+	// when asked to, since they may have been classified already
+	resplit := SplitBundleRequest{Segments: []models.BundleSegment{{StartPage: 1, EndPage: 3, DocumentType: "invoice"}}}
+	if rr := splitBundle(doc.ID, resplit); rr.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409 without replace, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := store.Get().GetDocument(child.ID); err != nil {
+		t.Fatalf("Expected the children to be kept after a refused split, got %v", err)
+	}
+	resplit.Replace = true
+	rr = splitBundle(doc.ID, resplit)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := store.Get().GetDocument(child.ID); err == nil {
+		t.Error("Expected the previous children to be deleted")
+	}
+
+	json.NewDecoder(rr.Body).Decode(&split)
+
+	// Segmenting again also needs replace, and deletes the children once
+	// the new boundaries are saved
+	if rr := segmentBundle(doc.ID, SegmentBundleRequest{}); rr.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409 without replace, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := segmentBundle(doc.ID, SegmentBundleRequest{Replace: true}); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := store.Get().GetDocument(split.Children[0].ID); err == nil {
+		t.Error("Expected the children of the replaced split to be deleted")
+	}
+	if rr := splitBundle(doc.ID, SplitBundleRequest{}); rr.Code != http.StatusOK {
+		t.Errorf("Expected the new boundaries to split without replace, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := splitBundle(doc.ID, SplitBundleRequest{Segments: []models.BundleSegment{{StartPage: 2, EndPage: 4}}, Replace: true}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for segments outside the document, got %d", rr.Code)
+	}
+}
+
+// failingBundleStore fails to save bundle reports, as a full disk would
+type failingBundleStore struct {
+	store.Store
+}
+
+func (s failingBundleStore) SaveBundle(documentID string, bundle *models.BundleReport) error {
+	return errors.New("disk full")
+}
+
+func TestSplitBundle_FailedSaveKeepsPreviousChildren(t *testing.T) {
+	doc := &models.Document{ID: "resplit-doc", Filename: "scan.pdf", PDFData: pdf.GenerateTextPDF([]string{"Invoice", "Receipt"})}
+	store.Get().SaveDocument(doc)
+	rr := splitBundle(doc.ID, SplitBundleRequest{Segments: []models.BundleSegment{{StartPage: 1, EndPage: 1}, {StartPage: 2, EndPage: 2}}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var split BundleResponse
+	json.NewDecoder(rr.Body).Decode(&split)
+
+	previous := store.Get()
+	store.Initialize(failingBundleStore{Store: previous})
+	defer store.Initialize(previous)
+
+	rr = splitBundle(doc.ID, SplitBundleRequest{Segments: []models.BundleSegment{{StartPage: 1, EndPage: 2}}, Replace: true})
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status 500, got %d: %s", rr.Code, rr.Body.String())
+	}
+	bundle, _ := previous.GetDocument(doc.ID)
+	if len(bundle.Bundle.Segments) != 2 {
+		t.Fatalf("Expected the bundle to keep its previous split, got %+v", bundle.Bundle.Segments)
+	}
+	for _, segment := range bundle.Bundle.Segments {
+		if _, err := previous.GetDocument(segment.ChildID); err != nil {
+			t.Errorf("Expected previous child %s to be kept, got %v", segment.ChildID, err)
+		}
+	}
+	docs, _ := previous.ListDocuments(100, 0)
+	for _, d := range docs {
+		if d.ParentID == doc.ID && d.ID != bundle.Bundle.Segments[0].ChildID && d.ID != bundle.Bundle.Segments[1].ChildID {
+			t.Errorf("Expected the new child %s to be removed", d.ID)
+		}
+	}
+}
+
+func TestSplitBundle_ConcurrentSplitsLeaveNoOrphans(t *testing.T) {
+	doc := &models.Document{ID: "concurrent-split-doc", Filename: "scan.pdf", PDFData: pdf.GenerateTextPDF([]string{"Invoice", "Receipt", "Letter"})}
+	store.Get().SaveDocument(doc)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			splitBundle(doc.ID, SplitBundleRequest{Segments: []models.BundleSegment{{StartPage: 1, EndPage: 1}, {StartPage: 2, EndPage: 3}}, Replace: true})
+		}()
+	}
+	wg.Wait()
+
+	bundle, _ := store.Get().GetDocument(doc.ID)
+	docs, _ := store.Get().ListDocuments(0, 0)
+	children := 0
+	for _, d := range docs {
+		if d.ParentID == doc.ID {
+			children++
+		}
+	}
+	if len(bundle.Bundle.Segments) != 2 || children != 2 {
+		t.Errorf("Expected only the 2 children of the last split, got %d for %+v", children, bundle.Bundle.Segments)
+	}
+}
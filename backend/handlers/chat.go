@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/store"
+)
+
+type ChatRequest struct {
+	ThreadID string `json:"thread_id,omitempty"` // Continue this thread; empty starts a new one
+	Message  string `json:"message"`
+	Model    string `json:"model,omitempty"` // Override the server default model
+}
+
+// ChatResponse is the data of the final "done" event of a chat stream
+type ChatResponse struct {
+	ThreadID   string             `json:"thread_id"`
+	Answer     string             `json:"answer"`
+	PromptID   string             `json:"prompt_id"`
+	TokenUsage *models.TokenUsage `json:"token_usage"`
+}
+
+// chatTitleLength bounds the title a thread takes from its first question
+const chatTitleLength = 80
+
+// ChatWithDocument answers a question about a document, continuing a thread
+// or starting a new one. The answer is streamed as server-sent events:
+// "delta" events with pieces of the answer, then "done" with a ChatResponse,
+// or "error" if the agent fails midway. Both turns are appended to the
+// thread only once the answer is complete, and the answer is saved as a
+// "chat" prompt record with its cost; an answer cut off midway is saved as
+// a prompt record too, since its tokens were billed.
+func ChatWithDocument(w http.ResponseWriter, r *http.Request) {
+	var req ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		http.Error(w, "message is required", http.StatusBadRequest)
+		return
+	}
+
+	model, err := agents.GetModelConfig().Resolve(agents.StepChat, req.Model)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	chatter, ok := agents.GetClient().(agents.Chatter)
+	if !ok {
+		http.Error(w, "Chat is not supported by the configured agent", http.StatusNotImplemented)
+		return
+	}
+
+	doc, err := store.Get().GetDocument(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Document not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	now := time.Now()
+	thread := &models.ChatThread{
+		ID:         uuid.New().String(),
+		DocumentID: doc.ID,
+		Title:      chatTitle(req.Message),
+		CreatedAt:  now,
+	}
+	newThread := req.ThreadID == ""
+	if !newThread {
+		thread, err = getDocumentChatThread(doc.ID, req.ThreadID)
+		if err != nil {
+			http.Error(w, "Chat thread not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+	}
+
+	events := newEventStream(w)
+	answer, tokenUsage, err := chatter.Chat(r.Context(), doc.PDFData, thread.Turns, req.Message, agents.Options{Model: model}, func(text string) {
+		events.send("delta", map[string]string{"text": text})
+	})
+	if err != nil {
+		// A stream cut off midway was still billed for what it generated
+		if tokenUsage != nil {
+			store.Get().SavePrompt(newPromptRecord(doc.ID, agents.StepChat, req.Message, answer, tokenUsage))
+		}
+		if !events.started {
+			writeAgentError(w, "Chat failed: ", err)
+			return
+		}
+		events.send("error", map[string]string{"error": "Chat failed: " + err.Error()})
+		return
+	}
+
+	promptRecord := newPromptRecord(doc.ID, agents.StepChat, req.Message, answer, tokenUsage)
+	store.Get().SavePrompt(promptRecord)
+
+	// Turns are appended to what is stored now, not to the thread as it was
+	// read, so a concurrent question on the same thread is not lost
+	turns := []models.ChatTurn{
+		{Role: models.ChatRoleUser, Content: req.Message, CreatedAt: now},
+		{Role: models.ChatRoleAssistant, Content: answer, PromptID: promptRecord.ID, CreatedAt: time.Now()},
+	}
+	if newThread {
+		thread.Turns = turns
+		thread.UpdatedAt = turns[1].CreatedAt
+		err = store.Get().SaveChatThread(thread)
+	} else {
+		_, err = store.Get().AppendChatTurns(thread.ID, turns)
+	}
+	if err != nil {
+		if !events.started {
+			http.Error(w, "Failed to save chat thread: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		events.send("error", map[string]string{"error": "Failed to save chat thread: " + err.Error()})
+		return
+	}
+
+	events.send("done", ChatResponse{
+		ThreadID:   thread.ID,
+		Answer:     answer,
+		PromptID:   promptRecord.ID,
+		TokenUsage: tokenUsage,
+	})
+}
+
+// ListChatThreads returns the chat threads of a document, most recently
+// updated first
+func ListChatThreads(w http.ResponseWriter, r *http.Request) {
+	threads, err := store.Get().ListChatThreads(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Failed to list chat threads: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if threads == nil {
+		threads = []*models.ChatThread{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(threads)
+}
+
+// GetChatThread returns a chat thread with all its turns
+func GetChatThread(w http.ResponseWriter, r *http.Request) {
+	thread, err := getDocumentChatThread(r.PathValue("id"), r.PathValue("thread_id"))
+	if err != nil {
+		http.Error(w, "Chat thread not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(thread)
+}
+
+// DeleteChatThread removes a chat thread. Its prompt records stay in the
+// document's prompt history.
+func DeleteChatThread(w http.ResponseWriter, r *http.Request) {
+	thread, err := getDocumentChatThread(r.PathValue("id"), r.PathValue("thread_id"))
+	if err != nil {
+		http.Error(w, "Chat thread not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err := store.Get().DeleteChatThread(thread.ID); err != nil {
+		http.Error(w, "Failed to delete chat thread: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getDocumentChatThread returns a thread only if it belongs to the document
+func getDocumentChatThread(documentID, threadID string) (*models.ChatThread, error) {
+	thread, err := store.Get().GetChatThread(threadID)
+	if err != nil {
+		return nil, err
+	}
+	if thread.DocumentID != documentID {
+		return nil, fmt.Errorf("chat thread %s does not belong to document %s", threadID, documentID)
+	}
+	return thread, nil
+}
+
+// chatTitle returns the start of a question, cut at a rune boundary
+func chatTitle(message string) string {
+	title := strings.Join(strings.Fields(message), " ")
+	if utf8.RuneCountInString(title) <= chatTitleLength {
+		return title
+	}
+	runes := []rune(title)
+	return string(runes[:chatTitleLength]) + "..."
+}
+
+// eventStream writes server-sent events. The response headers are written
+// with the first event, so errors before it can still set a status code.
+type eventStream struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func newEventStream(w http.ResponseWriter) *eventStream {
+	return &eventStream{w: w}
+}
+
+func (s *eventStream) send(event string, data interface{}) {
+	if !s.started {
+		s.w.Header().Set("Content-Type", "text/event-stream")
+		s.w.Header().Set("Cache-Control", "no-cache")
+		s.w.WriteHeader(http.StatusOK)
+		s.started = true
+	}
+	payload, _ := json.Marshal(data)
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload)
+	http.NewResponseController(s.w).Flush()
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/store"
+)
+
+type sseEvent struct {
+	name string
+	data string
+}
+
+func readEvents(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var event sseEvent
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event.data = strings.TrimPrefix(line, "data: ")
+		case line == "":
+			events = append(events, event)
+			event = sseEvent{}
+		}
+	}
+	return events
+}
+
+func chat(documentID string, req ChatRequest) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(req)
+	r := httptest.NewRequest(http.MethodPost, "/api/documents/"+documentID+"/chat", bytes.NewReader(body))
+	r.SetPathValue("id", documentID)
+	rr := httptest.NewRecorder()
+	ChatWithDocument(rr, r)
+	return rr
+}
+
+func TestChatWithDocument_Thread(t *testing.T) {
+	var history []models.ChatTurn
+	mockClient := &agents.MockClient{
+		ChatFunc: func(ctx context.Context, pdfData []byte, turns []models.ChatTurn, question string, opts agents.Options, onText func(string)) (string, *models.TokenUsage, error) {
+			history = turns
+			onText("Answer to ")
+			onText(question)
+			return "Answer to " + question, &models.TokenUsage{Model: "claude-sonnet-4-5-20250929", InputTokens: 1500, OutputTokens: 20, TotalCost: 0.0048}, nil
+		},
+	}
+	agents.SetClient(mockClient)
+	defer agents.SetClient(nil)
+
+	doc := &models.Document{ID: "chat-doc", Filename: "contract.pdf", PDFData: []byte("%PDF-1.4 contract")}
+	store.Get().SaveDocument(doc)
+
+	rr := chat(doc.ID, ChatRequest{Message: "What's the late fee?"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected an event stream, got %s", ct)
+	}
+	events := readEvents(t, rr.Body.String())
+	if len(events) != 3 || events[0].name != "delta" || events[1].name != "delta" || events[2].name != "done" {
+		t.Fatalf("Expected 2 deltas and done, got %+v", events)
+	}
+	var done ChatResponse
+	json.Unmarshal([]byte(events[2].data), &done)
+	if done.ThreadID == "" || done.Answer != "Answer to What's the late fee?" {
+		t.Errorf("Unexpected done event: %+v", done)
+	}
+
+	rr = chat(doc.ID, ChatRequest{ThreadID: done.ThreadID, Message: "And the due date?"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(history) != 2 || history[0].Content != "What's the late fee?" || history[1].Role != models.ChatRoleAssistant {
+		t.Errorf("Expected the first exchange as history, got %+v", history)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/documents/"+doc.ID+"/chat/"+done.ThreadID, nil)
+	req.SetPathValue("id", doc.ID)
+	req.SetPathValue("thread_id", done.ThreadID)
+	rr = httptest.NewRecorder()
+	GetChatThread(rr, req)
+	var thread models.ChatThread
+	json.NewDecoder(rr.Body).Decode(&thread)
+	if len(thread.Turns) != 4 || thread.Title != "What's the late fee?" || thread.Turns[3].PromptID == "" {
+		t.Errorf("Expected 4 turns with prompt records, got %+v", thread)
+	}
+
+	prompts, _ := store.Get().GetPromptsByDocument(doc.ID)
+	if len(prompts) != 2 || prompts[0].AgentType != agents.StepChat || prompts[0].TotalCost != 0.0048 {
+		t.Errorf("Expected 2 chat prompt records with cost, got %+v", prompts)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/documents/"+doc.ID+"/chat", nil)
+	req.SetPathValue("id", doc.ID)
+	rr = httptest.NewRecorder()
+	ListChatThreads(rr, req)
+	var threads []models.ChatThread
+	json.NewDecoder(rr.Body).Decode(&threads)
+	if len(threads) != 1 {
+		t.Errorf("Expected 1 thread, got %d", len(threads))
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/documents/other-doc/chat/"+done.ThreadID, nil)
+	req.SetPathValue("id", "other-doc")
+	req.SetPathValue("thread_id", done.ThreadID)
+	rr = httptest.NewRecorder()
+	DeleteChatThread(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 deleting through another document, got %d", rr.Code)
+	}
+	req.SetPathValue("id", doc.ID)
+	rr = httptest.NewRecorder()
+	DeleteChatThread(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestChatWithDocument_Errors(t *testing.T) {
+	failAfter := false
+	mockClient := &agents.MockClient{
+		ChatFunc: func(ctx context.Context, pdfData []byte, turns []models.ChatTurn, question string, opts agents.Options, onText func(string)) (string, *models.TokenUsage, error) {
+			if failAfter {
+				onText("The late")
+				return "The late", &models.TokenUsage{Model: "claude-sonnet-4-5-20250929", InputTokens: 1500, OutputTokens: 2, TotalCost: 0.0045}, errors.New("stream interrupted: connection reset")
+			}
+			return "", nil, errors.New("connection reset")
+		},
+	}
+	agents.SetClient(mockClient)
+	defer agents.SetClient(nil)
+
+	doc := &models.Document{ID: "chat-error-doc", Filename: "contract.pdf", PDFData: []byte("%PDF-1.4 contract")}
+	store.Get().SaveDocument(doc)
+
+	if rr := chat(doc.ID, ChatRequest{Message: " "}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an empty message, got %d", rr.Code)
+	}
+	if rr := chat("missing", ChatRequest{Message: "Hi"}); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing document, got %d", rr.Code)
+	}
+	if rr := chat(doc.ID, ChatRequest{ThreadID: "missing", Message: "Hi"}); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing thread, got %d", rr.Code)
+	}
+	if rr := chat(doc.ID, ChatRequest{Message: "Hi"}); rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 when the agent fails before answering, got %d", rr.Code)
+	}
+
+	failAfter = true
+	rr := chat(doc.ID, ChatRequest{Message: "Hi"})
+	events := readEvents(t, rr.Body.String())
+	if len(events) != 2 || events[1].name != "error" {
+		t.Errorf("Expected an error event after the delta, got %+v", events)
+	}
+	if threads, _ := store.Get().ListChatThreads(doc.ID); len(threads) != 0 {
+		t.Errorf("Expected no thread saved for failed answers, got %d", len(threads))
+	}
+	prompts, _ := store.Get().GetPromptsByDocument(doc.ID)
+	if len(prompts) != 1 || prompts[0].Response != "The late" || prompts[0].TotalCost != 0.0045 {
+		t.Errorf("Expected a prompt record for the billed partial answer only, got %+v", prompts)
+	}
+}
@@ -2,17 +2,22 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
-	"time"
+	"strconv"
 
-	"github.com/google/uuid"
 	"github.com/pdf-viewer/backend/agents"
 	"github.com/pdf-viewer/backend/models"
 	"github.com/pdf-viewer/backend/store"
 )
 
 type ClassifyRequest struct {
-	DocumentID string `json:"document_id"`
+	DocumentID     string   `json:"document_id"`
+	Model          string   `json:"model,omitempty"`           // Override the server default model
+	Samples        int      `json:"samples,omitempty"`         // Classify by majority vote of this many samples
+	Models         []string `json:"models,omitempty"`          // Models the samples are spread over; defaults to Model
+	Strict         bool     `json:"strict,omitempty"`          // Reject a document type outside the taxonomy instead of remapping it
+	ThinkingBudget int      `json:"thinking_budget,omitempty"` // Let the model think with this many tokens before classifying
 }
 
 type ClassifyResponse struct {
@@ -28,6 +33,30 @@ func ClassifyDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	model, err := agents.GetModelConfig().Resolve("classification", req.Model)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := agents.ValidateThinkingBudget(req.ThinkingBudget); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Samples < 0 || req.Samples > agents.MaxEnsembleSamples {
+		http.Error(w, fmt.Sprintf("samples must be between 1 and %d", agents.MaxEnsembleSamples), http.StatusBadRequest)
+		return
+	}
+	var ensembleModels []string
+	for _, requested := range req.Models {
+		ensembleModel, err := agents.GetModelConfig().Resolve("classification", requested)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ensembleModels = append(ensembleModels, ensembleModel)
+	}
+
 	// Get document from store
 	doc, err := store.Get().GetDocument(req.DocumentID)
 	if err != nil {
@@ -35,10 +64,37 @@ func ClassifyDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	types, err := loadDocumentTypes()
+	if err != nil {
+		http.Error(w, "Failed to list document types: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	opts := agents.Options{Model: model, DocumentTypes: types, ThinkingBudget: req.ThinkingBudget}
+
+	// Use a prompt template version if any are weighted
+	prompt, promptTemplate, err := renderPromptTemplate(agents.StepClassification, agents.NewPromptData("", "", types))
+	if err != nil {
+		http.Error(w, "Failed to render prompt template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	opts.ClassificationPrompt = prompt
+
+	if req.Samples > 1 || len(ensembleModels) > 1 {
+		classifyEnsemble(w, r, doc, opts, promptTemplate, agents.EnsembleOptions{Samples: max(req.Samples, len(ensembleModels)), Models: ensembleModels}, req.Strict)
+		return
+	}
+
 	// Call agent to classify
-	classification, prompt, tokenUsage, err := agents.GetClient().ClassifyDocument(r.Context(), doc.PDFData)
+	classification, prompt, tokenUsage, err := agents.GetClient().ClassifyDocument(r.Context(), doc.PDFData, opts)
 	if err != nil {
-		http.Error(w, "Classification failed: "+err.Error(), http.StatusInternalServerError)
+		writeAgentError(w, "Classification failed: ", err)
+		return
+	}
+
+	// Keep the document type within the taxonomy
+	if err := agents.ApplyTaxonomy(classification, types, req.Strict); err != nil {
+		store.Get().SavePrompt(setPromptTemplate(newPromptRecord(doc.ID, "classification", prompt, toJSON(classification), tokenUsage), promptTemplate))
+		http.Error(w, "Classification failed: "+err.Error(), http.StatusBadGateway)
 		return
 	}
 
@@ -50,18 +106,43 @@ func ClassifyDocument(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Save prompt record with token usage
-	promptRecord := &models.PromptRecord{
-		ID:           uuid.New().String(),
-		DocumentID:   doc.ID,
-		AgentType:    "classification",
-		Prompt:       prompt,
-		Response:     toJSON(classification),
-		Model:        tokenUsage.Model,
-		InputTokens:  tokenUsage.InputTokens,
-		OutputTokens: tokenUsage.OutputTokens,
-		TotalCost:    tokenUsage.TotalCost,
-		CreatedAt:    time.Now(),
+	promptRecord := setPromptTemplate(newPromptRecord(doc.ID, "classification", prompt, toJSON(classification), tokenUsage), promptTemplate)
+	store.Get().SavePrompt(promptRecord)
+
+	response := ClassifyResponse{
+		DocumentID:     doc.ID,
+		Classification: classification,
+		PromptID:       promptRecord.ID,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// classifyEnsemble classifies a document by majority vote. Every sample is
+// saved to the prompt history, followed by the ensemble result.
+func classifyEnsemble(w http.ResponseWriter, r *http.Request, doc *models.Document, opts agents.Options, promptTemplate *models.PromptTemplate, ensemble agents.EnsembleOptions, strict bool) {
+	classification, steps, err := agents.ClassifyEnsemble(r.Context(), agents.GetClient(), doc.PDFData, opts, ensemble)
+	for _, step := range steps {
+		store.Get().SavePrompt(setPromptTemplate(newPromptRecord(doc.ID, step.AgentType, step.Prompt, step.Response, step.TokenUsage), promptTemplate))
+	}
+	if err != nil {
+		writeAgentError(w, "Classification failed: ", err)
+		return
 	}
+	if err := agents.ApplyTaxonomy(classification, opts.DocumentTypes, strict); err != nil {
+		http.Error(w, "Classification failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	doc.Classification = classification
+	if err := store.Get().SaveDocument(doc); err != nil {
+		http.Error(w, "Failed to save classification: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The votes carry the token usage, so the result record costs nothing
+	promptRecord := setPromptTemplate(newPromptRecord(doc.ID, "classification", steps[0].Prompt, toJSON(classification), nil), promptTemplate)
 	store.Get().SavePrompt(promptRecord)
 
 	response := ClassifyResponse{
@@ -78,3 +159,16 @@ func toJSON(v interface{}) string {
 	b, _ := json.MarshalIndent(v, "", "  ")
 	return string(b)
 }
+
+// writeAgentError reports an agent failure. Transient API errors that
+// outlasted the retry policy become 503 so clients know to try again later.
+func writeAgentError(w http.ResponseWriter, message string, err error) {
+	status := http.StatusInternalServerError
+	if agents.IsRetryable(err) {
+		status = http.StatusServiceUnavailable
+		if delay, ok := agents.RetryAfter(err); ok && delay > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(delay.Seconds()+0.5)))
+		}
+	}
+	http.Error(w, message+err.Error(), status)
+}
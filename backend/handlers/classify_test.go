@@ -5,11 +5,15 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/pdf-viewer/backend/agents"
 	"github.com/pdf-viewer/backend/models"
 	"github.com/pdf-viewer/backend/store"
@@ -90,7 +94,7 @@ func TestClassifyDocument_DocumentNotFound(t *testing.T) {
 func TestClassifyDocument_ClassificationError(t *testing.T) {
 	// Setup mock client that returns an error
 	mockClient := &agents.MockClient{
-		ClassifyFunc: func(ctx context.Context, pdfData []byte) (*models.Classification, string, *models.TokenUsage, error) {
+		ClassifyFunc: func(ctx context.Context, pdfData []byte, opts agents.Options) (*models.Classification, string, *models.TokenUsage, error) {
 			return nil, "", nil, errors.New("classification failed")
 		},
 	}
@@ -119,6 +123,136 @@ func TestClassifyDocument_ClassificationError(t *testing.T) {
 	}
 }
 
+func TestClassifyDocument_OverloadedReturns503(t *testing.T) {
+	apiReq, _ := http.NewRequest(http.MethodPost, "https://api.anthropic.com/v1/messages", nil)
+	overloaded := &anthropic.Error{
+		StatusCode: 529,
+		Request:    apiReq,
+		Response:   &http.Response{StatusCode: 529, Header: http.Header{"Retry-After": []string{"20"}}},
+	}
+	mockClient := &agents.MockClient{
+		ClassifyFunc: func(ctx context.Context, pdfData []byte, opts agents.Options) (*models.Classification, string, *models.TokenUsage, error) {
+			return nil, "", nil, fmt.Errorf("claude API error after 4 attempt(s): %w", overloaded)
+		},
+	}
+	agents.SetClient(mockClient)
+	defer agents.SetClient(nil)
+
+	doc := &models.Document{
+		ID:       "classify-overloaded-doc",
+		Filename: "test.pdf",
+		PDFData:  []byte("%PDF-1.4 test"),
+	}
+	store.Get().SaveDocument(doc)
+
+	body, _ := json.Marshal(ClassifyRequest{DocumentID: "classify-overloaded-doc"})
+	req := httptest.NewRequest(http.MethodPost, "/api/classify", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	ClassifyDocument(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "20" {
+		t.Errorf("Expected Retry-After 20, got '%s'", rr.Header().Get("Retry-After"))
+	}
+}
+
+func TestClassifyDocument_WithModel(t *testing.T) {
+	var usedModel string
+	mockClient := &agents.MockClient{
+		ClassifyFunc: func(ctx context.Context, pdfData []byte, opts agents.Options) (*models.Classification, string, *models.TokenUsage, error) {
+			usedModel = opts.Model
+			return &models.Classification{DocumentType: "invoice"}, "prompt", &models.TokenUsage{Model: opts.Model}, nil
+		},
+	}
+	agents.SetClient(mockClient)
+	defer agents.SetClient(nil)
+	agents.SetModelConfig(&agents.ModelConfig{Allowed: []string{"claude-haiku-4-5"}})
+	defer agents.SetModelConfig(nil)
+
+	doc := &models.Document{ID: "classify-model-doc", Filename: "test.pdf", PDFData: []byte("%PDF-1.4 test")}
+	store.Get().SaveDocument(doc)
+
+	body, _ := json.Marshal(ClassifyRequest{DocumentID: "classify-model-doc", Model: "claude-haiku-4-5"})
+	req := httptest.NewRequest(http.MethodPost, "/api/classify", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	ClassifyDocument(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if usedModel != "claude-haiku-4-5" {
+		t.Errorf("Expected requested model to be passed to agent, got '%s'", usedModel)
+	}
+}
+
+func TestClassifyDocument_ThinkingSavedToPrompt(t *testing.T) {
+	var budget int
+	mockClient := &agents.MockClient{
+		ClassifyFunc: func(ctx context.Context, pdfData []byte, opts agents.Options) (*models.Classification, string, *models.TokenUsage, error) {
+			budget = opts.ThinkingBudget
+			usage := &models.TokenUsage{Model: "claude-sonnet-4-5", OutputTokens: 3000, TotalCost: 0.05, Thinking: "The header says INVOICE.", ThinkingTokens: 2500, ThinkingCost: 0.0375}
+			return &models.Classification{DocumentType: "invoice"}, "prompt", usage, nil
+		},
+	}
+	agents.SetClient(mockClient)
+	defer agents.SetClient(nil)
+
+	doc := &models.Document{ID: "classify-thinking-doc", Filename: "test.pdf", PDFData: []byte("%PDF-1.4 test")}
+	store.Get().SaveDocument(doc)
+
+	body, _ := json.Marshal(ClassifyRequest{DocumentID: "classify-thinking-doc", ThinkingBudget: 2048})
+	rr := httptest.NewRecorder()
+	ClassifyDocument(rr, httptest.NewRequest(http.MethodPost, "/api/classify", bytes.NewReader(body)))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if budget != 2048 {
+		t.Errorf("Expected thinking budget 2048 to be passed to agent, got %d", budget)
+	}
+
+	var response ClassifyResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	prompt, err := store.Get().GetPrompt(response.PromptID)
+	if err != nil {
+		t.Fatalf("Failed to get prompt: %v", err)
+	}
+	if prompt.Thinking != "The header says INVOICE." || prompt.ThinkingTokens != 2500 {
+		t.Errorf("Expected thinking on prompt record, got %q (%d tokens)", prompt.Thinking, prompt.ThinkingTokens)
+	}
+	if prompt.ThinkingCost != 0.0375 || prompt.TotalCost != 0.05 {
+		t.Errorf("Expected thinking cost 0.0375 of 0.05, got %f of %f", prompt.ThinkingCost, prompt.TotalCost)
+	}
+}
+
+func TestClassifyDocument_InvalidThinkingBudget(t *testing.T) {
+	body, _ := json.Marshal(ClassifyRequest{DocumentID: "classify-test-doc", ThinkingBudget: 100})
+	rr := httptest.NewRecorder()
+	ClassifyDocument(rr, httptest.NewRequest(http.MethodPost, "/api/classify", bytes.NewReader(body)))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rr.Code)
+	}
+}
+
+func TestClassifyDocument_DisallowedModel(t *testing.T) {
+	agents.SetModelConfig(&agents.ModelConfig{Allowed: []string{"claude-haiku-4-5"}})
+	defer agents.SetModelConfig(nil)
+
+	body, _ := json.Marshal(ClassifyRequest{DocumentID: "classify-test-doc", Model: "claude-opus-4-5"})
+	req := httptest.NewRequest(http.MethodPost, "/api/classify", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	ClassifyDocument(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rr.Code)
+	}
+}
+
 func TestToJSON(t *testing.T) {
 	input := map[string]string{"key": "value"}
 	result := toJSON(input)
@@ -136,3 +270,66 @@ func TestToJSON(t *testing.T) {
 		t.Errorf("Expected key='value', got '%s'", parsed["key"])
 	}
 }
+
+func TestClassifyDocument_Ensemble(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	mockClient := &agents.MockClient{
+		ClassifyFunc: func(ctx context.Context, pdfData []byte, opts agents.Options) (*models.Classification, string, *models.TokenUsage, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls++
+			documentType := "invoice"
+			if calls == 3 {
+				documentType = "receipt"
+			}
+			return &models.Classification{DocumentType: documentType, Confidence: 0.95}, "prompt", &models.TokenUsage{Model: opts.Model, TotalCost: 0.01}, nil
+		},
+	}
+	agents.SetClient(mockClient)
+	defer agents.SetClient(nil)
+	agents.SetModelConfig(&agents.ModelConfig{Allowed: []string{"claude-haiku-4-5", "claude-sonnet-4-5"}})
+	defer agents.SetModelConfig(nil)
+
+	doc := &models.Document{ID: "classify-ensemble-doc", Filename: "test.pdf", PDFData: []byte("%PDF-1.4 test")}
+	store.Get().SaveDocument(doc)
+
+	body, _ := json.Marshal(ClassifyRequest{DocumentID: doc.ID, Samples: 4, Models: []string{"claude-haiku-4-5", "claude-sonnet-4-5"}})
+	rr := httptest.NewRecorder()
+	ClassifyDocument(rr, httptest.NewRequest(http.MethodPost, "/api/classify", bytes.NewReader(body)))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response ClassifyResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.Classification.DocumentType != "invoice" || response.Classification.Confidence != 0.75 {
+		t.Errorf("Expected invoice at 0.75 agreement, got %+v", response.Classification)
+	}
+
+	// Four votes and the ensemble result
+	prompts, _ := store.Get().GetPromptsByDocument(doc.ID)
+	votes := 0
+	for _, prompt := range prompts {
+		if prompt.AgentType == agents.StepClassificationVote {
+			votes++
+		}
+	}
+	if len(prompts) != 5 || votes != 4 {
+		t.Errorf("Expected 4 votes and a result in the prompt history, got %d of %d", votes, len(prompts))
+	}
+	result, err := store.Get().GetPrompt(response.PromptID)
+	if err != nil || result.TotalCost != 0 || !strings.Contains(result.Response, `"disagreement": true`) {
+		t.Errorf("Expected a free result record with the disagreement, got %+v, %v", result, err)
+	}
+}
+
+func TestClassifyDocument_TooManySamples(t *testing.T) {
+	body, _ := json.Marshal(ClassifyRequest{DocumentID: "classify-test-doc", Samples: agents.MaxEnsembleSamples + 1})
+	rr := httptest.NewRecorder()
+	ClassifyDocument(rr, httptest.NewRequest(http.MethodPost, "/api/classify", bytes.NewReader(body)))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/store"
+)
+
+type CompareRequest struct {
+	BeforeDocumentID string `json:"before_document_id"` // The previous version
+	AfterDocumentID  string `json:"after_document_id"`  // The revised version
+	Model            string `json:"model,omitempty"`    // Override the server default model
+}
+
+type CompareResponse struct {
+	Comparison *models.Comparison `json:"comparison"`
+	PromptID   string             `json:"prompt_id"`
+}
+
+// CompareDocuments lists the clause changes between two versions of a
+// document, with the changed contract fields when both have contract
+// extractions. The prompt record is saved on the revised version.
+func CompareDocuments(w http.ResponseWriter, r *http.Request) {
+	var req CompareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.BeforeDocumentID == "" || req.AfterDocumentID == "" {
+		http.Error(w, "before_document_id and after_document_id are required", http.StatusBadRequest)
+		return
+	}
+	if req.BeforeDocumentID == req.AfterDocumentID {
+		http.Error(w, "Cannot compare a document with itself", http.StatusBadRequest)
+		return
+	}
+
+	model, err := agents.GetModelConfig().Resolve(agents.StepComparison, req.Model)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get both documents from store
+	before, err := store.Get().GetDocument(req.BeforeDocumentID)
+	if err != nil {
+		http.Error(w, "Document not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	after, err := store.Get().GetDocument(req.AfterDocumentID)
+	if err != nil {
+		http.Error(w, "Document not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	types, err := loadDocumentTypes()
+	if err != nil {
+		http.Error(w, "Failed to list document types: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	comparison, step, err := agents.CompareVersions(r.Context(), agents.GetClient(), before, after, types, agents.Options{Model: model})
+	if err != nil {
+		writeAgentError(w, "Comparison failed: ", err)
+		return
+	}
+	comparison.CreatedAt = time.Now()
+
+	// Save prompt record with token usage
+	promptRecord := newPromptRecord(after.ID, agents.StepComparison, step.Prompt, step.Response, step.TokenUsage)
+	store.Get().SavePrompt(promptRecord)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CompareResponse{Comparison: comparison, PromptID: promptRecord.ID})
+}
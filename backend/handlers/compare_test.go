@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/store"
+)
+
+func compare(req CompareRequest) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(req)
+	rr := httptest.NewRecorder()
+	CompareDocuments(rr, httptest.NewRequest(http.MethodPost, "/api/compare", bytes.NewReader(body)))
+	return rr
+}
+
+func TestCompareDocuments_ContractRedline(t *testing.T) {
+	agents.SetClient(&agents.MockClient{
+		CompareFunc: func(ctx context.Context, beforePDF []byte, afterPDF []byte, opts agents.Options) (*models.Comparison, string, *models.TokenUsage, error) {
+			if string(beforePDF) != "%PDF-1.4 msa v1" || string(afterPDF) != "%PDF-1.4 msa v2" {
+				t.Errorf("Expected the previous version first, got %q and %q", beforePDF, afterPDF)
+			}
+			return &models.Comparison{Summary: "Governing law moved to Delaware.", Changes: []models.ClauseChange{
+				{Kind: models.ChangeModified, Clause: "Governing Law", BeforeText: "New York", AfterText: "Delaware", Materiality: models.MaterialityHigh},
+			}}, "comparison prompt", &models.TokenUsage{Model: "claude-sonnet-4-5-20250929", InputTokens: 4000, OutputTokens: 200, TotalCost: 0.015}, nil
+		},
+	})
+	defer agents.SetClient(nil)
+
+	before := &models.Document{ID: "compare-v1", Filename: "msa-v1.pdf", PDFData: []byte("%PDF-1.4 msa v1"),
+		Classification: &models.Classification{DocumentType: "contract"},
+		Extraction:     &models.Extraction{SchemaUsed: "contract", Data: map[string]interface{}{"governing_law": "New York"}}}
+	after := &models.Document{ID: "compare-v2", Filename: "msa-v2.pdf", PDFData: []byte("%PDF-1.4 msa v2"),
+		Classification: &models.Classification{DocumentType: "contract"},
+		Extraction:     &models.Extraction{SchemaUsed: "contract", Data: map[string]interface{}{"governing_law": "Delaware"}}}
+	store.Get().SaveDocument(before)
+	store.Get().SaveDocument(after)
+
+	rr := compare(CompareRequest{BeforeDocumentID: before.ID, AfterDocumentID: after.ID})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response CompareResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if len(response.Comparison.Changes) != 1 || response.Comparison.Model == "" || response.PromptID == "" {
+		t.Errorf("Expected the clause change and prompt record, got %+v", response)
+	}
+	if len(response.Comparison.FieldChanges) != 1 || !response.Comparison.FieldChanges[0].Highlight {
+		t.Errorf("Expected the governing law change highlighted, got %+v", response.Comparison.FieldChanges)
+	}
+
+	prompts, _ := store.Get().GetPromptsByDocument(after.ID)
+	if len(prompts) != 1 || prompts[0].AgentType != agents.StepComparison {
+		t.Errorf("Expected a comparison prompt record on the revised version, got %+v", prompts)
+	}
+}
+
+func TestCompareDocuments_InvalidRequest(t *testing.T) {
+	agents.SetClient(&agents.MockClient{})
+	defer agents.SetClient(nil)
+
+	doc := &models.Document{ID: "compare-only", Filename: "nda.pdf", PDFData: []byte("%PDF-1.4 nda")}
+	store.Get().SaveDocument(doc)
+
+	if rr := compare(CompareRequest{BeforeDocumentID: doc.ID}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without a revised version, got %d", rr.Code)
+	}
+	if rr := compare(CompareRequest{BeforeDocumentID: doc.ID, AfterDocumentID: doc.ID}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 comparing a document with itself, got %d", rr.Code)
+	}
+	if rr := compare(CompareRequest{BeforeDocumentID: doc.ID, AfterDocumentID: "missing"}); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing document, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/store"
+)
+
+type DocumentTypeRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Indicators  []string `json:"indicators,omitempty"`
+	Parent      string   `json:"parent,omitempty"`
+}
+
+// loadDocumentTypes returns the classification taxonomy: the built-in
+// document types followed by the registered ones
+func loadDocumentTypes() ([]models.DocumentType, error) {
+	registered, err := store.Get().ListDocumentTypes()
+	if err != nil {
+		return nil, err
+	}
+	return agents.MergeDocumentTypes(registered), nil
+}
+
+// ListDocumentTypes returns the classification taxonomy
+func ListDocumentTypes(w http.ResponseWriter, r *http.Request) {
+	types, err := loadDocumentTypes()
+	if err != nil {
+		http.Error(w, "Failed to list document types: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(types)
+}
+
+// SaveDocumentType registers a document type, or updates a registered one
+func SaveDocumentType(w http.ResponseWriter, r *http.Request) {
+	var req DocumentTypeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	types, err := loadDocumentTypes()
+	if err != nil {
+		http.Error(w, "Failed to list document types: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	documentType := &models.DocumentType{
+		Name:        req.Name,
+		Description: req.Description,
+		Indicators:  req.Indicators,
+		Parent:      req.Parent,
+		CreatedAt:   time.Now(),
+	}
+	existing, exists := agents.FindDocumentType(types, req.Name)
+	if exists && existing.BuiltIn {
+		http.Error(w, "Cannot redefine built-in document type "+existing.Name, http.StatusConflict)
+		return
+	}
+	if err := agents.ValidateDocumentType(*documentType, types); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if parent, ok := agents.FindDocumentType(types, req.Parent); ok {
+		documentType.Parent = parent.Name
+	}
+	if exists {
+		documentType.CreatedAt = existing.CreatedAt
+	}
+
+	if err := store.Get().SaveDocumentType(documentType); err != nil {
+		http.Error(w, "Failed to save document type: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !exists {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(documentType)
+}
+
+// DeleteDocumentType removes a registered document type that no other type
+// refines
+func DeleteDocumentType(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	types, err := loadDocumentTypes()
+	if err != nil {
+		http.Error(w, "Failed to list document types: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	documentType, ok := agents.FindDocumentType(types, name)
+	if !ok {
+		http.Error(w, "Document type not found: "+name, http.StatusNotFound)
+		return
+	}
+	if documentType.BuiltIn {
+		http.Error(w, "Cannot delete built-in document type "+documentType.Name, http.StatusBadRequest)
+		return
+	}
+	for _, child := range types {
+		if child.Parent == documentType.Name {
+			http.Error(w, "Document type "+documentType.Name+" is the parent of "+child.Name, http.StatusConflict)
+			return
+		}
+	}
+
+	if err := store.Get().DeleteDocumentType(documentType.Name); err != nil {
+		http.Error(w, "Failed to delete document type: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/store"
+)
+
+func saveDocumentType(t *testing.T, req DocumentTypeRequest) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	rr := httptest.NewRecorder()
+	SaveDocumentType(rr, httptest.NewRequest(http.MethodPost, "/api/document-types", bytes.NewReader(body)))
+	return rr
+}
+
+func deleteDocumentType(name string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodDelete, "/api/document-types/"+name, nil)
+	req.SetPathValue("name", name)
+	rr := httptest.NewRecorder()
+	DeleteDocumentType(rr, req)
+	return rr
+}
+
+func TestDocumentTypes_RegisterListAndDelete(t *testing.T) {
+	defer store.Get().DeleteDocumentType("bill_of_lading")
+	defer store.Get().DeleteDocumentType("purchase_order")
+
+	rr := saveDocumentType(t, DocumentTypeRequest{Name: "purchase_order", Description: "A buyer's order", Indicators: []string{"PO number"}, Parent: "Form"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created models.DocumentType
+	json.NewDecoder(rr.Body).Decode(&created)
+	if created.Parent != "form" {
+		t.Errorf("Expected the parent to be named as registered, got %q", created.Parent)
+	}
+
+	if rr := saveDocumentType(t, DocumentTypeRequest{Name: "purchase_order", Description: "A buyer's purchase order", Parent: "form"}); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for an update, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := saveDocumentType(t, DocumentTypeRequest{Name: "bill_of_lading", Parent: "purchase_order"}); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	ListDocumentTypes(rr, httptest.NewRequest(http.MethodGet, "/api/document-types", nil))
+	var types []models.DocumentType
+	json.NewDecoder(rr.Body).Decode(&types)
+	if len(types) != len(agents.DefaultDocumentTypes())+2 {
+		t.Fatalf("Expected the built-in types and 2 registered ones, got %d", len(types))
+	}
+	if last := types[len(types)-1]; last.Name != "purchase_order" || last.Description != "A buyer's purchase order" || last.BuiltIn {
+		t.Errorf("Expected the updated purchase_order last, got %+v", last)
+	}
+
+	if rr := deleteDocumentType("purchase_order"); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 deleting a parent type, got %d", rr.Code)
+	}
+	if rr := deleteDocumentType("bill_of_lading"); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := deleteDocumentType("bill_of_lading"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a deleted type, got %d", rr.Code)
+	}
+}
+
+func TestDocumentTypes_BuiltInTypesAreFixed(t *testing.T) {
+	if rr := saveDocumentType(t, DocumentTypeRequest{Name: "Invoice", Description: "Mine"}); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 redefining a built-in type, got %d", rr.Code)
+	}
+	if rr := deleteDocumentType("invoice"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 deleting a built-in type, got %d", rr.Code)
+	}
+	if rr := saveDocumentType(t, DocumentTypeRequest{Name: "w9", Parent: "tax_form"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown parent, got %d", rr.Code)
+	}
+}
+
+func TestClassifyDocument_UsesRegisteredTypes(t *testing.T) {
+	store.Get().SaveDocumentType(&models.DocumentType{Name: "W-9", Description: "US taxpayer identification request", Parent: "form"})
+	defer store.Get().DeleteDocumentType("W-9")
+
+	var prompt string
+	answer := "w9"
+	mockClient := &agents.MockClient{
+		ClassifyFunc: func(ctx context.Context, pdfData []byte, opts agents.Options) (*models.Classification, string, *models.TokenUsage, error) {
+			prompt = agents.BuildClassificationPrompt(opts.DocumentTypes)
+			return &models.Classification{DocumentType: answer, Confidence: 0.9}, prompt, &models.TokenUsage{}, nil
+		},
+	}
+	agents.SetClient(mockClient)
+	defer agents.SetClient(nil)
+
+	doc := &models.Document{ID: "classify-taxonomy-doc", Filename: "w9.pdf", PDFData: []byte("%PDF-1.4 w9")}
+	store.Get().SaveDocument(doc)
+
+	classify := func(strict bool) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(ClassifyRequest{DocumentID: doc.ID, Strict: strict})
+		rr := httptest.NewRecorder()
+		ClassifyDocument(rr, httptest.NewRequest(http.MethodPost, "/api/classify", bytes.NewReader(body)))
+		return rr
+	}
+
+	rr := classify(true)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(prompt, "- W-9 (a kind of form)") {
+		t.Errorf("Expected the registered type in the prompt, got %s", prompt)
+	}
+	var response ClassifyResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.Classification.DocumentType != "W-9" {
+		t.Errorf("Expected w9 to be named W-9, got %s", response.Classification.DocumentType)
+	}
+
+	answer = "bill_of_lading"
+	if rr := classify(true); rr.Code != http.StatusBadGateway {
+		t.Errorf("Expected status 502 for an unknown type in strict mode, got %d", rr.Code)
+	}
+	rr = classify(false)
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.Classification.DocumentType != "other" || response.Classification.UnmappedType != "bill_of_lading" {
+		t.Errorf("Expected the unknown type remapped to other, got %+v", response.Classification)
+	}
+}
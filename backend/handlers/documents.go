@@ -9,14 +9,20 @@ import (
 )
 
 type DocumentResponse struct {
-	ID             string      `json:"id"`
-	Filename       string      `json:"filename"`
-	ContentType    string      `json:"content_type"`
-	Size           int64       `json:"size"`
-	PDFBase64      string      `json:"pdf_base64"`
-	Classification interface{} `json:"classification,omitempty"`
-	Extraction     interface{} `json:"extraction,omitempty"`
-	CreatedAt      string      `json:"created_at"`
+	ID              string      `json:"id"`
+	Filename        string      `json:"filename"`
+	ContentType     string      `json:"content_type"`
+	Size            int64       `json:"size"`
+	PDFBase64       string      `json:"pdf_base64"`
+	Classification  interface{} `json:"classification,omitempty"`
+	Extraction      interface{} `json:"extraction,omitempty"`
+	Summaries       interface{} `json:"summaries,omitempty"`
+	Translations    interface{} `json:"translations,omitempty"`
+	Redaction       interface{} `json:"redaction,omitempty"`
+	TableExtraction interface{} `json:"table_extraction,omitempty"`
+	ParentID        string      `json:"parent_id,omitempty"`
+	Bundle          interface{} `json:"bundle,omitempty"`
+	CreatedAt       string      `json:"created_at"`
 }
 
 func GetDocument(w http.ResponseWriter, r *http.Request) {
@@ -33,14 +39,20 @@ func GetDocument(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := DocumentResponse{
-		ID:             doc.ID,
-		Filename:       doc.Filename,
-		ContentType:    doc.ContentType,
-		Size:           doc.Size,
-		PDFBase64:      base64.StdEncoding.EncodeToString(doc.PDFData),
-		Classification: doc.Classification,
-		Extraction:     doc.Extraction,
-		CreatedAt:      doc.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		ID:              doc.ID,
+		Filename:        doc.Filename,
+		ContentType:     doc.ContentType,
+		Size:            doc.Size,
+		PDFBase64:       base64.StdEncoding.EncodeToString(doc.PDFData),
+		Classification:  doc.Classification,
+		Extraction:      doc.Extraction,
+		Summaries:       doc.Summaries,
+		Translations:    doc.Translations,
+		Redaction:       doc.Redaction,
+		TableExtraction: doc.TableExtraction,
+		ParentID:        doc.ParentID,
+		Bundle:          doc.Bundle,
+		CreatedAt:       doc.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -107,3 +107,29 @@ func TestGetDocument_WithClassification(t *testing.T) {
 		t.Error("Expected classification to be present")
 	}
 }
+
+func TestGetDocument_WithSummariesAndTranslations(t *testing.T) {
+	doc := &models.Document{
+		ID:           "test-doc-summarized",
+		Filename:     "vertrag.pdf",
+		ContentType:  "application/pdf",
+		PDFData:      []byte("%PDF-1.4 vertrag"),
+		Summaries:    map[string]*models.Summary{"one_line": {Length: "one_line", Text: "A lease."}},
+		Translations: map[string]*models.Translation{"English": {TargetLanguage: "English"}},
+		CreatedAt:    time.Now(),
+	}
+	store.Get().SaveDocument(doc)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/documents/test-doc-summarized", nil)
+	req.SetPathValue("id", "test-doc-summarized")
+
+	rr := httptest.NewRecorder()
+	GetDocument(rr, req)
+
+	var response DocumentResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+
+	if response.Summaries == nil || response.Translations == nil {
+		t.Errorf("Expected summaries and translations to be present, got %+v", response)
+	}
+}
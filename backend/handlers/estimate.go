@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/store"
+)
+
+type EstimateRequest struct {
+	DocumentID   string `json:"document_id"`
+	AgentType    string `json:"agent_type"`              // "classification" or "extraction"
+	DocumentType string `json:"document_type,omitempty"` // Extraction: override the classification
+	Model        string `json:"model,omitempty"`         // Override the server default model
+}
+
+// EstimateCost counts the input tokens a classification or extraction of a
+// document would send and prices them, without calling the model
+func EstimateCost(w http.ResponseWriter, r *http.Request) {
+	var req EstimateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.AgentType != agents.StepClassification && req.AgentType != agents.StepExtraction {
+		http.Error(w, "agent_type must be classification or extraction", http.StatusBadRequest)
+		return
+	}
+
+	model, err := agents.GetModelConfig().Resolve(req.AgentType, req.Model)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	doc, err := store.Get().GetDocument(req.DocumentID)
+	if err != nil {
+		http.Error(w, "Document not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	types, err := loadDocumentTypes()
+	if err != nil {
+		http.Error(w, "Failed to list document types: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var schema string
+	documentType := req.DocumentType
+	if req.AgentType == agents.StepExtraction {
+		if documentType == "" {
+			if doc.Classification == nil {
+				http.Error(w, "Document must be classified first or document_type must be provided", http.StatusBadRequest)
+				return
+			}
+			documentType = doc.Classification.DocumentType
+		}
+		schema = agents.SchemaForDocumentType(documentType, types)
+	}
+
+	estimate, err := agents.EstimateCost(r.Context(), agents.GetClient(), doc.PDFData, req.AgentType, documentType, schema, agents.Options{Model: model, DocumentTypes: types})
+	if err != nil {
+		writeAgentError(w, "Estimate failed: ", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(estimate)
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/store"
+)
+
+func TestEstimateCost_Extraction(t *testing.T) {
+	var countedType, countedSchema string
+	mockClient := &agents.MockClient{
+		CountFunc: func(ctx context.Context, pdfData []byte, agentType string, documentType string, schema string, opts agents.Options) (*agents.TokenCount, error) {
+			countedType, countedSchema = documentType, schema
+			return &agents.TokenCount{InputTokens: 50_000}, nil
+		},
+	}
+	agents.SetClient(mockClient)
+	defer agents.SetClient(nil)
+
+	store.Get().SaveDocument(&models.Document{
+		ID:             "estimate-doc",
+		PDFData:        []byte("%PDF-1.4 contract"),
+		Classification: &models.Classification{DocumentType: "contract"},
+	})
+
+	body, _ := json.Marshal(EstimateRequest{DocumentID: "estimate-doc", AgentType: "extraction", Model: "claude-sonnet-4-5-20250929"})
+	req := httptest.NewRequest(http.MethodPost, "/api/estimate", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	EstimateCost(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var estimate models.CostEstimate
+	json.NewDecoder(rr.Body).Decode(&estimate)
+	if estimate.InputTokens != 50_000 || estimate.Model != "claude-sonnet-4-5-20250929" || estimate.AgentType != "extraction" {
+		t.Errorf("Unexpected estimate %+v", estimate)
+	}
+	if !estimate.Priced || estimate.MinCost <= 0 || estimate.MaxCost <= estimate.MinCost {
+		t.Errorf("Expected a cost range, got %f-%f", estimate.MinCost, estimate.MaxCost)
+	}
+	if countedType != "contract" || countedSchema != agents.GetSchemaForDocumentType("contract") {
+		t.Errorf("Expected the contract schema to be counted, got %s", countedType)
+	}
+}
+
+func TestEstimateCost_InvalidRequests(t *testing.T) {
+	agents.SetClient(agents.NewMockClient())
+	defer agents.SetClient(nil)
+	store.Get().SaveDocument(&models.Document{ID: "estimate-unclassified", PDFData: []byte("%PDF-1.4")})
+
+	tests := []struct {
+		name   string
+		req    EstimateRequest
+		status int
+	}{
+		{"unknown agent type", EstimateRequest{DocumentID: "estimate-unclassified", AgentType: "chat"}, http.StatusBadRequest},
+		{"unclassified extraction", EstimateRequest{DocumentID: "estimate-unclassified", AgentType: "extraction"}, http.StatusBadRequest},
+		{"missing document", EstimateRequest{DocumentID: "missing", AgentType: "classification"}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.req)
+			req := httptest.NewRequest(http.MethodPost, "/api/estimate", bytes.NewReader(body))
+			rr := httptest.NewRecorder()
+			EstimateCost(rr, req)
+			if rr.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, rr.Code)
+			}
+		})
+	}
+}
@@ -3,17 +3,18 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
-	"time"
 
-	"github.com/google/uuid"
 	"github.com/pdf-viewer/backend/agents"
 	"github.com/pdf-viewer/backend/models"
 	"github.com/pdf-viewer/backend/store"
 )
 
 type ExtractRequest struct {
-	DocumentID   string `json:"document_id"`
-	DocumentType string `json:"document_type,omitempty"` // Override classification if needed
+	DocumentID     string `json:"document_id"`
+	DocumentType   string `json:"document_type,omitempty"`   // Override classification if needed
+	Model          string `json:"model,omitempty"`           // Override the server default model
+	ThinkingBudget int    `json:"thinking_budget,omitempty"` // Let the model think with this many tokens before extracting
+	Ground         bool   `json:"ground,omitempty"`          // Also cite each field in the PDF, at the cost of extra model calls
 }
 
 type ExtractResponse struct {
@@ -30,6 +31,16 @@ func ExtractData(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	model, err := agents.GetModelConfig().Resolve("extraction", req.Model)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := agents.ValidateThinkingBudget(req.ThinkingBudget); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Get document from store
 	doc, err := store.Get().GetDocument(req.DocumentID)
 	if err != nil {
@@ -48,16 +59,42 @@ func ExtractData(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Get schema for document type
-	schema := agents.GetSchemaForDocumentType(documentType)
+	// Get schema for document type, or the nearest parent type that has one
+	types, err := loadDocumentTypes()
+	if err != nil {
+		http.Error(w, "Failed to list document types: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	schema := agents.SchemaForDocumentType(documentType, types)
+
+	// Use a prompt template version if any are weighted
+	prompt, promptTemplate, err := renderPromptTemplate(agents.StepExtraction, agents.NewPromptData(documentType, schema, types))
+	if err != nil {
+		http.Error(w, "Failed to render prompt template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	opts := agents.Options{Model: model, ExtractionPrompt: prompt, ThinkingBudget: req.ThinkingBudget}
 
-	// Call agent to extract
-	extraction, prompt, tokenUsage, err := agents.GetClient().ExtractData(r.Context(), doc.PDFData, documentType, schema)
+	// Call agent to extract page range by page range, validating against the
+	// schema and repairing if needed
+	extraction, steps, err := agents.ExtractChunked(r.Context(), agents.GetClient(), doc.PDFData, documentType, schema, opts, agents.DefaultMaxRepairRounds, agents.DefaultChunkOptions())
 	if err != nil {
-		http.Error(w, "Extraction failed: "+err.Error(), http.StatusInternalServerError)
+		saveExtractionSteps(doc.ID, steps, schema, promptTemplate)
+		writeAgentError(w, "Extraction failed: ", err)
 		return
 	}
 
+	// Ground each field in the PDF with API-verified citations, if asked to
+	if req.Ground {
+		grounding, groundingSteps := agents.GroundExtraction(r.Context(), agents.GetClient(), doc.PDFData, extraction, agents.Options{Model: model}, agents.DefaultChunkOptions())
+		extraction.Grounding = grounding
+		steps = append(steps, groundingSteps...)
+	}
+
+	// Check the quoted source text against the PDF's text layer
+	extraction.Verification = agents.VerifyExtraction(extraction, doc.PDFData)
+	steps = append(steps, agents.ExtractionStep{AgentType: agents.StepVerification, Response: toJSON(extraction.Verification)})
+
 	// Save extraction to document
 	doc.Extraction = extraction
 	if err := store.Get().SaveDocument(doc); err != nil {
@@ -65,29 +102,37 @@ func ExtractData(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Save prompt record with token usage
-	promptRecord := &models.PromptRecord{
-		ID:           uuid.New().String(),
-		DocumentID:   doc.ID,
-		AgentType:    "extraction",
-		Prompt:       prompt,
-		Response:     toJSON(extraction),
-		Schema:       schema,
-		Model:        tokenUsage.Model,
-		InputTokens:  tokenUsage.InputTokens,
-		OutputTokens: tokenUsage.OutputTokens,
-		TotalCost:    tokenUsage.TotalCost,
-		CreatedAt:    time.Now(),
-	}
-	store.Get().SavePrompt(promptRecord)
+	promptID := saveExtractionSteps(doc.ID, steps, schema, promptTemplate)
 
 	response := ExtractResponse{
 		DocumentID: doc.ID,
 		Extraction: extraction,
-		PromptID:   promptRecord.ID,
+		PromptID:   promptID,
 		SchemaUsed: schema,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// saveExtractionSteps saves a prompt record for every extraction,
+// validation, repair, citation and verification step, and returns the ID of
+// the model call that produced the final data. Extraction and repair prompts
+// come from the template, if any.
+func saveExtractionSteps(documentID string, steps []agents.ExtractionStep, schema string, promptTemplate *models.PromptTemplate) string {
+	var promptID string
+	for _, step := range steps {
+		promptRecord := newPromptRecord(documentID, step.AgentType, step.Prompt, step.Response, step.TokenUsage)
+		promptRecord.Schema = schema
+		promptRecord.PageStart, promptRecord.PageEnd = step.StartPage, step.EndPage
+		promptRecord.Error = step.Error
+		if step.AgentType == agents.StepExtraction || step.AgentType == agents.StepRepair {
+			setPromptTemplate(promptRecord, promptTemplate)
+		}
+		store.Get().SavePrompt(promptRecord)
+		if step.TokenUsage != nil && step.Error == "" && step.AgentType != agents.StepCitations {
+			promptID = promptRecord.ID
+		}
+	}
+	return promptID
+}
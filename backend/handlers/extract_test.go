@@ -5,13 +5,17 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/pdf-viewer/backend/agents"
 	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/pdf"
 	"github.com/pdf-viewer/backend/store"
 )
 
@@ -147,8 +151,8 @@ func TestExtractData_NoClassificationNoType(t *testing.T) {
 
 func TestExtractData_ExtractionError(t *testing.T) {
 	mockClient := &agents.MockClient{
-		ExtractFunc: func(ctx context.Context, pdfData []byte, documentType string, schema string) (*models.Extraction, string, *models.TokenUsage, error) {
-			return nil, "", nil, errors.New("extraction failed")
+		ExtractFunc: func(ctx context.Context, pdfData []byte, documentType string, schema string, opts agents.Options) (*models.Extraction, string, *models.TokenUsage, error) {
+			return nil, "extract prompt", &models.TokenUsage{Model: "test-model", InputTokens: 1000}, errors.New("extraction failed")
 		},
 	}
 	agents.SetClient(mockClient)
@@ -176,4 +180,346 @@ func TestExtractData_ExtractionError(t *testing.T) {
 	if rr.Code != http.StatusInternalServerError {
 		t.Errorf("Expected status 500, got %d", rr.Code)
 	}
+	prompts, _ := store.Get().GetPromptsByDocument("extract-error-doc")
+	if len(prompts) != 1 || prompts[0].InputTokens != 1000 || prompts[0].Error != "extraction failed" {
+		t.Errorf("Expected a prompt record of the failed call, got %+v", prompts)
+	}
+}
+
+func TestExtractData_UsesAgentDefaultModel(t *testing.T) {
+	var usedModel string
+	mockClient := &agents.MockClient{
+		ExtractFunc: func(ctx context.Context, pdfData []byte, documentType string, schema string, opts agents.Options) (*models.Extraction, string, *models.TokenUsage, error) {
+			usedModel = opts.Model
+			return &models.Extraction{SchemaUsed: documentType}, "prompt", &models.TokenUsage{Model: opts.Model}, nil
+		},
+	}
+	agents.SetClient(mockClient)
+	defer agents.SetClient(nil)
+	agents.SetModelConfig(&agents.ModelConfig{Defaults: map[string]string{"extraction": "claude-opus-4-5"}})
+	defer agents.SetModelConfig(nil)
+
+	doc := &models.Document{
+		ID:             "extract-default-model",
+		Filename:       "test.pdf",
+		PDFData:        []byte("%PDF-1.4 content"),
+		Classification: &models.Classification{DocumentType: "contract"},
+	}
+	store.Get().SaveDocument(doc)
+
+	body, _ := json.Marshal(ExtractRequest{DocumentID: "extract-default-model"})
+	req := httptest.NewRequest(http.MethodPost, "/api/extract", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	ExtractData(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if usedModel != "claude-opus-4-5" {
+		t.Errorf("Expected extraction default model, got '%s'", usedModel)
+	}
+}
+
+func TestExtractData_RecordsValidationAndRepairs(t *testing.T) {
+	mockClient := &agents.MockClient{
+		ExtractFunc: func(ctx context.Context, pdfData []byte, documentType string, schema string, opts agents.Options) (*models.Extraction, string, *models.TokenUsage, error) {
+			return &models.Extraction{SchemaUsed: documentType, Data: map[string]interface{}{"total": "abc"}}, "extract prompt", &models.TokenUsage{Model: "test-model"}, nil
+		},
+		RepairFunc: func(ctx context.Context, pdfData []byte, documentType string, schema string, previous *models.Extraction, validationErrors []models.ValidationError, opts agents.Options) (*models.Extraction, string, *models.TokenUsage, error) {
+			return &models.Extraction{SchemaUsed: documentType, Data: map[string]interface{}{"total": 42.0}}, "repair prompt", &models.TokenUsage{Model: "test-model"}, nil
+		},
+	}
+	agents.SetClient(mockClient)
+	defer agents.SetClient(nil)
+
+	doc := &models.Document{
+		ID:             "extract-repair-doc",
+		Filename:       "invoice.pdf",
+		PDFData:        []byte("%PDF-1.4 content"),
+		Classification: &models.Classification{DocumentType: "invoice"},
+	}
+	store.Get().SaveDocument(doc)
+
+	body, _ := json.Marshal(ExtractRequest{DocumentID: "extract-repair-doc"})
+	req := httptest.NewRequest(http.MethodPost, "/api/extract", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	ExtractData(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var response ExtractResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.Extraction.Validation == nil || !response.Extraction.Validation.Valid {
+		t.Errorf("Expected valid validation report, got %+v", response.Extraction.Validation)
+	}
+
+	prompts, _ := store.Get().GetPromptsByDocument("extract-repair-doc")
+	counts := map[string]int{}
+	for _, p := range prompts {
+		counts[p.AgentType]++
+	}
+	if counts["extraction"] != 1 || counts["validation"] != 2 || counts["extraction_repair"] != 1 {
+		t.Errorf("Unexpected prompt records: %v", counts)
+	}
+
+	final, err := store.Get().GetPrompt(response.PromptID)
+	if err != nil || final.AgentType != "extraction_repair" {
+		t.Errorf("Expected response prompt ID to point at the repair call, got %+v", final)
+	}
+}
+
+func TestExtractData_VerifiesSourceText(t *testing.T) {
+	mockClient := &agents.MockClient{
+		ExtractFunc: func(ctx context.Context, pdfData []byte, documentType string, schema string, opts agents.Options) (*models.Extraction, string, *models.TokenUsage, error) {
+			return &models.Extraction{
+				SchemaUsed: documentType,
+				Data:       map[string]interface{}{},
+				Fields: []models.ExtractedField{
+					{Name: "total", Value: 1250.0, SourceText: "Total due: $1,250.00", PageNumber: 1},
+					{Name: "vendor", Value: "Acme", SourceText: "Sold by Acme Corp", PageNumber: 1},
+				},
+			}, "extract prompt", &models.TokenUsage{Model: "test-model"}, nil
+		},
+	}
+	agents.SetClient(mockClient)
+	defer agents.SetClient(nil)
+
+	doc := &models.Document{
+		ID:             "extract-verify-doc",
+		Filename:       "invoice.pdf",
+		PDFData:        pdf.GenerateTextPDF([]string{"Invoice 1234\nTotal due: $1,250.00"}),
+		Classification: &models.Classification{DocumentType: "other"},
+	}
+	store.Get().SaveDocument(doc)
+
+	body, _ := json.Marshal(ExtractRequest{DocumentID: "extract-verify-doc"})
+	req := httptest.NewRequest(http.MethodPost, "/api/extract", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	ExtractData(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var response ExtractResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	report := response.Extraction.Verification
+	if report == nil || report.Exact != 1 || report.NotFound != 1 || report.HallucinationScore != 0.5 {
+		t.Errorf("Unexpected verification report: %+v", report)
+	}
+	if response.Extraction.Fields[1].Verification != models.VerificationNotFound {
+		t.Errorf("Expected vendor to be not_found, got '%s'", response.Extraction.Fields[1].Verification)
+	}
+
+	saved, _ := store.Get().GetDocument("extract-verify-doc")
+	if saved.Extraction.Verification == nil {
+		t.Error("Expected verification report to be saved with the document")
+	}
+
+	prompts, _ := store.Get().GetPromptsByDocument("extract-verify-doc")
+	found := false
+	for _, p := range prompts {
+		found = found || p.AgentType == "verification"
+	}
+	if !found {
+		t.Error("Expected a verification prompt record")
+	}
+}
+
+func TestExtractData_ChunksLargeDocuments(t *testing.T) {
+	pages := make([]string, 45)
+	for i := range pages {
+		pages[i] = "Statement page"
+	}
+
+	var chunkSizes []int
+	mockClient := &agents.MockClient{
+		ExtractFunc: func(ctx context.Context, pdfData []byte, documentType string, schema string, opts agents.Options) (*models.Extraction, string, *models.TokenUsage, error) {
+			texts, _ := pdf.ExtractPageTexts(pdfData)
+			chunkSizes = append(chunkSizes, len(texts))
+			return &models.Extraction{
+				SchemaUsed: documentType,
+				Data:       map[string]interface{}{"transactions": []interface{}{len(chunkSizes)}},
+			}, "extract prompt", &models.TokenUsage{Model: "test-model"}, nil
+		},
+	}
+	agents.SetClient(mockClient)
+	defer agents.SetClient(nil)
+
+	doc := &models.Document{
+		ID:             "extract-chunked-doc",
+		Filename:       "statement.pdf",
+		PDFData:        pdf.GenerateTextPDF(pages),
+		Classification: &models.Classification{DocumentType: "statement"},
+	}
+	store.Get().SaveDocument(doc)
+
+	body, _ := json.Marshal(ExtractRequest{DocumentID: "extract-chunked-doc"})
+	req := httptest.NewRequest(http.MethodPost, "/api/extract", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	ExtractData(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(chunkSizes) != 3 || chunkSizes[0] != 20 || chunkSizes[2] != 5 {
+		t.Errorf("Expected chunks of 20, 20 and 5 pages, got %v", chunkSizes)
+	}
+
+	var response ExtractResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if len(response.Extraction.Chunks) != 3 {
+		t.Errorf("Expected 3 chunk records, got %+v", response.Extraction.Chunks)
+	}
+
+	prompts, _ := store.Get().GetPromptsByDocument("extract-chunked-doc")
+	ranges := []string{}
+	for _, p := range prompts {
+		if p.AgentType == "extraction" {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", p.PageStart, p.PageEnd))
+		}
+	}
+	sort.Strings(ranges)
+	if strings.Join(ranges, ",") != "1-20,21-40,41-45" {
+		t.Errorf("Expected a prompt record per chunk with its page range, got %v", ranges)
+	}
+}
+
+func TestExtractData_GroundsFieldsWithCitations(t *testing.T) {
+	mockClient := &agents.MockClient{
+		ExtractFunc: func(ctx context.Context, pdfData []byte, documentType string, schema string, opts agents.Options) (*models.Extraction, string, *models.TokenUsage, error) {
+			return &models.Extraction{
+				SchemaUsed: documentType,
+				Data:       map[string]interface{}{},
+				Fields: []models.ExtractedField{
+					{Name: "total", SourceText: "Total $40", PageNumber: 1},
+					{Name: "po_number", Value: "PO-1"},
+				},
+			}, "extract prompt", &models.TokenUsage{Model: "test-model"}, nil
+		},
+	}
+	agents.SetClient(mockClient)
+	defer agents.SetClient(nil)
+
+	doc := &models.Document{
+		ID:             "extract-cited-doc",
+		Filename:       "invoice.pdf",
+		PDFData:        pdf.GenerateTextPDF([]string{"Total $40"}),
+		Classification: &models.Classification{DocumentType: "invoice"},
+	}
+	store.Get().SaveDocument(doc)
+
+	body, _ := json.Marshal(ExtractRequest{DocumentID: "extract-cited-doc", Ground: true})
+	req := httptest.NewRequest(http.MethodPost, "/api/extract", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	ExtractData(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var response ExtractResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	grounding := response.Extraction.Grounding
+	if grounding == nil || grounding.Cited != 1 || grounding.Uncited != 1 {
+		t.Errorf("Expected 1 cited and 1 uncited field, got %+v", grounding)
+	}
+	fields := response.Extraction.Fields
+	if len(fields) != 2 || len(fields[0].Citations) != 1 || !fields[1].Uncited {
+		t.Errorf("Expected citations on the first field and the second flagged, got %+v", fields)
+	}
+
+	final, err := store.Get().GetPrompt(response.PromptID)
+	if err != nil || final.AgentType != "extraction" {
+		t.Errorf("Expected response prompt ID to point at the extraction call, got %+v", final)
+	}
+	prompts, _ := store.Get().GetPromptsByDocument("extract-cited-doc")
+	found := false
+	for _, p := range prompts {
+		found = found || p.AgentType == "citations"
+	}
+	if !found {
+		t.Error("Expected a citations prompt record")
+	}
+}
+
+func TestExtractData_GroundingIsOptIn(t *testing.T) {
+	cited := false
+	mockClient := &agents.MockClient{
+		CiteFunc: func(ctx context.Context, pdfData []byte, fields []models.ExtractedField, opts agents.Options) ([][]models.Citation, string, *models.TokenUsage, error) {
+			cited = true
+			return nil, "cite prompt", nil, nil
+		},
+	}
+	agents.SetClient(mockClient)
+	defer agents.SetClient(nil)
+
+	store.Get().SaveDocument(&models.Document{
+		ID:             "extract-ungrounded-doc",
+		PDFData:        pdf.GenerateTextPDF([]string{"Total $40"}),
+		Classification: &models.Classification{DocumentType: "invoice"},
+	})
+
+	body, _ := json.Marshal(ExtractRequest{DocumentID: "extract-ungrounded-doc"})
+	rr := httptest.NewRecorder()
+	ExtractData(rr, httptest.NewRequest(http.MethodPost, "/api/extract", bytes.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var response ExtractResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if cited || response.Extraction.Grounding != nil {
+		t.Errorf("Expected no citation calls without ground, got %+v", response.Extraction.Grounding)
+	}
+}
+
+func TestExtractData_RecordsChunksBeforeAFailure(t *testing.T) {
+	pages := make([]string, 45)
+	for i := range pages {
+		pages[i] = "Statement page"
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls := 0
+	agents.SetClient(&agents.MockClient{
+		ExtractFunc: func(ctx context.Context, pdfData []byte, documentType string, schema string, opts agents.Options) (*models.Extraction, string, *models.TokenUsage, error) {
+			calls++
+			if calls == 2 {
+				cancel()
+				return nil, "", nil, ctx.Err()
+			}
+			return &models.Extraction{SchemaUsed: documentType, Data: map[string]interface{}{}}, "extract prompt", &models.TokenUsage{Model: "test-model", InputTokens: 1000}, nil
+		},
+	})
+	defer agents.SetClient(nil)
+
+	doc := &models.Document{
+		ID:             "extract-chunk-failure-doc",
+		Filename:       "statement.pdf",
+		PDFData:        pdf.GenerateTextPDF(pages),
+		Classification: &models.Classification{DocumentType: "statement"},
+	}
+	store.Get().SaveDocument(doc)
+
+	body, _ := json.Marshal(ExtractRequest{DocumentID: doc.ID})
+	rr := httptest.NewRecorder()
+	ExtractData(rr, httptest.NewRequest(http.MethodPost, "/api/extract", bytes.NewReader(body)).WithContext(ctx))
+
+	if rr.Code == http.StatusOK {
+		t.Fatalf("Expected the extraction to fail, got %s", rr.Body.String())
+	}
+	prompts, _ := store.Get().GetPromptsByDocument(doc.ID)
+	var billed []string
+	for _, p := range prompts {
+		if p.InputTokens > 0 {
+			billed = append(billed, fmt.Sprintf("%d-%d", p.PageStart, p.PageEnd))
+		}
+	}
+	if strings.Join(billed, ",") != "1-20" {
+		t.Errorf("Expected the first chunk's call to be recorded, got %v", billed)
+	}
 }
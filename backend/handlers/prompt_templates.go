@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/store"
+)
+
+type PromptTemplateRequest struct {
+	ID        string `json:"id"`
+	AgentType string `json:"agent_type"`
+	Template  string `json:"template"`
+	Weight    *int   `json:"weight,omitempty"` // Defaults to 1
+}
+
+type PromptTemplateWeightRequest struct {
+	Weight int `json:"weight"`
+}
+
+// ListPromptTemplates returns every template version, or those of the
+// agent_type query parameter
+func ListPromptTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := store.Get().ListPromptTemplates(r.URL.Query().Get("agent_type"))
+	if err != nil {
+		http.Error(w, "Failed to list prompt templates: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if templates == nil {
+		templates = []*models.PromptTemplate{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(templates)
+}
+
+// GetPromptTemplate returns the versions of a template, oldest first
+func GetPromptTemplate(w http.ResponseWriter, r *http.Request) {
+	versions, err := promptTemplateVersions(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Failed to list prompt templates: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(versions) == 0 {
+		http.Error(w, "Prompt template not found: "+r.PathValue("id"), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+}
+
+// SavePromptTemplate saves a template as the next version of its ID, as
+// allocated by the store. Earlier versions keep their weight, so both stay
+// in the traffic split until one is set to weight 0.
+func SavePromptTemplate(w http.ResponseWriter, r *http.Request) {
+	var req PromptTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	promptTemplate := &models.PromptTemplate{
+		ID:        req.ID,
+		AgentType: req.AgentType,
+		Template:  req.Template,
+		Weight:    1,
+		CreatedAt: time.Now(),
+	}
+	if req.Weight != nil {
+		promptTemplate.Weight = *req.Weight
+	}
+	if err := agents.ValidatePromptTemplate(*promptTemplate); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	versions, err := promptTemplateVersions(req.ID)
+	if err != nil {
+		http.Error(w, "Failed to list prompt templates: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(versions) > 0 && versions[0].AgentType != req.AgentType {
+		http.Error(w, "Prompt template "+req.ID+" is a "+versions[0].AgentType+" template", http.StatusConflict)
+		return
+	}
+
+	if err := store.Get().SavePromptTemplate(promptTemplate); err != nil {
+		http.Error(w, "Failed to save prompt template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(promptTemplate)
+}
+
+// SetPromptTemplateWeight changes the traffic share of a template version
+func SetPromptTemplateWeight(w http.ResponseWriter, r *http.Request) {
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil {
+		http.Error(w, "Invalid version: "+r.PathValue("version"), http.StatusBadRequest)
+		return
+	}
+	var req PromptTemplateWeightRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Weight < 0 {
+		http.Error(w, "weight must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	promptTemplate, err := store.Get().SetPromptTemplateWeight(r.PathValue("id"), version, req.Weight)
+	if err != nil {
+		http.Error(w, "Prompt template not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(promptTemplate)
+}
+
+// promptTemplateVersions returns the versions of a template, oldest first
+func promptTemplateVersions(id string) ([]*models.PromptTemplate, error) {
+	templates, err := store.Get().ListPromptTemplates("")
+	if err != nil {
+		return nil, err
+	}
+	var versions []*models.PromptTemplate
+	for _, promptTemplate := range templates {
+		if promptTemplate.ID == id {
+			versions = append(versions, promptTemplate)
+		}
+	}
+	return versions, nil
+}
+
+// renderPromptTemplate assigns the call a template version of the agent
+// type by weight and renders it. With no weighted version it returns an
+// empty prompt and a nil template, and the built-in prompt is used.
+func renderPromptTemplate(agentType string, data agents.PromptData) (string, *models.PromptTemplate, error) {
+	templates, err := store.Get().ListPromptTemplates(agentType)
+	if err != nil {
+		return "", nil, err
+	}
+	promptTemplate := agents.SelectPromptTemplate(templates, rand.Float64())
+	if promptTemplate == nil {
+		return "", nil, nil
+	}
+	prompt, err := agents.RenderPromptTemplate(promptTemplate.Template, data)
+	if err != nil {
+		return "", nil, err
+	}
+	return prompt, promptTemplate, nil
+}
+
+// setPromptTemplate records the template version a call's prompt came from
+func setPromptTemplate(promptRecord *models.PromptRecord, promptTemplate *models.PromptTemplate) *models.PromptRecord {
+	if promptTemplate != nil {
+		promptRecord.TemplateID = promptTemplate.ID
+		promptRecord.TemplateVersion = promptTemplate.Version
+	}
+	return promptRecord
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/store"
+)
+
+// useTemplateStore gives a test its own store, so the templates it saves
+// do not reach the prompts of other tests
+func useTemplateStore(t *testing.T) {
+	t.Helper()
+	previous := store.Get()
+	store.Initialize(store.NewMemoryStore())
+	t.Cleanup(func() { store.Initialize(previous) })
+}
+
+func savePromptTemplate(t *testing.T, req PromptTemplateRequest) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	rr := httptest.NewRecorder()
+	SavePromptTemplate(rr, httptest.NewRequest(http.MethodPost, "/api/prompt-templates", bytes.NewReader(body)))
+	return rr
+}
+
+func setPromptTemplateWeight(id, version string, weight int) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(PromptTemplateWeightRequest{Weight: weight})
+	req := httptest.NewRequest(http.MethodPut, "/api/prompt-templates/"+id+"/versions/"+version, bytes.NewReader(body))
+	req.SetPathValue("id", id)
+	req.SetPathValue("version", version)
+	rr := httptest.NewRecorder()
+	SetPromptTemplateWeight(rr, req)
+	return rr
+}
+
+func TestPromptTemplates_Versioning(t *testing.T) {
+	useTemplateStore(t)
+
+	rr := savePromptTemplate(t, PromptTemplateRequest{ID: "concise", AgentType: "extraction", Template: "Extract the {{.DocumentType}}"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var first models.PromptTemplate
+	json.NewDecoder(rr.Body).Decode(&first)
+	if first.Version != 1 || first.Weight != 1 {
+		t.Errorf("Expected version 1 with weight 1, got %+v", first)
+	}
+
+	zero := 0
+	rr = savePromptTemplate(t, PromptTemplateRequest{ID: "concise", AgentType: "extraction", Template: "Extract the {{.DocumentType}} per {{.Schema}}", Weight: &zero})
+	var second models.PromptTemplate
+	json.NewDecoder(rr.Body).Decode(&second)
+	if second.Version != 2 || second.Weight != 0 {
+		t.Errorf("Expected version 2 with weight 0, got %+v", second)
+	}
+
+	if rr := savePromptTemplate(t, PromptTemplateRequest{ID: "concise", AgentType: "classification", Template: "Classify"}); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 changing the agent type, got %d", rr.Code)
+	}
+	if rr := savePromptTemplate(t, PromptTemplateRequest{ID: "broken", AgentType: "extraction", Template: "{{.Pages}}"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown variable, got %d", rr.Code)
+	}
+
+	if rr := setPromptTemplateWeight("concise", "2", 3); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := setPromptTemplateWeight("concise", "9", 3); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing version, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/prompt-templates/concise", nil)
+	req.SetPathValue("id", "concise")
+	rr = httptest.NewRecorder()
+	GetPromptTemplate(rr, req)
+	var versions []models.PromptTemplate
+	json.NewDecoder(rr.Body).Decode(&versions)
+	if len(versions) != 2 || versions[1].Weight != 3 {
+		t.Errorf("Expected 2 versions with the new weight, got %+v", versions)
+	}
+
+	rr = httptest.NewRecorder()
+	ListPromptTemplates(rr, httptest.NewRequest(http.MethodGet, "/api/prompt-templates?agent_type=classification", nil))
+	if rr.Body.String() != "[]\n" {
+		t.Errorf("Expected no classification templates, got %s", rr.Body.String())
+	}
+}
+
+func TestExtractData_UsesPromptTemplate(t *testing.T) {
+	useTemplateStore(t)
+	savePromptTemplate(t, PromptTemplateRequest{ID: "terse", AgentType: "extraction", Template: "Extract the {{.DocumentType}}."})
+
+	var sent string
+	mockClient := &agents.MockClient{
+		ExtractFunc: func(ctx context.Context, pdfData []byte, documentType string, schema string, opts agents.Options) (*models.Extraction, string, *models.TokenUsage, error) {
+			sent = opts.ExtractionPrompt
+			return &models.Extraction{Data: map[string]interface{}{}}, opts.ExtractionPrompt, &models.TokenUsage{}, nil
+		},
+	}
+	agents.SetClient(mockClient)
+	defer agents.SetClient(nil)
+
+	doc := &models.Document{ID: "template-doc", Filename: "note.pdf", PDFData: []byte("%PDF-1.4 note")}
+	store.Get().SaveDocument(doc)
+
+	body, _ := json.Marshal(ExtractRequest{DocumentID: doc.ID, DocumentType: "letter"})
+	rr := httptest.NewRecorder()
+	ExtractData(rr, httptest.NewRequest(http.MethodPost, "/api/extract", bytes.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if sent != "Extract the letter." {
+		t.Errorf("Expected the rendered template to be sent, got %q", sent)
+	}
+
+	prompts, _ := store.Get().GetPromptsByDocument(doc.ID)
+	extractions := 0
+	for _, prompt := range prompts {
+		if prompt.AgentType != agents.StepExtraction {
+			continue
+		}
+		extractions++
+		if prompt.TemplateID != "terse" || prompt.TemplateVersion != 1 {
+			t.Errorf("Expected the extraction record to carry terse v1, got %q v%d", prompt.TemplateID, prompt.TemplateVersion)
+		}
+	}
+	if extractions != 1 {
+		t.Errorf("Expected 1 extraction record, got %d", extractions)
+	}
+}
@@ -3,7 +3,11 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
 	"github.com/pdf-viewer/backend/store"
 )
 
@@ -32,3 +36,49 @@ func GetPromptHistory(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(prompts)
 }
+
+// newPromptRecord builds the prompt history entry for an agent call.
+// tokenUsage may be nil for steps that did not call a model.
+func newPromptRecord(documentID, agentType, prompt, response string, tokenUsage *models.TokenUsage) *models.PromptRecord {
+	if tokenUsage == nil {
+		tokenUsage = &models.TokenUsage{}
+	}
+	return &models.PromptRecord{
+		ID:                       uuid.New().String(),
+		DocumentID:               documentID,
+		AgentType:                agentType,
+		Prompt:                   prompt,
+		Response:                 response,
+		Model:                    tokenUsage.Model,
+		InputTokens:              tokenUsage.InputTokens,
+		OutputTokens:             tokenUsage.OutputTokens,
+		TotalCost:                tokenUsage.TotalCost,
+		Unpriced:                 tokenUsage.Unpriced,
+		Attempts:                 tokenUsage.Attempts,
+		CacheCreationInputTokens: tokenUsage.CacheCreationInputTokens,
+		CacheReadInputTokens:     tokenUsage.CacheReadInputTokens,
+		CacheSavings:             tokenUsage.CacheSavings,
+		Thinking:                 tokenUsage.Thinking,
+		ThinkingTokens:           tokenUsage.ThinkingTokens,
+		ThinkingCost:             tokenUsage.ThinkingCost,
+		RawResponse:              tokenUsage.RawResponse,
+		StopReason:               tokenUsage.StopReason,
+		Continuations:            tokenUsage.Continuations,
+		JSONRepairs:              tokenUsage.JSONRepairs,
+		CreatedAt:                time.Now(),
+	}
+}
+
+// saveSteps saves a prompt record for each model call of an agent, with its
+// page range, and returns their IDs
+func saveSteps(documentID string, steps []agents.ExtractionStep) []string {
+	ids := make([]string, len(steps))
+	for i, step := range steps {
+		promptRecord := newPromptRecord(documentID, step.AgentType, step.Prompt, step.Response, step.TokenUsage)
+		promptRecord.PageStart, promptRecord.PageEnd = step.StartPage, step.EndPage
+		promptRecord.Error = step.Error
+		store.Get().SavePrompt(promptRecord)
+		ids[i] = promptRecord.ID
+	}
+	return ids
+}
@@ -124,3 +124,34 @@ func TestGetPromptHistory_WithSchema(t *testing.T) {
 		t.Error("Expected schema to be present")
 	}
 }
+
+func TestNewPromptRecord_CopiesTokenUsage(t *testing.T) {
+	usage := &models.TokenUsage{
+		Model:                    "claude-sonnet-4-5",
+		InputTokens:              100,
+		OutputTokens:             50,
+		TotalCost:                0.01,
+		Attempts:                 2,
+		CacheCreationInputTokens: 3000,
+		CacheReadInputTokens:     6000,
+		CacheSavings:             0.015,
+		RawResponse:              `{"document_type": "invoice"}`,
+		StopReason:               "tool_use",
+		Continuations:            1,
+	}
+
+	record := newPromptRecord("doc-1", "classification", "prompt", "{}", usage)
+
+	if record.ID == "" || record.DocumentID != "doc-1" || record.AgentType != "classification" {
+		t.Errorf("Unexpected record identity: %+v", record)
+	}
+	if record.CacheCreationInputTokens != 3000 || record.CacheReadInputTokens != 6000 {
+		t.Errorf("Expected cache tokens to be copied, got %d/%d", record.CacheCreationInputTokens, record.CacheReadInputTokens)
+	}
+	if record.CacheSavings != 0.015 || record.Attempts != 2 {
+		t.Errorf("Expected savings and attempts to be copied, got %f/%d", record.CacheSavings, record.Attempts)
+	}
+	if record.RawResponse != usage.RawResponse || record.StopReason != "tool_use" || record.Continuations != 1 {
+		t.Errorf("Expected raw response, stop reason and continuations to be copied, got %q/%q/%d", record.RawResponse, record.StopReason, record.Continuations)
+	}
+}
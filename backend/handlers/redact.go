@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/store"
+)
+
+type RedactRequest struct {
+	Agent bool   `json:"agent,omitempty"` // Also ask the model for names, addresses and other PII the detectors miss
+	Model string `json:"model,omitempty"` // Override the server default model
+}
+
+type RedactResponse struct {
+	DocumentID string                  `json:"document_id"`
+	Redaction  *models.RedactionReport `json:"redaction"`
+	PromptID   string                  `json:"prompt_id,omitempty"` // Set when the agent pass ran
+}
+
+// RedactDocument detects the PII in a document and stores a copy of the PDF
+// with it removed, along with the redaction report. A new redaction
+// replaces the previous one.
+func RedactDocument(w http.ResponseWriter, r *http.Request) {
+	var req RedactRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	model, err := agents.GetModelConfig().Resolve(agents.StepPII, req.Model)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	doc, err := store.Get().GetDocument(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Document not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	redacted, report, steps, err := agents.RedactPII(r.Context(), agents.GetClient(), doc.PDFData, agents.RedactionOptions{Agent: req.Agent}, agents.Options{Model: model})
+
+	// Save prompt record of the agent pass with token usage, even if the
+	// redaction then failed
+	response := RedactResponse{DocumentID: doc.ID}
+	var stepModel string
+	for _, step := range steps {
+		if step.TokenUsage != nil {
+			stepModel = step.TokenUsage.Model
+		}
+		promptRecord := newPromptRecord(doc.ID, step.AgentType, step.Prompt, step.Response, step.TokenUsage)
+		store.Get().SavePrompt(promptRecord)
+		response.PromptID = promptRecord.ID
+	}
+
+	if errors.Is(err, agents.ErrNoTextLayer) {
+		http.Error(w, "Redaction failed: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		writeAgentError(w, "Redaction failed: ", err)
+		return
+	}
+	report.CreatedAt = time.Now()
+	report.Model = stepModel
+	response.Redaction = report
+
+	// Save redacted copy and report to document, keeping the original PDF
+	// and what other agents saved during the call
+	if err := store.Get().SaveRedaction(doc.ID, redacted, report); err != nil {
+		http.Error(w, "Failed to save redaction: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetRedactedPDF returns the redacted copy of a document's PDF
+func GetRedactedPDF(w http.ResponseWriter, r *http.Request) {
+	doc, err := store.Get().GetDocument(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Document not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	if doc.RedactedPDFData == nil {
+		http.Error(w, "Document has not been redacted", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote("redacted-"+doc.Filename))
+	w.Write(doc.RedactedPDFData)
+}
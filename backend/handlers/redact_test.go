@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/pdf"
+	"github.com/pdf-viewer/backend/store"
+)
+
+func redact(id string, req RedactRequest) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(req)
+	r := httptest.NewRequest(http.MethodPost, "/api/documents/"+id+"/redact", bytes.NewReader(body))
+	r.SetPathValue("id", id)
+	rr := httptest.NewRecorder()
+	RedactDocument(rr, r)
+	return rr
+}
+
+func getRedactedPDF(id string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/api/documents/"+id+"/redacted", nil)
+	r.SetPathValue("id", id)
+	rr := httptest.NewRecorder()
+	GetRedactedPDF(rr, r)
+	return rr
+}
+
+func TestRedactDocument_StoresRedactedCopy(t *testing.T) {
+	agents.SetClient(&agents.MockClient{
+		PIIFunc: func(ctx context.Context, pdfData []byte, opts agents.Options) (*agents.PIIResult, string, *models.TokenUsage, error) {
+			return &agents.PIIResult{Findings: []agents.PIIFinding{{Type: models.PIIPersonName, Text: "Jane Roe", PageNumber: 1}}},
+				"pii prompt", &models.TokenUsage{Model: "claude-sonnet-4-5-20250929", InputTokens: 2000, OutputTokens: 50, TotalCost: 0.00675}, nil
+		},
+	})
+	defer agents.SetClient(nil)
+
+	doc := &models.Document{ID: "redact-doc", Filename: "resume.pdf", PDFData: pdf.GenerateTextPDF([]string{"Jane Roe\nSSN 123-45-6789"})}
+	store.Get().SaveDocument(doc)
+
+	if rr := getRedactedPDF(doc.ID); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 before redaction, got %d", rr.Code)
+	}
+
+	rr := redact(doc.ID, RedactRequest{Agent: true})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response RedactResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if len(response.Redaction.Spans) != 2 || response.PromptID == "" || response.Redaction.Model == "" {
+		t.Errorf("Expected 2 spans and the agent pass record, got %+v", response)
+	}
+	if strings.Contains(rr.Body.String(), "123-45-6789") {
+		t.Error("Expected the report not to contain the PII")
+	}
+	prompts, _ := store.Get().GetPromptsByDocument(doc.ID)
+	if len(prompts) != 1 || strings.Contains(prompts[0].Response, "Jane Roe") {
+		t.Errorf("Expected the agent pass saved without the PII, got %+v", prompts)
+	}
+
+	rr = getRedactedPDF(doc.ID)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/pdf" {
+		t.Fatalf("Expected the redacted PDF, got %d: %s", rr.Code, rr.Body.String())
+	}
+	texts, err := pdf.ExtractPageTexts(rr.Body.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to read redacted PDF: %v", err)
+	}
+	if strings.Contains(texts[0], "Jane") || strings.Contains(texts[0], "6789") {
+		t.Errorf("Expected the PII removed, got %q", texts[0])
+	}
+
+	saved, _ := store.Get().GetDocument(doc.ID)
+	if saved.Redaction == nil || !bytes.Equal(saved.PDFData, doc.PDFData) {
+		t.Error("Expected the report stored and the original PDF kept")
+	}
+}
+
+func TestRedactDocument_KeepsConcurrentResults(t *testing.T) {
+	doc := &models.Document{ID: "redact-concurrent-doc", Filename: "resume.pdf", PDFData: pdf.GenerateTextPDF([]string{"Jane Roe\nSSN 123-45-6789"})}
+	store.Get().SaveDocument(doc)
+
+	agents.SetClient(&agents.MockClient{
+		PIIFunc: func(ctx context.Context, pdfData []byte, opts agents.Options) (*agents.PIIResult, string, *models.TokenUsage, error) {
+			// A classification finishes while the redaction is being made
+			classified := *doc
+			classified.Classification = &models.Classification{DocumentType: "resume"}
+			store.Get().SaveDocument(&classified)
+			return &agents.PIIResult{}, "pii prompt", &models.TokenUsage{Model: "test-model"}, nil
+		},
+	})
+	defer agents.SetClient(nil)
+
+	if rr := redact(doc.ID, RedactRequest{Agent: true}); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	saved, _ := store.Get().GetDocument(doc.ID)
+	if saved.Classification == nil || saved.Redaction == nil || saved.RedactedPDFData == nil {
+		t.Errorf("Expected both the classification and the redaction, got %+v", saved)
+	}
+	if !bytes.Equal(saved.PDFData, doc.PDFData) {
+		t.Error("Expected the original PDF to be kept")
+	}
+}
+
+func TestRedactDocument_SavesCostOfFailedAgentPass(t *testing.T) {
+	agents.SetClient(&agents.MockClient{
+		PIIFunc: func(ctx context.Context, pdfData []byte, opts agents.Options) (*agents.PIIResult, string, *models.TokenUsage, error) {
+			return nil, "pii prompt", &models.TokenUsage{Model: "claude-sonnet-4-5-20250929", InputTokens: 2000, TotalCost: 0.006}, errors.New("no tool call in response")
+		},
+	})
+	defer agents.SetClient(nil)
+
+	doc := &models.Document{ID: "redact-failed", Filename: "resume.pdf", PDFData: pdf.GenerateTextPDF([]string{"Jane Roe"})}
+	store.Get().SaveDocument(doc)
+
+	if rr := redact(doc.ID, RedactRequest{Agent: true}); rr.Code == http.StatusOK {
+		t.Fatalf("Expected the redaction to fail, got %d", rr.Code)
+	}
+	prompts, _ := store.Get().GetPromptsByDocument(doc.ID)
+	if len(prompts) != 1 || prompts[0].TotalCost != 0.006 {
+		t.Errorf("Expected the failed agent pass to be saved with its cost, got %+v", prompts)
+	}
+}
+
+func TestRedactDocument_InvalidRequest(t *testing.T) {
+	agents.SetClient(&agents.MockClient{})
+	defer agents.SetClient(nil)
+
+	if rr := redact("missing", RedactRequest{}); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing document, got %d", rr.Code)
+	}
+
+	doc := &models.Document{ID: "redact-scan", Filename: "scan.pdf", PDFData: pdf.GenerateTextPDF([]string{""})}
+	store.Get().SaveDocument(doc)
+	if rr := redact(doc.ID, RedactRequest{}); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 without a text layer, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/pdf"
+	"github.com/pdf-viewer/backend/store"
+)
+
+// TestReplay_ClassifyAndExtractInvoice runs the classify and extract handlers
+// through the Claude client against recorded API responses. Re-record
+// testdata/invoice_cassette.json by running the server with
+// AGENT_CASSETTE_MODE=record when a prompt changes.
+func TestReplay_ClassifyAndExtractInvoice(t *testing.T) {
+	cassette, err := agents.NewReplayCassette("testdata/invoice_cassette.json")
+	if err != nil {
+		t.Fatalf("Failed to load cassette: %v", err)
+	}
+	agents.SetClient(agents.NewClaudeClient(option.WithAPIKey("test-key"), option.WithHTTPClient(cassette.HTTPClient())))
+	defer agents.SetClient(nil)
+
+	doc := &models.Document{
+		ID:        "replay-invoice",
+		Filename:  "invoice.pdf",
+		PDFData:   pdf.GenerateTextPDF([]string{"Invoice INV-2024-0117\nAcme Supplies Ltd\nTotal due: 1,250.00 EUR"}),
+		CreatedAt: time.Now(),
+	}
+	store.Get().SaveDocument(doc)
+
+	body, _ := json.Marshal(ClassifyRequest{DocumentID: doc.ID})
+	rr := httptest.NewRecorder()
+	ClassifyDocument(rr, httptest.NewRequest(http.MethodPost, "/api/classify", bytes.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var classified ClassifyResponse
+	json.NewDecoder(rr.Body).Decode(&classified)
+	if classified.Classification.DocumentType != "invoice" || classified.Classification.Confidence != 0.96 {
+		t.Errorf("Expected the recorded invoice classification, got %+v", classified.Classification)
+	}
+
+	body, _ = json.Marshal(ExtractRequest{DocumentID: doc.ID, Ground: true})
+	rr = httptest.NewRecorder()
+	ExtractData(rr, httptest.NewRequest(http.MethodPost, "/api/extract", bytes.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var extracted ExtractResponse
+	json.NewDecoder(rr.Body).Decode(&extracted)
+
+	extraction := extracted.Extraction
+	if extraction.Data["total"] != 1250.0 {
+		t.Errorf("Expected the repaired total 1250, got %v", extraction.Data["total"])
+	}
+	if !extraction.Validation.Valid || extraction.Validation.RepairRounds != 1 {
+		t.Errorf("Expected a valid extraction after one repair, got %+v", extraction.Validation)
+	}
+	if extraction.Verification.Exact != 3 || extraction.Grounding == nil || extraction.Grounding.Cited != 3 {
+		t.Errorf("Expected 3 verified and cited fields, got %+v and %+v", extraction.Verification, extraction.Grounding)
+	}
+
+	// Classification, extraction, repair and citations were model calls
+	prompts, _ := store.Get().GetPromptsByDocument(doc.ID)
+	calls := 0
+	for _, prompt := range prompts {
+		if prompt.AgentType != agents.StepValidation && prompt.AgentType != agents.StepVerification {
+			calls++
+		}
+	}
+	if calls != 4 {
+		t.Errorf("Expected 4 recorded model calls in the prompt history, got %d of %d prompts", calls, len(prompts))
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/store"
+)
+
+type SummarizeRequest struct {
+	DocumentID string `json:"document_id"`
+	Length     string `json:"length,omitempty"` // one_line, paragraph (default) or sections
+	Style      string `json:"style,omitempty"`  // prose (default) or bullets
+	Model      string `json:"model,omitempty"`  // Override the server default model
+}
+
+type SummarizeResponse struct {
+	DocumentID string          `json:"document_id"`
+	Summary    *models.Summary `json:"summary"`
+	PromptID   string          `json:"prompt_id"`
+}
+
+// SummarizeDocument summarizes a document and stores the summary under its
+// length, replacing the previous summary of that length
+func SummarizeDocument(w http.ResponseWriter, r *http.Request) {
+	var req SummarizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	summaryOpts, err := agents.SummaryOptions{Length: req.Length, Style: req.Style}.Normalize()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	model, err := agents.GetModelConfig().Resolve(agents.StepSummary, req.Model)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get document from store
+	doc, err := store.Get().GetDocument(req.DocumentID)
+	if err != nil {
+		http.Error(w, "Document not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	summary, prompt, tokenUsage, err := agents.GetClient().SummarizeDocument(r.Context(), doc.PDFData, summaryOpts, agents.Options{Model: model})
+	if err != nil {
+		writeAgentError(w, "Summarization failed: ", err)
+		return
+	}
+	summary.Length = summaryOpts.Length
+	summary.Style = summaryOpts.Style
+	summary.CreatedAt = time.Now()
+	if tokenUsage != nil {
+		summary.Model = tokenUsage.Model
+	}
+
+	// Save summary to document, keeping what other agents saved during the call
+	if err := store.Get().SaveSummary(doc.ID, summary); err != nil {
+		http.Error(w, "Failed to save summary: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Save prompt record with token usage
+	promptRecord := newPromptRecord(doc.ID, agents.StepSummary, prompt, toJSON(summary), tokenUsage)
+	store.Get().SavePrompt(promptRecord)
+
+	response := SummarizeResponse{
+		DocumentID: doc.ID,
+		Summary:    summary,
+		PromptID:   promptRecord.ID,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
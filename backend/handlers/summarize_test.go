@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/store"
+)
+
+func summarize(req SummarizeRequest) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(req)
+	rr := httptest.NewRecorder()
+	SummarizeDocument(rr, httptest.NewRequest(http.MethodPost, "/api/summarize", bytes.NewReader(body)))
+	return rr
+}
+
+func TestSummarizeDocument_StoresEachLength(t *testing.T) {
+	var requested []agents.SummaryOptions
+	mockClient := &agents.MockClient{
+		SummarizeFunc: func(ctx context.Context, pdfData []byte, summary agents.SummaryOptions, opts agents.Options) (*models.Summary, string, *models.TokenUsage, error) {
+			requested = append(requested, summary)
+			return &models.Summary{Text: summary.Length + " summary"}, "summary prompt", &models.TokenUsage{Model: "claude-sonnet-4-5-20250929", InputTokens: 2000, OutputTokens: 100, TotalCost: 0.0075}, nil
+		},
+	}
+	agents.SetClient(mockClient)
+	defer agents.SetClient(nil)
+
+	doc := &models.Document{ID: "summarize-doc", Filename: "report.pdf", PDFData: []byte("%PDF-1.4 report")}
+	store.Get().SaveDocument(doc)
+
+	rr := summarize(SummarizeRequest{DocumentID: doc.ID})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response SummarizeResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.Summary.Length != agents.SummaryParagraph || response.Summary.Style != agents.SummaryProse || response.Summary.Model == "" {
+		t.Errorf("Expected a prose paragraph with its model, got %+v", response.Summary)
+	}
+
+	if rr := summarize(SummarizeRequest{DocumentID: doc.ID, Length: "one_line", Style: "bullets"}); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if requested[1].Length != agents.SummaryOneLine || requested[1].Style != agents.SummaryBullets {
+		t.Errorf("Expected the requested length and style, got %+v", requested[1])
+	}
+
+	saved, _ := store.Get().GetDocument(doc.ID)
+	if len(saved.Summaries) != 2 || saved.Summaries["one_line"].Text != "one_line summary" {
+		t.Errorf("Expected a summary per length, got %+v", saved.Summaries)
+	}
+
+	prompts, _ := store.Get().GetPromptsByDocument(doc.ID)
+	if len(prompts) != 2 || prompts[0].AgentType != agents.StepSummary || prompts[0].TotalCost != 0.0075 {
+		t.Errorf("Expected 2 summary prompt records with cost, got %+v", prompts)
+	}
+}
+
+func TestSummarizeDocument_KeepsConcurrentResults(t *testing.T) {
+	doc := &models.Document{ID: "summarize-concurrent-doc", Filename: "report.pdf", PDFData: []byte("%PDF-1.4 report")}
+	store.Get().SaveDocument(doc)
+
+	agents.SetClient(&agents.MockClient{
+		SummarizeFunc: func(ctx context.Context, pdfData []byte, summary agents.SummaryOptions, opts agents.Options) (*models.Summary, string, *models.TokenUsage, error) {
+			// A classification finishes while the summary is being written
+			classified := *doc
+			classified.Classification = &models.Classification{DocumentType: "report"}
+			store.Get().SaveDocument(&classified)
+			return &models.Summary{Text: "A report."}, "summary prompt", &models.TokenUsage{Model: "test-model"}, nil
+		},
+	})
+	defer agents.SetClient(nil)
+
+	if rr := summarize(SummarizeRequest{DocumentID: doc.ID}); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	saved, _ := store.Get().GetDocument(doc.ID)
+	if saved.Classification == nil || len(saved.Summaries) != 1 {
+		t.Errorf("Expected both the classification and the summary, got %+v", saved)
+	}
+}
+
+func TestSummarizeDocument_InvalidRequest(t *testing.T) {
+	if rr := summarize(SummarizeRequest{DocumentID: "missing"}); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing document, got %d", rr.Code)
+	}
+	if rr := summarize(SummarizeRequest{DocumentID: "missing", Length: "chapter"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown length, got %d", rr.Code)
+	}
+}
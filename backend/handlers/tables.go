@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/store"
+)
+
+type ExtractTablesRequest struct {
+	Model string `json:"model,omitempty"` // Override the server default model
+}
+
+type ExtractTablesResponse struct {
+	DocumentID      string                  `json:"document_id"`
+	TableExtraction *models.TableExtraction `json:"table_extraction"`
+	PromptID        string                  `json:"prompt_id"`
+}
+
+// ExtractTables extracts every table of a document and stores them,
+// replacing the tables of the previous extraction
+func ExtractTables(w http.ResponseWriter, r *http.Request) {
+	var req ExtractTablesRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	model, err := agents.GetModelConfig().Resolve(agents.StepTables, req.Model)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	doc, err := store.Get().GetDocument(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Document not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	tables, steps, err := agents.ExtractTables(r.Context(), agents.GetClient(), doc.PDFData, agents.Options{Model: model}, agents.DefaultChunkOptions())
+	if err != nil {
+		// Page ranges extracted before the failure were still billed
+		saveSteps(doc.ID, steps)
+		writeAgentError(w, "Table extraction failed: ", err)
+		return
+	}
+	tables.CreatedAt = time.Now()
+
+	// Save tables to document
+	doc.TableExtraction = tables
+	if err := store.Get().SaveDocument(doc); err != nil {
+		http.Error(w, "Failed to save tables: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Save a prompt record with token usage for every call, the first
+	// being returned
+	promptIDs := saveSteps(doc.ID, steps)
+
+	response := ExtractTablesResponse{
+		DocumentID:      doc.ID,
+		TableExtraction: tables,
+		PromptID:        promptIDs[0],
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetTable downloads one extracted table, by its 1-indexed position, as CSV
+// (the default) or JSON according to the format query parameter
+func GetTable(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		http.Error(w, "Unknown table format '"+format+"' (available: csv, json)", http.StatusBadRequest)
+		return
+	}
+
+	doc, err := store.Get().GetDocument(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Document not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	if doc.TableExtraction == nil {
+		http.Error(w, "Document has no extracted tables", http.StatusNotFound)
+		return
+	}
+
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil || index < 1 || index > len(doc.TableExtraction.Tables) {
+		http.Error(w, fmt.Sprintf("Table not found: document has %d table(s)", len(doc.TableExtraction.Tables)), http.StatusNotFound)
+		return
+	}
+	table := doc.TableExtraction.Tables[index-1]
+
+	var data []byte
+	var contentType string
+	if format == "csv" {
+		if data, err = table.CSV(); err != nil {
+			http.Error(w, "Failed to encode table: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		contentType = "text/csv; charset=utf-8"
+	} else {
+		data, _ = json.MarshalIndent(table, "", "  ")
+		contentType = "application/json"
+	}
+
+	filename := fmt.Sprintf("%s-table-%d.%s", strings.TrimSuffix(doc.Filename, filepath.Ext(doc.Filename)), index, format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(filename))
+	w.Write(data)
+}
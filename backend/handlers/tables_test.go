@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/pdf"
+	"github.com/pdf-viewer/backend/store"
+)
+
+func getTable(id, index, format string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/api/documents/"+id+"/tables/"+index+"?format="+format, nil)
+	r.SetPathValue("id", id)
+	r.SetPathValue("index", index)
+	rr := httptest.NewRecorder()
+	GetTable(rr, r)
+	return rr
+}
+
+func TestExtractTables_DownloadsCSVAndJSON(t *testing.T) {
+	agents.SetClient(&agents.MockClient{})
+	defer agents.SetClient(nil)
+
+	doc := &models.Document{ID: "tables-doc", Filename: "prices.pdf", PDFData: pdf.GenerateTextPDF([]string{"Item Amount"})}
+	store.Get().SaveDocument(doc)
+
+	if rr := getTable(doc.ID, "1", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 before extraction, got %d", rr.Code)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/documents/"+doc.ID+"/tables", nil)
+	r.SetPathValue("id", doc.ID)
+	rr := httptest.NewRecorder()
+	ExtractTables(rr, r)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response ExtractTablesResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if len(response.TableExtraction.Tables) != 1 || response.PromptID == "" {
+		t.Errorf("Expected the table and prompt record, got %+v", response)
+	}
+
+	rr = getTable(doc.ID, "1", "")
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("Expected CSV, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr.Body.String() != "Item,Amount\nMock item,100.00\n" {
+		t.Errorf("Unexpected CSV: %q", rr.Body.String())
+	}
+	if !strings.Contains(rr.Header().Get("Content-Disposition"), "prices-table-1.csv") {
+		t.Errorf("Expected a CSV file name, got %q", rr.Header().Get("Content-Disposition"))
+	}
+
+	rr = getTable(doc.ID, "1", "json")
+	var table models.Table
+	if err := json.NewDecoder(rr.Body).Decode(&table); err != nil || table.HeaderRows != 1 || table.Caption != "Mock table" {
+		t.Errorf("Expected the table as JSON, got %+v (%v)", table, err)
+	}
+
+	if rr := getTable(doc.ID, "2", "csv"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing table, got %d", rr.Code)
+	}
+	if rr := getTable(doc.ID, "1", "xlsx"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown format, got %d", rr.Code)
+	}
+}
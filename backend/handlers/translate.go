@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/store"
+)
+
+type TranslateRequest struct {
+	DocumentID     string `json:"document_id"`
+	TargetLanguage string `json:"target_language"`     // Language name or code, such as "English" or "en"
+	FullText       bool   `json:"full_text,omitempty"` // Also translate the whole text, page by page
+	Model          string `json:"model,omitempty"`     // Override the server default model
+}
+
+type TranslateResponse struct {
+	DocumentID  string              `json:"document_id"`
+	Translation *models.Translation `json:"translation"`
+	PromptID    string              `json:"prompt_id"`
+}
+
+// TranslateDocument translates the string values of a document's extraction,
+// and its full text if requested, into the target language. The translation
+// is stored under the target language, replacing the previous one; the
+// extraction is left as it is.
+func TranslateDocument(w http.ResponseWriter, r *http.Request) {
+	var req TranslateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	translationOpts := agents.TranslationOptions{TargetLanguage: strings.TrimSpace(req.TargetLanguage), FullText: req.FullText}
+	if err := agents.ValidateTranslationOptions(translationOpts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	model, err := agents.GetModelConfig().Resolve(agents.StepTranslation, req.Model)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get document from store
+	doc, err := store.Get().GetDocument(req.DocumentID)
+	if err != nil {
+		http.Error(w, "Document not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	if doc.Extraction == nil && !req.FullText {
+		http.Error(w, "Document has no extraction to translate; extract it first or set full_text", http.StatusBadRequest)
+		return
+	}
+
+	translation, steps, err := agents.TranslateExtraction(r.Context(), agents.GetClient(), doc.PDFData, doc.Extraction, translationOpts, agents.Options{Model: model}, agents.DefaultChunkOptions())
+	if err != nil {
+		// Page ranges translated before the failure were still billed
+		saveSteps(doc.ID, steps)
+		writeAgentError(w, "Translation failed: ", err)
+		return
+	}
+	translation.CreatedAt = time.Now()
+	if steps[0].TokenUsage != nil {
+		translation.Model = steps[0].TokenUsage.Model
+	}
+
+	// Save translation to document, keeping what other agents saved during the call
+	if err := store.Get().SaveTranslation(doc.ID, translation); err != nil {
+		http.Error(w, "Failed to save translation: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Save a prompt record with token usage for every call, the first
+	// being returned
+	promptIDs := saveSteps(doc.ID, steps)
+
+	response := TranslateResponse{
+		DocumentID:  doc.ID,
+		Translation: translation,
+		PromptID:    promptIDs[0],
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pdf-viewer/backend/agents"
+	"github.com/pdf-viewer/backend/models"
+	"github.com/pdf-viewer/backend/store"
+)
+
+func translate(req TranslateRequest) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(req)
+	rr := httptest.NewRecorder()
+	TranslateDocument(rr, httptest.NewRequest(http.MethodPost, "/api/translate", bytes.NewReader(body)))
+	return rr
+}
+
+func TestTranslateDocument_KeepsOriginals(t *testing.T) {
+	agents.SetClient(&agents.MockClient{})
+	defer agents.SetClient(nil)
+
+	doc := &models.Document{
+		ID:       "translate-doc",
+		Filename: "rechnung.pdf",
+		PDFData:  []byte("%PDF-1.4 rechnung"),
+		Extraction: &models.Extraction{
+			Data: map[string]interface{}{"description": "Beratung", "total": 100.0},
+			Fields: []models.ExtractedField{
+				{Name: "description", Value: "Beratung", SourceText: "Beratung", PageNumber: 1},
+				{Name: "total", Value: 100.0, SourceText: "100,00 EUR", PageNumber: 1},
+			},
+		},
+	}
+	store.Get().SaveDocument(doc)
+
+	rr := translate(TranslateRequest{DocumentID: doc.ID, TargetLanguage: "English", FullText: true})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response TranslateResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.Translation.Data["description"] != "Translated Beratung" || len(response.Translation.Pages) != 1 {
+		t.Errorf("Expected translated data and pages, got %+v", response.Translation)
+	}
+
+	saved, _ := store.Get().GetDocument(doc.ID)
+	english := saved.Translations["English"]
+	if english == nil || len(english.Fields) != 1 || english.Fields[0].Original != "Beratung" || english.Fields[0].SourceText != "Beratung" {
+		t.Errorf("Expected the translated field with its original, got %+v", saved.Translations)
+	}
+	if saved.Extraction.Data["description"] != "Beratung" {
+		t.Errorf("Expected the extraction to keep its original, got %v", saved.Extraction.Data["description"])
+	}
+
+	prompts, _ := store.Get().GetPromptsByDocument(doc.ID)
+	if len(prompts) != 1 || prompts[0].AgentType != agents.StepTranslation || prompts[0].TotalCost == 0 {
+		t.Errorf("Expected a translation prompt record with cost, got %+v", prompts)
+	}
+}
+
+func TestTranslateDocument_KeepsConcurrentResults(t *testing.T) {
+	doc := &models.Document{
+		ID:         "translate-concurrent-doc",
+		Filename:   "rechnung.pdf",
+		PDFData:    []byte("%PDF-1.4 rechnung"),
+		Extraction: &models.Extraction{Data: map[string]interface{}{"description": "Beratung"}},
+	}
+	store.Get().SaveDocument(doc)
+
+	agents.SetClient(&agents.MockClient{
+		TranslateFunc: func(ctx context.Context, pdfData []byte, texts []string, translation agents.TranslationOptions, opts agents.Options) (*agents.TranslationResult, string, *models.TokenUsage, error) {
+			// A summary finishes while the translation is being written
+			store.Get().SaveSummary(doc.ID, &models.Summary{Length: "one_line", Text: "An invoice."})
+			return &agents.TranslationResult{SourceLanguage: "de"}, "translate prompt", &models.TokenUsage{Model: "test-model"}, nil
+		},
+	})
+	defer agents.SetClient(nil)
+
+	if rr := translate(TranslateRequest{DocumentID: doc.ID, TargetLanguage: "English"}); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	saved, _ := store.Get().GetDocument(doc.ID)
+	if saved.Summaries["one_line"] == nil || saved.Translations["English"] == nil {
+		t.Errorf("Expected both the summary and the translation, got %+v", saved)
+	}
+}
+
+func TestTranslateDocument_InvalidRequest(t *testing.T) {
+	agents.SetClient(&agents.MockClient{})
+	defer agents.SetClient(nil)
+
+	doc := &models.Document{ID: "translate-unextracted-doc", Filename: "brief.pdf", PDFData: []byte("%PDF-1.4 brief")}
+	store.Get().SaveDocument(doc)
+
+	if rr := translate(TranslateRequest{DocumentID: doc.ID, TargetLanguage: "English"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without an extraction, got %d", rr.Code)
+	}
+	if rr := translate(TranslateRequest{DocumentID: doc.ID, TargetLanguage: "English", FullText: true}); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for a full-text translation, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := translate(TranslateRequest{DocumentID: doc.ID, TargetLanguage: "English; drop table"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid language, got %d", rr.Code)
+	}
+	if rr := translate(TranslateRequest{DocumentID: "missing", TargetLanguage: "en"}); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing document, got %d", rr.Code)
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"net/http"
 	"os"
 
+	"github.com/pdf-viewer/backend/agents"
 	"github.com/pdf-viewer/backend/handlers"
 	"github.com/pdf-viewer/backend/middleware"
+	"github.com/pdf-viewer/backend/models"
 	"github.com/pdf-viewer/backend/store"
 )
 
@@ -17,6 +19,12 @@ func main() {
 		log.Fatalf("Failed to initialize store: %v", err)
 	}
 
+	// Initialize the LLM provider based on LLM_PROVIDER
+	// Options: "anthropic" (default), "openai", "ollama"
+	if err := initializeAgents(); err != nil {
+		log.Fatalf("Failed to initialize LLM provider: %v", err)
+	}
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
@@ -28,8 +36,31 @@ func main() {
 	mux.HandleFunc("POST /api/upload", handlers.UploadPDF)
 	mux.HandleFunc("POST /api/classify", handlers.ClassifyDocument)
 	mux.HandleFunc("POST /api/extract", handlers.ExtractData)
+	mux.HandleFunc("POST /api/summarize", handlers.SummarizeDocument)
+	mux.HandleFunc("POST /api/translate", handlers.TranslateDocument)
+	mux.HandleFunc("POST /api/compare", handlers.CompareDocuments)
+	mux.HandleFunc("POST /api/estimate", handlers.EstimateCost)
 	mux.HandleFunc("GET /api/prompts/{id}", handlers.GetPromptHistory)
 	mux.HandleFunc("GET /api/documents/{id}", handlers.GetDocument)
+	mux.HandleFunc("POST /api/documents/{id}/chat", handlers.ChatWithDocument)
+	mux.HandleFunc("GET /api/documents/{id}/chat", handlers.ListChatThreads)
+	mux.HandleFunc("GET /api/documents/{id}/chat/{thread_id}", handlers.GetChatThread)
+	mux.HandleFunc("DELETE /api/documents/{id}/chat/{thread_id}", handlers.DeleteChatThread)
+	mux.HandleFunc("POST /api/documents/{id}/redact", handlers.RedactDocument)
+	mux.HandleFunc("GET /api/documents/{id}/redacted", handlers.GetRedactedPDF)
+	mux.HandleFunc("POST /api/documents/{id}/tables", handlers.ExtractTables)
+	mux.HandleFunc("GET /api/documents/{id}/tables/{index}", handlers.GetTable)
+	mux.HandleFunc("POST /api/documents/{id}/bundle", handlers.SegmentBundle)
+	mux.HandleFunc("POST /api/documents/{id}/split", handlers.SplitBundle)
+	mux.HandleFunc("GET /api/document-types", handlers.ListDocumentTypes)
+	mux.HandleFunc("POST /api/document-types", handlers.SaveDocumentType)
+	mux.HandleFunc("DELETE /api/document-types/{name}", handlers.DeleteDocumentType)
+	mux.HandleFunc("GET /api/prompt-templates", handlers.ListPromptTemplates)
+	mux.HandleFunc("POST /api/prompt-templates", handlers.SavePromptTemplate)
+	mux.HandleFunc("GET /api/prompt-templates/{id}", handlers.GetPromptTemplate)
+	mux.HandleFunc("PUT /api/prompt-templates/{id}/versions/{version}", handlers.SetPromptTemplateWeight)
+	mux.HandleFunc("POST /api/batches", handlers.SubmitBatch)
+	mux.HandleFunc("GET /api/batches/{id}", handlers.GetBatch)
 
 	// Health check
 	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
@@ -46,6 +77,38 @@ func main() {
 	}
 }
 
+// initializeAgents sets up the agent client and model configuration based on
+// environment variables. MODEL_PRICING registers pricing for additional
+// models, such as self-hosted ones, before the allowed models are computed.
+func initializeAgents() error {
+	if pricing := os.Getenv("MODEL_PRICING"); pricing != "" {
+		if err := models.RegisterPricingJSON([]byte(pricing)); err != nil {
+			return err
+		}
+	}
+
+	// AGENT_CASSETTE records the provider's responses or replays them offline
+	client, err := agents.NewClientFromEnv()
+	if err != nil {
+		return err
+	}
+	agents.SetClient(client)
+
+	config := agents.LoadModelConfigFromEnv()
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	agents.SetModelConfig(config)
+	for _, model := range config.Allowed {
+		if !models.IsPriced(model) {
+			log.Printf("Model %s has no pricing; its costs are reported as 0 and flagged unpriced (set MODEL_PRICING to price it)", model)
+		}
+	}
+
+	log.Printf("Using LLM provider %s (default model %s)", config.Provider, config.Fallback)
+	return nil
+}
+
 // initializeStore sets up the storage backend based on environment variables
 func initializeStore() error {
 	storageType := os.Getenv("STORAGE_TYPE")
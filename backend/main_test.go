@@ -43,8 +43,31 @@ func TestRouterSetup(t *testing.T) {
 	mux.HandleFunc("POST /api/upload", handlers.UploadPDF)
 	mux.HandleFunc("POST /api/classify", handlers.ClassifyDocument)
 	mux.HandleFunc("POST /api/extract", handlers.ExtractData)
+	mux.HandleFunc("POST /api/summarize", handlers.SummarizeDocument)
+	mux.HandleFunc("POST /api/translate", handlers.TranslateDocument)
+	mux.HandleFunc("POST /api/compare", handlers.CompareDocuments)
+	mux.HandleFunc("POST /api/estimate", handlers.EstimateCost)
 	mux.HandleFunc("GET /api/prompts/{id}", handlers.GetPromptHistory)
 	mux.HandleFunc("GET /api/documents/{id}", handlers.GetDocument)
+	mux.HandleFunc("POST /api/documents/{id}/chat", handlers.ChatWithDocument)
+	mux.HandleFunc("GET /api/documents/{id}/chat", handlers.ListChatThreads)
+	mux.HandleFunc("GET /api/documents/{id}/chat/{thread_id}", handlers.GetChatThread)
+	mux.HandleFunc("DELETE /api/documents/{id}/chat/{thread_id}", handlers.DeleteChatThread)
+	mux.HandleFunc("POST /api/documents/{id}/redact", handlers.RedactDocument)
+	mux.HandleFunc("GET /api/documents/{id}/redacted", handlers.GetRedactedPDF)
+	mux.HandleFunc("POST /api/documents/{id}/tables", handlers.ExtractTables)
+	mux.HandleFunc("GET /api/documents/{id}/tables/{index}", handlers.GetTable)
+	mux.HandleFunc("POST /api/documents/{id}/bundle", handlers.SegmentBundle)
+	mux.HandleFunc("POST /api/documents/{id}/split", handlers.SplitBundle)
+	mux.HandleFunc("GET /api/document-types", handlers.ListDocumentTypes)
+	mux.HandleFunc("POST /api/document-types", handlers.SaveDocumentType)
+	mux.HandleFunc("DELETE /api/document-types/{name}", handlers.DeleteDocumentType)
+	mux.HandleFunc("GET /api/prompt-templates", handlers.ListPromptTemplates)
+	mux.HandleFunc("POST /api/prompt-templates", handlers.SavePromptTemplate)
+	mux.HandleFunc("GET /api/prompt-templates/{id}", handlers.GetPromptTemplate)
+	mux.HandleFunc("PUT /api/prompt-templates/{id}/versions/{version}", handlers.SetPromptTemplateWeight)
+	mux.HandleFunc("POST /api/batches", handlers.SubmitBatch)
+	mux.HandleFunc("GET /api/batches/{id}", handlers.GetBatch)
 
 	handler := middleware.CORS(mux)
 	handler = middleware.Logger(handler)
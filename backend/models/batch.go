@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Batch statuses. in_progress, canceling and ended are reported by the
+// provider; completed means the results have been written back.
+const (
+	BatchInProgress = "in_progress"
+	BatchCanceling  = "canceling"
+	BatchEnded      = "ended"
+	BatchCompleted  = "completed"
+)
+
+// Batch is a set of classification or extraction requests submitted to a
+// provider's batch API. Requests beyond the provider's limits on a batch are
+// split across several provider batches, and the batch ends with the last.
+type Batch struct {
+	ID               string         `json:"id"`
+	ProviderBatchIDs []string       `json:"provider_batch_ids"`
+	AgentType        string         `json:"agent_type"` // "classification" or "extraction"
+	Model            string         `json:"model"`
+	Status           string         `json:"status"` // One of the Batch* statuses
+	Counts           BatchCounts    `json:"counts"` // Summed over the provider batches
+	Requests         []BatchRequest `json:"requests"`
+	TotalCost        float64        `json:"total_cost"`         // Discounted cost of the results written back, in USD
+	Unpriced         bool           `json:"unpriced,omitempty"` // The model has no known pricing, so TotalCost is 0 rather than measured
+	CreatedAt        time.Time      `json:"created_at"`
+	EndedAt          *time.Time     `json:"ended_at,omitempty"`
+}
+
+// BatchCounts tallies the requests of a batch by status. The provider only
+// moves requests out of processing once the whole batch has ended.
+type BatchCounts struct {
+	Processing int `json:"processing"`
+	Succeeded  int `json:"succeeded"`
+	Errored    int `json:"errored"`
+	Canceled   int `json:"canceled"`
+	Expired    int `json:"expired"`
+}
+
+// BatchRequest is one document of a Batch
+type BatchRequest struct {
+	CustomID        string `json:"custom_id"`
+	ProviderBatchID string `json:"provider_batch_id"`
+	DocumentID      string `json:"document_id"`
+	DocumentType    string `json:"document_type,omitempty"` // Extraction only
+	Prompt          string `json:"prompt"`
+	Status          string `json:"status"` // pending, succeeded, errored, canceled or expired
+	Error           string `json:"error,omitempty"`
+	PromptID        string `json:"prompt_id,omitempty"` // Prompt record written for the result
+}
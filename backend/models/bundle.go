@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// BundleReport divides a PDF bundling several documents, such as a scan of
+// an invoice, two receipts and a letter, into page ranges
+type BundleReport struct {
+	Segments  []BundleSegment `json:"segments"` // In page order, covering every page once
+	Model     string          `json:"model,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	// SplitAt is set once the segments have been split into child documents
+	SplitAt *time.Time `json:"split_at,omitempty"`
+}
+
+// BundleSegment is the page range of one document within a bundle
+type BundleSegment struct {
+	StartPage    int     `json:"start_page"` // 1-indexed, inclusive
+	EndPage      int     `json:"end_page"`
+	DocumentType string  `json:"document_type"`
+	Confidence   float64 `json:"confidence,omitempty"`
+	Title        string  `json:"title,omitempty"`     // Short description, e.g. "Invoice INV-001 from Acme"
+	Reasoning    string  `json:"reasoning,omitempty"` // Why the document starts and ends here
+	ChildID      string  `json:"child_id,omitempty"`  // The child document split from the range
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Roles of a chat turn
+const (
+	ChatRoleUser      = "user"
+	ChatRoleAssistant = "assistant"
+)
+
+// ChatThread is a multi-turn conversation about one document. Turns
+// alternate between the user and the assistant, starting with the user.
+type ChatThread struct {
+	ID         string     `json:"id"`
+	DocumentID string     `json:"document_id"`
+	Title      string     `json:"title"` // The start of the first question
+	Turns      []ChatTurn `json:"turns"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// ChatTurn is one message of a chat thread
+type ChatTurn struct {
+	Role      string    `json:"role"` // ChatRoleUser or ChatRoleAssistant
+	Content   string    `json:"content"`
+	PromptID  string    `json:"prompt_id,omitempty"` // Prompt record of an assistant turn, with its cost
+	CreatedAt time.Time `json:"created_at"`
+}
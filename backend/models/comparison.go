@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// Kinds of clause change
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+)
+
+// Materiality ratings of a clause change
+const (
+	MaterialityHigh   = "high"
+	MaterialityMedium = "medium"
+	MaterialityLow    = "low"
+)
+
+// Comparison lists the changes between two versions of a document
+type Comparison struct {
+	BeforeDocumentID string         `json:"before_document_id"`
+	AfterDocumentID  string         `json:"after_document_id"`
+	Summary          string         `json:"summary"`
+	Changes          []ClauseChange `json:"changes"`
+	// FieldChanges lists the extracted fields whose values differ, when
+	// both documents have contract extractions
+	FieldChanges []FieldChange `json:"field_changes,omitempty"`
+	Model        string        `json:"model,omitempty"`
+	CreatedAt    time.Time     `json:"created_at"`
+}
+
+// ClauseChange is one clause added, removed or modified between versions
+type ClauseChange struct {
+	Kind        string `json:"kind"`                  // "added", "removed" or "modified"
+	Clause      string `json:"clause"`                // Heading, number or short name of the clause
+	BeforeText  string `json:"before_text,omitempty"` // Empty for added clauses
+	AfterText   string `json:"after_text,omitempty"`  // Empty for removed clauses
+	BeforePage  int    `json:"before_page,omitempty"` // 1-indexed page in the previous version
+	AfterPage   int    `json:"after_page,omitempty"`  // 1-indexed page in the revised version
+	Materiality string `json:"materiality"`           // "high", "medium" or "low"
+	Explanation string `json:"explanation,omitempty"` // What the change means for the parties
+}
+
+// FieldChange is an extracted field whose value differs between versions
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Kind   string      `json:"kind"` // "added", "removed" or "modified"
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+	// Highlight marks the key contract terms, such as governing law and
+	// termination, whose change usually matters most
+	Highlight bool `json:"highlight,omitempty"`
+}
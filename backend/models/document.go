@@ -10,7 +10,21 @@ type Document struct {
 	PDFData        []byte          `json:"-"` // Base64 PDF data, not exposed in JSON
 	Classification *Classification `json:"classification,omitempty"`
 	Extraction     *Extraction     `json:"extraction,omitempty"`
-	CreatedAt      time.Time       `json:"created_at"`
+	// Summaries holds the latest summary of each length, keyed by length
+	Summaries map[string]*Summary `json:"summaries,omitempty"`
+	// Translations holds the latest translation into each language, keyed
+	// by target language
+	Translations map[string]*Translation `json:"translations,omitempty"`
+	// RedactedPDFData is a copy of PDFData with the PII of Redaction removed
+	RedactedPDFData []byte           `json:"-"`
+	Redaction       *RedactionReport `json:"redaction,omitempty"`
+	// TableExtraction holds the tables of the latest table extraction
+	TableExtraction *TableExtraction `json:"table_extraction,omitempty"`
+	// Bundle holds the proposed document boundaries of a multi-document PDF
+	Bundle *BundleReport `json:"bundle,omitempty"`
+	// ParentID is the bundle the document was split from, if any
+	ParentID  string    `json:"parent_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type Classification struct {
@@ -19,34 +33,145 @@ type Classification struct {
 	Reasoning    string   `json:"reasoning"`
 	Subtypes     []string `json:"subtypes,omitempty"`
 	Language     string   `json:"language,omitempty"`
+	// UnmappedType is the model's answer when it was outside the taxonomy
+	// and DocumentType was remapped
+	UnmappedType string `json:"unmapped_type,omitempty"`
+	// Ensemble is set when the classification is the majority vote of
+	// several samples; Confidence is then the vote agreement
+	Ensemble *EnsembleReport `json:"ensemble,omitempty"`
+}
+
+// EnsembleReport records how the samples of an ensemble classification voted
+type EnsembleReport struct {
+	Samples         int                  `json:"samples"` // Classification calls made
+	Votes           []ClassificationVote `json:"votes"`
+	Tally           map[string]int       `json:"tally"`            // Votes per document type
+	Agreement       float64              `json:"agreement"`        // Share of samples, failed ones included, that voted for the winner
+	ModelConfidence float64              `json:"model_confidence"` // Mean confidence the winning votes reported
+	Disagreement    bool                 `json:"disagreement"`     // Some samples voted for another type
+	Failed          int                  `json:"failed,omitempty"` // Samples whose call failed
+}
+
+// ClassificationVote is the answer of one ensemble sample
+type ClassificationVote struct {
+	Model        string  `json:"model"`
+	DocumentType string  `json:"document_type,omitempty"`
+	Confidence   float64 `json:"confidence,omitempty"` // As reported by the model
+	Error        string  `json:"error,omitempty"`      // Why the call failed, if it did
 }
 
 type Extraction struct {
-	SchemaUsed string                 `json:"schema_used"`
-	Data       map[string]interface{} `json:"data"`
-	Fields     []ExtractedField       `json:"fields"`
+	SchemaUsed   string                 `json:"schema_used"`
+	Data         map[string]interface{} `json:"data"`
+	Fields       []ExtractedField       `json:"fields"`
+	Validation   *ValidationReport      `json:"validation,omitempty"`
+	Verification *VerificationReport    `json:"verification,omitempty"`
+	Grounding    *GroundingReport       `json:"grounding,omitempty"`
+	Chunks       []ExtractionChunk      `json:"chunks,omitempty"` // Set when the document was extracted in page ranges
+	// Truncated is set when the response was cut off and its JSON had to be
+	// closed, so data from the end of the document may be missing
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ExtractionChunk records one page range of a chunked extraction
+type ExtractionChunk struct {
+	StartPage int    `json:"start_page"`
+	EndPage   int    `json:"end_page"`
+	Fields    int    `json:"fields"`          // Fields extracted from the chunk
+	Error     string `json:"error,omitempty"` // Why the chunk failed, if it did
+}
+
+// ValidationReport is the result of checking Extraction.Data against its schema
+type ValidationReport struct {
+	Valid        bool              `json:"valid"`
+	Errors       []ValidationError `json:"errors,omitempty"`
+	RepairRounds int               `json:"repair_rounds"`          // Repair prompts sent to the model
+	RepairError  string            `json:"repair_error,omitempty"` // Why repairing stopped early, if it did
+}
+
+// ValidationError describes one schema violation at a JSON path such as "$.vendor.name"
+type ValidationError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
 }
 
 type ExtractedField struct {
-	Name       string      `json:"name"`
-	Value      interface{} `json:"value"`
-	SourceText string      `json:"source_text"`
-	PageNumber int         `json:"page_number"`
-	Confidence float64     `json:"confidence"`
+	Name         string      `json:"name"`
+	Value        interface{} `json:"value"`
+	SourceText   string      `json:"source_text"`
+	PageNumber   int         `json:"page_number"`
+	Confidence   float64     `json:"confidence"`
+	Verification string      `json:"verification,omitempty"` // One of the Verification* statuses
+	MatchedPage  int         `json:"matched_page,omitempty"` // Page where SourceText was found
+	MatchScore   float64     `json:"match_score,omitempty"`  // Similarity of the best match, 0-1
+	Citations    []Citation  `json:"citations,omitempty"`    // Passages the API cited for the value
+	Uncited      bool        `json:"uncited,omitempty"`      // Grounding ran but returned no citation
+}
+
+// Citation is a passage of the PDF cited by the Citations API
+type Citation struct {
+	StartPage int    `json:"start_page"` // 1-indexed, inclusive
+	EndPage   int    `json:"end_page"`
+	CitedText string `json:"cited_text"`
+}
+
+// Verification statuses of an ExtractedField's SourceText
+const (
+	VerificationExact     = "exact"      // Found verbatim on the claimed page
+	VerificationFuzzy     = "fuzzy"      // Found approximately on the claimed page
+	VerificationWrongPage = "wrong_page" // Found only on a different page
+	VerificationNotFound  = "not_found"  // Not found anywhere in the document
+)
+
+// VerificationReport summarizes how well the extracted source text matches
+// the PDF's text layer
+type VerificationReport struct {
+	Exact              int     `json:"exact"`
+	Fuzzy              int     `json:"fuzzy"`
+	WrongPage          int     `json:"wrong_page"`
+	NotFound           int     `json:"not_found"`
+	HallucinationScore float64 `json:"hallucination_score"` // 0 when every quote was found, 1 when none were
+	Error              string  `json:"error,omitempty"`     // Why the document could not be verified, if it could not
+}
+
+// GroundingReport summarizes how many extracted fields the Citations API
+// could locate in the PDF
+type GroundingReport struct {
+	Cited   int    `json:"cited"`
+	Uncited int    `json:"uncited"`
+	Error   string `json:"error,omitempty"` // Why grounding failed for some or all fields, if it did
 }
 
 type PromptRecord struct {
-	ID           string     `json:"id"`
-	DocumentID   string     `json:"document_id"`
-	AgentType    string     `json:"agent_type"` // "classification" or "extraction"
-	Prompt       string     `json:"prompt"`
-	Response     string     `json:"response"`
-	Schema       string     `json:"schema,omitempty"` // JSON schema used for extraction
-	Model        string     `json:"model"`
-	InputTokens  int        `json:"input_tokens"`
-	OutputTokens int        `json:"output_tokens"`
-	TotalCost    float64    `json:"total_cost"` // Cost in USD
-	CreatedAt    time.Time  `json:"created_at"`
+	ID                       string    `json:"id"`
+	DocumentID               string    `json:"document_id"`
+	AgentType                string    `json:"agent_type"` // "classification" or "extraction"
+	Prompt                   string    `json:"prompt"`
+	Response                 string    `json:"response"`
+	Schema                   string    `json:"schema,omitempty"` // JSON schema used for extraction
+	Model                    string    `json:"model"`
+	InputTokens              int       `json:"input_tokens"`
+	OutputTokens             int       `json:"output_tokens"`
+	TotalCost                float64   `json:"total_cost"`         // Cost in USD
+	Unpriced                 bool      `json:"unpriced,omitempty"` // The model has no known pricing, so TotalCost is 0 rather than measured
+	Attempts                 int       `json:"attempts,omitempty"` // API calls made, including retries
+	CacheCreationInputTokens int       `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int       `json:"cache_read_input_tokens,omitempty"`
+	CacheSavings             float64   `json:"cache_savings,omitempty"` // USD saved by prompt caching
+	PageStart                int       `json:"page_start,omitempty"`    // Page range of a chunked extraction step
+	PageEnd                  int       `json:"page_end,omitempty"`
+	BatchID                  string    `json:"batch_id,omitempty"`    // Set when the call ran in a message batch
+	TemplateID               string    `json:"template_id,omitempty"` // Prompt template used instead of the built-in prompt
+	TemplateVersion          int       `json:"template_version,omitempty"`
+	Thinking                 string    `json:"thinking,omitempty"`        // Extended thinking the model returned
+	ThinkingTokens           int       `json:"thinking_tokens,omitempty"` // Estimated share of OutputTokens spent thinking
+	ThinkingCost             float64   `json:"thinking_cost,omitempty"`   // USD of TotalCost spent thinking
+	RawResponse              string    `json:"raw_response,omitempty"`    // Model output before parsing: text or tool call input
+	StopReason               string    `json:"stop_reason,omitempty"`     // Why the model stopped generating, as the provider reports it
+	Continuations            int       `json:"continuations,omitempty"`   // Extra calls made because the output hit max_tokens
+	JSONRepairs              []string  `json:"json_repairs,omitempty"`    // Fixes made to the response's JSON to parse it
+	Error                    string    `json:"error,omitempty"`           // Why a billed call failed, such as a response that could not be parsed
+	CreatedAt                time.Time `json:"created_at"`
 }
 
 // TokenUsage holds token counts and cost information from Claude API
@@ -55,17 +180,26 @@ type TokenUsage struct {
 	InputTokens  int
 	OutputTokens int
 	TotalCost    float64
-}
+	Unpriced     bool // The model has no known pricing, so the costs are 0 rather than measured
+	Attempts     int  // Number of API calls made, including retries
 
-// Claude Sonnet 4.5 pricing (as of 2025)
-const (
-	SonnetInputPricePerMillion  = 3.0  // $3 per million input tokens
-	SonnetOutputPricePerMillion = 15.0 // $15 per million output tokens
-)
+	CacheCreationInputTokens int     // Input tokens written to the prompt cache
+	CacheReadInputTokens     int     // Input tokens read from the prompt cache
+	CacheSavings             float64 // USD saved by the cache versus uncached input
+
+	// Extended thinking. ThinkingTokens is estimated, since the API counts
+	// thinking within OutputTokens; ThinkingCost is part of TotalCost.
+	Thinking       string // Text of the thinking blocks
+	ThinkingTokens int
+	ThinkingCost   float64
+
+	// Output as generated. A response cut off at max_tokens is continued or
+	// retried with a larger limit, and Continuations counts those calls.
+	RawResponse   string
+	StopReason    string
+	Continuations int
 
-// CalculateCost computes the cost in USD for the given token usage
-func CalculateCost(inputTokens, outputTokens int) float64 {
-	inputCost := float64(inputTokens) * SonnetInputPricePerMillion / 1_000_000
-	outputCost := float64(outputTokens) * SonnetOutputPricePerMillion / 1_000_000
-	return inputCost + outputCost
+	// Fixes made to a text response to parse its JSON, such as
+	// "closed_string" when the output was cut off
+	JSONRepairs []string
 }
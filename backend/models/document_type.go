@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// DocumentType is an entry of the classification taxonomy
+type DocumentType struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Indicators  []string  `json:"indicators,omitempty"` // Features that identify the type, such as "PO number"
+	Parent      string    `json:"parent,omitempty"`     // Broader type this one refines
+	BuiltIn     bool      `json:"built_in,omitempty"`   // Shipped with the server rather than registered
+	CreatedAt   time.Time `json:"created_at"`
+}
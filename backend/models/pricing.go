@@ -0,0 +1,194 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// BatchPriceMultiplier is the share of the regular price charged for
+// requests processed through a message batch
+const BatchPriceMultiplier = 0.5
+
+// ModelPricing holds the USD price per million tokens for a model
+type ModelPricing struct {
+	InputPerMillion      float64 `json:"input_per_million"`
+	OutputPerMillion     float64 `json:"output_per_million"`
+	CacheWritePerMillion float64 `json:"cache_write_per_million"`
+	CacheReadPerMillion  float64 `json:"cache_read_per_million"`
+}
+
+// Cost computes the cost in USD for the given input and output tokens
+func (p ModelPricing) Cost(inputTokens, outputTokens int) float64 {
+	inputCost := float64(inputTokens) * p.InputPerMillion / 1_000_000
+	outputCost := float64(outputTokens) * p.OutputPerMillion / 1_000_000
+	return inputCost + outputCost
+}
+
+// UsageCost computes the cost in USD of a TokenUsage. InputTokens are the
+// uncached input; cache writes and reads are priced at their own rates.
+func (p ModelPricing) UsageCost(usage TokenUsage) float64 {
+	cacheWriteCost := float64(usage.CacheCreationInputTokens) * p.CacheWritePerMillion / 1_000_000
+	cacheReadCost := float64(usage.CacheReadInputTokens) * p.CacheReadPerMillion / 1_000_000
+	return p.Cost(usage.InputTokens, usage.OutputTokens) + cacheWriteCost + cacheReadCost
+}
+
+// ThinkingCost returns the part of the output cost spent on extended thinking
+func (p ModelPricing) ThinkingCost(usage TokenUsage) float64 {
+	return float64(usage.ThinkingTokens) * p.OutputPerMillion / 1_000_000
+}
+
+// CacheSavings returns how much cheaper usage was than sending the cached
+// tokens as regular input. It is negative when only cache writes happened.
+func (p ModelPricing) CacheSavings(usage TokenUsage) float64 {
+	cachedTokens := usage.CacheCreationInputTokens + usage.CacheReadInputTokens
+	uncached := p.Cost(usage.InputTokens+cachedTokens, usage.OutputTokens)
+	return uncached - p.UsageCost(usage)
+}
+
+var (
+	opusPricing       = ModelPricing{InputPerMillion: 5.0, OutputPerMillion: 25.0, CacheWritePerMillion: 6.25, CacheReadPerMillion: 0.50}
+	opusLegacyPricing = ModelPricing{InputPerMillion: 15.0, OutputPerMillion: 75.0, CacheWritePerMillion: 18.75, CacheReadPerMillion: 1.50}
+	sonnetPricing     = ModelPricing{InputPerMillion: 3.0, OutputPerMillion: 15.0, CacheWritePerMillion: 3.75, CacheReadPerMillion: 0.30}
+	haikuPricing      = ModelPricing{InputPerMillion: 1.0, OutputPerMillion: 5.0, CacheWritePerMillion: 1.25, CacheReadPerMillion: 0.10}
+	haiku35Pricing    = ModelPricing{InputPerMillion: 0.80, OutputPerMillion: 4.0, CacheWritePerMillion: 1.0, CacheReadPerMillion: 0.08}
+	haiku3Pricing     = ModelPricing{InputPerMillion: 0.25, OutputPerMillion: 1.25, CacheWritePerMillion: 0.30, CacheReadPerMillion: 0.03}
+
+	// OpenAI caches prompts automatically, so there is no cache write premium
+	gpt41Pricing     = ModelPricing{InputPerMillion: 2.0, OutputPerMillion: 8.0, CacheReadPerMillion: 0.50}
+	gpt41MiniPricing = ModelPricing{InputPerMillion: 0.40, OutputPerMillion: 1.60, CacheReadPerMillion: 0.10}
+	gpt41NanoPricing = ModelPricing{InputPerMillion: 0.10, OutputPerMillion: 0.40, CacheReadPerMillion: 0.025}
+	gpt4oPricing     = ModelPricing{InputPerMillion: 2.50, OutputPerMillion: 10.0, CacheReadPerMillion: 1.25}
+	gpt4oMiniPricing = ModelPricing{InputPerMillion: 0.15, OutputPerMillion: 0.60, CacheReadPerMillion: 0.075}
+)
+
+// pricingRegistry maps model IDs (including aliases) to their pricing
+var (
+	pricingRegistry = map[string]ModelPricing{
+		"claude-opus-4-6":            opusPricing,
+		"claude-opus-4-5":            opusPricing,
+		"claude-opus-4-5-20251101":   opusPricing,
+		"claude-opus-4-1-20250805":   opusLegacyPricing,
+		"claude-opus-4-0":            opusLegacyPricing,
+		"claude-opus-4-20250514":     opusLegacyPricing,
+		"claude-sonnet-4-5":          sonnetPricing,
+		"claude-sonnet-4-5-20250929": sonnetPricing,
+		"claude-sonnet-4-0":          sonnetPricing,
+		"claude-sonnet-4-20250514":   sonnetPricing,
+		"claude-3-7-sonnet-20250219": sonnetPricing,
+		"claude-haiku-4-5":           haikuPricing,
+		"claude-haiku-4-5-20251001":  haikuPricing,
+		"claude-3-5-haiku-20241022":  haiku35Pricing,
+		"claude-3-haiku-20240307":    haiku3Pricing,
+		"gpt-4.1":                    gpt41Pricing,
+		"gpt-4.1-mini":               gpt41MiniPricing,
+		"gpt-4.1-nano":               gpt41NanoPricing,
+		"gpt-4o":                     gpt4oPricing,
+		"gpt-4o-mini":                gpt4oMiniPricing,
+	}
+	pricingMu sync.RWMutex
+)
+
+// RegisterModelPricing adds or replaces the pricing for a model
+func RegisterModelPricing(model string, pricing ModelPricing) {
+	pricingMu.Lock()
+	defer pricingMu.Unlock()
+	pricingRegistry[model] = pricing
+}
+
+// RegisterPricingJSON registers pricing from a JSON object mapping model IDs
+// to ModelPricing, e.g. {"llama3.2": {"input_per_million": 0.1}}. It is used
+// to price self-hosted models.
+func RegisterPricingJSON(data []byte) error {
+	var pricing map[string]ModelPricing
+	if err := json.Unmarshal(data, &pricing); err != nil {
+		return fmt.Errorf("invalid model pricing: %w", err)
+	}
+	for model, p := range pricing {
+		RegisterModelPricing(model, p)
+	}
+	return nil
+}
+
+// GetModelPricing returns the pricing for a model and whether it is known
+func GetModelPricing(model string) (ModelPricing, bool) {
+	pricingMu.RLock()
+	defer pricingMu.RUnlock()
+	pricing, ok := pricingRegistry[model]
+	return pricing, ok
+}
+
+// IsPriced reports whether a model has known pricing. The costs of other
+// models are reported as 0 and flagged Unpriced.
+func IsPriced(model string) bool {
+	_, ok := GetModelPricing(model)
+	return ok
+}
+
+// PricedModels returns the IDs of all models with known pricing, sorted
+func PricedModels() []string {
+	pricingMu.RLock()
+	defer pricingMu.RUnlock()
+	ids := make([]string, 0, len(pricingRegistry))
+	for id := range pricingRegistry {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// CalculateCostForModel computes the cost in USD for the given model.
+// Models without registered pricing cost 0; use IsPriced to tell them from
+// free ones.
+func CalculateCostForModel(model string, inputTokens, outputTokens int) float64 {
+	pricing, ok := GetModelPricing(model)
+	if !ok {
+		return 0
+	}
+	return pricing.Cost(inputTokens, outputTokens)
+}
+
+// CalculateUsageCost computes the cost in USD of a TokenUsage, including
+// prompt cache writes and reads, using the pricing of usage.Model. It is 0
+// for models without registered pricing, which usage.Unpriced flags.
+func CalculateUsageCost(usage TokenUsage) float64 {
+	pricing, ok := GetModelPricing(usage.Model)
+	if !ok {
+		return 0
+	}
+	return pricing.UsageCost(usage)
+}
+
+// CalculateThinkingCost returns the USD spent on extended thinking for a TokenUsage
+func CalculateThinkingCost(usage TokenUsage) float64 {
+	pricing, ok := GetModelPricing(usage.Model)
+	if !ok {
+		return 0
+	}
+	return pricing.ThinkingCost(usage)
+}
+
+// CalculateCacheSavings returns the USD saved by the prompt cache for a TokenUsage
+func CalculateCacheSavings(usage TokenUsage) float64 {
+	pricing, ok := GetModelPricing(usage.Model)
+	if !ok {
+		return 0
+	}
+	return pricing.CacheSavings(usage)
+}
+
+// CostEstimate is the expected token usage and cost of running an agent on
+// a document, before any call is made
+type CostEstimate struct {
+	Model           string  `json:"model"`
+	AgentType       string  `json:"agent_type"`
+	InputTokens     int     `json:"input_tokens"`      // Input of the calls every run makes
+	Calls           int     `json:"calls"`             // Calls every run makes, one per chunk
+	MaxCalls        int     `json:"max_calls"`         // Including repair and citation calls
+	MaxOutputTokens int     `json:"max_output_tokens"` // Output limit summed over MaxCalls
+	MinCost         float64 `json:"min_cost"`          // USD
+	MaxCost         float64 `json:"max_cost"`
+	Priced          bool    `json:"priced"`      // False when the model has no known pricing
+	Approximate     bool    `json:"approximate"` // Tokens were estimated locally, not counted by the provider
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCalculateCostForModel(t *testing.T) {
+	tests := []struct {
+		model    string
+		expected float64
+	}{
+		// 1M input + 1M output tokens
+		{"claude-haiku-4-5", 1.0 + 5.0},
+		{"claude-sonnet-4-5-20250929", 3.0 + 15.0},
+		{"claude-opus-4-5", 5.0 + 25.0},
+		{"claude-opus-4-1-20250805", 15.0 + 75.0},
+		{"unknown-model", 0},
+	}
+
+	for _, tc := range tests {
+		cost := CalculateCostForModel(tc.model, 1_000_000, 1_000_000)
+		if math.Abs(cost-tc.expected) > 1e-9 {
+			t.Errorf("%s: expected $%.2f, got $%.2f", tc.model, tc.expected, cost)
+		}
+	}
+}
+
+func TestRegisterModelPricing(t *testing.T) {
+	RegisterModelPricing("test-model", ModelPricing{InputPerMillion: 2, OutputPerMillion: 4})
+
+	pricing, ok := GetModelPricing("test-model")
+	if !ok {
+		t.Fatal("Expected registered pricing")
+	}
+	if pricing.InputPerMillion != 2 {
+		t.Errorf("Expected input price 2, got %f", pricing.InputPerMillion)
+	}
+
+	found := false
+	for _, model := range PricedModels() {
+		if model == "test-model" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected registered model in PricedModels")
+	}
+	if !IsPriced("test-model") || IsPriced("unknown-model") {
+		t.Error("Expected IsPriced to report registered models only")
+	}
+}
+
+func TestRegisterPricingJSON(t *testing.T) {
+	err := RegisterPricingJSON([]byte(`{"local-llama": {"input_per_million": 0.1, "output_per_million": 0.2}}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cost := CalculateCostForModel("local-llama", 1_000_000, 1_000_000); cost < 0.2999 || cost > 0.3001 {
+		t.Errorf("Expected cost 0.3, got %f", cost)
+	}
+
+	if err := RegisterPricingJSON([]byte(`not json`)); err == nil {
+		t.Error("Expected error for invalid JSON")
+	}
+}
+
+func TestCalculateUsageCost_PricesCacheTokens(t *testing.T) {
+	usage := TokenUsage{
+		Model:                    "claude-sonnet-4-5",
+		InputTokens:              1_000_000,
+		OutputTokens:             1_000_000,
+		CacheCreationInputTokens: 1_000_000,
+		CacheReadInputTokens:     1_000_000,
+	}
+
+	expected := 3.0 + 15.0 + 3.75 + 0.30
+	if cost := CalculateUsageCost(usage); math.Abs(cost-expected) > 1e-9 {
+		t.Errorf("Expected $%.2f, got $%.2f", expected, cost)
+	}
+}
+
+func TestCalculateCacheSavings(t *testing.T) {
+	read := TokenUsage{Model: "claude-sonnet-4-5", CacheReadInputTokens: 1_000_000}
+	if savings := CalculateCacheSavings(read); math.Abs(savings-2.70) > 1e-9 {
+		t.Errorf("Expected $2.70 saved by cache read, got $%.2f", savings)
+	}
+
+	write := TokenUsage{Model: "claude-sonnet-4-5", CacheCreationInputTokens: 1_000_000}
+	if savings := CalculateCacheSavings(write); math.Abs(savings+0.75) > 1e-9 {
+		t.Errorf("Expected cache write to cost $0.75 extra, got $%.2f", savings)
+	}
+}
+
+func TestCalculateThinkingCost(t *testing.T) {
+	usage := TokenUsage{Model: "claude-sonnet-4-5", OutputTokens: 1_000_000, ThinkingTokens: 400_000}
+	if cost := CalculateThinkingCost(usage); math.Abs(cost-6.0) > 1e-9 {
+		t.Errorf("Expected $6.00 of thinking, got $%.2f", cost)
+	}
+	if cost := CalculateThinkingCost(TokenUsage{Model: "unknown-model", ThinkingTokens: 1000}); cost != 0 {
+		t.Errorf("Expected no cost for unknown model, got %f", cost)
+	}
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// PromptTemplate is one version of a classification or extraction prompt,
+// written as a Go text/template. Each edit of a template is saved as a new
+// version; traffic is split between the versions of an agent type by weight.
+type PromptTemplate struct {
+	ID        string    `json:"id"`         // Name shared by all versions, such as "concise-extraction"
+	Version   int       `json:"version"`    // 1 for the first version
+	AgentType string    `json:"agent_type"` // "classification" or "extraction"
+	Template  string    `json:"template"`
+	Weight    int       `json:"weight"` // Relative share of the agent type's calls; 0 takes none
+	CreatedAt time.Time `json:"created_at"`
+}
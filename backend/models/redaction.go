@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// PII types
+const (
+	PIISSN           = "ssn"
+	PIICreditCard    = "credit_card"
+	PIIIBAN          = "iban"
+	PIIRoutingNumber = "routing_number"
+	PIIAccountNumber = "account_number"
+	PIIEmail         = "email"
+	PIIPhone         = "phone"
+	PIIDateOfBirth   = "date_of_birth"
+	PIIAddress       = "address"
+	PIIPersonName    = "person_name"
+)
+
+// PII detectors
+const (
+	DetectorRegex    = "regex"    // Pattern match
+	DetectorChecksum = "checksum" // Pattern match with a valid check digit
+	DetectorAgent    = "agent"    // Found by the model
+)
+
+// PIISpan locates one piece of PII in a page's text. The text itself is not
+// kept, since the report is visible to everyone who can see the document.
+type PIISpan struct {
+	Type       string `json:"type"`
+	Masked     string `json:"masked"` // The text with all but a few characters masked
+	PageNumber int    `json:"page_number"`
+	Start      int    `json:"start"` // Byte offsets into the page's extracted text
+	End        int    `json:"end"`
+	Detector   string `json:"detector"`
+	Redacted   bool   `json:"redacted"` // The text was removed from the redacted PDF
+}
+
+// RedactionReport records the PII found in a document and what was removed
+// from its redacted copy
+type RedactionReport struct {
+	Spans      []PIISpan      `json:"spans"`
+	Counts     map[string]int `json:"counts"`     // Spans per type
+	Unredacted int            `json:"unredacted"` // Spans whose text could not be removed, such as text drawn by form XObjects
+	AgentPass  bool           `json:"agent_pass"` // The model was asked for PII the detectors miss
+	Model      string         `json:"model,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
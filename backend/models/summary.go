@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Summary is a summary of a document at one length
+type Summary struct {
+	Length string `json:"length"` // "one_line", "paragraph" or "sections"
+	Style  string `json:"style"`  // "prose" or "bullets"
+	Text   string `json:"text"`   // The whole summary; for sections, an overview
+	// Sections summarizes the document part by part, for the "sections" length
+	Sections  []SummarySection `json:"sections,omitempty"`
+	Model     string           `json:"model,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// SummarySection summarizes one part of a document
+type SummarySection struct {
+	Title     string `json:"title"`
+	StartPage int    `json:"start_page"` // 1-indexed
+	EndPage   int    `json:"end_page"`
+	Summary   string `json:"summary"`
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TableExtraction holds every table found in a document
+type TableExtraction struct {
+	Tables    []Table   `json:"tables"`
+	Model     string    `json:"model,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Table is one table of a document as a grid of cell texts. A merged cell's
+// text is in its top-left cell; the other cells it covers are empty.
+type Table struct {
+	Index      int    `json:"index"`       // 1-indexed position in the document
+	PageNumber int    `json:"page_number"` // 1-indexed page the table starts on
+	Caption    string `json:"caption,omitempty"`
+	// HeaderRows is the number of leading rows of Cells that are headers
+	HeaderRows  int          `json:"header_rows"`
+	Cells       [][]string   `json:"cells"` // Rows of cells, all of the same width
+	MergedCells []MergedCell `json:"merged_cells,omitempty"`
+}
+
+// MergedCell is a cell spanning several rows or columns, given by the
+// 0-indexed row and column of its top-left cell in Table.Cells
+type MergedCell struct {
+	Row     int `json:"row"`
+	Column  int `json:"column"`
+	RowSpan int `json:"row_span"`
+	ColSpan int `json:"col_span"`
+}
+
+// CSV encodes the table's cell grid as CSV, header rows first. Cells that a
+// spreadsheet would run as a formula are prefixed with a quote, since their
+// text comes from the document; signed numbers such as "-12.50" are kept.
+func (t Table) CSV() ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	for _, row := range t.Cells {
+		escaped := make([]string, len(row))
+		for i, cell := range row {
+			escaped[i] = escapeFormula(cell)
+		}
+		if err := w.Write(escaped); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// escapeFormula prefixes a cell starting like a formula with a quote
+func escapeFormula(cell string) string {
+	if cell == "" || !strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return cell
+	}
+	if (cell[0] == '-' || cell[0] == '+') && isNumber(cell) {
+		return cell
+	}
+	return "'" + cell
+}
+
+// isNumber reports whether a cell is a number as documents write them, such
+// as "-1,200.50", "+3%" or "-12,50 €"
+func isNumber(cell string) bool {
+	number := strings.Map(func(r rune) rune {
+		switch r {
+		case ',', '\'', ' ', '\u00a0', '%', '$', '€', '£', '¥':
+			return -1
+		}
+		return r
+	}, cell)
+	_, err := strconv.ParseFloat(number, 64)
+	return err == nil
+}
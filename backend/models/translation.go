@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Translation renders a document's extracted values, and optionally its
+// full text, into another language. The originals are kept next to each
+// translation; the extraction itself is left unchanged, so its source_text
+// still matches the PDF.
+type Translation struct {
+	TargetLanguage string                 `json:"target_language"`
+	SourceLanguage string                 `json:"source_language,omitempty"` // As detected by the model
+	Data           map[string]interface{} `json:"data,omitempty"`            // Extraction.Data with its strings translated
+	Values         []TranslatedValue      `json:"values,omitempty"`          // Each translated string of Extraction.Data
+	Fields         []TranslatedField      `json:"fields,omitempty"`          // Each extracted field with a string value
+	Pages          []TranslatedPage       `json:"pages,omitempty"`           // Full-text translation, if requested
+	Model          string                 `json:"model,omitempty"`
+	CreatedAt      time.Time              `json:"created_at"`
+}
+
+// TranslatedValue is one string of the extracted data at a JSON path such
+// as "$.vendor.name"
+type TranslatedValue struct {
+	Path       string `json:"path"`
+	Original   string `json:"original"`
+	Translated string `json:"translated"`
+}
+
+// TranslatedField is the translation of an extracted field's value. Its
+// SourceText and PageNumber are those of the original field.
+type TranslatedField struct {
+	Name       string `json:"name"`
+	Original   string `json:"original"`
+	Translated string `json:"translated"`
+	SourceText string `json:"source_text"`
+	PageNumber int    `json:"page_number"`
+}
+
+// TranslatedPage is the translated text of one page
+type TranslatedPage struct {
+	PageNumber int    `json:"page_number"` // 1-indexed
+	Text       string `json:"text"`
+}
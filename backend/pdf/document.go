@@ -0,0 +1,547 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// Document is a parsed PDF file. Objects are located by scanning the file
+// rather than trusting the cross-reference table, which makes reading
+// tolerant of damaged or incrementally updated files.
+type Document struct {
+	data    []byte
+	offsets map[int]int         // Object number to file offset of "N G obj"
+	objects map[int]Object      // Parsed objects by number
+	inStm   map[int]streamEntry // Objects stored in object streams
+	trailer Dict
+	pages   []*Page
+}
+
+// Page is a leaf of the page tree
+type Page struct {
+	Number int  // 1-indexed
+	Ref    *Ref // Object of the page, or nil if the page dictionary is direct
+	Dict   Dict // Page dictionary with inherited attributes applied
+}
+
+type streamEntry struct {
+	stream int // Object number of the object stream
+	index  int // Index within the stream
+}
+
+var objHeader = regexp.MustCompile(`(\d+)[ \t\r\n\f\x00]+(\d+)[ \t\r\n\f\x00]+obj\b`)
+
+// Open parses a PDF from memory
+func Open(data []byte) (*Document, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF")) {
+		return nil, fmt.Errorf("not a PDF file")
+	}
+
+	doc := &Document{
+		data:    data,
+		offsets: map[int]int{},
+		objects: map[int]Object{},
+		inStm:   map[int]streamEntry{},
+	}
+
+	// Later definitions win, matching incremental updates
+	for _, m := range objHeader.FindAllSubmatchIndex(data, -1) {
+		if m[0] > 0 && !isWhitespace(data[m[0]-1]) && !isDelimiter(data[m[0]-1]) {
+			continue
+		}
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		doc.offsets[num] = m[1]
+	}
+	if len(doc.offsets) == 0 {
+		return nil, fmt.Errorf("no objects found")
+	}
+
+	doc.indexObjectStreams()
+	doc.trailer = doc.findTrailer()
+
+	if err := doc.loadPages(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// NumPages returns the number of pages
+func (d *Document) NumPages() int {
+	return len(d.pages)
+}
+
+// Pages returns the pages in document order
+func (d *Document) Pages() []*Page {
+	return d.pages
+}
+
+// Trailer returns the trailer dictionary (or cross-reference stream dictionary)
+func (d *Document) Trailer() Dict {
+	return d.trailer
+}
+
+// Object returns the object with the given number, or nil if it does not exist
+func (d *Document) Object(num int) Object {
+	if obj, ok := d.objects[num]; ok {
+		return obj
+	}
+
+	var obj Object
+	if offset, ok := d.offsets[num]; ok {
+		parsed, err := d.parseIndirect(offset)
+		if err == nil {
+			obj = parsed
+		}
+	} else if entry, ok := d.inStm[num]; ok {
+		obj = d.parseFromObjectStream(entry)
+	}
+	d.objects[num] = obj
+	return obj
+}
+
+// Resolve follows indirect references until it reaches a direct object
+func (d *Document) Resolve(obj Object) Object {
+	for i := 0; i < 32; i++ {
+		ref, ok := obj.(Ref)
+		if !ok {
+			return obj
+		}
+		obj = d.Object(ref.Num)
+	}
+	return nil
+}
+
+// ResolveDict resolves obj and returns it as a dictionary (the stream
+// dictionary for streams), or nil
+func (d *Document) ResolveDict(obj Object) Dict {
+	switch v := d.Resolve(obj).(type) {
+	case Dict:
+		return v
+	case *Stream:
+		return v.Dict
+	}
+	return nil
+}
+
+// ObjectNumbers returns the numbers of all objects in the file
+func (d *Document) ObjectNumbers() []int {
+	nums := make([]int, 0, len(d.offsets)+len(d.inStm))
+	for num := range d.offsets {
+		nums = append(nums, num)
+	}
+	for num := range d.inStm {
+		if _, ok := d.offsets[num]; !ok {
+			nums = append(nums, num)
+		}
+	}
+	return nums
+}
+
+// parseIndirect parses the object body following "N G obj" at offset
+func (d *Document) parseIndirect(offset int) (Object, error) {
+	p := newParser(d.data, offset)
+	obj, err := p.parseObject()
+	if err != nil {
+		return nil, err
+	}
+
+	dict, ok := obj.(Dict)
+	if !ok {
+		return obj, nil
+	}
+	next, err := p.peekToken(0)
+	if err != nil || next.kind != tokKeyword || next.value != keyword("stream") {
+		return obj, nil
+	}
+
+	// Stream data starts after the EOL following the keyword
+	start := next.end
+	if start < len(d.data) && d.data[start] == '\r' {
+		start++
+	}
+	if start < len(d.data) && d.data[start] == '\n' {
+		start++
+	}
+	return &Stream{Dict: dict, Data: d.streamData(dict, start)}, nil
+}
+
+// streamData returns the raw bytes of a stream starting at start. The
+// /Length entry is used when it is consistent with the endstream keyword.
+func (d *Document) streamData(dict Dict, start int) []byte {
+	if length, ok := d.lengthOf(dict); ok && length >= 0 && start+length <= len(d.data) {
+		rest := bytes.TrimLeft(d.data[start+length:min(len(d.data), start+length+32)], " \t\r\n")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			return d.data[start : start+length]
+		}
+	}
+
+	end := bytes.Index(d.data[start:], []byte("endstream"))
+	if end < 0 {
+		return d.data[start:]
+	}
+	data := d.data[start : start+end]
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	data = bytes.TrimSuffix(data, []byte("\r"))
+	return data
+}
+
+func (d *Document) lengthOf(dict Dict) (int, bool) {
+	switch v := dict["Length"].(type) {
+	case int64:
+		return int(v), true
+	case Ref:
+		// Parse the length object directly to avoid recursing through Object
+		if offset, ok := d.offsets[v.Num]; ok {
+			if obj, err := newParser(d.data, offset).parseObject(); err == nil {
+				if n, ok := obj.(int64); ok {
+					return int(n), true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+// indexObjectStreams records the objects stored inside object streams
+func (d *Document) indexObjectStreams() {
+	for num := range d.offsets {
+		stream, ok := d.Object(num).(*Stream)
+		if !ok || stream.Dict["Type"] != Name("ObjStm") {
+			continue
+		}
+		header, _, err := d.objectStreamHeader(stream)
+		if err != nil {
+			continue
+		}
+		for i, pair := range header {
+			if _, direct := d.offsets[pair[0]]; !direct {
+				d.inStm[pair[0]] = streamEntry{stream: num, index: i}
+			}
+		}
+	}
+}
+
+// objectStreamHeader returns the (object number, offset) pairs of an object
+// stream and its decoded data
+func (d *Document) objectStreamHeader(stream *Stream) ([][2]int, []byte, error) {
+	data, err := d.Decode(stream)
+	if err != nil {
+		return nil, nil, err
+	}
+	n, _ := stream.Dict["N"].(int64)
+	p := newParser(data, 0)
+	header := make([][2]int, 0, n)
+	for i := int64(0); i < n; i++ {
+		numObj, err1 := p.nextToken()
+		offObj, err2 := p.nextToken()
+		if err1 != nil || err2 != nil {
+			break
+		}
+		num, ok1 := numObj.value.(int64)
+		off, ok2 := offObj.value.(int64)
+		if !ok1 || !ok2 {
+			break
+		}
+		header = append(header, [2]int{int(num), int(off)})
+	}
+	return header, data, nil
+}
+
+func (d *Document) parseFromObjectStream(entry streamEntry) Object {
+	stream, ok := d.Object(entry.stream).(*Stream)
+	if !ok {
+		return nil
+	}
+	header, data, err := d.objectStreamHeader(stream)
+	if err != nil || entry.index >= len(header) {
+		return nil
+	}
+	first, _ := stream.Dict["First"].(int64)
+	start := int(first) + header[entry.index][1]
+	if start >= len(data) {
+		return nil
+	}
+	obj, err := newParser(data, start).parseObject()
+	if err != nil {
+		return nil
+	}
+	return obj
+}
+
+// findTrailer returns the last trailer dictionary, falling back to the last
+// cross-reference stream dictionary
+func (d *Document) findTrailer() Dict {
+	if idx := bytes.LastIndex(d.data, []byte("trailer")); idx >= 0 {
+		if obj, err := newParser(d.data, idx+len("trailer")).parseObject(); err == nil {
+			if dict, ok := obj.(Dict); ok && dict["Root"] != nil {
+				return dict
+			}
+		}
+	}
+
+	var trailer Dict
+	maxOffset := -1
+	for num, offset := range d.offsets {
+		if stream, ok := d.Object(num).(*Stream); ok && stream.Dict["Type"] == Name("XRef") && offset > maxOffset {
+			trailer = stream.Dict
+			maxOffset = offset
+		}
+	}
+	return trailer
+}
+
+// catalog returns the document catalog
+func (d *Document) catalog() Dict {
+	if d.trailer != nil {
+		if root := d.ResolveDict(d.trailer["Root"]); root != nil {
+			return root
+		}
+	}
+	for _, num := range d.ObjectNumbers() {
+		if dict, ok := d.Object(num).(Dict); ok && dict["Type"] == Name("Catalog") {
+			return dict
+		}
+	}
+	return nil
+}
+
+// inheritable page attributes
+var inheritedKeys = []Name{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+func (d *Document) loadPages() error {
+	catalog := d.catalog()
+	if catalog == nil {
+		return fmt.Errorf("document catalog not found")
+	}
+
+	seen := map[int]bool{}
+	var walk func(node Object, inherited Dict) error
+	walk = func(node Object, inherited Dict) error {
+		ref, isRef := node.(Ref)
+		if isRef {
+			if seen[ref.Num] {
+				return fmt.Errorf("cycle in page tree")
+			}
+			seen[ref.Num] = true
+		}
+		dict := d.ResolveDict(node)
+		if dict == nil {
+			return nil
+		}
+
+		attrs := Dict{}
+		for k, v := range inherited {
+			attrs[k] = v
+		}
+		for _, key := range inheritedKeys {
+			if v, ok := dict[key]; ok {
+				attrs[key] = v
+			}
+		}
+
+		if kids, ok := d.Resolve(dict["Kids"]).(Array); ok && dict["Type"] != Name("Page") {
+			for _, kid := range kids {
+				if err := walk(kid, attrs); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		page := Dict{}
+		for k, v := range dict {
+			page[k] = v
+		}
+		for k, v := range attrs {
+			if _, ok := page[k]; !ok {
+				page[k] = v
+			}
+		}
+		p := &Page{Number: len(d.pages) + 1, Dict: page}
+		if isRef {
+			p.Ref = &ref
+		}
+		d.pages = append(d.pages, p)
+		return nil
+	}
+
+	if err := walk(catalog["Pages"], Dict{}); err != nil {
+		return err
+	}
+	if len(d.pages) == 0 {
+		return fmt.Errorf("document has no pages")
+	}
+	return nil
+}
+
+// Decode returns the decoded data of a stream. FlateDecode (with PNG
+// predictors) and ASCIIHexDecode are supported.
+func (d *Document) Decode(stream *Stream) ([]byte, error) {
+	data := stream.Data
+	filters, params := d.filters(stream.Dict)
+	for i, filter := range filters {
+		var err error
+		switch filter {
+		case "FlateDecode", "Fl":
+			data, err = inflate(data)
+			if err == nil && i < len(params) && params[i] != nil {
+				data, err = unpredict(data, params[i])
+			}
+		case "ASCIIHexDecode", "AHx":
+			data, err = decodeHex(data)
+		default:
+			err = fmt.Errorf("unsupported filter: %s", filter)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+func (d *Document) filters(dict Dict) ([]Name, []Dict) {
+	var filters []Name
+	var params []Dict
+	switch f := d.Resolve(dict["Filter"]).(type) {
+	case Name:
+		filters = []Name{f}
+		params = []Dict{d.ResolveDict(dict["DecodeParms"])}
+	case Array:
+		paramArr, _ := d.Resolve(dict["DecodeParms"]).(Array)
+		for i, item := range f {
+			if name, ok := d.Resolve(item).(Name); ok {
+				filters = append(filters, name)
+				var p Dict
+				if i < len(paramArr) {
+					p = d.ResolveDict(paramArr[i])
+				}
+				params = append(params, p)
+			}
+		}
+	}
+	return filters, params
+}
+
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("flate decode: %w", err)
+	}
+	defer r.Close()
+	out, err := io.ReadAll(r)
+	if err != nil && len(out) == 0 {
+		return nil, fmt.Errorf("flate decode: %w", err)
+	}
+	// Keep whatever was decoded from truncated streams
+	return out, nil
+}
+
+// unpredict reverses PNG predictors (Predictor >= 10) used by xref and image streams
+func unpredict(data []byte, params Dict) ([]byte, error) {
+	predictor, _ := params["Predictor"].(int64)
+	if predictor < 10 {
+		return data, nil
+	}
+	columns := int64(1)
+	if c, ok := params["Columns"].(int64); ok {
+		columns = c
+	}
+	colors := int64(1)
+	if c, ok := params["Colors"].(int64); ok {
+		colors = c
+	}
+	bpc := int64(8)
+	if b, ok := params["BitsPerComponent"].(int64); ok {
+		bpc = b
+	}
+	bpp := int((colors*bpc + 7) / 8)
+	rowLen := int((columns*colors*bpc + 7) / 8)
+
+	var out []byte
+	prev := make([]byte, rowLen)
+	for i := 0; i+rowLen < len(data)+1 && i < len(data); i += rowLen + 1 {
+		filterType := data[i]
+		end := min(i+1+rowLen, len(data))
+		row := make([]byte, rowLen)
+		copy(row, data[i+1:end])
+		for j := 0; j < rowLen; j++ {
+			var left, up, upLeft byte
+			if j >= bpp {
+				left = row[j-bpp]
+				upLeft = prev[j-bpp]
+			}
+			up = prev[j]
+			switch filterType {
+			case 1:
+				row[j] += left
+			case 2:
+				row[j] += up
+			case 3:
+				row[j] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[j] += paeth(left, up, upLeft)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func decodeHex(data []byte) ([]byte, error) {
+	end := bytes.IndexByte(data, '>')
+	if end >= 0 {
+		data = data[:end]
+	}
+	s, err := (&lexer{data: append(append([]byte{'<'}, data...), '>')}).readHexString()
+	return []byte(s), err
+}
+
+// Contents returns the concatenated, decoded content streams of a page
+func (d *Document) Contents(page *Page) ([]byte, error) {
+	var streams []Object
+	switch v := d.Resolve(page.Dict["Contents"]).(type) {
+	case *Stream:
+		streams = []Object{v}
+	case Array:
+		streams = v
+	}
+
+	var out []byte
+	for _, s := range streams {
+		stream, ok := d.Resolve(s).(*Stream)
+		if !ok {
+			continue
+		}
+		data, err := d.Decode(stream)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", page.Number, err)
+		}
+		out = append(out, data...)
+		out = append(out, '\n')
+	}
+	return out, nil
+}
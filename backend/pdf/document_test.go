@@ -0,0 +1,158 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"testing"
+)
+
+func TestOpen_GeneratedPDF(t *testing.T) {
+	data := GenerateTextPDF([]string{"first", "second", "third"})
+
+	doc, err := Open(data)
+	if err != nil {
+		t.Fatalf("Failed to open PDF: %v", err)
+	}
+	if doc.NumPages() != 3 {
+		t.Errorf("Expected 3 pages, got %d", doc.NumPages())
+	}
+	for i, page := range doc.Pages() {
+		if page.Number != i+1 {
+			t.Errorf("Expected page number %d, got %d", i+1, page.Number)
+		}
+		if _, ok := page.Dict["MediaBox"].(Array); !ok {
+			t.Errorf("Expected page %d to have a MediaBox", page.Number)
+		}
+	}
+}
+
+func TestOpen_NotPDF(t *testing.T) {
+	if _, err := Open([]byte("hello world")); err == nil {
+		t.Error("Expected error for non-PDF data")
+	}
+	if _, err := Open([]byte("%PDF-1.4\n%%EOF")); err == nil {
+		t.Error("Expected error for PDF without objects")
+	}
+}
+
+func TestOpen_PageTreeCycleThroughObjectZero(t *testing.T) {
+	data := []byte("%PDF-1.4\n1 0 obj<</Type/Catalog/Pages 0 0 R>>endobj\n0 0 obj<</Kids[0 0 R 7 0 R]>>endobj\n%%EOF")
+	if _, err := Open(data); err == nil {
+		t.Error("Expected error for a page tree that contains itself")
+	}
+}
+
+func TestOpen_InheritsPageAttributes(t *testing.T) {
+	b := NewBuilder()
+	catalog := b.Reserve()
+	pages := b.Reserve()
+	page := b.Add(Dict{"Type": Name("Page"), "Parent": pages})
+	b.Set(pages, Dict{
+		"Type":     Name("Pages"),
+		"Kids":     Array{page},
+		"Count":    int64(1),
+		"MediaBox": Array{int64(0), int64(0), int64(100), int64(200)},
+		"Rotate":   int64(90),
+	})
+	b.Set(catalog, Dict{"Type": Name("Catalog"), "Pages": pages})
+
+	doc, err := Open(b.Bytes(catalog))
+	if err != nil {
+		t.Fatalf("Failed to open PDF: %v", err)
+	}
+	dict := doc.Pages()[0].Dict
+	if dict["Rotate"] != int64(90) {
+		t.Errorf("Expected inherited Rotate 90, got %v", dict["Rotate"])
+	}
+	if box, ok := dict["MediaBox"].(Array); !ok || box[3] != int64(200) {
+		t.Errorf("Expected inherited MediaBox, got %v", dict["MediaBox"])
+	}
+}
+
+func TestDocument_FlateDecode(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write([]byte("BT /F1 12 Tf 72 700 Td (Compressed text) Tj ET"))
+	zw.Close()
+
+	b := NewBuilder()
+	catalog := b.Reserve()
+	pages := b.Reserve()
+	font := b.Add(Dict{"Type": Name("Font"), "Subtype": Name("Type1"), "BaseFont": Name("Helvetica")})
+	contents := b.Add(&Stream{Dict: Dict{"Filter": Name("FlateDecode")}, Data: compressed.Bytes()})
+	page := b.Add(Dict{"Type": Name("Page"), "Parent": pages, "Contents": contents, "Resources": Dict{"Font": Dict{"F1": font}}})
+	b.Set(pages, Dict{"Type": Name("Pages"), "Kids": Array{page}, "Count": int64(1)})
+	b.Set(catalog, Dict{"Type": Name("Catalog"), "Pages": pages})
+
+	texts, err := ExtractPageTexts(b.Bytes(catalog))
+	if err != nil {
+		t.Fatalf("Failed to extract text: %v", err)
+	}
+	if len(texts) != 1 || texts[0] != "Compressed text" {
+		t.Errorf("Expected 'Compressed text', got %q", texts)
+	}
+}
+
+func TestDocument_WrongStreamLength(t *testing.T) {
+	data := GenerateTextPDF([]string{"Length mismatch"})
+	// Corrupt the /Length of the content stream; the reader falls back to endstream
+	data = bytes.Replace(data, []byte("/Length "), []byte("/Length 9"), 1)
+
+	texts, err := ExtractPageTexts(data)
+	if err != nil {
+		t.Fatalf("Failed to extract text: %v", err)
+	}
+	if len(texts) != 1 || texts[0] != "Length mismatch" {
+		t.Errorf("Expected 'Length mismatch', got %q", texts)
+	}
+}
+
+func TestParseObject(t *testing.T) {
+	obj, err := newParser([]byte(`<< /Type /Page /Kids [1 0 R 2 0 R] /Name (a\(b\)) /Hex <414243> /N#20ame -1.5 /Flag true >>`), 0).parseObject()
+	if err != nil {
+		t.Fatalf("Failed to parse object: %v", err)
+	}
+	dict, ok := obj.(Dict)
+	if !ok {
+		t.Fatalf("Expected Dict, got %T", obj)
+	}
+	if dict["Type"] != Name("Page") {
+		t.Errorf("Expected /Type /Page, got %v", dict["Type"])
+	}
+	if kids, ok := dict["Kids"].(Array); !ok || len(kids) != 2 || kids[1] != (Ref{Num: 2}) {
+		t.Errorf("Expected two references in Kids, got %v", dict["Kids"])
+	}
+	if s, ok := dict["Name"].(String); !ok || string(s) != "a(b)" {
+		t.Errorf("Expected escaped literal string, got %v", dict["Name"])
+	}
+	if s, ok := dict["Hex"].(String); !ok || string(s) != "ABC" {
+		t.Errorf("Expected hex string ABC, got %v", dict["Hex"])
+	}
+	if dict["N ame"] != -1.5 {
+		t.Errorf("Expected escaped name with value -1.5, got %v", dict["N ame"])
+	}
+	if dict["Flag"] != true {
+		t.Errorf("Expected Flag true, got %v", dict["Flag"])
+	}
+}
+
+// FuzzOpen checks that no input makes Open, or reading and copying the pages
+// of what it opens, panic or recurse without bound
+func FuzzOpen(f *testing.F) {
+	f.Add(GenerateTextPDF([]string{"first", "second"}))
+	f.Add([]byte("%PDF-1.4\n1 0 obj<</Type/Catalog/Pages 0 0 R>>endobj\n0 0 obj<</Kids[0 0 R 7 0 R]>>endobj\n%%EOF"))
+	f.Add([]byte("%PDF-1.4\n1 0 obj<</Type/Catalog/Pages 2 0 R>>endobj\n2 0 obj<</Kids[<</Type/Page/Contents 3 0 R>>]>>endobj\n3 0 obj<</Length 9/Filter/FlateDecode>>stream\nnot flate\nendstream endobj\n%%EOF"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		doc, err := Open(data)
+		if err != nil {
+			return
+		}
+		numbers := make([]int, doc.NumPages())
+		for i, page := range doc.Pages() {
+			numbers[i] = page.Number
+			doc.PageText(page)
+			doc.PageImages(page)
+		}
+		doc.ExtractPages(numbers)
+	})
+}
@@ -0,0 +1,128 @@
+package pdf
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// Image is an image embedded in a page, encoded as JPEG or PNG
+type Image struct {
+	MediaType string // "image/jpeg" or "image/png"
+	Data      []byte
+	Width     int
+	Height    int
+}
+
+// minImageSize skips icons, bullets and other decorations
+const minImageSize = 32
+
+// PageImages returns the images a page uses, including those inside form
+// XObjects. JPEG images are returned as stored; uncompressed or Flate
+// encoded 8-bit gray and RGB images are converted to PNG. Other images,
+// such as JBIG2 or CMYK images, are skipped.
+func (d *Document) PageImages(page *Page) []Image {
+	var images []Image
+	seen := map[Object]bool{}
+	d.collectImages(d.ResolveDict(page.Dict["Resources"]), &images, seen, 0)
+	return images
+}
+
+func (d *Document) collectImages(resources Dict, images *[]Image, seen map[Object]bool, depth int) {
+	xobjects := d.ResolveDict(resources["XObject"])
+	for _, ref := range xobjects {
+		if r, ok := ref.(Ref); ok {
+			if seen[r] {
+				continue
+			}
+			seen[r] = true
+		}
+		stream, ok := d.Resolve(ref).(*Stream)
+		if !ok {
+			continue
+		}
+
+		switch stream.Dict["Subtype"] {
+		case Name("Image"):
+			if img, ok := d.convertImage(stream); ok {
+				*images = append(*images, img)
+			}
+		case Name("Form"):
+			if depth < maxFormDepth {
+				d.collectImages(d.ResolveDict(stream.Dict["Resources"]), images, seen, depth+1)
+			}
+		}
+	}
+}
+
+func (d *Document) convertImage(stream *Stream) (Image, bool) {
+	width := int(number(d.Resolve(stream.Dict["Width"])))
+	height := int(number(d.Resolve(stream.Dict["Height"])))
+	if width < minImageSize || height < minImageSize {
+		return Image{}, false
+	}
+
+	filters, _ := d.filters(stream.Dict)
+	if len(filters) == 1 && (filters[0] == "DCTDecode" || filters[0] == "DCT") {
+		return Image{MediaType: "image/jpeg", Data: stream.Data, Width: width, Height: height}, true
+	}
+
+	if bpc, ok := d.Resolve(stream.Dict["BitsPerComponent"]).(int64); !ok || bpc != 8 {
+		return Image{}, false
+	}
+	components := d.colorComponents(stream.Dict["ColorSpace"])
+	if components != 1 && components != 3 {
+		return Image{}, false
+	}
+	data, err := d.Decode(stream)
+	if err != nil || len(data) < width*height*components {
+		return Image{}, false
+	}
+
+	var img image.Image
+	if components == 1 {
+		gray := image.NewGray(image.Rect(0, 0, width, height))
+		copy(gray.Pix, data)
+		img = gray
+	} else {
+		rgba := image.NewRGBA(image.Rect(0, 0, width, height))
+		for i := 0; i < width*height; i++ {
+			rgba.SetRGBA(i%width, i/width, color.RGBA{data[3*i], data[3*i+1], data[3*i+2], 255})
+		}
+		img = rgba
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return Image{}, false
+	}
+	return Image{MediaType: "image/png", Data: buf.Bytes(), Width: width, Height: height}, true
+}
+
+// colorComponents returns the number of components of a color space, or 0
+// for color spaces that cannot be converted
+func (d *Document) colorComponents(cs Object) int {
+	switch v := d.Resolve(cs).(type) {
+	case Name:
+		switch v {
+		case "DeviceGray", "G", "CalGray":
+			return 1
+		case "DeviceRGB", "RGB", "CalRGB":
+			return 3
+		}
+	case Array:
+		if len(v) >= 1 && v[0] == Name("Indexed") {
+			return 0
+		}
+		if len(v) >= 2 && v[0] == Name("ICCBased") {
+			if stream, ok := d.Resolve(v[1]).(*Stream); ok {
+				return int(number(d.Resolve(stream.Dict["N"])))
+			}
+		}
+		if len(v) >= 1 {
+			return d.colorComponents(v[0])
+		}
+	}
+	return 0
+}
@@ -0,0 +1,63 @@
+package pdf
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+)
+
+func TestPageImages(t *testing.T) {
+	gray := bytes.Repeat([]byte{0x80}, 40*40)
+	jpeg := []byte("\xff\xd8\xff\xe0 fake jpeg data \xff\xd9")
+
+	b := NewBuilder()
+	catalog := b.Reserve()
+	pages := b.Reserve()
+	grayImage := b.Add(&Stream{Dict: Dict{
+		"Type": Name("XObject"), "Subtype": Name("Image"), "Width": int64(40), "Height": int64(40),
+		"ColorSpace": Name("DeviceGray"), "BitsPerComponent": int64(8),
+	}, Data: gray})
+	jpegImage := b.Add(&Stream{Dict: Dict{
+		"Type": Name("XObject"), "Subtype": Name("Image"), "Width": int64(100), "Height": int64(50),
+		"ColorSpace": Name("DeviceRGB"), "BitsPerComponent": int64(8), "Filter": Name("DCTDecode"),
+	}, Data: jpeg})
+	icon := b.Add(&Stream{Dict: Dict{
+		"Type": Name("XObject"), "Subtype": Name("Image"), "Width": int64(8), "Height": int64(8),
+		"ColorSpace": Name("DeviceGray"), "BitsPerComponent": int64(8),
+	}, Data: make([]byte, 64)})
+	form := b.Add(&Stream{Dict: Dict{
+		"Type": Name("XObject"), "Subtype": Name("Form"),
+		"Resources": Dict{"XObject": Dict{"Im2": jpegImage}},
+	}, Data: []byte("/Im2 Do")})
+	contents := b.Add(&Stream{Dict: Dict{}, Data: []byte("/Im1 Do /Fm1 Do /Im3 Do")})
+	page := b.Add(Dict{
+		"Type": Name("Page"), "Parent": pages, "Contents": contents,
+		"Resources": Dict{"XObject": Dict{"Im1": grayImage, "Fm1": form, "Im3": icon}},
+	})
+	b.Set(pages, Dict{"Type": Name("Pages"), "Kids": Array{page}, "Count": int64(1)})
+	b.Set(catalog, Dict{"Type": Name("Catalog"), "Pages": pages})
+
+	doc, err := Open(b.Bytes(catalog))
+	if err != nil {
+		t.Fatalf("Failed to open PDF: %v", err)
+	}
+	images := doc.PageImages(doc.Pages()[0])
+	if len(images) != 2 {
+		t.Fatalf("Expected 2 images (icon skipped), got %d", len(images))
+	}
+
+	byType := map[string]Image{}
+	for _, img := range images {
+		byType[img.MediaType] = img
+	}
+	if !bytes.Equal(byType["image/jpeg"].Data, jpeg) {
+		t.Error("Expected JPEG data to be returned unchanged")
+	}
+	decoded, err := png.Decode(bytes.NewReader(byType["image/png"].Data))
+	if err != nil {
+		t.Fatalf("Expected valid PNG: %v", err)
+	}
+	if decoded.Bounds().Dx() != 40 || decoded.Bounds().Dy() != 40 {
+		t.Errorf("Expected 40x40 PNG, got %v", decoded.Bounds())
+	}
+}
@@ -0,0 +1,392 @@
+// Package pdf implements the subset of the PDF format the backend needs:
+// reading objects and pages, extracting the text layer, and writing
+// documents back out.
+package pdf
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Object is any PDF object: nil, bool, int64, float64, String, Name,
+// Array, Dict, Ref or *Stream
+type Object interface{}
+
+// Name is a PDF name object such as /Type
+type Name string
+
+// String is a PDF string object holding raw bytes
+type String []byte
+
+// Array is a PDF array object
+type Array []Object
+
+// Dict is a PDF dictionary object
+type Dict map[Name]Object
+
+// Ref is an indirect reference such as "12 0 R"
+type Ref struct {
+	Num int
+	Gen int
+}
+
+// Stream is a stream object. Data holds the encoded bytes as stored in the file.
+type Stream struct {
+	Dict Dict
+	Data []byte
+}
+
+// keyword is a bare token such as obj, endobj, stream or a content stream operator
+type keyword string
+
+// token kinds produced by the lexer
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokName
+	tokString
+	tokKeyword
+	tokArrayStart
+	tokArrayEnd
+	tokDictStart
+	tokDictEnd
+)
+
+type token struct {
+	kind  tokenKind
+	value Object // int64/float64, Name, String or keyword
+	start int    // Offset of the first byte of the token
+	end   int    // Offset just past the token
+}
+
+// lexer tokenizes PDF file and content stream syntax
+type lexer struct {
+	data []byte
+	pos  int
+}
+
+func isWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == 0
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isWhitespace(c) {
+			l.pos++
+		} else if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		} else {
+			return
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipSpace()
+	start := l.pos
+	if l.pos >= len(l.data) {
+		return token{kind: tokEOF, start: start, end: start}, nil
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '[':
+		l.pos++
+		return token{kind: tokArrayStart, start: start, end: l.pos}, nil
+	case c == ']':
+		l.pos++
+		return token{kind: tokArrayEnd, start: start, end: l.pos}, nil
+	case c == '<' && l.peekAt(1) == '<':
+		l.pos += 2
+		return token{kind: tokDictStart, start: start, end: l.pos}, nil
+	case c == '>' && l.peekAt(1) == '>':
+		l.pos += 2
+		return token{kind: tokDictEnd, start: start, end: l.pos}, nil
+	case c == '<':
+		s, err := l.readHexString()
+		return token{kind: tokString, value: s, start: start, end: l.pos}, err
+	case c == '(':
+		s, err := l.readLiteralString()
+		return token{kind: tokString, value: s, start: start, end: l.pos}, err
+	case c == '/':
+		return token{kind: tokName, value: l.readName(), start: start, end: l.pos}, nil
+	case c == '{' || c == '}' || c == ')' || c == '>':
+		l.pos++
+		return token{kind: tokKeyword, value: keyword(c), start: start, end: l.pos}, nil
+	}
+
+	for l.pos < len(l.data) && !isWhitespace(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	word := string(l.data[start:l.pos])
+	if n, ok := parseNumber(word); ok {
+		return token{kind: tokNumber, value: n, start: start, end: l.pos}, nil
+	}
+	return token{kind: tokKeyword, value: keyword(word), start: start, end: l.pos}, nil
+}
+
+func (l *lexer) peekAt(offset int) byte {
+	if l.pos+offset < len(l.data) {
+		return l.data[l.pos+offset]
+	}
+	return 0
+}
+
+func parseNumber(word string) (Object, bool) {
+	if word == "" {
+		return nil, false
+	}
+	c := word[0]
+	if !(c >= '0' && c <= '9') && c != '-' && c != '+' && c != '.' {
+		return nil, false
+	}
+	if i, err := strconv.ParseInt(word, 10, 64); err == nil {
+		return i, true
+	}
+	if f, err := strconv.ParseFloat(word, 64); err == nil {
+		return f, true
+	}
+	return nil, false
+}
+
+func (l *lexer) readName() Name {
+	l.pos++ // skip '/'
+	var name []byte
+	for l.pos < len(l.data) && !isWhitespace(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+		c := l.data[l.pos]
+		if c == '#' && l.pos+2 < len(l.data) {
+			if v, err := strconv.ParseUint(string(l.data[l.pos+1:l.pos+3]), 16, 8); err == nil {
+				name = append(name, byte(v))
+				l.pos += 3
+				continue
+			}
+		}
+		name = append(name, c)
+		l.pos++
+	}
+	return Name(name)
+}
+
+func (l *lexer) readHexString() (String, error) {
+	l.pos++ // skip '<'
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		c := l.data[l.pos]
+		if !isWhitespace(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	if l.pos >= len(l.data) {
+		return nil, fmt.Errorf("unterminated hex string")
+	}
+	l.pos++ // skip '>'
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		v, err := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex string: %w", err)
+		}
+		out[i] = byte(v)
+	}
+	return String(out), nil
+}
+
+func (l *lexer) readLiteralString() (String, error) {
+	l.pos++ // skip '('
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+			out = append(out, c)
+		case ')':
+			depth--
+			if depth == 0 {
+				return String(out), nil
+			}
+			out = append(out, c)
+		case '\\':
+			if l.pos >= len(l.data) {
+				return String(out), nil
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				// Line continuation
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+				// Line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					out = append(out, byte(v))
+				} else {
+					out = append(out, e)
+				}
+			}
+		default:
+			out = append(out, c)
+		}
+	}
+	return nil, fmt.Errorf("unterminated literal string")
+}
+
+// parser builds objects from lexer tokens
+type parser struct {
+	lex    *lexer
+	peeked []token
+}
+
+func newParser(data []byte, pos int) *parser {
+	return &parser{lex: &lexer{data: data, pos: pos}}
+}
+
+func (p *parser) nextToken() (token, error) {
+	if len(p.peeked) > 0 {
+		t := p.peeked[0]
+		p.peeked = p.peeked[1:]
+		return t, nil
+	}
+	return p.lex.next()
+}
+
+func (p *parser) peekToken(n int) (token, error) {
+	for len(p.peeked) <= n {
+		t, err := p.lex.next()
+		if err != nil {
+			return token{}, err
+		}
+		p.peeked = append(p.peeked, t)
+	}
+	return p.peeked[n], nil
+}
+
+// offset returns the position of the next unread token
+func (p *parser) offset() int {
+	if len(p.peeked) > 0 {
+		return p.peeked[0].start
+	}
+	return p.lex.pos
+}
+
+// parseObject reads one object. Integers followed by "G R" become references.
+func (p *parser) parseObject() (Object, error) {
+	t, err := p.nextToken()
+	if err != nil {
+		return nil, err
+	}
+	return p.parseFrom(t)
+}
+
+func (p *parser) parseFrom(t token) (Object, error) {
+	switch t.kind {
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of data")
+	case tokNumber:
+		if num, ok := t.value.(int64); ok {
+			t1, err1 := p.peekToken(0)
+			t2, err2 := p.peekToken(1)
+			if err1 == nil && err2 == nil && t1.kind == tokNumber && t2.kind == tokKeyword && t2.value == keyword("R") {
+				if gen, ok := t1.value.(int64); ok {
+					p.nextToken()
+					p.nextToken()
+					return Ref{Num: int(num), Gen: int(gen)}, nil
+				}
+			}
+		}
+		return t.value, nil
+	case tokName, tokString:
+		return t.value, nil
+	case tokArrayStart:
+		arr := Array{}
+		for {
+			next, err := p.nextToken()
+			if err != nil {
+				return nil, err
+			}
+			if next.kind == tokArrayEnd {
+				return arr, nil
+			}
+			if next.kind == tokEOF {
+				return nil, fmt.Errorf("unterminated array")
+			}
+			obj, err := p.parseFrom(next)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, obj)
+		}
+	case tokDictStart:
+		dict := Dict{}
+		for {
+			next, err := p.nextToken()
+			if err != nil {
+				return nil, err
+			}
+			if next.kind == tokDictEnd {
+				return dict, nil
+			}
+			if next.kind == tokEOF {
+				return nil, fmt.Errorf("unterminated dictionary")
+			}
+			key, ok := next.value.(Name)
+			if next.kind != tokName || !ok {
+				// Skip junk keys rather than failing the whole object
+				continue
+			}
+			value, err := p.parseObject()
+			if err != nil {
+				return nil, err
+			}
+			dict[key] = value
+		}
+	case tokKeyword:
+		switch t.value {
+		case keyword("true"):
+			return true, nil
+		case keyword("false"):
+			return false, nil
+		case keyword("null"):
+			return nil, nil
+		}
+		return t.value, nil
+	}
+	return nil, fmt.Errorf("unexpected token at offset %d", t.start)
+}
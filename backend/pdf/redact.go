@@ -0,0 +1,214 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Redaction is a range of a page's text to remove, as byte offsets into the
+// text ExtractPageTexts returns for the page
+type Redaction struct {
+	PageNumber int // 1-indexed
+	Start      int
+	End        int
+}
+
+// redactedPageEntries are page entries left out of redacted copies, since
+// they can carry the page's text outside its content stream
+var redactedPageEntries = []Name{"Annots", "Thumb", "Metadata", "PieceInfo"}
+
+// Redact builds a copy of a PDF with the text of each redaction removed from
+// the page content streams and a black box drawn where it was. Each removed
+// glyph is replaced by a TJ displacement of its width, so the rest of the
+// line keeps its layout. Only the pages' own content streams are rewritten:
+// text drawn by form XObjects or inside images stays. The returned flags
+// report, for each redaction, whether all of its text was removed.
+//
+// Annotations, thumbnails, outlines and document metadata are not copied.
+func Redact(data []byte, redactions []Redaction) ([]byte, []bool, error) {
+	doc, err := Open(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byPage := map[int][]int{}
+	for i, r := range redactions {
+		if r.PageNumber < 1 || r.PageNumber > doc.NumPages() {
+			return nil, nil, fmt.Errorf("page %d out of range (document has %d pages)", r.PageNumber, doc.NumPages())
+		}
+		byPage[r.PageNumber] = append(byPage[r.PageNumber], i)
+	}
+
+	removed := make([]bool, len(redactions))
+	rewrite := &pageRewrite{contents: map[int][]byte{}, omit: redactedPageEntries}
+	for number, indices := range byPage {
+		page := doc.pages[number-1]
+		content, err := doc.Contents(page)
+		if err != nil {
+			return nil, nil, err
+		}
+		w := &textWriter{record: true}
+		doc.interpret(content, doc.ResolveDict(page.Dict["Resources"]), w, 0)
+
+		// Offsets are into the trimmed text
+		text := w.buf.String()
+		lead := len(text) - len(strings.TrimLeftFunc(text, unicode.IsSpace))
+
+		// covered marks the bytes of the text shown by the page's own glyphs;
+		// the rest is whitespace inserted from positions or text of forms
+		covered := make([]bool, len(text))
+		for _, g := range w.glyphs {
+			for b := g.start; b < g.end; b++ {
+				covered[b] = true
+			}
+		}
+
+		cut := make([]bool, len(w.glyphs))
+		for _, i := range indices {
+			start, end := redactions[i].Start+lead, redactions[i].End+lead
+			if start < lead || start >= end || end > len(text) {
+				return nil, nil, fmt.Errorf("redaction %d-%d out of range of page %d text", redactions[i].Start, redactions[i].End, number)
+			}
+			removed[i] = true
+			for b := start; b < end; b++ {
+				if !covered[b] && text[b] != ' ' && text[b] != '\n' {
+					removed[i] = false
+				}
+			}
+			for j, g := range w.glyphs {
+				// Glyphs without text are cut when they sit inside the range
+				if g.start < end && (g.end > start || g.start > start) {
+					cut[j] = true
+				}
+			}
+		}
+		rewrite.contents[number] = rewriteContent(content, w.ops, w.glyphs, cut)
+	}
+
+	pageNumbers := make([]int, doc.NumPages())
+	for i := range pageNumbers {
+		pageNumbers[i] = i + 1
+	}
+	out, err := doc.writePages(pageNumbers, rewrite)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, removed, nil
+}
+
+// rewriteContent replaces the text-showing operations with cut glyphs and
+// paints a box over each run of cut glyphs. The original content is wrapped
+// in q/Q so the boxes are drawn in default user space.
+func rewriteContent(content []byte, ops []textOp, glyphs []glyph, cut []bool) []byte {
+	byOp := make([][]int, len(ops))
+	edited := make([]bool, len(ops))
+	for j, g := range glyphs {
+		byOp[g.op] = append(byOp[g.op], j)
+		edited[g.op] = edited[g.op] || cut[j]
+	}
+
+	var out bytes.Buffer
+	out.WriteString("q\n")
+	pos := 0
+	for i, op := range ops {
+		if !edited[i] {
+			continue
+		}
+		out.Write(content[pos:op.start])
+		writeTextOp(&out, op, glyphs, byOp[i], cut)
+		pos = op.end
+	}
+	out.Write(content[pos:])
+	out.WriteString("\nQ\nq 0 g\n")
+
+	// One box per run of consecutive cut glyphs of an operation
+	var box [4]float64
+	open := false
+	for j, g := range glyphs {
+		if open && (!cut[j] || g.op != glyphs[j-1].op) {
+			writeBox(&out, box)
+			open = false
+		}
+		if !cut[j] {
+			continue
+		}
+		if !open {
+			box, open = g.box, true
+			continue
+		}
+		box = [4]float64{min(box[0], g.box[0]), min(box[1], g.box[1]), max(box[2], g.box[2]), max(box[3], g.box[3])}
+	}
+	if open {
+		writeBox(&out, box)
+	}
+	out.WriteString("Q\n")
+	return out.Bytes()
+}
+
+// writeTextOp writes a text-showing operation as a TJ with the cut glyphs
+// replaced by displacements
+func writeTextOp(out *bytes.Buffer, op textOp, glyphs []glyph, indices []int, cut []bool) {
+	var shown Array
+	showElement := func(element int) {
+		var run String
+		for _, j := range indices {
+			g := glyphs[j]
+			if g.element != element {
+				continue
+			}
+			if !cut[j] {
+				run = append(run, g.code...)
+				continue
+			}
+			if len(run) > 0 {
+				shown = append(shown, run)
+				run = nil
+			}
+			if n := len(shown); n > 0 {
+				if adjust, ok := shown[n-1].(float64); ok {
+					shown[n-1] = adjust + g.adjust
+					continue
+				}
+			}
+			shown = append(shown, g.adjust)
+		}
+		if len(run) > 0 {
+			shown = append(shown, run)
+		}
+	}
+
+	switch op.op {
+	case "TJ":
+		items, _ := op.operands[0].(Array)
+		for i, item := range items {
+			if _, ok := item.(String); ok {
+				showElement(i)
+			} else {
+				shown = append(shown, item)
+			}
+		}
+	case "'":
+		out.WriteString("T* ")
+		showElement(0)
+	case "\"":
+		writeObject(out, op.operands[0])
+		out.WriteString(" Tw ")
+		writeObject(out, op.operands[1])
+		out.WriteString(" Tc T* ")
+		showElement(0)
+	default:
+		showElement(0)
+	}
+	writeObject(out, shown)
+	out.WriteString(" TJ")
+}
+
+func writeBox(out *bytes.Buffer, box [4]float64) {
+	format := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	}
+	fmt.Fprintf(out, "%s %s %s %s re f\n", format(box[0]), format(box[1]), format(box[2]-box[0]), format(box[3]-box[1]))
+}
@@ -0,0 +1,121 @@
+package pdf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// span returns the redaction of the first occurrence of value in text
+func span(t *testing.T, page int, text, value string) Redaction {
+	t.Helper()
+	start := strings.Index(text, value)
+	if start < 0 {
+		t.Fatalf("%q not found in %q", value, text)
+	}
+	return Redaction{PageNumber: page, Start: start, End: start + len(value)}
+}
+
+func TestRedact_RemovesText(t *testing.T) {
+	data := GenerateTextPDF([]string{"Name: Jane Roe\nSSN: 123-45-6789 on file", "Account 0012345678"})
+	texts, _ := ExtractPageTexts(data)
+
+	redacted, removed, err := Redact(data, []Redaction{
+		span(t, 1, texts[0], "123-45-6789"),
+		span(t, 1, texts[0], "Jane Roe"),
+		span(t, 2, texts[1], "0012345678"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to redact: %v", err)
+	}
+	for i, ok := range removed {
+		if !ok {
+			t.Errorf("Expected redaction %d to be removed", i)
+		}
+	}
+
+	got, err := ExtractPageTexts(redacted)
+	if err != nil {
+		t.Fatalf("Failed to extract redacted text: %v", err)
+	}
+	if got[0] != "Name: \nSSN:  on file" {
+		t.Errorf("Expected the values removed and the rest kept, got %q", got[0])
+	}
+	if got[1] != "Account" {
+		t.Errorf("Expected 'Account', got %q", got[1])
+	}
+	for _, value := range []string{"123-45-6789", "Jane", "0012345678"} {
+		if bytes.Contains(redacted, []byte(value)) {
+			t.Errorf("Expected %q to be absent from the redacted file", value)
+		}
+	}
+}
+
+func TestRedact_KeepsLayoutAndDrawsBoxes(t *testing.T) {
+	data := buildPage("BT /F1 10 Tf 72 700 Td [(Call ) -20 (555-1234)] TJ ( today) Tj ET", helvetica)
+	text := extractSingle(t, data)
+
+	redacted, _, err := Redact(data, []Redaction{span(t, 1, text, "555-1234")})
+	if err != nil {
+		t.Fatalf("Failed to redact: %v", err)
+	}
+	doc, _ := Open(redacted)
+	content, _ := doc.Contents(doc.Pages()[0])
+	if !bytes.Contains(content, []byte("[(Call ) -20 -")) || !bytes.Contains(content, []byte("( today) Tj")) {
+		t.Errorf("Expected the number replaced by a displacement, got %s", content)
+	}
+	if !bytes.Contains(content, []byte(" re f")) {
+		t.Errorf("Expected a box over the removed text, got %s", content)
+	}
+	if got := extractSingle(t, redacted); got != "Call  today" {
+		t.Errorf("Expected 'Call  today', got %q", got)
+	}
+}
+
+func TestRedact_ReportsTextInForms(t *testing.T) {
+	b := NewBuilder()
+	catalog := b.Reserve()
+	pages := b.Reserve()
+	fonts := helvetica(b)
+	form := b.Add(&Stream{
+		Dict: Dict{"Type": Name("XObject"), "Subtype": Name("Form"), "Resources": Dict{"Font": fonts}},
+		Data: []byte("BT /F1 10 Tf 72 680 Td (Form 987) Tj ET"),
+	})
+	contents := b.Add(&Stream{Dict: Dict{}, Data: []byte("BT /F1 10 Tf 72 700 Td (Page 123) Tj ET q /X1 Do Q")})
+	page := b.Add(Dict{
+		"Type":      Name("Page"),
+		"Parent":    pages,
+		"Contents":  contents,
+		"Resources": Dict{"Font": fonts, "XObject": Dict{"X1": form}},
+		"Annots":    Array{Dict{"Subtype": Name("Text"), "Contents": String("987")}},
+	})
+	b.Set(pages, Dict{"Type": Name("Pages"), "Kids": Array{page}, "Count": int64(1)})
+	b.Set(catalog, Dict{"Type": Name("Catalog"), "Pages": pages})
+	data := b.Bytes(catalog)
+	text := extractSingle(t, data)
+
+	redacted, removed, err := Redact(data, []Redaction{span(t, 1, text, "123"), span(t, 1, text, "987")})
+	if err != nil {
+		t.Fatalf("Failed to redact: %v", err)
+	}
+	if !removed[0] || removed[1] {
+		t.Errorf("Expected only the page's own text to be removed, got %v", removed)
+	}
+	if got := extractSingle(t, redacted); got != "Page \nForm 987" {
+		t.Errorf("Expected 'Page \\nForm 987', got %q", got)
+	}
+	doc, _ := Open(redacted)
+	if _, ok := doc.Pages()[0].Dict["Annots"]; ok {
+		t.Error("Expected annotations to be dropped")
+	}
+}
+
+func TestRedact_InvalidRange(t *testing.T) {
+	data := GenerateTextPDF([]string{"Short"})
+	if _, _, err := Redact(data, []Redaction{{PageNumber: 2, Start: 0, End: 1}}); err == nil {
+		t.Error("Expected an error for a page out of range")
+	}
+	if _, _, err := Redact(data, []Redaction{{PageNumber: 1, Start: 3, End: 20}}); err == nil {
+		t.Error("Expected an error for a range past the page text")
+	}
+}
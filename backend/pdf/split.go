@@ -0,0 +1,154 @@
+package pdf
+
+import (
+	"fmt"
+)
+
+// ExtractPages builds a new PDF containing the given 1-indexed pages of the
+// document, in the order given. Only objects reachable from those pages are
+// copied. Links to pages that are not copied are dropped.
+func (d *Document) ExtractPages(pageNumbers []int) ([]byte, error) {
+	return d.writePages(pageNumbers, nil)
+}
+
+// pageRewrite changes pages as writePages copies them
+type pageRewrite struct {
+	contents map[int][]byte // New decoded content of pages, by page number
+	omit     []Name         // Page entries not to copy
+}
+
+// writePages builds a new PDF from the given 1-indexed pages, applying the
+// rewrite if it is not nil
+func (d *Document) writePages(pageNumbers []int, rewrite *pageRewrite) ([]byte, error) {
+	if len(pageNumbers) == 0 {
+		return nil, fmt.Errorf("no pages to extract")
+	}
+
+	b := NewBuilder()
+	catalog := b.Reserve()
+	pageTree := b.Reserve()
+
+	c := &copier{doc: d, builder: b, refs: map[int]Ref{}, pages: map[int]bool{}}
+	for _, page := range d.pages {
+		if page.Ref != nil {
+			c.pages[page.Ref.Num] = true
+		}
+	}
+
+	// Reserve the new page objects first so links between copied pages resolve
+	newPages := make([]Ref, len(pageNumbers))
+	for i, n := range pageNumbers {
+		if n < 1 || n > len(d.pages) {
+			return nil, fmt.Errorf("page %d out of range (document has %d pages)", n, len(d.pages))
+		}
+		newPages[i] = b.Reserve()
+		if ref := d.pages[n-1].Ref; ref != nil {
+			c.refs[ref.Num] = newPages[i]
+		}
+	}
+
+	kids := make(Array, len(pageNumbers))
+	for i, n := range pageNumbers {
+		source := d.pages[n-1].Dict
+		var content []byte
+		if rewrite != nil {
+			source = rewrite.apply(n, source)
+			content = rewrite.contents[n]
+		}
+		page := c.copyObject(source).(Dict)
+		if content != nil {
+			page["Contents"] = b.Add(flateStream(content))
+		}
+		page["Parent"] = pageTree
+		b.Set(newPages[i], page)
+		kids[i] = newPages[i]
+	}
+
+	b.Set(pageTree, Dict{"Type": Name("Pages"), "Kids": kids, "Count": int64(len(kids))})
+	b.Set(catalog, Dict{"Type": Name("Catalog"), "Pages": pageTree})
+	return b.Bytes(catalog), nil
+}
+
+// apply returns the page entries to copy. A page with new content does not
+// copy its old content streams.
+func (r *pageRewrite) apply(number int, page Dict) Dict {
+	out := Dict{}
+	for key, value := range page {
+		out[key] = value
+	}
+	for _, key := range r.omit {
+		delete(out, key)
+	}
+	if _, ok := r.contents[number]; ok {
+		delete(out, "Contents")
+	}
+	return out
+}
+
+// copier deep-copies objects from a document into a builder, renumbering
+// indirect references
+type copier struct {
+	doc     *Document
+	builder *Builder
+	refs    map[int]Ref  // Source object number to new reference
+	pages   map[int]bool // Object numbers of all source pages
+}
+
+func (c *copier) copyObject(obj Object) Object {
+	switch v := obj.(type) {
+	case Ref:
+		if ref, ok := c.refs[v.Num]; ok {
+			return ref
+		}
+		if c.pages[v.Num] {
+			// A link to a page that is not being copied
+			return nil
+		}
+		ref := c.builder.Reserve()
+		c.refs[v.Num] = ref
+		c.builder.Set(ref, c.copyObject(c.doc.Object(v.Num)))
+		return ref
+	case Array:
+		out := make(Array, len(v))
+		for i, item := range v {
+			out[i] = c.copyObject(item)
+		}
+		return out
+	case Dict:
+		out := Dict{}
+		for key, value := range v {
+			// Parent links lead back into the source page tree
+			if key == "Parent" {
+				continue
+			}
+			out[key] = c.copyObject(value)
+		}
+		return out
+	case *Stream:
+		// The writer sets Length, so an indirect length is not copied
+		dict := Dict{}
+		for key, value := range v.Dict {
+			if key != "Length" {
+				dict[key] = value
+			}
+		}
+		return &Stream{Dict: c.copyObject(dict).(Dict), Data: v.Data}
+	}
+	return obj
+}
+
+// SplitPages builds a new PDF from a page range (1-indexed, inclusive)
+func SplitPages(data []byte, start, end int) ([]byte, error) {
+	doc, err := Open(data)
+	if err != nil {
+		return nil, err
+	}
+	if start < 1 || end < start || end > doc.NumPages() {
+		return nil, fmt.Errorf("invalid page range %d-%d (document has %d pages)", start, end, doc.NumPages())
+	}
+	pages := make([]int, 0, end-start+1)
+	for n := start; n <= end; n++ {
+		pages = append(pages, n)
+	}
+	return doc.ExtractPages(pages)
+}
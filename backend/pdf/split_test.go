@@ -0,0 +1,64 @@
+package pdf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSplitPages(t *testing.T) {
+	data := GenerateTextPDF([]string{"one", "two", "three", "four"})
+
+	part, err := SplitPages(data, 2, 3)
+	if err != nil {
+		t.Fatalf("SplitPages failed: %v", err)
+	}
+	texts, err := ExtractPageTexts(part)
+	if err != nil {
+		t.Fatalf("Failed to read split PDF: %v", err)
+	}
+	if len(texts) != 2 || texts[0] != "two" || texts[1] != "three" {
+		t.Errorf("Expected pages two and three, got %q", texts)
+	}
+	if len(part) >= len(data) {
+		t.Errorf("Expected split PDF to be smaller than the original")
+	}
+
+	for _, r := range [][2]int{{0, 1}, {3, 2}, {1, 5}} {
+		if _, err := SplitPages(data, r[0], r[1]); err == nil {
+			t.Errorf("Expected error for range %d-%d", r[0], r[1])
+		}
+	}
+}
+
+func TestExtractPages_DropsLinksToOtherPages(t *testing.T) {
+	b := NewBuilder()
+	catalog := b.Reserve()
+	pages := b.Reserve()
+	page1 := b.Reserve()
+	page2 := b.Reserve()
+	link := b.Add(Dict{"Type": Name("Annot"), "Subtype": Name("Link"), "Dest": Array{page2, Name("Fit")}})
+	b.Set(page1, Dict{"Type": Name("Page"), "Parent": pages, "Annots": Array{link}})
+	b.Set(page2, Dict{"Type": Name("Page"), "Parent": pages})
+	b.Set(pages, Dict{"Type": Name("Pages"), "Kids": Array{page1, page2}, "Count": int64(2)})
+	b.Set(catalog, Dict{"Type": Name("Catalog"), "Pages": pages})
+
+	doc, err := Open(b.Bytes(catalog))
+	if err != nil {
+		t.Fatalf("Failed to open PDF: %v", err)
+	}
+	out, err := doc.ExtractPages([]int{1})
+	if err != nil {
+		t.Fatalf("ExtractPages failed: %v", err)
+	}
+
+	extracted, err := Open(out)
+	if err != nil {
+		t.Fatalf("Failed to open extracted PDF: %v", err)
+	}
+	if extracted.NumPages() != 1 {
+		t.Errorf("Expected 1 page, got %d", extracted.NumPages())
+	}
+	if !bytes.Contains(out, []byte("/Dest [null /Fit]")) {
+		t.Errorf("Expected link to the dropped page to be removed, got %s", out)
+	}
+}
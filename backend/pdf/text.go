@@ -0,0 +1,723 @@
+package pdf
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// ExtractPageTexts returns the text layer of every page of a PDF, in page
+// order. Pages without text yield empty strings.
+func ExtractPageTexts(data []byte) ([]string, error) {
+	doc, err := Open(data)
+	if err != nil {
+		return nil, err
+	}
+	texts := make([]string, doc.NumPages())
+	for i, page := range doc.Pages() {
+		text, err := doc.PageText(page)
+		if err != nil {
+			return nil, err
+		}
+		texts[i] = text
+	}
+	return texts, nil
+}
+
+// PageText extracts the text of a page in content stream order. Line breaks
+// are inserted when the baseline moves and spaces when the pen jumps forward.
+func (d *Document) PageText(page *Page) (string, error) {
+	content, err := d.Contents(page)
+	if err != nil {
+		return "", err
+	}
+	w := &textWriter{}
+	d.interpret(content, d.ResolveDict(page.Dict["Resources"]), w, 0)
+	return strings.TrimSpace(w.buf.String()), nil
+}
+
+// textState tracks the parts of the graphics and text state that affect layout
+type textState struct {
+	font     *font
+	fontSize float64
+	charSp   float64
+	wordSp   float64
+	scale    float64 // Horizontal scaling as a fraction
+	leading  float64
+	tm       [6]float64 // Text matrix
+	tlm      [6]float64 // Text line matrix
+	ctm      [6]float64 // Current transformation matrix
+}
+
+var identity = [6]float64{1, 0, 0, 1, 0, 0}
+
+// textWriter assembles shown text, inserting whitespace from positions.
+// With record set it also keeps the glyphs shown by the page's own content
+// stream, for redaction.
+type textWriter struct {
+	buf     strings.Builder
+	started bool
+	lastX   float64 // Pen position after the last shown glyph
+	lastY   float64
+
+	record  bool
+	ops     []textOp
+	glyphs  []glyph
+	current int // Index in ops of the operation being run, or -1
+	element int // Index of the string being shown in a TJ array
+}
+
+// textOp is a text-showing operation of a page's content stream
+type textOp struct {
+	start    int // Byte range of the operands and operator in the content
+	end      int
+	op       keyword
+	operands []Object
+}
+
+// glyph is one character code shown by a textOp
+type glyph struct {
+	op      int // Index in textWriter.ops
+	element int // Index of the string in a TJ array; 0 for other operators
+	code    string
+	start   int        // Byte range of the decoded text in the page text
+	end     int        // (before trimming)
+	adjust  float64    // TJ adjustment that moves the pen as far as the glyph
+	box     [4]float64 // Bounds in default user space: x0, y0, x1, y1
+}
+
+// beginOp starts recording the glyphs of a text-showing operation. Only the
+// page's own content is recorded, not that of form XObjects.
+func (w *textWriter) beginOp(depth, start, end int, op keyword, operands []Object) {
+	w.current, w.element = -1, 0
+	if !w.record || depth > 0 {
+		return
+	}
+	w.current = len(w.ops)
+	w.ops = append(w.ops, textOp{start: start, end: end, op: op, operands: append([]Object(nil), operands...)})
+}
+
+func (w *textWriter) show(text string, x, y, endX, size float64) {
+	if text == "" {
+		return
+	}
+	if w.started {
+		tolerance := math.Max(size, 1) * 0.5
+		switch {
+		case math.Abs(y-w.lastY) > tolerance:
+			w.newline()
+		case x-w.lastX > math.Max(size, 1)*0.15:
+			w.space()
+		}
+	}
+	w.buf.WriteString(text)
+	w.started = true
+	w.lastX = endX
+	w.lastY = y
+}
+
+func (w *textWriter) space() {
+	s := w.buf.String()
+	if s != "" && !strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\n") {
+		w.buf.WriteByte(' ')
+	}
+}
+
+func (w *textWriter) newline() {
+	if s := w.buf.String(); s != "" && !strings.HasSuffix(s, "\n") {
+		w.buf.WriteByte('\n')
+	}
+}
+
+// maximum nesting of form XObjects
+const maxFormDepth = 8
+
+// interpret runs a content stream, writing shown text to w
+func (d *Document) interpret(content []byte, resources Dict, w *textWriter, depth int) {
+	st := &textState{scale: 1, tm: identity, tlm: identity, ctm: identity}
+	fonts := map[Name]*font{}
+	p := newParser(content, 0)
+	var operands []Object
+	var saved [][6]float64 // CTMs saved by q
+	opStart := 0
+
+	for {
+		t, err := p.nextToken()
+		if err != nil || t.kind == tokEOF {
+			return
+		}
+		if t.kind != tokKeyword {
+			if len(operands) == 0 {
+				opStart = t.start
+			}
+			obj, err := p.parseFrom(t)
+			if err != nil {
+				return
+			}
+			operands = append(operands, obj)
+			continue
+		}
+		if len(operands) == 0 {
+			opStart = t.start
+		}
+
+		op := t.value.(keyword)
+		switch op {
+		case "Tj", "'", "\"", "TJ":
+			w.beginOp(depth, opStart, t.end, op, operands)
+		}
+		switch op {
+		case "BI":
+			skipInlineImage(p)
+		case "q":
+			saved = append(saved, st.ctm)
+		case "Q":
+			if len(saved) > 0 {
+				st.ctm = saved[len(saved)-1]
+				saved = saved[:len(saved)-1]
+			}
+		case "cm":
+			if len(operands) >= 6 {
+				var m [6]float64
+				for i := range m {
+					m[i] = number(operands[i])
+				}
+				st.ctm = multiply(m, st.ctm)
+			}
+		case "BT":
+			st.tm, st.tlm = identity, identity
+		case "ET":
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[0].(Name); ok {
+					st.font = d.loadFont(resources, name, fonts)
+				}
+				st.fontSize = number(operands[1])
+			}
+		case "Tc":
+			if len(operands) >= 1 {
+				st.charSp = number(operands[0])
+			}
+		case "Tw":
+			if len(operands) >= 1 {
+				st.wordSp = number(operands[0])
+			}
+		case "Tz":
+			if len(operands) >= 1 {
+				st.scale = number(operands[0]) / 100
+			}
+		case "TL":
+			if len(operands) >= 1 {
+				st.leading = number(operands[0])
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				tx, ty := number(operands[0]), number(operands[1])
+				if op == "TD" {
+					st.leading = -ty
+				}
+				st.moveLine(tx, ty)
+			}
+		case "Tm":
+			if len(operands) >= 6 {
+				for i := range st.tm {
+					st.tm[i] = number(operands[i])
+				}
+				st.tlm = st.tm
+			}
+		case "T*":
+			st.moveLine(0, -st.leading)
+		case "Tj":
+			if len(operands) >= 1 {
+				d.showString(st, w, operands[0])
+			}
+		case "'":
+			st.moveLine(0, -st.leading)
+			if len(operands) >= 1 {
+				d.showString(st, w, operands[0])
+			}
+		case "\"":
+			if len(operands) >= 3 {
+				st.wordSp = number(operands[0])
+				st.charSp = number(operands[1])
+				st.moveLine(0, -st.leading)
+				d.showString(st, w, operands[2])
+			}
+		case "TJ":
+			if len(operands) >= 1 {
+				arr, _ := operands[0].(Array)
+				for i, item := range arr {
+					switch v := item.(type) {
+					case String:
+						w.element = i
+						d.showString(st, w, v)
+					case int64, float64:
+						// Negative adjustments move the pen right; large ones separate words
+						adj := number(v)
+						st.advance(-adj / 1000 * st.fontSize * st.scale)
+						if adj < -200 {
+							w.space()
+						}
+					}
+				}
+			}
+		case "Do":
+			if len(operands) >= 1 && depth < maxFormDepth {
+				if name, ok := operands[0].(Name); ok {
+					d.runForm(resources, name, w, depth)
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+func (st *textState) moveLine(tx, ty float64) {
+	st.tlm[4] += tx*st.tlm[0] + ty*st.tlm[2]
+	st.tlm[5] += tx*st.tlm[1] + ty*st.tlm[3]
+	st.tm = st.tlm
+}
+
+// advance moves the pen along the baseline by tx text space units
+func (st *textState) advance(tx float64) {
+	st.tm[4] += tx * st.tm[0]
+	st.tm[5] += tx * st.tm[1]
+}
+
+// effectiveSize is the font size in user space
+func (st *textState) effectiveSize() float64 {
+	scale := math.Hypot(st.tm[2], st.tm[3])
+	if scale == 0 {
+		scale = 1
+	}
+	return st.fontSize * scale
+}
+
+func (d *Document) showString(st *textState, w *textWriter, operand Object) {
+	s, ok := operand.(String)
+	if !ok || st.font == nil {
+		return
+	}
+	recording := w.record && w.current >= 0
+	x, y := st.tm[4], st.tm[5]
+	var text strings.Builder
+	var shown []glyph
+	for _, code := range st.font.codes(s) {
+		start := text.Len()
+		text.WriteString(st.font.decode(code))
+		tx := st.font.width(code)/1000*st.fontSize + st.charSp
+		if len(code) == 1 && code[0] == ' ' {
+			tx += st.wordSp
+		}
+		if recording {
+			g := glyph{op: w.current, element: w.element, code: code, start: start, end: text.Len(), box: st.glyphBox(tx * st.scale)}
+			if st.fontSize != 0 {
+				g.adjust = -tx * 1000 / st.fontSize
+			}
+			shown = append(shown, g)
+		}
+		st.advance(tx * st.scale)
+	}
+	w.show(text.String(), x, y, st.tm[4], st.effectiveSize())
+
+	// show appended the text last, after any whitespace it inserted
+	base := w.buf.Len() - text.Len()
+	for _, g := range shown {
+		g.start += base
+		g.end += base
+		w.glyphs = append(w.glyphs, g)
+	}
+}
+
+// glyphBox returns the bounds in default user space of a glyph advancing
+// the pen by width, from a typical descender to a typical ascender
+func (st *textState) glyphBox(width float64) [4]float64 {
+	m := multiply(st.tm, st.ctm)
+	box := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, corner := range [4][2]float64{{0, -0.25}, {1, -0.25}, {0, 0.9}, {1, 0.9}} {
+		x, y := transform(m, corner[0]*width, corner[1]*st.fontSize)
+		box[0], box[1] = math.Min(box[0], x), math.Min(box[1], y)
+		box[2], box[3] = math.Max(box[2], x), math.Max(box[3], y)
+	}
+	return box
+}
+
+// multiply returns the matrix product a × b, which applies a then b
+func multiply(a, b [6]float64) [6]float64 {
+	return [6]float64{
+		a[0]*b[0] + a[1]*b[2],
+		a[0]*b[1] + a[1]*b[3],
+		a[2]*b[0] + a[3]*b[2],
+		a[2]*b[1] + a[3]*b[3],
+		a[4]*b[0] + a[5]*b[2] + b[4],
+		a[4]*b[1] + a[5]*b[3] + b[5],
+	}
+}
+
+// transform applies a matrix to a point
+func transform(m [6]float64, x, y float64) (float64, float64) {
+	return x*m[0] + y*m[2] + m[4], x*m[1] + y*m[3] + m[5]
+}
+
+// runForm interprets a form XObject referenced by a Do operator
+func (d *Document) runForm(resources Dict, name Name, w *textWriter, depth int) {
+	xobjects := d.ResolveDict(resources["XObject"])
+	stream, ok := d.Resolve(xobjects[name]).(*Stream)
+	if !ok || stream.Dict["Subtype"] != Name("Form") {
+		return
+	}
+	data, err := d.Decode(stream)
+	if err != nil {
+		return
+	}
+	formResources := d.ResolveDict(stream.Dict["Resources"])
+	if formResources == nil {
+		formResources = resources
+	}
+	d.interpret(data, formResources, w, depth+1)
+}
+
+// skipInlineImage advances past the data of an inline image (BI ... ID data EI)
+func skipInlineImage(p *parser) {
+	for {
+		t, err := p.nextToken()
+		if err != nil || t.kind == tokEOF {
+			return
+		}
+		if t.kind == tokKeyword && t.value == keyword("ID") {
+			break
+		}
+	}
+	data := p.lex.data
+	pos := p.lex.pos + 1
+	for pos+2 <= len(data) {
+		if data[pos] == 'E' && data[pos+1] == 'I' && isWhitespace(data[pos-1]) &&
+			(pos+2 == len(data) || isWhitespace(data[pos+2]) || isDelimiter(data[pos+2])) {
+			p.lex.pos = pos + 2
+			p.peeked = nil
+			return
+		}
+		pos++
+	}
+	p.lex.pos = len(data)
+	p.peeked = nil
+}
+
+func number(obj Object) float64 {
+	switch v := obj.(type) {
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+// font maps character codes in shown strings to Unicode text
+type font struct {
+	codeBytes  int               // Fixed code width in bytes (1 or 2)
+	toUnicode  map[string]string // Code bytes to text, from the ToUnicode CMap
+	encoding   [256]rune         // For simple fonts without a ToUnicode entry
+	widths     map[int]float64   // Glyph widths in thousandths of an em
+	firstChar  int
+	defaultW   float64
+	simpleFont bool
+}
+
+func (d *Document) loadFont(resources Dict, name Name, cache map[Name]*font) *font {
+	if f, ok := cache[name]; ok {
+		return f
+	}
+	dict := d.ResolveDict(d.ResolveDict(resources["Font"])[name])
+	f := d.newFont(dict)
+	cache[name] = f
+	return f
+}
+
+func (d *Document) newFont(dict Dict) *font {
+	f := &font{codeBytes: 1, encoding: winAnsiEncoding, widths: map[int]float64{}, defaultW: 500, simpleFont: true}
+	if dict == nil {
+		return f
+	}
+
+	if dict["Subtype"] == Name("Type0") {
+		f.codeBytes = 2
+		f.simpleFont = false
+		f.defaultW = 1000
+		if descendants, ok := d.Resolve(dict["DescendantFonts"]).(Array); ok && len(descendants) > 0 {
+			cid := d.ResolveDict(descendants[0])
+			if dw, ok := cid["DW"]; ok {
+				f.defaultW = number(d.Resolve(dw))
+			}
+			f.loadCIDWidths(d, d.Resolve(cid["W"]))
+		}
+	} else {
+		f.firstChar = int(number(d.Resolve(dict["FirstChar"])))
+		if widths, ok := d.Resolve(dict["Widths"]).(Array); ok {
+			for i, w := range widths {
+				f.widths[f.firstChar+i] = number(d.Resolve(w))
+			}
+		}
+		f.applyEncoding(d, d.Resolve(dict["Encoding"]))
+	}
+
+	if stream, ok := d.Resolve(dict["ToUnicode"]).(*Stream); ok {
+		if data, err := d.Decode(stream); err == nil {
+			f.parseCMap(data)
+		}
+	}
+	return f
+}
+
+func (f *font) loadCIDWidths(d *Document, obj Object) {
+	arr, ok := obj.(Array)
+	if !ok {
+		return
+	}
+	for i := 0; i < len(arr); {
+		first := int(number(d.Resolve(arr[i])))
+		if i+1 >= len(arr) {
+			return
+		}
+		if list, ok := d.Resolve(arr[i+1]).(Array); ok {
+			for j, w := range list {
+				f.widths[first+j] = number(d.Resolve(w))
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= len(arr) {
+			return
+		}
+		last := int(number(d.Resolve(arr[i+1])))
+		w := number(d.Resolve(arr[i+2]))
+		for c := first; c <= last && c-first < 65536; c++ {
+			f.widths[c] = w
+		}
+		i += 3
+	}
+}
+
+func (f *font) applyEncoding(d *Document, enc Object) {
+	switch v := enc.(type) {
+	case Dict:
+		// Standard, MacRoman and WinAnsi agree on the printable ASCII range,
+		// which is what matters for matching text, so only the differences
+		// from the base encoding are applied
+		diffs, _ := d.Resolve(v["Differences"]).(Array)
+		code := 0
+		for _, item := range diffs {
+			switch x := item.(type) {
+			case int64:
+				code = int(x)
+			case Name:
+				if code >= 0 && code < 256 {
+					if r, ok := glyphRune(string(x)); ok {
+						f.encoding[code] = r
+					}
+				}
+				code++
+			}
+		}
+	}
+}
+
+// codes splits a shown string into character codes
+func (f *font) codes(s String) []string {
+	n := f.codeBytes
+	codes := make([]string, 0, len(s)/n+1)
+	for i := 0; i < len(s); i += n {
+		end := min(i+n, len(s))
+		codes = append(codes, string(s[i:end]))
+	}
+	return codes
+}
+
+func (f *font) decode(code string) string {
+	if f.toUnicode != nil {
+		if text, ok := f.toUnicode[code]; ok {
+			return text
+		}
+	}
+	if f.simpleFont && len(code) == 1 {
+		if r := f.encoding[code[0]]; r != 0 {
+			return string(r)
+		}
+		return ""
+	}
+	return ""
+}
+
+func (f *font) width(code string) float64 {
+	c := 0
+	for i := 0; i < len(code); i++ {
+		c = c<<8 | int(code[i])
+	}
+	if w, ok := f.widths[c]; ok {
+		return w
+	}
+	return f.defaultW
+}
+
+// parseCMap reads the bfchar and bfrange mappings of a ToUnicode CMap
+func (f *font) parseCMap(data []byte) {
+	f.toUnicode = map[string]string{}
+	p := newParser(data, 0)
+	var operands []Object
+	for {
+		t, err := p.nextToken()
+		if err != nil || t.kind == tokEOF {
+			return
+		}
+		if t.kind != tokKeyword {
+			obj, err := p.parseFrom(t)
+			if err != nil {
+				return
+			}
+			operands = append(operands, obj)
+			continue
+		}
+		switch t.value {
+		case keyword("endcodespacerange"):
+			if len(operands) >= 1 {
+				if lo, ok := operands[0].(String); ok && len(lo) > 0 {
+					f.codeBytes = len(lo)
+				}
+			}
+		case keyword("endbfchar"):
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(String)
+				dst, ok2 := operands[i+1].(String)
+				if ok1 && ok2 {
+					f.toUnicode[string(src)] = decodeUTF16(dst)
+				}
+			}
+		case keyword("endbfrange"):
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(String)
+				hi, ok2 := operands[i+1].(String)
+				if !ok1 || !ok2 || len(lo) != len(hi) || len(lo) == 0 {
+					continue
+				}
+				start, end := codeValue(lo), codeValue(hi)
+				for c := start; c <= end && c-start < 65536; c++ {
+					key := codeBytes(c, len(lo))
+					switch dst := operands[i+2].(type) {
+					case String:
+						f.toUnicode[key] = decodeUTF16(incrementLast(dst, c-start))
+					case Array:
+						if c-start < len(dst) {
+							if s, ok := dst[c-start].(String); ok {
+								f.toUnicode[key] = decodeUTF16(s)
+							}
+						}
+					}
+				}
+			}
+		}
+		if t.value == keyword("begincodespacerange") || t.value == keyword("beginbfchar") || t.value == keyword("beginbfrange") {
+			operands = operands[:0]
+			continue
+		}
+		if strings.HasPrefix(string(t.value.(keyword)), "end") {
+			operands = operands[:0]
+		}
+	}
+}
+
+func codeValue(s String) int {
+	v := 0
+	for _, b := range s {
+		v = v<<8 | int(b)
+	}
+	return v
+}
+
+func codeBytes(v, n int) string {
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return string(b)
+}
+
+// incrementLast adds n to the last byte pair of a UTF-16BE destination
+func incrementLast(dst String, n int) String {
+	out := append(String(nil), dst...)
+	if len(out) < 2 {
+		return out
+	}
+	v := int(out[len(out)-2])<<8 | int(out[len(out)-1])
+	v += n
+	out[len(out)-2] = byte(v >> 8)
+	out[len(out)-1] = byte(v)
+	return out
+}
+
+func decodeUTF16(s String) string {
+	if len(s)%2 == 1 {
+		return string(s)
+	}
+	units := make([]uint16, len(s)/2)
+	for i := range units {
+		units[i] = uint16(s[2*i])<<8 | uint16(s[2*i+1])
+	}
+	return string(utf16.Decode(units))
+}
+
+// winAnsiEncoding maps WinAnsiEncoding codes to Unicode
+var winAnsiEncoding = func() [256]rune {
+	var enc [256]rune
+	for c := 32; c < 256; c++ {
+		enc[c] = rune(c)
+	}
+	enc[127] = 0
+	high := map[int]rune{
+		0x80: '€', 0x82: '‚', 0x83: 'ƒ', 0x84: '„', 0x85: '…', 0x86: '†', 0x87: '‡',
+		0x88: 'ˆ', 0x89: '‰', 0x8A: 'Š', 0x8B: '‹', 0x8C: 'Œ', 0x8E: 'Ž',
+		0x91: '‘', 0x92: '’', 0x93: '“', 0x94: '”', 0x95: '•', 0x96: '–', 0x97: '—',
+		0x98: '˜', 0x99: '™', 0x9A: 'š', 0x9B: '›', 0x9C: 'œ', 0x9E: 'ž', 0x9F: 'Ÿ',
+	}
+	for c := 0x80; c < 0xA0; c++ {
+		enc[c] = high[c]
+	}
+	enc['\t'], enc['\n'], enc['\r'] = ' ', ' ', ' '
+	return enc
+}()
+
+// glyphNames covers the non-alphanumeric glyph names used in Differences arrays
+var glyphNames = map[string]rune{
+	"space": ' ', "exclam": '!', "quotedbl": '"', "numbersign": '#', "dollar": '$',
+	"percent": '%', "ampersand": '&', "quotesingle": '\'', "parenleft": '(',
+	"parenright": ')', "asterisk": '*', "plus": '+', "comma": ',', "hyphen": '-',
+	"period": '.', "slash": '/', "zero": '0', "one": '1', "two": '2', "three": '3',
+	"four": '4', "five": '5', "six": '6', "seven": '7', "eight": '8', "nine": '9',
+	"colon": ':', "semicolon": ';', "less": '<', "equal": '=', "greater": '>',
+	"question": '?', "at": '@', "bracketleft": '[', "backslash": '\\',
+	"bracketright": ']', "asciicircum": '^', "underscore": '_', "grave": '`',
+	"braceleft": '{', "bar": '|', "braceright": '}', "asciitilde": '~',
+	"quoteleft": '‘', "quoteright": '’', "quotedblleft": '“', "quotedblright": '”',
+	"endash": '–', "emdash": '—', "bullet": '•', "ellipsis": '…', "section": '§',
+	"paragraph": '¶', "copyright": '©', "registered": '®', "trademark": '™',
+	"degree": '°', "sterling": '£', "yen": '¥', "Euro": '€', "euro": '€',
+	"fi": 'ﬁ', "fl": 'ﬂ', "nbspace": ' ', "minus": '−', "multiply": '×',
+}
+
+func glyphRune(name string) (rune, bool) {
+	if r, ok := glyphNames[name]; ok {
+		return r, true
+	}
+	if len(name) == 1 {
+		return rune(name[0]), true
+	}
+	for _, prefix := range []string{"uni", "u"} {
+		if strings.HasPrefix(name, prefix) && len(name) >= len(prefix)+4 {
+			if v, err := strconv.ParseUint(name[len(prefix):len(prefix)+4], 16, 32); err == nil {
+				return rune(v), true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,160 @@
+package pdf
+
+import (
+	"testing"
+)
+
+// buildPage returns a one-page PDF with the given content stream and fonts
+func buildPage(content string, fonts func(b *Builder) Dict) []byte {
+	b := NewBuilder()
+	catalog := b.Reserve()
+	pages := b.Reserve()
+	contents := b.Add(&Stream{Dict: Dict{}, Data: []byte(content)})
+	page := b.Add(Dict{
+		"Type":      Name("Page"),
+		"Parent":    pages,
+		"Contents":  contents,
+		"Resources": Dict{"Font": fonts(b)},
+	})
+	b.Set(pages, Dict{"Type": Name("Pages"), "Kids": Array{page}, "Count": int64(1)})
+	b.Set(catalog, Dict{"Type": Name("Catalog"), "Pages": pages})
+	return b.Bytes(catalog)
+}
+
+func helvetica(b *Builder) Dict {
+	return Dict{"F1": b.Add(Dict{"Type": Name("Font"), "Subtype": Name("Type1"), "BaseFont": Name("Helvetica")})}
+}
+
+func extractSingle(t *testing.T, data []byte) string {
+	t.Helper()
+	texts, err := ExtractPageTexts(data)
+	if err != nil {
+		t.Fatalf("Failed to extract text: %v", err)
+	}
+	if len(texts) != 1 {
+		t.Fatalf("Expected 1 page, got %d", len(texts))
+	}
+	return texts[0]
+}
+
+func TestExtractPageTexts_RoundTrip(t *testing.T) {
+	pages := []string{"Invoice #1234\nTotal due: $1,250.00", "Page two – “quoted” (text)"}
+	texts, err := ExtractPageTexts(GenerateTextPDF(pages))
+	if err != nil {
+		t.Fatalf("Failed to extract text: %v", err)
+	}
+	if len(texts) != 2 {
+		t.Fatalf("Expected 2 pages, got %d", len(texts))
+	}
+	for i := range pages {
+		if texts[i] != pages[i] {
+			t.Errorf("Page %d: expected %q, got %q", i+1, pages[i], texts[i])
+		}
+	}
+}
+
+func TestPageText_TJKerningInsertsSpaces(t *testing.T) {
+	text := extractSingle(t, buildPage("BT /F1 10 Tf 72 700 Td [(Hello) -50 (W) 20 (orld) -800 (again)] TJ ET", helvetica))
+	if text != "HelloWorld again" {
+		t.Errorf("Expected 'HelloWorld again', got %q", text)
+	}
+}
+
+func TestPageText_PositioningInsertsLineBreaks(t *testing.T) {
+	content := `BT /F1 10 Tf 72 700 Td (Line one) Tj 0 -14 Td (Line two) Tj ET
+BT /F1 10 Tf 1 0 0 1 300 686 Tm (right column) Tj ET`
+	text := extractSingle(t, buildPage(content, helvetica))
+	if text != "Line one\nLine two right column" {
+		t.Errorf("Unexpected text %q", text)
+	}
+}
+
+func TestPageText_ToUnicodeCMap(t *testing.T) {
+	cmap := `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+1 begincodespacerange <0000> <FFFF> endcodespacerange
+2 beginbfchar
+<0001> <0048>
+<0002> <0069>
+endbfchar
+1 beginbfrange
+<0010> <0012> <0061>
+endbfrange
+endcmap`
+	data := buildPage("BT /F0 12 Tf 72 700 Td <000100020003> Tj <001000110012> Tj ET", func(b *Builder) Dict {
+		toUnicode := b.Add(&Stream{Dict: Dict{}, Data: []byte(cmap)})
+		descendant := b.Add(Dict{"Type": Name("Font"), "Subtype": Name("CIDFontType2"), "DW": int64(500)})
+		return Dict{"F0": b.Add(Dict{
+			"Type":            Name("Font"),
+			"Subtype":         Name("Type0"),
+			"Encoding":        Name("Identity-H"),
+			"DescendantFonts": Array{descendant},
+			"ToUnicode":       toUnicode,
+		})}
+	})
+
+	if text := extractSingle(t, data); text != "Hiabc" {
+		t.Errorf("Expected 'Hiabc', got %q", text)
+	}
+}
+
+func TestPageText_Differences(t *testing.T) {
+	data := buildPage("BT /F1 12 Tf 72 700 Td (\\001\\002\\003) Tj ET", func(b *Builder) Dict {
+		return Dict{"F1": b.Add(Dict{
+			"Type":     Name("Font"),
+			"Subtype":  Name("Type1"),
+			"BaseFont": Name("Custom"),
+			"Encoding": Dict{"Differences": Array{int64(1), Name("F"), Name("uni00E9"), Name("fi")}},
+		})}
+	})
+
+	if text := extractSingle(t, data); text != "Féﬁ" {
+		t.Errorf("Expected 'Féﬁ', got %q", text)
+	}
+}
+
+func TestPageText_SkipsInlineImages(t *testing.T) {
+	content := "BT /F1 10 Tf 72 700 Td (Before) Tj ET\nBI /W 2 /H 1 /BPC 8 /CS /G ID \x00(Tj) EI\nBT /F1 10 Tf 72 700 Td ( after) Tj ET"
+	if text := extractSingle(t, buildPage(content, helvetica)); text != "Before after" {
+		t.Errorf("Expected 'Before after', got %q", text)
+	}
+}
+
+func TestPageText_FormXObject(t *testing.T) {
+	b := NewBuilder()
+	catalog := b.Reserve()
+	pages := b.Reserve()
+	fonts := helvetica(b)
+	form := b.Add(&Stream{
+		Dict: Dict{"Type": Name("XObject"), "Subtype": Name("Form"), "Resources": Dict{"Font": fonts}},
+		Data: []byte("BT /F1 10 Tf 72 700 Td (Inside form) Tj ET"),
+	})
+	contents := b.Add(&Stream{Dict: Dict{}, Data: []byte("q /X1 Do Q")})
+	page := b.Add(Dict{
+		"Type":      Name("Page"),
+		"Parent":    pages,
+		"Contents":  contents,
+		"Resources": Dict{"XObject": Dict{"X1": form}},
+	})
+	b.Set(pages, Dict{"Type": Name("Pages"), "Kids": Array{page}, "Count": int64(1)})
+	b.Set(catalog, Dict{"Type": Name("Catalog"), "Pages": pages})
+
+	if text := extractSingle(t, b.Bytes(catalog)); text != "Inside form" {
+		t.Errorf("Expected 'Inside form', got %q", text)
+	}
+}
+
+// FuzzPageText checks that no content stream makes PageText panic or loop
+func FuzzPageText(f *testing.F) {
+	f.Add("BT /F1 12 Tf 72 720 Td (Hello) Tj ET")
+	f.Add("BT /F1 12 Tf [(Hel) -120 (lo)] TJ T* (next) ' 1 2 (x) \" ET")
+	f.Add("q BI /W 1 /H 1 ID x EI Q /X1 Do")
+	f.Fuzz(func(t *testing.T, content string) {
+		doc, err := Open(buildPage(content, helvetica))
+		if err != nil {
+			t.Fatalf("Failed to open PDF: %v", err)
+		}
+		doc.PageText(doc.Pages()[0])
+	})
+}
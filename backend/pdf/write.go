@@ -0,0 +1,223 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Builder assembles a new PDF file from objects
+type Builder struct {
+	objects []Object // Index i holds object number i+1
+}
+
+// NewBuilder returns an empty builder
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// Add appends an object and returns a reference to it
+func (b *Builder) Add(obj Object) Ref {
+	b.objects = append(b.objects, obj)
+	return Ref{Num: len(b.objects)}
+}
+
+// Reserve allocates an object number to be filled in later with Set, for
+// objects that must refer to each other
+func (b *Builder) Reserve() Ref {
+	return b.Add(nil)
+}
+
+// Set replaces the object behind a reference returned by Add or Reserve
+func (b *Builder) Set(ref Ref, obj Object) {
+	b.objects[ref.Num-1] = obj
+}
+
+// Bytes serializes the objects with a cross-reference table and a trailer
+// pointing at root
+func (b *Builder) Bytes(root Ref) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+
+	offsets := make([]int, len(b.objects))
+	for i, obj := range b.objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n", i+1)
+		writeObject(&buf, obj)
+		buf.WriteString("\nendobj\n")
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(b.objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	buf.WriteString("trailer\n")
+	writeObject(&buf, Dict{"Size": int64(len(b.objects) + 1), "Root": root})
+	fmt.Fprintf(&buf, "\nstartxref\n%d\n%%%%EOF\n", xref)
+	return buf.Bytes()
+}
+
+// writeObject serializes a direct object. Dictionary keys are sorted so the
+// output is deterministic.
+func writeObject(buf *bytes.Buffer, obj Object) {
+	switch v := obj.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case int:
+		buf.WriteString(strconv.Itoa(v))
+	case int64:
+		buf.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		buf.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+	case Name:
+		writeName(buf, v)
+	case String:
+		writeString(buf, v)
+	case keyword:
+		buf.WriteString(string(v))
+	case Ref:
+		fmt.Fprintf(buf, "%d %d R", v.Num, v.Gen)
+	case Array:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			writeObject(buf, item)
+		}
+		buf.WriteByte(']')
+	case Dict:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+		buf.WriteString("<<")
+		for _, k := range keys {
+			writeName(buf, Name(k))
+			buf.WriteByte(' ')
+			writeObject(buf, v[Name(k)])
+		}
+		buf.WriteString(">>")
+	case *Stream:
+		dict := Dict{}
+		for k, val := range v.Dict {
+			dict[k] = val
+		}
+		dict["Length"] = int64(len(v.Data))
+		writeObject(buf, dict)
+		buf.WriteString("\nstream\n")
+		buf.Write(v.Data)
+		buf.WriteString("\nendstream")
+	default:
+		buf.WriteString("null")
+	}
+}
+
+func writeName(buf *bytes.Buffer, name Name) {
+	buf.WriteByte('/')
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c < '!' || c > '~' || c == '#' || isDelimiter(c) {
+			fmt.Fprintf(buf, "#%02X", c)
+		} else {
+			buf.WriteByte(c)
+		}
+	}
+}
+
+func writeString(buf *bytes.Buffer, s String) {
+	buf.WriteByte('(')
+	for _, c := range []byte(s) {
+		switch c {
+		case '(', ')', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\n':
+			buf.WriteString("\\n")
+		case '\r':
+			buf.WriteString("\\r")
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	buf.WriteByte(')')
+}
+
+// flateStream returns a stream holding data compressed with FlateDecode
+func flateStream(data []byte) *Stream {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return &Stream{Dict: Dict{"Filter": Name("FlateDecode")}, Data: buf.Bytes()}
+}
+
+// EncodeWinAnsi converts text to WinAnsiEncoding bytes. Characters that
+// cannot be encoded become '?'.
+func EncodeWinAnsi(text string) []byte {
+	out := make([]byte, 0, len(text))
+	for _, r := range text {
+		out = append(out, winAnsiByte(r))
+	}
+	return out
+}
+
+func winAnsiByte(r rune) byte {
+	if r >= 32 && r < 127 || r >= 0xA0 && r <= 0xFF {
+		return byte(r)
+	}
+	for c := 0x80; c < 0xA0; c++ {
+		if winAnsiEncoding[c] == r && r != 0 {
+			return byte(c)
+		}
+	}
+	return '?'
+}
+
+// GenerateTextPDF builds a letter-size PDF with one page per entry, setting
+// each line of text in 11pt Helvetica
+func GenerateTextPDF(pages []string) []byte {
+	b := NewBuilder()
+	catalog := b.Reserve()
+	pageTree := b.Reserve()
+	font := b.Add(Dict{
+		"Type":     Name("Font"),
+		"Subtype":  Name("Type1"),
+		"BaseFont": Name("Helvetica"),
+		"Encoding": Name("WinAnsiEncoding"),
+	})
+
+	kids := Array{}
+	for _, text := range pages {
+		var content bytes.Buffer
+		content.WriteString("BT\n/F1 11 Tf\n13 TL\n72 720 Td\n")
+		for i, line := range strings.Split(text, "\n") {
+			if i > 0 {
+				content.WriteString("T*\n")
+			}
+			writeString(&content, String(EncodeWinAnsi(line)))
+			content.WriteString(" Tj\n")
+		}
+		content.WriteString("ET")
+
+		contents := b.Add(&Stream{Dict: Dict{}, Data: content.Bytes()})
+		kids = append(kids, b.Add(Dict{
+			"Type":      Name("Page"),
+			"Parent":    pageTree,
+			"MediaBox":  Array{int64(0), int64(0), int64(612), int64(792)},
+			"Resources": Dict{"Font": Dict{"F1": font}},
+			"Contents":  contents,
+		}))
+	}
+
+	b.Set(pageTree, Dict{"Type": Name("Pages"), "Kids": kids, "Count": int64(len(kids))})
+	b.Set(catalog, Dict{"Type": Name("Catalog"), "Pages": pageTree})
+	return b.Bytes(catalog)
+}
@@ -2,6 +2,9 @@ package store
 
 import (
 	"fmt"
+	"maps"
+	"slices"
+	"sort"
 	"sync"
 
 	"github.com/pdf-viewer/backend/models"
@@ -12,6 +15,10 @@ import (
 type MemoryStore struct {
 	documents map[string]*models.Document
 	prompts   map[string]*models.PromptRecord
+	batches   map[string]*models.Batch
+	types     map[string]*models.DocumentType
+	templates map[string]*models.PromptTemplate // Keyed by ID and version
+	threads   map[string]*models.ChatThread
 	mu        sync.RWMutex
 }
 
@@ -23,6 +30,10 @@ func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
 		documents: make(map[string]*models.Document),
 		prompts:   make(map[string]*models.PromptRecord),
+		batches:   make(map[string]*models.Batch),
+		types:     make(map[string]*models.DocumentType),
+		templates: make(map[string]*models.PromptTemplate),
+		threads:   make(map[string]*models.ChatThread),
 	}
 }
 
@@ -53,6 +64,54 @@ func (s *MemoryStore) DeleteDocument(id string) error {
 	return nil
 }
 
+func (s *MemoryStore) SaveSummary(documentID string, summary *models.Summary) error {
+	return s.updateDocument(documentID, func(doc *models.Document) {
+		doc.Summaries = maps.Clone(doc.Summaries)
+		if doc.Summaries == nil {
+			doc.Summaries = map[string]*models.Summary{}
+		}
+		doc.Summaries[summary.Length] = summary
+	})
+}
+
+func (s *MemoryStore) SaveTranslation(documentID string, translation *models.Translation) error {
+	return s.updateDocument(documentID, func(doc *models.Document) {
+		doc.Translations = maps.Clone(doc.Translations)
+		if doc.Translations == nil {
+			doc.Translations = map[string]*models.Translation{}
+		}
+		doc.Translations[translation.TargetLanguage] = translation
+	})
+}
+
+func (s *MemoryStore) SaveRedaction(documentID string, redactedPDFData []byte, report *models.RedactionReport) error {
+	return s.updateDocument(documentID, func(doc *models.Document) {
+		doc.RedactedPDFData = redactedPDFData
+		doc.Redaction = report
+	})
+}
+
+func (s *MemoryStore) SaveBundle(documentID string, bundle *models.BundleReport) error {
+	return s.updateDocument(documentID, func(doc *models.Document) {
+		doc.Bundle = bundle
+	})
+}
+
+// updateDocument replaces a stored document with an updated copy, so
+// documents already returned to callers are left as they were
+func (s *MemoryStore) updateDocument(id string, update func(doc *models.Document)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc, ok := s.documents[id]
+	if !ok {
+		return fmt.Errorf("document not found: %s", id)
+	}
+	updated := *doc
+	update(&updated)
+	s.documents[id] = &updated
+	return nil
+}
+
 func (s *MemoryStore) ListDocuments(limit, offset int) ([]*models.Document, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -102,3 +161,180 @@ func (s *MemoryStore) GetPromptsByDocument(documentID string) ([]*models.PromptR
 	}
 	return prompts, nil
 }
+
+func (s *MemoryStore) SaveBatch(batch *models.Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches[batch.ID] = batch
+	return nil
+}
+
+func (s *MemoryStore) GetBatch(id string) (*models.Batch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	batch, ok := s.batches[id]
+	if !ok {
+		return nil, fmt.Errorf("batch not found: %s", id)
+	}
+	return batch, nil
+}
+
+func (s *MemoryStore) SaveDocumentType(documentType *models.DocumentType) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.types[documentType.Name] = documentType
+	return nil
+}
+
+func (s *MemoryStore) GetDocumentType(name string) (*models.DocumentType, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	documentType, ok := s.types[name]
+	if !ok {
+		return nil, fmt.Errorf("document type not found: %s", name)
+	}
+	return documentType, nil
+}
+
+// ListDocumentTypes returns the document types sorted by name
+func (s *MemoryStore) ListDocumentTypes() ([]*models.DocumentType, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	types := make([]*models.DocumentType, 0, len(s.types))
+	for _, documentType := range s.types {
+		types = append(types, documentType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	return types, nil
+}
+
+func (s *MemoryStore) DeleteDocumentType(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.types[name]; !ok {
+		return fmt.Errorf("document type not found: %s", name)
+	}
+	delete(s.types, name)
+	return nil
+}
+
+func templateKey(id string, version int) string {
+	return fmt.Sprintf("%s@%d", id, version)
+}
+
+func (s *MemoryStore) SavePromptTemplate(template *models.PromptTemplate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	template.Version = 1
+	for _, saved := range s.templates {
+		if saved.ID == template.ID && saved.Version >= template.Version {
+			template.Version = saved.Version + 1
+		}
+	}
+	s.templates[templateKey(template.ID, template.Version)] = template
+	return nil
+}
+
+func (s *MemoryStore) SetPromptTemplateWeight(id string, version, weight int) (*models.PromptTemplate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	template, ok := s.templates[templateKey(id, version)]
+	if !ok {
+		return nil, fmt.Errorf("prompt template not found: %s version %d", id, version)
+	}
+	updated := *template
+	updated.Weight = weight
+	s.templates[templateKey(id, version)] = &updated
+	return &updated, nil
+}
+
+func (s *MemoryStore) GetPromptTemplate(id string, version int) (*models.PromptTemplate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	template, ok := s.templates[templateKey(id, version)]
+	if !ok {
+		return nil, fmt.Errorf("prompt template not found: %s version %d", id, version)
+	}
+	return template, nil
+}
+
+func (s *MemoryStore) ListPromptTemplates(agentType string) ([]*models.PromptTemplate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var templates []*models.PromptTemplate
+	for _, template := range s.templates {
+		if agentType == "" || template.AgentType == agentType {
+			templates = append(templates, template)
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		if templates[i].ID != templates[j].ID {
+			return templates[i].ID < templates[j].ID
+		}
+		return templates[i].Version < templates[j].Version
+	})
+	return templates, nil
+}
+
+func (s *MemoryStore) SaveChatThread(thread *models.ChatThread) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.threads[thread.ID] = thread
+	return nil
+}
+
+func (s *MemoryStore) AppendChatTurns(id string, turns []models.ChatTurn) (*models.ChatThread, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	thread, ok := s.threads[id]
+	if !ok {
+		return nil, fmt.Errorf("chat thread not found: %s", id)
+	}
+
+	// Threads already returned to callers are left as they were
+	updated := *thread
+	updated.Turns = append(slices.Clone(thread.Turns), turns...)
+	if len(turns) > 0 {
+		updated.UpdatedAt = turns[len(turns)-1].CreatedAt
+	}
+	s.threads[id] = &updated
+	return &updated, nil
+}
+
+func (s *MemoryStore) GetChatThread(id string) (*models.ChatThread, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	thread, ok := s.threads[id]
+	if !ok {
+		return nil, fmt.Errorf("chat thread not found: %s", id)
+	}
+	return thread, nil
+}
+
+func (s *MemoryStore) ListChatThreads(documentID string) ([]*models.ChatThread, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var threads []*models.ChatThread
+	for _, thread := range s.threads {
+		if thread.DocumentID == documentID {
+			threads = append(threads, thread)
+		}
+	}
+	sort.Slice(threads, func(i, j int) bool {
+		return threads[i].UpdatedAt.After(threads[j].UpdatedAt)
+	})
+	return threads, nil
+}
+
+func (s *MemoryStore) DeleteChatThread(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.threads[id]; !ok {
+		return fmt.Errorf("chat thread not found: %s", id)
+	}
+	delete(s.threads, id)
+	return nil
+}
@@ -215,6 +215,52 @@ func TestMemoryStore_UpdateDocument(t *testing.T) {
 	}
 }
 
+func TestMemoryStore_SaveSummary(t *testing.T) {
+	s := NewMemoryStore()
+	doc := &models.Document{ID: "summary-doc", Filename: "lease.pdf"}
+	s.SaveDocument(doc)
+
+	if err := s.SaveSummary(doc.ID, &models.Summary{Length: "one_line", Text: "A lease."}); err != nil {
+		t.Fatalf("Failed to save summary: %v", err)
+	}
+	retrieved, _ := s.GetDocument(doc.ID)
+	if retrieved.Summaries["one_line"] == nil || doc.Summaries != nil {
+		t.Errorf("Expected the summary on a copy of the document, got %+v and %+v", retrieved.Summaries, doc.Summaries)
+	}
+	if err := s.SaveSummary("missing", &models.Summary{Length: "one_line"}); err == nil {
+		t.Error("Expected an error for a missing document")
+	}
+}
+
+func TestMemoryStore_SaveTranslation(t *testing.T) {
+	s := NewMemoryStore()
+	doc := &models.Document{ID: "translation-doc", Filename: "rechnung.pdf"}
+	s.SaveDocument(doc)
+	s.SaveSummary(doc.ID, &models.Summary{Length: "one_line", Text: "An invoice."})
+
+	if err := s.SaveTranslation(doc.ID, &models.Translation{TargetLanguage: "English"}); err != nil {
+		t.Fatalf("Failed to save translation: %v", err)
+	}
+	retrieved, _ := s.GetDocument(doc.ID)
+	if retrieved.Translations["English"] == nil || retrieved.Summaries["one_line"] == nil {
+		t.Errorf("Expected the translation next to the summary, got %+v", retrieved)
+	}
+}
+
+func TestMemoryStore_SaveRedaction(t *testing.T) {
+	s := NewMemoryStore()
+	doc := &models.Document{ID: "redaction-doc", Filename: "resume.pdf", PDFData: []byte("%PDF-1.4 original")}
+	s.SaveDocument(doc)
+
+	if err := s.SaveRedaction(doc.ID, []byte("%PDF-1.7 redacted"), &models.RedactionReport{}); err != nil {
+		t.Fatalf("Failed to save redaction: %v", err)
+	}
+	retrieved, _ := s.GetDocument(doc.ID)
+	if string(retrieved.PDFData) != "%PDF-1.4 original" || retrieved.RedactedPDFData == nil || retrieved.Redaction == nil {
+		t.Errorf("Expected the redaction next to the original PDF, got %+v", retrieved)
+	}
+}
+
 func TestStore_Initialize(t *testing.T) {
 	memStore := NewMemoryStore()
 	Initialize(memStore)
@@ -231,3 +277,68 @@ func TestMemoryStore_ImplementsInterface(t *testing.T) {
 	var _ Store = (*MemoryStore)(nil)
 	var _ Store = NewMemoryStore()
 }
+
+func TestMemoryStore_DocumentTypes(t *testing.T) {
+	store := NewMemoryStore()
+
+	store.SaveDocumentType(&models.DocumentType{Name: "purchase_order", Parent: "form"})
+	store.SaveDocumentType(&models.DocumentType{Name: "bill_of_lading"})
+
+	types, err := store.ListDocumentTypes()
+	if err != nil {
+		t.Fatalf("Failed to list document types: %v", err)
+	}
+	if len(types) != 2 || types[0].Name != "bill_of_lading" {
+		t.Errorf("Expected 2 types sorted by name, got %+v", types)
+	}
+
+	if err := store.DeleteDocumentType("purchase_order"); err != nil {
+		t.Fatalf("Failed to delete document type: %v", err)
+	}
+	if _, err := store.GetDocumentType("purchase_order"); err == nil {
+		t.Error("Expected error for deleted document type")
+	}
+	if err := store.DeleteDocumentType("purchase_order"); err == nil {
+		t.Error("Expected error deleting a missing document type")
+	}
+}
+
+func TestMemoryStore_PromptTemplates(t *testing.T) {
+	store := NewMemoryStore()
+
+	first := &models.PromptTemplate{ID: "concise", Version: 7, AgentType: "extraction", Template: "Extract", Weight: 1}
+	second := &models.PromptTemplate{ID: "concise", AgentType: "extraction", Template: "Extract {{.DocumentType}}", Weight: 1}
+	brief := &models.PromptTemplate{ID: "brief", AgentType: "classification", Template: "Classify", Weight: 1}
+	for _, template := range []*models.PromptTemplate{first, second, brief} {
+		store.SavePromptTemplate(template)
+	}
+	if first.Version != 1 || second.Version != 2 || brief.Version != 1 {
+		t.Errorf("Expected versions allocated per ID, got %d, %d and %d", first.Version, second.Version, brief.Version)
+	}
+
+	if _, err := store.SetPromptTemplateWeight("concise", 1, 0); err != nil {
+		t.Fatalf("Failed to set prompt template weight: %v", err)
+	}
+	got, err := store.GetPromptTemplate("concise", 1)
+	if err != nil {
+		t.Fatalf("Failed to get prompt template: %v", err)
+	}
+	if got.Weight != 0 || got.Template != "Extract" {
+		t.Errorf("Expected the updated weight 0, got %+v", got)
+	}
+	if _, err := store.GetPromptTemplate("concise", 3); err == nil {
+		t.Error("Expected error for missing template version")
+	}
+	if _, err := store.SetPromptTemplateWeight("concise", 3, 1); err == nil {
+		t.Error("Expected error setting the weight of a missing template version")
+	}
+
+	extraction, _ := store.ListPromptTemplates("extraction")
+	if len(extraction) != 2 || extraction[0].Version != 1 || extraction[1].Version != 2 {
+		t.Errorf("Expected both extraction versions in order, got %+v", extraction)
+	}
+	all, _ := store.ListPromptTemplates("")
+	if len(all) != 3 || all[0].ID != "brief" {
+		t.Errorf("Expected every template sorted by ID, got %+v", all)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -52,6 +53,13 @@ func (s *SQLiteStore) migrate() error {
 		pdf_data BLOB,
 		classification_json TEXT,
 		extraction_json TEXT,
+		summaries_json TEXT,
+		translations_json TEXT,
+		redacted_pdf_data BLOB,
+		redaction_json TEXT,
+		tables_json TEXT,
+		parent_id TEXT,
+		bundle_json TEXT,
 		created_at DATETIME NOT NULL
 	);
 
@@ -66,16 +74,133 @@ func (s *SQLiteStore) migrate() error {
 		input_tokens INTEGER DEFAULT 0,
 		output_tokens INTEGER DEFAULT 0,
 		total_cost REAL DEFAULT 0,
+		attempts INTEGER DEFAULT 0,
+		cache_creation_input_tokens INTEGER DEFAULT 0,
+		cache_read_input_tokens INTEGER DEFAULT 0,
+		cache_savings REAL DEFAULT 0,
+		page_start INTEGER DEFAULT 0,
+		page_end INTEGER DEFAULT 0,
+		batch_id TEXT,
+		template_id TEXT,
+		template_version INTEGER DEFAULT 0,
+		thinking TEXT,
+		thinking_tokens INTEGER DEFAULT 0,
+		thinking_cost REAL DEFAULT 0,
+		raw_response TEXT,
+		stop_reason TEXT,
+		continuations INTEGER DEFAULT 0,
+		json_repairs TEXT,
+		unpriced INTEGER DEFAULT 0,
+		error TEXT,
 		created_at DATETIME NOT NULL,
 		FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE
 	);
 
+	CREATE TABLE IF NOT EXISTS batches (
+		id TEXT PRIMARY KEY,
+		batch_json TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS prompt_templates (
+		id TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		agent_type TEXT NOT NULL,
+		template_json TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (id, version)
+	);
+
+	CREATE TABLE IF NOT EXISTS chat_threads (
+		id TEXT PRIMARY KEY,
+		document_id TEXT NOT NULL,
+		thread_json TEXT NOT NULL,
+		updated_at DATETIME NOT NULL,
+		FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS document_types (
+		name TEXT PRIMARY KEY,
+		type_json TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+
 	CREATE INDEX IF NOT EXISTS idx_prompts_document_id ON prompts(document_id);
 	CREATE INDEX IF NOT EXISTS idx_documents_created_at ON documents(created_at);
+	CREATE INDEX IF NOT EXISTS idx_chat_threads_document_id ON chat_threads(document_id);
 	`
 
-	_, err := s.db.Exec(schema)
-	return err
+	if _, err := s.db.Exec(schema); err != nil {
+		return err
+	}
+
+	// Columns added after the initial schema, applied to existing databases
+	columns := []struct {
+		table, name, definition string
+	}{
+		{"documents", "summaries_json", "TEXT"},
+		{"documents", "translations_json", "TEXT"},
+		{"documents", "redacted_pdf_data", "BLOB"},
+		{"documents", "redaction_json", "TEXT"},
+		{"documents", "tables_json", "TEXT"},
+		{"documents", "parent_id", "TEXT"},
+		{"documents", "bundle_json", "TEXT"},
+		{"prompts", "attempts", "INTEGER DEFAULT 0"},
+		{"prompts", "cache_creation_input_tokens", "INTEGER DEFAULT 0"},
+		{"prompts", "cache_read_input_tokens", "INTEGER DEFAULT 0"},
+		{"prompts", "cache_savings", "REAL DEFAULT 0"},
+		{"prompts", "page_start", "INTEGER DEFAULT 0"},
+		{"prompts", "page_end", "INTEGER DEFAULT 0"},
+		{"prompts", "batch_id", "TEXT"},
+		{"prompts", "template_id", "TEXT"},
+		{"prompts", "template_version", "INTEGER DEFAULT 0"},
+		{"prompts", "thinking", "TEXT"},
+		{"prompts", "thinking_tokens", "INTEGER DEFAULT 0"},
+		{"prompts", "thinking_cost", "REAL DEFAULT 0"},
+		{"prompts", "raw_response", "TEXT"},
+		{"prompts", "stop_reason", "TEXT"},
+		{"prompts", "continuations", "INTEGER DEFAULT 0"},
+		{"prompts", "json_repairs", "TEXT"},
+		{"prompts", "unpriced", "INTEGER DEFAULT 0"},
+		{"prompts", "error", "TEXT"},
+	}
+	for _, col := range columns {
+		if err := s.addColumnIfMissing(col.table, col.name, col.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumnIfMissing adds a column to an existing table unless it is already present
+func (s *SQLiteStore) addColumnIfMissing(table, column, definition string) error {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return fmt.Errorf("failed to inspect table %s: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
 }
 
 // Close closes the database connection
@@ -83,38 +208,63 @@ func (s *SQLiteStore) Close() error {
 	return s.db.Close()
 }
 
-func (s *SQLiteStore) SaveDocument(doc *models.Document) error {
-	var classificationJSON, extractionJSON sql.NullString
+// documentColumns lists the documents table columns in the order used by
+// SaveDocument and scanDocument
+const documentColumns = `id, filename, content_type, size, pdf_data,
+	classification_json, extraction_json, summaries_json, translations_json,
+	redacted_pdf_data, redaction_json, tables_json, parent_id, bundle_json,
+	created_at`
 
-	if doc.Classification != nil {
-		data, err := json.Marshal(doc.Classification)
-		if err != nil {
-			return fmt.Errorf("failed to marshal classification: %w", err)
-		}
-		classificationJSON = sql.NullString{String: string(data), Valid: true}
+func (s *SQLiteStore) SaveDocument(doc *models.Document) error {
+	classificationJSON, err := nullJSON(doc.Classification, doc.Classification != nil)
+	if err != nil {
+		return fmt.Errorf("failed to marshal classification: %w", err)
 	}
-
-	if doc.Extraction != nil {
-		data, err := json.Marshal(doc.Extraction)
-		if err != nil {
-			return fmt.Errorf("failed to marshal extraction: %w", err)
-		}
-		extractionJSON = sql.NullString{String: string(data), Valid: true}
+	extractionJSON, err := nullJSON(doc.Extraction, doc.Extraction != nil)
+	if err != nil {
+		return fmt.Errorf("failed to marshal extraction: %w", err)
+	}
+	summariesJSON, err := nullJSON(doc.Summaries, len(doc.Summaries) > 0)
+	if err != nil {
+		return fmt.Errorf("failed to marshal summaries: %w", err)
+	}
+	translationsJSON, err := nullJSON(doc.Translations, len(doc.Translations) > 0)
+	if err != nil {
+		return fmt.Errorf("failed to marshal translations: %w", err)
+	}
+	redactionJSON, err := nullJSON(doc.Redaction, doc.Redaction != nil)
+	if err != nil {
+		return fmt.Errorf("failed to marshal redaction: %w", err)
+	}
+	tablesJSON, err := nullJSON(doc.TableExtraction, doc.TableExtraction != nil)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tables: %w", err)
+	}
+	bundleJSON, err := nullJSON(doc.Bundle, doc.Bundle != nil)
+	if err != nil {
+		return fmt.Errorf("failed to marshal bundle: %w", err)
 	}
 
 	query := `
-		INSERT INTO documents (id, filename, content_type, size, pdf_data, classification_json, extraction_json, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO documents (` + documentColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(id) DO UPDATE SET
 			filename = excluded.filename,
 			content_type = excluded.content_type,
 			size = excluded.size,
 			pdf_data = excluded.pdf_data,
 			classification_json = excluded.classification_json,
-			extraction_json = excluded.extraction_json
+			extraction_json = excluded.extraction_json,
+			summaries_json = excluded.summaries_json,
+			translations_json = excluded.translations_json,
+			redacted_pdf_data = excluded.redacted_pdf_data,
+			redaction_json = excluded.redaction_json,
+			tables_json = excluded.tables_json,
+			parent_id = excluded.parent_id,
+			bundle_json = excluded.bundle_json
 	`
 
-	_, err := s.db.Exec(query,
+	_, err = s.db.Exec(query,
 		doc.ID,
 		doc.Filename,
 		doc.ContentType,
@@ -122,21 +272,121 @@ func (s *SQLiteStore) SaveDocument(doc *models.Document) error {
 		doc.PDFData,
 		classificationJSON,
 		extractionJSON,
+		summariesJSON,
+		translationsJSON,
+		doc.RedactedPDFData,
+		redactionJSON,
+		tablesJSON,
+		sql.NullString{String: doc.ParentID, Valid: doc.ParentID != ""},
+		bundleJSON,
 		doc.CreatedAt,
 	)
 	return err
 }
 
-func (s *SQLiteStore) GetDocument(id string) (*models.Document, error) {
-	query := `
-		SELECT id, filename, content_type, size, pdf_data, classification_json, extraction_json, created_at
-		FROM documents WHERE id = ?
-	`
+// SaveSummary sets one member of the stored summaries with one UPDATE
+func (s *SQLiteStore) SaveSummary(documentID string, summary *models.Summary) error {
+	if err := s.setDocumentMember(documentID, "summaries_json", summary.Length, summary); err != nil {
+		return fmt.Errorf("failed to save summary: %w", err)
+	}
+	return nil
+}
+
+// SaveTranslation sets one member of the stored translations with one UPDATE
+func (s *SQLiteStore) SaveTranslation(documentID string, translation *models.Translation) error {
+	if err := s.setDocumentMember(documentID, "translations_json", translation.TargetLanguage, translation); err != nil {
+		return fmt.Errorf("failed to save translation: %w", err)
+	}
+	return nil
+}
+
+// SaveRedaction updates only the redaction columns
+func (s *SQLiteStore) SaveRedaction(documentID string, redactedPDFData []byte, report *models.RedactionReport) error {
+	redactionJSON, err := nullJSON(report, report != nil)
+	if err != nil {
+		return fmt.Errorf("failed to marshal redaction: %w", err)
+	}
+	result, err := s.db.Exec("UPDATE documents SET redacted_pdf_data = ?, redaction_json = ? WHERE id = ?", redactedPDFData, redactionJSON, documentID)
+	if err != nil {
+		return fmt.Errorf("failed to save redaction: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("document not found: %s", documentID)
+	}
+	return nil
+}
 
+// SaveBundle updates only the bundle column
+func (s *SQLiteStore) SaveBundle(documentID string, bundle *models.BundleReport) error {
+	bundleJSON, err := nullJSON(bundle, bundle != nil)
+	if err != nil {
+		return fmt.Errorf("failed to marshal bundle: %w", err)
+	}
+	result, err := s.db.Exec("UPDATE documents SET bundle_json = ? WHERE id = ?", bundleJSON, documentID)
+	if err != nil {
+		return fmt.Errorf("failed to save bundle: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("document not found: %s", documentID)
+	}
+	return nil
+}
+
+// setDocumentMember sets the member key of the JSON object in a documents
+// column to v, creating the object if the column is NULL
+func (s *SQLiteStore) setDocumentMember(documentID, column, key string, v interface{}) error {
+	// SQLite JSON paths cannot escape quotes in member names
+	if key == "" || strings.ContainsAny(key, `"\`) {
+		return fmt.Errorf("invalid key %q", key)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(`
+		UPDATE documents SET `+column+` = json_set(COALESCE(`+column+`, '{}'), ?, json(?))
+		WHERE id = ?
+	`, `$."`+key+`"`, string(data), documentID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("document not found: %s", documentID)
+	}
+	return nil
+}
+
+// nullJSON encodes v for a nullable JSON column, or NULL if it is not set
+func nullJSON(v interface{}, set bool) (sql.NullString, error) {
+	if !set {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// scanDocument reads a document selected with documentColumns
+func scanDocument(row rowScanner) (*models.Document, error) {
 	var doc models.Document
-	var classificationJSON, extractionJSON sql.NullString
+	var classificationJSON, extractionJSON, summariesJSON, translationsJSON, redactionJSON, tablesJSON, parentID, bundleJSON sql.NullString
 
-	err := s.db.QueryRow(query, id).Scan(
+	err := row.Scan(
 		&doc.ID,
 		&doc.Filename,
 		&doc.ContentType,
@@ -144,13 +394,17 @@ func (s *SQLiteStore) GetDocument(id string) (*models.Document, error) {
 		&doc.PDFData,
 		&classificationJSON,
 		&extractionJSON,
+		&summariesJSON,
+		&translationsJSON,
+		&doc.RedactedPDFData,
+		&redactionJSON,
+		&tablesJSON,
+		&parentID,
+		&bundleJSON,
 		&doc.CreatedAt,
 	)
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("document not found: %s", id)
-	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get document: %w", err)
+		return nil, err
 	}
 
 	if classificationJSON.Valid {
@@ -169,9 +423,59 @@ func (s *SQLiteStore) GetDocument(id string) (*models.Document, error) {
 		doc.Extraction = &extraction
 	}
 
+	if summariesJSON.Valid {
+		if err := json.Unmarshal([]byte(summariesJSON.String), &doc.Summaries); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal summaries: %w", err)
+		}
+	}
+
+	if translationsJSON.Valid {
+		if err := json.Unmarshal([]byte(translationsJSON.String), &doc.Translations); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal translations: %w", err)
+		}
+	}
+
+	if redactionJSON.Valid {
+		var redaction models.RedactionReport
+		if err := json.Unmarshal([]byte(redactionJSON.String), &redaction); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal redaction: %w", err)
+		}
+		doc.Redaction = &redaction
+	}
+
+	if tablesJSON.Valid {
+		var tables models.TableExtraction
+		if err := json.Unmarshal([]byte(tablesJSON.String), &tables); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tables: %w", err)
+		}
+		doc.TableExtraction = &tables
+	}
+
+	doc.ParentID = parentID.String
+	if bundleJSON.Valid {
+		var bundle models.BundleReport
+		if err := json.Unmarshal([]byte(bundleJSON.String), &bundle); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal bundle: %w", err)
+		}
+		doc.Bundle = &bundle
+	}
+
 	return &doc, nil
 }
 
+func (s *SQLiteStore) GetDocument(id string) (*models.Document, error) {
+	query := `SELECT ` + documentColumns + ` FROM documents WHERE id = ?`
+
+	doc, err := scanDocument(s.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("document not found: %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+	return doc, nil
+}
+
 func (s *SQLiteStore) DeleteDocument(id string) error {
 	result, err := s.db.Exec("DELETE FROM documents WHERE id = ?", id)
 	if err != nil {
@@ -191,7 +495,7 @@ func (s *SQLiteStore) DeleteDocument(id string) error {
 
 func (s *SQLiteStore) ListDocuments(limit, offset int) ([]*models.Document, error) {
 	query := `
-		SELECT id, filename, content_type, size, pdf_data, classification_json, extraction_json, created_at
+		SELECT ` + documentColumns + `
 		FROM documents
 		ORDER BY created_at DESC
 		LIMIT ? OFFSET ?
@@ -209,49 +513,28 @@ func (s *SQLiteStore) ListDocuments(limit, offset int) ([]*models.Document, erro
 
 	var docs []*models.Document
 	for rows.Next() {
-		var doc models.Document
-		var classificationJSON, extractionJSON sql.NullString
-
-		err := rows.Scan(
-			&doc.ID,
-			&doc.Filename,
-			&doc.ContentType,
-			&doc.Size,
-			&doc.PDFData,
-			&classificationJSON,
-			&extractionJSON,
-			&doc.CreatedAt,
-		)
+		doc, err := scanDocument(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan document: %w", err)
 		}
-
-		if classificationJSON.Valid {
-			var classification models.Classification
-			if err := json.Unmarshal([]byte(classificationJSON.String), &classification); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal classification: %w", err)
-			}
-			doc.Classification = &classification
-		}
-
-		if extractionJSON.Valid {
-			var extraction models.Extraction
-			if err := json.Unmarshal([]byte(extractionJSON.String), &extraction); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal extraction: %w", err)
-			}
-			doc.Extraction = &extraction
-		}
-
-		docs = append(docs, &doc)
+		docs = append(docs, doc)
 	}
 
 	return docs, rows.Err()
 }
 
+// promptColumns lists the prompts table columns in the order used by
+// SavePrompt and scanPrompt
+const promptColumns = `id, document_id, agent_type, prompt, response, schema, model,
+	input_tokens, output_tokens, total_cost, attempts,
+	cache_creation_input_tokens, cache_read_input_tokens, cache_savings,
+	page_start, page_end, batch_id, template_id, template_version,
+	thinking, thinking_tokens, thinking_cost, raw_response, stop_reason, continuations, json_repairs, unpriced, error, created_at`
+
 func (s *SQLiteStore) SavePrompt(prompt *models.PromptRecord) error {
 	query := `
-		INSERT INTO prompts (id, document_id, agent_type, prompt, response, schema, model, input_tokens, output_tokens, total_cost, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO prompts (` + promptColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(id) DO UPDATE SET
 			prompt = excluded.prompt,
 			response = excluded.response,
@@ -259,15 +542,37 @@ func (s *SQLiteStore) SavePrompt(prompt *models.PromptRecord) error {
 			model = excluded.model,
 			input_tokens = excluded.input_tokens,
 			output_tokens = excluded.output_tokens,
-			total_cost = excluded.total_cost
+			total_cost = excluded.total_cost,
+			attempts = excluded.attempts,
+			cache_creation_input_tokens = excluded.cache_creation_input_tokens,
+			cache_read_input_tokens = excluded.cache_read_input_tokens,
+			cache_savings = excluded.cache_savings,
+			page_start = excluded.page_start,
+			page_end = excluded.page_end,
+			batch_id = excluded.batch_id,
+			template_id = excluded.template_id,
+			template_version = excluded.template_version,
+			thinking = excluded.thinking,
+			thinking_tokens = excluded.thinking_tokens,
+			thinking_cost = excluded.thinking_cost,
+			raw_response = excluded.raw_response,
+			stop_reason = excluded.stop_reason,
+			continuations = excluded.continuations,
+			json_repairs = excluded.json_repairs,
+			unpriced = excluded.unpriced,
+			error = excluded.error
 	`
 
 	var schema sql.NullString
 	if prompt.Schema != "" {
 		schema = sql.NullString{String: prompt.Schema, Valid: true}
 	}
+	repairsJSON, err := nullJSON(prompt.JSONRepairs, len(prompt.JSONRepairs) > 0)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON repairs: %w", err)
+	}
 
-	_, err := s.db.Exec(query,
+	_, err = s.db.Exec(query,
 		prompt.ID,
 		prompt.DocumentID,
 		prompt.AgentType,
@@ -278,23 +583,45 @@ func (s *SQLiteStore) SavePrompt(prompt *models.PromptRecord) error {
 		prompt.InputTokens,
 		prompt.OutputTokens,
 		prompt.TotalCost,
+		prompt.Attempts,
+		prompt.CacheCreationInputTokens,
+		prompt.CacheReadInputTokens,
+		prompt.CacheSavings,
+		prompt.PageStart,
+		prompt.PageEnd,
+		sql.NullString{String: prompt.BatchID, Valid: prompt.BatchID != ""},
+		sql.NullString{String: prompt.TemplateID, Valid: prompt.TemplateID != ""},
+		prompt.TemplateVersion,
+		sql.NullString{String: prompt.Thinking, Valid: prompt.Thinking != ""},
+		prompt.ThinkingTokens,
+		prompt.ThinkingCost,
+		sql.NullString{String: prompt.RawResponse, Valid: prompt.RawResponse != ""},
+		sql.NullString{String: prompt.StopReason, Valid: prompt.StopReason != ""},
+		prompt.Continuations,
+		repairsJSON,
+		prompt.Unpriced,
+		sql.NullString{String: prompt.Error, Valid: prompt.Error != ""},
 		prompt.CreatedAt,
 	)
 	return err
 }
 
-func (s *SQLiteStore) GetPrompt(id string) (*models.PromptRecord, error) {
-	query := `
-		SELECT id, document_id, agent_type, prompt, response, schema, model, input_tokens, output_tokens, total_cost, created_at
-		FROM prompts WHERE id = ?
-	`
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
+// scanPrompt reads a prompt record selected with promptColumns
+func scanPrompt(row rowScanner) (*models.PromptRecord, error) {
 	var prompt models.PromptRecord
 	var schema sql.NullString
 	var model sql.NullString
+	var batchID sql.NullString
+	var templateID sql.NullString
+	var thinking, rawResponse, stopReason, repairsJSON, errorText sql.NullString
 	var createdAt time.Time
 
-	err := s.db.QueryRow(query, id).Scan(
+	err := row.Scan(
 		&prompt.ID,
 		&prompt.DocumentID,
 		&prompt.AgentType,
@@ -305,28 +632,63 @@ func (s *SQLiteStore) GetPrompt(id string) (*models.PromptRecord, error) {
 		&prompt.InputTokens,
 		&prompt.OutputTokens,
 		&prompt.TotalCost,
+		&prompt.Attempts,
+		&prompt.CacheCreationInputTokens,
+		&prompt.CacheReadInputTokens,
+		&prompt.CacheSavings,
+		&prompt.PageStart,
+		&prompt.PageEnd,
+		&batchID,
+		&templateID,
+		&prompt.TemplateVersion,
+		&thinking,
+		&prompt.ThinkingTokens,
+		&prompt.ThinkingCost,
+		&rawResponse,
+		&stopReason,
+		&prompt.Continuations,
+		&repairsJSON,
+		&prompt.Unpriced,
+		&errorText,
 		&createdAt,
 	)
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("prompt not found: %s", id)
-	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get prompt: %w", err)
+		return nil, err
 	}
 
 	prompt.Schema = schema.String
 	prompt.Model = model.String
+	prompt.BatchID = batchID.String
+	prompt.TemplateID = templateID.String
+	prompt.Thinking = thinking.String
+	prompt.RawResponse = rawResponse.String
+	prompt.StopReason = stopReason.String
+	prompt.Error = errorText.String
+	if repairsJSON.Valid {
+		if err := json.Unmarshal([]byte(repairsJSON.String), &prompt.JSONRepairs); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal JSON repairs: %w", err)
+		}
+	}
 	prompt.CreatedAt = createdAt
-
 	return &prompt, nil
 }
 
+func (s *SQLiteStore) GetPrompt(id string) (*models.PromptRecord, error) {
+	query := `SELECT ` + promptColumns + ` FROM prompts WHERE id = ?`
+
+	prompt, err := scanPrompt(s.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("prompt not found: %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prompt: %w", err)
+	}
+
+	return prompt, nil
+}
+
 func (s *SQLiteStore) GetPromptsByDocument(documentID string) ([]*models.PromptRecord, error) {
-	query := `
-		SELECT id, document_id, agent_type, prompt, response, schema, model, input_tokens, output_tokens, total_cost, created_at
-		FROM prompts WHERE document_id = ?
-		ORDER BY created_at ASC
-	`
+	query := `SELECT ` + promptColumns + ` FROM prompts WHERE document_id = ? ORDER BY created_at ASC`
 
 	rows, err := s.db.Query(query, documentID)
 	if err != nil {
@@ -336,33 +698,333 @@ func (s *SQLiteStore) GetPromptsByDocument(documentID string) ([]*models.PromptR
 
 	var prompts []*models.PromptRecord
 	for rows.Next() {
-		var prompt models.PromptRecord
-		var schema sql.NullString
-		var model sql.NullString
-		var createdAt time.Time
-
-		err := rows.Scan(
-			&prompt.ID,
-			&prompt.DocumentID,
-			&prompt.AgentType,
-			&prompt.Prompt,
-			&prompt.Response,
-			&schema,
-			&model,
-			&prompt.InputTokens,
-			&prompt.OutputTokens,
-			&prompt.TotalCost,
-			&createdAt,
-		)
+		prompt, err := scanPrompt(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan prompt: %w", err)
 		}
-
-		prompt.Schema = schema.String
-		prompt.Model = model.String
-		prompt.CreatedAt = createdAt
-		prompts = append(prompts, &prompt)
+		prompts = append(prompts, prompt)
 	}
 
 	return prompts, rows.Err()
 }
+
+// SaveBatch stores a batch, replacing any earlier state of it. Batches are
+// only read back whole, so they are stored as JSON.
+func (s *SQLiteStore) SaveBatch(batch *models.Batch) error {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO batches (id, batch_json, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET batch_json = excluded.batch_json
+	`, batch.ID, string(data), batch.CreatedAt)
+	return err
+}
+
+func (s *SQLiteStore) GetBatch(id string) (*models.Batch, error) {
+	var data string
+	err := s.db.QueryRow("SELECT batch_json FROM batches WHERE id = ?", id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("batch not found: %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch: %w", err)
+	}
+
+	var batch models.Batch
+	if err := json.Unmarshal([]byte(data), &batch); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal batch: %w", err)
+	}
+	return &batch, nil
+}
+
+// SaveDocumentType stores a document type, replacing any earlier definition
+// with the same name
+func (s *SQLiteStore) SaveDocumentType(documentType *models.DocumentType) error {
+	data, err := json.Marshal(documentType)
+	if err != nil {
+		return fmt.Errorf("failed to marshal document type: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO document_types (name, type_json, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET type_json = excluded.type_json
+	`, documentType.Name, string(data), documentType.CreatedAt)
+	return err
+}
+
+func (s *SQLiteStore) GetDocumentType(name string) (*models.DocumentType, error) {
+	var data string
+	err := s.db.QueryRow("SELECT type_json FROM document_types WHERE name = ?", name).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("document type not found: %s", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document type: %w", err)
+	}
+
+	var documentType models.DocumentType
+	if err := json.Unmarshal([]byte(data), &documentType); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal document type: %w", err)
+	}
+	return &documentType, nil
+}
+
+// ListDocumentTypes returns the document types sorted by name
+func (s *SQLiteStore) ListDocumentTypes() ([]*models.DocumentType, error) {
+	rows, err := s.db.Query("SELECT type_json FROM document_types ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list document types: %w", err)
+	}
+	defer rows.Close()
+
+	var types []*models.DocumentType
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan document type: %w", err)
+		}
+		var documentType models.DocumentType
+		if err := json.Unmarshal([]byte(data), &documentType); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal document type: %w", err)
+		}
+		types = append(types, &documentType)
+	}
+	return types, rows.Err()
+}
+
+func (s *SQLiteStore) DeleteDocumentType(name string) error {
+	result, err := s.db.Exec("DELETE FROM document_types WHERE name = ?", name)
+	if err != nil {
+		return fmt.Errorf("failed to delete document type: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("document type not found: %s", name)
+	}
+
+	return nil
+}
+
+// SavePromptTemplate stores a template as the next version of its ID and
+// sets its Version. The version is allocated in the same transaction as the
+// insert, which fails rather than replace a version saved concurrently.
+func (s *SQLiteStore) SavePromptTemplate(template *models.PromptTemplate) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var latest int
+	if err := tx.QueryRow("SELECT COALESCE(MAX(version), 0) FROM prompt_templates WHERE id = ?", template.ID).Scan(&latest); err != nil {
+		return fmt.Errorf("failed to get prompt template version: %w", err)
+	}
+	template.Version = latest + 1
+
+	data, err := json.Marshal(template)
+	if err != nil {
+		return fmt.Errorf("failed to marshal prompt template: %w", err)
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO prompt_templates (id, version, agent_type, template_json, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, template.ID, template.Version, template.AgentType, string(data), template.CreatedAt); err != nil {
+		return fmt.Errorf("failed to save prompt template: %w", err)
+	}
+	return tx.Commit()
+}
+
+// SetPromptTemplateWeight changes only the weight of a template version
+func (s *SQLiteStore) SetPromptTemplateWeight(id string, version, weight int) (*models.PromptTemplate, error) {
+	result, err := s.db.Exec(`
+		UPDATE prompt_templates SET template_json = json_set(template_json, '$.weight', ?)
+		WHERE id = ? AND version = ?
+	`, weight, id, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set prompt template weight: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return nil, fmt.Errorf("prompt template not found: %s version %d", id, version)
+	}
+	return s.GetPromptTemplate(id, version)
+}
+
+func (s *SQLiteStore) GetPromptTemplate(id string, version int) (*models.PromptTemplate, error) {
+	var data string
+	err := s.db.QueryRow("SELECT template_json FROM prompt_templates WHERE id = ? AND version = ?", id, version).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("prompt template not found: %s version %d", id, version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prompt template: %w", err)
+	}
+
+	var template models.PromptTemplate
+	if err := json.Unmarshal([]byte(data), &template); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal prompt template: %w", err)
+	}
+	return &template, nil
+}
+
+func (s *SQLiteStore) ListPromptTemplates(agentType string) ([]*models.PromptTemplate, error) {
+	rows, err := s.db.Query(`
+		SELECT template_json FROM prompt_templates
+		WHERE ? = '' OR agent_type = ?
+		ORDER BY id, version
+	`, agentType, agentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompt templates: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []*models.PromptTemplate
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan prompt template: %w", err)
+		}
+		var template models.PromptTemplate
+		if err := json.Unmarshal([]byte(data), &template); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal prompt template: %w", err)
+		}
+		templates = append(templates, &template)
+	}
+	return templates, rows.Err()
+}
+
+// SaveChatThread stores a thread with all its turns, replacing any earlier
+// state of it. Threads are only read back whole, so they are stored as JSON.
+func (s *SQLiteStore) SaveChatThread(thread *models.ChatThread) error {
+	data, err := json.Marshal(thread)
+	if err != nil {
+		return fmt.Errorf("failed to marshal chat thread: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO chat_threads (id, document_id, thread_json, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET thread_json = excluded.thread_json, updated_at = excluded.updated_at
+	`, thread.ID, thread.DocumentID, string(data), thread.UpdatedAt)
+	return err
+}
+
+// AppendChatTurns appends the turns to the stored JSON with one UPDATE, so
+// concurrent appends cannot overwrite each other
+func (s *SQLiteStore) AppendChatTurns(id string, turns []models.ChatTurn) (*models.ChatThread, error) {
+	if len(turns) == 0 {
+		return s.GetChatThread(id)
+	}
+	updatedAt := turns[len(turns)-1].CreatedAt
+	updatedAtJSON, err := json.Marshal(updatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chat thread: %w", err)
+	}
+
+	// Each '$.turns[#]' path appends to the array as left by the previous one
+	paths := make([]string, len(turns))
+	args := make([]any, 0, 2*len(turns)+3)
+	for i, turn := range turns {
+		data, err := json.Marshal(turn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal chat turn: %w", err)
+		}
+		paths[i] = "'$.turns[#]', json(?)"
+		args = append(args, string(data))
+	}
+	args = append(args, string(updatedAtJSON), updatedAt, id)
+
+	result, err := s.db.Exec(`
+		UPDATE chat_threads SET
+			thread_json = json_set(json_insert(
+				CASE WHEN json_type(thread_json, '$.turns') = 'array' THEN thread_json ELSE json_set(thread_json, '$.turns', json('[]')) END,
+				`+strings.Join(paths, ", ")+`), '$.updated_at', json(?)),
+			updated_at = ?
+		WHERE id = ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to append chat turns: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return nil, fmt.Errorf("chat thread not found: %s", id)
+	}
+	return s.GetChatThread(id)
+}
+
+func (s *SQLiteStore) GetChatThread(id string) (*models.ChatThread, error) {
+	var data string
+	err := s.db.QueryRow("SELECT thread_json FROM chat_threads WHERE id = ?", id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("chat thread not found: %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat thread: %w", err)
+	}
+
+	var thread models.ChatThread
+	if err := json.Unmarshal([]byte(data), &thread); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal chat thread: %w", err)
+	}
+	return &thread, nil
+}
+
+func (s *SQLiteStore) ListChatThreads(documentID string) ([]*models.ChatThread, error) {
+	rows, err := s.db.Query(`
+		SELECT thread_json FROM chat_threads
+		WHERE document_id = ?
+		ORDER BY updated_at DESC
+	`, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chat threads: %w", err)
+	}
+	defer rows.Close()
+
+	var threads []*models.ChatThread
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan chat thread: %w", err)
+		}
+		var thread models.ChatThread
+		if err := json.Unmarshal([]byte(data), &thread); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal chat thread: %w", err)
+		}
+		threads = append(threads, &thread)
+	}
+	return threads, rows.Err()
+}
+
+func (s *SQLiteStore) DeleteChatThread(id string) error {
+	result, err := s.db.Exec("DELETE FROM chat_threads WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete chat thread: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("chat thread not found: %s", id)
+	}
+
+	return nil
+}
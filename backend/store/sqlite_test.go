@@ -1,7 +1,11 @@
 package store
 
 import (
+	"database/sql"
+	"fmt"
 	"os"
+	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -41,6 +45,256 @@ func TestSQLiteStore_SaveAndGetDocument(t *testing.T) {
 	}
 }
 
+func TestSQLiteStore_SaveDocumentWithSummaries(t *testing.T) {
+	store, cleanup := setupTestSQLiteStore(t)
+	defer cleanup()
+
+	doc := &models.Document{
+		ID:          "summarized-doc",
+		Filename:    "lease.pdf",
+		ContentType: "application/pdf",
+		CreatedAt:   time.Now(),
+		Summaries: map[string]*models.Summary{
+			"sections": {
+				Length:   "sections",
+				Style:    "prose",
+				Text:     "A residential lease.",
+				Sections: []models.SummarySection{{Title: "Rent", StartPage: 2, EndPage: 3, Summary: "1,200 EUR monthly."}},
+			},
+		},
+	}
+	if err := store.SaveDocument(doc); err != nil {
+		t.Fatalf("Failed to save document: %v", err)
+	}
+
+	got, err := store.GetDocument(doc.ID)
+	if err != nil {
+		t.Fatalf("Failed to get document: %v", err)
+	}
+	sections := got.Summaries["sections"]
+	if sections == nil || len(sections.Sections) != 1 || sections.Sections[0].EndPage != 3 {
+		t.Errorf("Expected the section summary, got %+v", got.Summaries)
+	}
+
+	docs, err := store.ListDocuments(10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list documents: %v", err)
+	}
+	if len(docs) != 1 || docs[0].Summaries["sections"] == nil {
+		t.Errorf("Expected listed documents to carry their summaries, got %+v", docs)
+	}
+}
+
+func TestSQLiteStore_SaveSummaryKeepsOtherFields(t *testing.T) {
+	store, cleanup := setupTestSQLiteStore(t)
+	defer cleanup()
+
+	doc := &models.Document{ID: "summary-doc", Filename: "lease.pdf", ContentType: "application/pdf", CreatedAt: time.Now()}
+	store.SaveDocument(doc)
+	if err := store.SaveSummary(doc.ID, &models.Summary{Length: "one_line", Text: "A lease."}); err != nil {
+		t.Fatalf("Failed to save summary: %v", err)
+	}
+
+	// A classification saved while the summary was being written
+	doc.Classification = &models.Classification{DocumentType: "lease"}
+	store.SaveDocument(doc)
+	if err := store.SaveSummary(doc.ID, &models.Summary{Length: "paragraph", Text: "A residential lease."}); err != nil {
+		t.Fatalf("Failed to save summary: %v", err)
+	}
+
+	got, _ := store.GetDocument(doc.ID)
+	if got.Classification == nil || len(got.Summaries) != 1 || got.Summaries["paragraph"].Text != "A residential lease." {
+		t.Errorf("Expected the classification and the new summary, got %+v", got)
+	}
+	if err := store.SaveSummary("missing", &models.Summary{Length: "paragraph"}); err == nil {
+		t.Error("Expected an error for a missing document")
+	}
+}
+
+func TestSQLiteStore_SaveTranslationKeepsOtherFields(t *testing.T) {
+	store, cleanup := setupTestSQLiteStore(t)
+	defer cleanup()
+
+	doc := &models.Document{ID: "translation-doc", Filename: "rechnung.pdf", ContentType: "application/pdf", CreatedAt: time.Now()}
+	store.SaveDocument(doc)
+	store.SaveSummary(doc.ID, &models.Summary{Length: "one_line", Text: "An invoice."})
+	if err := store.SaveTranslation(doc.ID, &models.Translation{TargetLanguage: "English", SourceLanguage: "de"}); err != nil {
+		t.Fatalf("Failed to save translation: %v", err)
+	}
+	if err := store.SaveTranslation(doc.ID, &models.Translation{TargetLanguage: "fr", SourceLanguage: "de"}); err != nil {
+		t.Fatalf("Failed to save translation: %v", err)
+	}
+
+	got, _ := store.GetDocument(doc.ID)
+	if got.Summaries["one_line"] == nil || len(got.Translations) != 2 || got.Translations["English"].SourceLanguage != "de" {
+		t.Errorf("Expected the summary and both translations, got %+v", got)
+	}
+	if err := store.SaveTranslation(doc.ID, &models.Translation{TargetLanguage: `en"`}); err == nil {
+		t.Error("Expected an error for a language that cannot be a JSON member")
+	}
+}
+
+func TestSQLiteStore_SaveDocumentWithTranslations(t *testing.T) {
+	store, cleanup := setupTestSQLiteStore(t)
+	defer cleanup()
+
+	doc := &models.Document{
+		ID:          "translated-doc",
+		Filename:    "rechnung.pdf",
+		ContentType: "application/pdf",
+		CreatedAt:   time.Now(),
+		Translations: map[string]*models.Translation{
+			"English": {
+				TargetLanguage: "English",
+				SourceLanguage: "German",
+				Fields:         []models.TranslatedField{{Name: "description", Original: "Beratung", Translated: "Consulting", SourceText: "Beratung", PageNumber: 1}},
+			},
+		},
+	}
+	if err := store.SaveDocument(doc); err != nil {
+		t.Fatalf("Failed to save document: %v", err)
+	}
+
+	got, err := store.GetDocument(doc.ID)
+	if err != nil {
+		t.Fatalf("Failed to get document: %v", err)
+	}
+	english := got.Translations["English"]
+	if english == nil || len(english.Fields) != 1 || english.Fields[0].Original != "Beratung" || english.Fields[0].Translated != "Consulting" {
+		t.Errorf("Expected the English translation with its original, got %+v", got.Translations)
+	}
+}
+
+func TestSQLiteStore_SaveRedactionKeepsOtherFields(t *testing.T) {
+	store, cleanup := setupTestSQLiteStore(t)
+	defer cleanup()
+
+	doc := &models.Document{ID: "redaction-doc", Filename: "resume.pdf", ContentType: "application/pdf", PDFData: []byte("%PDF-1.4 original"), CreatedAt: time.Now()}
+	store.SaveDocument(doc)
+	store.SaveSummary(doc.ID, &models.Summary{Length: "one_line", Text: "A resume."})
+	report := &models.RedactionReport{Counts: map[string]int{models.PIISSN: 1}}
+	if err := store.SaveRedaction(doc.ID, []byte("%PDF-1.7 redacted"), report); err != nil {
+		t.Fatalf("Failed to save redaction: %v", err)
+	}
+
+	got, _ := store.GetDocument(doc.ID)
+	if string(got.PDFData) != "%PDF-1.4 original" || string(got.RedactedPDFData) != "%PDF-1.7 redacted" {
+		t.Errorf("Expected the original and redacted PDFs, got %q and %q", got.PDFData, got.RedactedPDFData)
+	}
+	if got.Summaries["one_line"] == nil || got.Redaction == nil || got.Redaction.Counts[models.PIISSN] != 1 {
+		t.Errorf("Expected the summary and the redaction report, got %+v", got)
+	}
+	if err := store.SaveRedaction("missing", nil, report); err == nil {
+		t.Error("Expected an error for a missing document")
+	}
+}
+
+func TestSQLiteStore_SaveDocumentWithRedaction(t *testing.T) {
+	store, cleanup := setupTestSQLiteStore(t)
+	defer cleanup()
+
+	doc := &models.Document{
+		ID:              "redacted-doc",
+		Filename:        "resume.pdf",
+		ContentType:     "application/pdf",
+		PDFData:         []byte("%PDF-1.4 original"),
+		RedactedPDFData: []byte("%PDF-1.7 redacted"),
+		Redaction: &models.RedactionReport{
+			Spans:  []models.PIISpan{{Type: models.PIISSN, Masked: "***-**-6789", PageNumber: 1, Start: 4, End: 15, Detector: models.DetectorRegex, Redacted: true}},
+			Counts: map[string]int{models.PIISSN: 1},
+		},
+		CreatedAt: time.Now(),
+	}
+	if err := store.SaveDocument(doc); err != nil {
+		t.Fatalf("Failed to save document: %v", err)
+	}
+
+	got, err := store.GetDocument(doc.ID)
+	if err != nil {
+		t.Fatalf("Failed to get document: %v", err)
+	}
+	if string(got.RedactedPDFData) != "%PDF-1.7 redacted" {
+		t.Errorf("Expected the redacted PDF, got %q", got.RedactedPDFData)
+	}
+	if got.Redaction == nil || len(got.Redaction.Spans) != 1 || !got.Redaction.Spans[0].Redacted {
+		t.Errorf("Expected the redaction report, got %+v", got.Redaction)
+	}
+}
+
+func TestSQLiteStore_SaveDocumentWithTables(t *testing.T) {
+	store, cleanup := setupTestSQLiteStore(t)
+	defer cleanup()
+
+	doc := &models.Document{
+		ID:          "tables-doc",
+		Filename:    "prices.pdf",
+		ContentType: "application/pdf",
+		PDFData:     []byte("%PDF-1.4 prices"),
+		TableExtraction: &models.TableExtraction{Tables: []models.Table{{
+			Index:       1,
+			PageNumber:  2,
+			HeaderRows:  1,
+			Cells:       [][]string{{"Item", "Price"}, {"Widget", "$10.00"}},
+			MergedCells: []models.MergedCell{{Row: 0, Column: 0, RowSpan: 1, ColSpan: 2}},
+		}}},
+		CreatedAt: time.Now(),
+	}
+	if err := store.SaveDocument(doc); err != nil {
+		t.Fatalf("Failed to save document: %v", err)
+	}
+
+	got, err := store.GetDocument(doc.ID)
+	if err != nil {
+		t.Fatalf("Failed to get document: %v", err)
+	}
+	if got.TableExtraction == nil || len(got.TableExtraction.Tables) != 1 || got.TableExtraction.Tables[0].Cells[1][1] != "$10.00" || len(got.TableExtraction.Tables[0].MergedCells) != 1 {
+		t.Errorf("Expected the table, got %+v", got.TableExtraction)
+	}
+}
+
+func TestSQLiteStore_SaveBundleAndChild(t *testing.T) {
+	store, cleanup := setupTestSQLiteStore(t)
+	defer cleanup()
+
+	bundle := &models.Document{
+		ID:          "bundle-doc",
+		Filename:    "scan.pdf",
+		ContentType: "application/pdf",
+		PDFData:     []byte("%PDF-1.4 scan"),
+		Bundle: &models.BundleReport{Segments: []models.BundleSegment{
+			{StartPage: 1, EndPage: 2, DocumentType: "invoice", ChildID: "child-doc"},
+		}},
+		CreatedAt: time.Now(),
+	}
+	child := &models.Document{ID: "child-doc", Filename: "scan-pages-1-2.pdf", ContentType: "application/pdf", PDFData: []byte("%PDF-1.4 child"), ParentID: bundle.ID, CreatedAt: time.Now()}
+	for _, doc := range []*models.Document{bundle, child} {
+		if err := store.SaveDocument(doc); err != nil {
+			t.Fatalf("Failed to save document: %v", err)
+		}
+	}
+
+	got, err := store.GetDocument(bundle.ID)
+	if err != nil {
+		t.Fatalf("Failed to get document: %v", err)
+	}
+	if got.ParentID != "" || got.Bundle == nil || len(got.Bundle.Segments) != 1 || got.Bundle.Segments[0].ChildID != child.ID {
+		t.Errorf("Expected the bundle segments, got %+v", got.Bundle)
+	}
+	if got, _ := store.GetDocument(child.ID); got.ParentID != bundle.ID {
+		t.Errorf("Expected the parent link, got %q", got.ParentID)
+	}
+
+	// Saving only the report keeps the rest of the bundle
+	store.SaveSummary(bundle.ID, &models.Summary{Length: "one_line", Text: "A scan."})
+	if err := store.SaveBundle(bundle.ID, &models.BundleReport{Segments: []models.BundleSegment{{StartPage: 1, EndPage: 1}}}); err != nil {
+		t.Fatalf("Failed to save bundle: %v", err)
+	}
+	got, _ = store.GetDocument(bundle.ID)
+	if len(got.Bundle.Segments) != 1 || got.Bundle.Segments[0].EndPage != 1 || got.Summaries["one_line"] == nil || string(got.PDFData) != "%PDF-1.4 scan" {
+		t.Errorf("Expected the new report next to the summary and PDF, got %+v", got)
+	}
+}
+
 func TestSQLiteStore_SaveDocumentWithClassification(t *testing.T) {
 	store, cleanup := setupTestSQLiteStore(t)
 	defer cleanup()
@@ -187,7 +441,26 @@ func TestSQLiteStore_SaveAndGetPrompt(t *testing.T) {
 		InputTokens:  500,
 		OutputTokens: 100,
 		TotalCost:    0.003,
+		Attempts:     2,
 		CreatedAt:    time.Now(),
+
+		CacheCreationInputTokens: 4000,
+		CacheReadInputTokens:     8000,
+		CacheSavings:             0.02,
+		PageStart:                21,
+		PageEnd:                  40,
+		BatchID:                  "batch-1",
+		TemplateID:               "concise",
+		TemplateVersion:          3,
+		Thinking:                 "The header says INVOICE.",
+		ThinkingTokens:           80,
+		ThinkingCost:             0.0012,
+		RawResponse:              `{"document_type": "Invoice", "confidence": 0.9,}`,
+		StopReason:               "max_tokens",
+		Continuations:            2,
+		JSONRepairs:              []string{"trailing_comma"},
+		Unpriced:                 true,
+		Error:                    "failed to parse response",
 	}
 
 	if err := store.SavePrompt(prompt); err != nil {
@@ -211,6 +484,373 @@ func TestSQLiteStore_SaveAndGetPrompt(t *testing.T) {
 	if got.InputTokens != prompt.InputTokens {
 		t.Errorf("Expected InputTokens %d, got %d", prompt.InputTokens, got.InputTokens)
 	}
+	if got.Attempts != prompt.Attempts {
+		t.Errorf("Expected Attempts %d, got %d", prompt.Attempts, got.Attempts)
+	}
+	if got.CacheCreationInputTokens != 4000 || got.CacheReadInputTokens != 8000 {
+		t.Errorf("Expected cache tokens 4000/8000, got %d/%d", got.CacheCreationInputTokens, got.CacheReadInputTokens)
+	}
+	if got.CacheSavings != prompt.CacheSavings {
+		t.Errorf("Expected CacheSavings %f, got %f", prompt.CacheSavings, got.CacheSavings)
+	}
+	if got.PageStart != 21 || got.PageEnd != 40 {
+		t.Errorf("Expected page range 21-40, got %d-%d", got.PageStart, got.PageEnd)
+	}
+	if got.BatchID != "batch-1" {
+		t.Errorf("Expected BatchID batch-1, got %s", got.BatchID)
+	}
+	if got.TemplateID != "concise" || got.TemplateVersion != 3 {
+		t.Errorf("Expected template concise version 3, got %s version %d", got.TemplateID, got.TemplateVersion)
+	}
+	if got.Thinking != prompt.Thinking || got.ThinkingTokens != 80 || got.ThinkingCost != prompt.ThinkingCost {
+		t.Errorf("Expected thinking %q (80 tokens, %f), got %q (%d tokens, %f)", prompt.Thinking, prompt.ThinkingCost, got.Thinking, got.ThinkingTokens, got.ThinkingCost)
+	}
+	if got.RawResponse != prompt.RawResponse || got.StopReason != "max_tokens" || got.Continuations != 2 {
+		t.Errorf("Expected raw response, stop reason and continuations to round-trip, got %q/%q/%d", got.RawResponse, got.StopReason, got.Continuations)
+	}
+	if !reflect.DeepEqual(got.JSONRepairs, prompt.JSONRepairs) {
+		t.Errorf("Expected JSON repairs %v, got %v", prompt.JSONRepairs, got.JSONRepairs)
+	}
+	if !got.Unpriced || got.Error != prompt.Error {
+		t.Errorf("Expected the unpriced flag and error to round-trip, got %v/%q", got.Unpriced, got.Error)
+	}
+}
+
+func TestSQLiteStore_SaveAndGetBatch(t *testing.T) {
+	store, cleanup := setupTestSQLiteStore(t)
+	defer cleanup()
+
+	batch := &models.Batch{
+		ID:               "batch-1",
+		ProviderBatchIDs: []string{"msgbatch_1"},
+		AgentType:        "classification",
+		Status:           models.BatchInProgress,
+		Requests:         []models.BatchRequest{{CustomID: "req-0", ProviderBatchID: "msgbatch_1", DocumentID: "doc-1", Status: "pending"}},
+		CreatedAt:        time.Now(),
+	}
+	if err := store.SaveBatch(batch); err != nil {
+		t.Fatalf("Failed to save batch: %v", err)
+	}
+
+	batch.Status = models.BatchCompleted
+	batch.Requests[0].Status = "succeeded"
+	if err := store.SaveBatch(batch); err != nil {
+		t.Fatalf("Failed to update batch: %v", err)
+	}
+
+	got, err := store.GetBatch("batch-1")
+	if err != nil {
+		t.Fatalf("Failed to get batch: %v", err)
+	}
+	if got.Status != models.BatchCompleted || len(got.ProviderBatchIDs) != 1 || got.ProviderBatchIDs[0] != "msgbatch_1" {
+		t.Errorf("Unexpected batch %+v", got)
+	}
+	if len(got.Requests) != 1 || got.Requests[0].Status != "succeeded" {
+		t.Errorf("Expected updated request status, got %+v", got.Requests)
+	}
+
+	if _, err := store.GetBatch("missing"); err == nil {
+		t.Error("Expected error for missing batch")
+	}
+}
+
+func TestSQLiteStore_DocumentTypes(t *testing.T) {
+	store, cleanup := setupTestSQLiteStore(t)
+	defer cleanup()
+
+	for _, documentType := range []*models.DocumentType{
+		{Name: "purchase_order", Description: "Order", Indicators: []string{"PO number"}, Parent: "form", CreatedAt: time.Now()},
+		{Name: "bill_of_lading", Description: "Shipping receipt", CreatedAt: time.Now()},
+	} {
+		if err := store.SaveDocumentType(documentType); err != nil {
+			t.Fatalf("Failed to save document type: %v", err)
+		}
+	}
+	if err := store.SaveDocumentType(&models.DocumentType{Name: "purchase_order", Description: "Purchase order", Parent: "form", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Failed to update document type: %v", err)
+	}
+
+	got, err := store.GetDocumentType("purchase_order")
+	if err != nil {
+		t.Fatalf("Failed to get document type: %v", err)
+	}
+	if got.Description != "Purchase order" || got.Parent != "form" || len(got.Indicators) != 0 {
+		t.Errorf("Expected the updated document type, got %+v", got)
+	}
+
+	types, err := store.ListDocumentTypes()
+	if err != nil {
+		t.Fatalf("Failed to list document types: %v", err)
+	}
+	if len(types) != 2 || types[0].Name != "bill_of_lading" {
+		t.Errorf("Expected 2 types sorted by name, got %+v", types)
+	}
+
+	if err := store.DeleteDocumentType("bill_of_lading"); err != nil {
+		t.Fatalf("Failed to delete document type: %v", err)
+	}
+	if err := store.DeleteDocumentType("bill_of_lading"); err == nil {
+		t.Error("Expected error deleting a missing document type")
+	}
+	if _, err := store.GetDocumentType("bill_of_lading"); err == nil {
+		t.Error("Expected error for deleted document type")
+	}
+}
+
+func TestSQLiteStore_PromptTemplates(t *testing.T) {
+	store, cleanup := setupTestSQLiteStore(t)
+	defer cleanup()
+
+	first := &models.PromptTemplate{ID: "concise", Version: 7, AgentType: "extraction", Template: "Extract", Weight: 1, CreatedAt: time.Now()}
+	second := &models.PromptTemplate{ID: "concise", AgentType: "extraction", Template: "Extract {{.DocumentType}}", Weight: 1, CreatedAt: time.Now()}
+	brief := &models.PromptTemplate{ID: "brief", AgentType: "classification", Template: "Classify", Weight: 1, CreatedAt: time.Now()}
+	for _, template := range []*models.PromptTemplate{first, second, brief} {
+		if err := store.SavePromptTemplate(template); err != nil {
+			t.Fatalf("Failed to save prompt template: %v", err)
+		}
+	}
+	if first.Version != 1 || second.Version != 2 || brief.Version != 1 {
+		t.Errorf("Expected versions allocated per ID, got %d, %d and %d", first.Version, second.Version, brief.Version)
+	}
+
+	updated, err := store.SetPromptTemplateWeight("concise", 1, 0)
+	if err != nil {
+		t.Fatalf("Failed to set prompt template weight: %v", err)
+	}
+	if updated.Weight != 0 || updated.Template != "Extract" || updated.Version != 1 {
+		t.Errorf("Expected only the weight updated, got %+v", updated)
+	}
+	got, err := store.GetPromptTemplate("concise", 1)
+	if err != nil {
+		t.Fatalf("Failed to get prompt template: %v", err)
+	}
+	if got.Weight != 0 || got.Template != "Extract" {
+		t.Errorf("Expected the updated weight, got %+v", got)
+	}
+	if _, err := store.GetPromptTemplate("concise", 3); err == nil {
+		t.Error("Expected error for missing template version")
+	}
+	if _, err := store.SetPromptTemplateWeight("concise", 3, 1); err == nil {
+		t.Error("Expected error setting the weight of a missing template version")
+	}
+
+	extraction, err := store.ListPromptTemplates("extraction")
+	if err != nil {
+		t.Fatalf("Failed to list prompt templates: %v", err)
+	}
+	if len(extraction) != 2 || extraction[0].Version != 1 || extraction[1].Version != 2 {
+		t.Errorf("Expected both extraction versions in order, got %+v", extraction)
+	}
+	all, _ := store.ListPromptTemplates("")
+	if len(all) != 3 || all[0].ID != "brief" {
+		t.Errorf("Expected every template sorted by ID, got %+v", all)
+	}
+}
+
+func TestSQLiteStore_SavePromptTemplateConcurrently(t *testing.T) {
+	store, cleanup := setupTestSQLiteStore(t)
+	defer cleanup()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	saved := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := store.SavePromptTemplate(&models.PromptTemplate{ID: "concise", AgentType: "extraction", Template: "Extract", Weight: 1, CreatedAt: time.Now()})
+			if err == nil {
+				mu.Lock()
+				saved++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// A save that loses the race for a version fails rather than replace it
+	templates, _ := store.ListPromptTemplates("extraction")
+	if saved == 0 || len(templates) != saved {
+		t.Errorf("Expected a version for each of the %d successful saves, got %d", saved, len(templates))
+	}
+}
+
+func TestSQLiteStore_AppendChatTurnsConcurrently(t *testing.T) {
+	store, cleanup := setupTestSQLiteStore(t)
+	defer cleanup()
+
+	doc := &models.Document{ID: "chat-doc", Filename: "contract.pdf", ContentType: "application/pdf", CreatedAt: time.Now()}
+	store.SaveDocument(doc)
+	if err := store.SaveChatThread(&models.ChatThread{ID: "thread-1", DocumentID: doc.ID, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Failed to save chat thread: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	appended := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			question := fmt.Sprintf("Question %d", i)
+			_, err := store.AppendChatTurns("thread-1", []models.ChatTurn{
+				{Role: models.ChatRoleUser, Content: question, CreatedAt: time.Now()},
+				{Role: models.ChatRoleAssistant, Content: "Answer to " + question, CreatedAt: time.Now()},
+			})
+			if err == nil {
+				mu.Lock()
+				appended++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	thread, err := store.GetChatThread("thread-1")
+	if err != nil {
+		t.Fatalf("Failed to get chat thread: %v", err)
+	}
+	if appended == 0 || len(thread.Turns) != 2*appended {
+		t.Fatalf("Expected the turns of all %d appends, got %d", appended, len(thread.Turns))
+	}
+	for i := 0; i < len(thread.Turns); i += 2 {
+		if thread.Turns[i+1].Content != "Answer to "+thread.Turns[i].Content {
+			t.Errorf("Expected each question followed by its answer, got %+v", thread.Turns[i:i+2])
+		}
+	}
+	if !thread.UpdatedAt.Equal(thread.Turns[len(thread.Turns)-1].CreatedAt) {
+		t.Errorf("Expected UpdatedAt %v to be the time of the last turn, got %v", thread.Turns[len(thread.Turns)-1].CreatedAt, thread.UpdatedAt)
+	}
+
+	if _, err := store.AppendChatTurns("missing", []models.ChatTurn{{Role: models.ChatRoleUser, Content: "Hi"}}); err == nil {
+		t.Error("Expected error appending to a missing chat thread")
+	}
+}
+
+func TestSQLiteStore_ChatThreads(t *testing.T) {
+	store, cleanup := setupTestSQLiteStore(t)
+	defer cleanup()
+
+	doc := &models.Document{ID: "chat-doc", Filename: "contract.pdf", ContentType: "application/pdf", CreatedAt: time.Now()}
+	if err := store.SaveDocument(doc); err != nil {
+		t.Fatalf("Failed to save document: %v", err)
+	}
+
+	older := &models.ChatThread{ID: "thread-1", DocumentID: doc.ID, Title: "Late fee", CreatedAt: time.Now(), UpdatedAt: time.Now().Add(-time.Hour)}
+	newer := &models.ChatThread{ID: "thread-2", DocumentID: doc.ID, Title: "Parties", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	for _, thread := range []*models.ChatThread{older, newer} {
+		if err := store.SaveChatThread(thread); err != nil {
+			t.Fatalf("Failed to save chat thread: %v", err)
+		}
+	}
+	older.Turns = []models.ChatTurn{
+		{Role: models.ChatRoleUser, Content: "What's the late fee?"},
+		{Role: models.ChatRoleAssistant, Content: "2% per month", PromptID: "prompt-1"},
+	}
+	older.UpdatedAt = time.Now().Add(time.Hour)
+	if err := store.SaveChatThread(older); err != nil {
+		t.Fatalf("Failed to update chat thread: %v", err)
+	}
+
+	got, err := store.GetChatThread("thread-1")
+	if err != nil {
+		t.Fatalf("Failed to get chat thread: %v", err)
+	}
+	if len(got.Turns) != 2 || got.Turns[1].PromptID != "prompt-1" {
+		t.Errorf("Expected the saved turns, got %+v", got.Turns)
+	}
+
+	threads, err := store.ListChatThreads(doc.ID)
+	if err != nil {
+		t.Fatalf("Failed to list chat threads: %v", err)
+	}
+	if len(threads) != 2 || threads[0].ID != "thread-1" {
+		t.Errorf("Expected the most recently updated thread first, got %+v", threads)
+	}
+
+	if err := store.DeleteChatThread("thread-2"); err != nil {
+		t.Fatalf("Failed to delete chat thread: %v", err)
+	}
+	if err := store.DeleteChatThread("thread-2"); err == nil {
+		t.Error("Expected error deleting a missing chat thread")
+	}
+
+	// Threads go with their document
+	if err := store.DeleteDocument(doc.ID); err != nil {
+		t.Fatalf("Failed to delete document: %v", err)
+	}
+	if _, err := store.GetChatThread("thread-1"); err == nil {
+		t.Error("Expected the thread to be deleted with its document")
+	}
+}
+
+func TestSQLiteStore_MigratesExistingDatabase(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "test-*.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+
+	// Create a database with the original documents and prompts tables
+	db, err := sql.Open("sqlite3", tmpFile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = db.Exec(`CREATE TABLE documents (
+		id TEXT PRIMARY KEY,
+		filename TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		pdf_data BLOB,
+		classification_json TEXT,
+		extraction_json TEXT,
+		created_at DATETIME NOT NULL
+	);
+	CREATE TABLE prompts (
+		id TEXT PRIMARY KEY,
+		document_id TEXT NOT NULL,
+		agent_type TEXT NOT NULL,
+		prompt TEXT NOT NULL,
+		response TEXT NOT NULL,
+		schema TEXT,
+		model TEXT,
+		input_tokens INTEGER DEFAULT 0,
+		output_tokens INTEGER DEFAULT 0,
+		total_cost REAL DEFAULT 0,
+		created_at DATETIME NOT NULL
+	)`)
+	db.Close()
+	if err != nil {
+		t.Fatalf("Failed to create legacy table: %v", err)
+	}
+
+	store, err := NewSQLiteStore(tmpFile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open legacy database: %v", err)
+	}
+	defer store.Close()
+
+	doc := &models.Document{ID: "legacy-doc", Filename: "a.pdf", ContentType: "application/pdf", CreatedAt: time.Now(),
+		Summaries: map[string]*models.Summary{"one_line": {Length: "one_line", Text: "A letter."}}}
+	if err := store.SaveDocument(doc); err != nil {
+		t.Fatalf("Failed to save document after migration: %v", err)
+	}
+	if got, err := store.GetDocument("legacy-doc"); err != nil || got.Summaries["one_line"] == nil {
+		t.Errorf("Expected the summary to be stored after migration, got %v", err)
+	}
+	prompt := &models.PromptRecord{ID: "legacy-prompt", DocumentID: "legacy-doc", AgentType: "classification", Attempts: 3, CreatedAt: time.Now()}
+	if err := store.SavePrompt(prompt); err != nil {
+		t.Fatalf("Failed to save prompt after migration: %v", err)
+	}
+
+	got, err := store.GetPrompt("legacy-prompt")
+	if err != nil {
+		t.Fatalf("Failed to get prompt: %v", err)
+	}
+	if got.Attempts != 3 {
+		t.Errorf("Expected Attempts 3, got %d", got.Attempts)
+	}
 }
 
 func TestSQLiteStore_GetPromptsByDocument(t *testing.T) {
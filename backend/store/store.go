@@ -9,6 +9,10 @@ import (
 type Store interface {
 	DocumentStore
 	PromptStore
+	BatchStore
+	DocumentTypeStore
+	PromptTemplateStore
+	ChatStore
 }
 
 // DocumentStore handles document persistence
@@ -17,6 +21,19 @@ type DocumentStore interface {
 	GetDocument(id string) (*models.Document, error)
 	DeleteDocument(id string) error
 	ListDocuments(limit, offset int) ([]*models.Document, error)
+	// SaveSummary sets the summary of its length on a stored document with
+	// a single write, leaving its other fields as they are, so the results
+	// of agents running concurrently on the document are all kept
+	SaveSummary(documentID string, summary *models.Summary) error
+	// SaveTranslation sets the translation into its target language on a
+	// stored document in the same way
+	SaveTranslation(documentID string, translation *models.Translation) error
+	// SaveRedaction sets the redacted copy of a stored document's PDF and
+	// its report, leaving the original PDF and other fields as they are
+	SaveRedaction(documentID string, redactedPDFData []byte, report *models.RedactionReport) error
+	// SaveBundle sets the bundle report of a stored document, leaving its
+	// other fields as they are
+	SaveBundle(documentID string, bundle *models.BundleReport) error
 }
 
 // PromptStore handles prompt record persistence
@@ -26,6 +43,49 @@ type PromptStore interface {
 	GetPromptsByDocument(documentID string) ([]*models.PromptRecord, error)
 }
 
+// BatchStore handles batch job persistence
+type BatchStore interface {
+	SaveBatch(batch *models.Batch) error
+	GetBatch(id string) (*models.Batch, error)
+}
+
+// DocumentTypeStore handles the user-defined document types of the
+// classification taxonomy
+type DocumentTypeStore interface {
+	SaveDocumentType(documentType *models.DocumentType) error
+	GetDocumentType(name string) (*models.DocumentType, error)
+	ListDocumentTypes() ([]*models.DocumentType, error)
+	DeleteDocumentType(name string) error
+}
+
+// PromptTemplateStore handles versioned prompt templates
+type PromptTemplateStore interface {
+	// SavePromptTemplate stores a template as the next version of its ID
+	// and sets its Version
+	SavePromptTemplate(template *models.PromptTemplate) error
+	GetPromptTemplate(id string, version int) (*models.PromptTemplate, error)
+	// SetPromptTemplateWeight changes the weight of a template version and
+	// returns the updated version
+	SetPromptTemplateWeight(id string, version, weight int) (*models.PromptTemplate, error)
+	// ListPromptTemplates returns the template versions of an agent type,
+	// or of every agent type when agentType is empty, by ID and version
+	ListPromptTemplates(agentType string) ([]*models.PromptTemplate, error)
+}
+
+// ChatStore handles the chat threads of documents
+type ChatStore interface {
+	SaveChatThread(thread *models.ChatThread) error
+	// AppendChatTurns adds turns to the end of a stored thread in a single
+	// write, so turns appended concurrently are all kept, and sets its
+	// UpdatedAt to the time of the last turn. It returns the updated thread.
+	AppendChatTurns(id string, turns []models.ChatTurn) (*models.ChatThread, error)
+	GetChatThread(id string) (*models.ChatThread, error)
+	// ListChatThreads returns the threads of a document, most recently
+	// updated first
+	ListChatThreads(documentID string) ([]*models.ChatThread, error)
+	DeleteChatThread(id string) error
+}
+
 // Global store instance
 var globalStore Store
 
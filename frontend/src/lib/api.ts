@@ -2,8 +2,22 @@ import type {
   UploadResponse,
   ClassifyResponse,
   ExtractResponse,
+  CostEstimate,
   Document,
+  DocumentType,
   PromptRecord,
+  PromptTemplate,
+  ChatThread,
+  ChatResponse,
+  SummarizeResponse,
+  SummaryLength,
+  SummaryStyle,
+  TranslateResponse,
+  RedactResponse,
+  CompareResponse,
+  ExtractTablesResponse,
+  BundleResponse,
+  BundleSegment,
 } from '@/types/api';
 
 const API_BASE = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080';
@@ -35,11 +49,16 @@ export async function uploadPDF(file: File): Promise<UploadResponse> {
   return handleResponse<UploadResponse>(response);
 }
 
-export async function classifyDocument(documentId: string): Promise<ClassifyResponse> {
+export async function classifyDocument(
+  documentId: string,
+  model?: string,
+  ensemble?: { samples?: number; models?: string[] },
+  thinkingBudget?: number
+): Promise<ClassifyResponse> {
   const response = await fetch(`${API_BASE}/api/classify`, {
     method: 'POST',
     headers: { 'Content-Type': 'application/json' },
-    body: JSON.stringify({ document_id: documentId }),
+    body: JSON.stringify({ document_id: documentId, model, ...ensemble, thinking_budget: thinkingBudget }),
   });
 
   return handleResponse<ClassifyResponse>(response);
@@ -47,7 +66,10 @@ export async function classifyDocument(documentId: string): Promise<ClassifyResp
 
 export async function extractData(
   documentId: string,
-  documentType?: string
+  documentType?: string,
+  model?: string,
+  thinkingBudget?: number,
+  ground?: boolean
 ): Promise<ExtractResponse> {
   const response = await fetch(`${API_BASE}/api/extract`, {
     method: 'POST',
@@ -55,12 +77,156 @@ export async function extractData(
     body: JSON.stringify({
       document_id: documentId,
       document_type: documentType,
+      model,
+      thinking_budget: thinkingBudget,
+      ground,
     }),
   });
 
   return handleResponse<ExtractResponse>(response);
 }
 
+export async function summarizeDocument(
+  documentId: string,
+  length?: SummaryLength,
+  style?: SummaryStyle,
+  model?: string
+): Promise<SummarizeResponse> {
+  const response = await fetch(`${API_BASE}/api/summarize`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({
+      document_id: documentId,
+      length,
+      style,
+      model,
+    }),
+  });
+
+  return handleResponse<SummarizeResponse>(response);
+}
+
+export async function translateDocument(
+  documentId: string,
+  targetLanguage: string,
+  fullText?: boolean,
+  model?: string
+): Promise<TranslateResponse> {
+  const response = await fetch(`${API_BASE}/api/translate`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({
+      document_id: documentId,
+      target_language: targetLanguage,
+      full_text: fullText,
+      model,
+    }),
+  });
+
+  return handleResponse<TranslateResponse>(response);
+}
+
+export async function redactDocument(
+  documentId: string,
+  agent?: boolean,
+  model?: string
+): Promise<RedactResponse> {
+  const response = await fetch(`${API_BASE}/api/documents/${documentId}/redact`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ agent, model }),
+  });
+
+  return handleResponse<RedactResponse>(response);
+}
+
+export function getRedactedPDFUrl(documentId: string): string {
+  return `${API_BASE}/api/documents/${documentId}/redacted`;
+}
+
+export async function extractTables(
+  documentId: string,
+  model?: string
+): Promise<ExtractTablesResponse> {
+  const response = await fetch(`${API_BASE}/api/documents/${documentId}/tables`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ model }),
+  });
+
+  return handleResponse<ExtractTablesResponse>(response);
+}
+
+export function getTableUrl(documentId: string, index: number, format: 'csv' | 'json' = 'csv'): string {
+  return `${API_BASE}/api/documents/${documentId}/tables/${index}?format=${format}`;
+}
+
+export async function segmentBundle(
+  documentId: string,
+  model?: string,
+  replace?: boolean
+): Promise<BundleResponse> {
+  const response = await fetch(`${API_BASE}/api/documents/${documentId}/bundle`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ model, replace }),
+  });
+
+  return handleResponse<BundleResponse>(response);
+}
+
+export async function splitBundle(
+  documentId: string,
+  segments?: BundleSegment[],
+  replace?: boolean
+): Promise<BundleResponse> {
+  const response = await fetch(`${API_BASE}/api/documents/${documentId}/split`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ segments, replace }),
+  });
+
+  return handleResponse<BundleResponse>(response);
+}
+
+export async function compareDocuments(
+  beforeDocumentId: string,
+  afterDocumentId: string,
+  model?: string
+): Promise<CompareResponse> {
+  const response = await fetch(`${API_BASE}/api/compare`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({
+      before_document_id: beforeDocumentId,
+      after_document_id: afterDocumentId,
+      model,
+    }),
+  });
+
+  return handleResponse<CompareResponse>(response);
+}
+
+export async function estimateCost(
+  documentId: string,
+  agentType: 'classification' | 'extraction',
+  documentType?: string,
+  model?: string
+): Promise<CostEstimate> {
+  const response = await fetch(`${API_BASE}/api/estimate`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({
+      document_id: documentId,
+      agent_type: agentType,
+      document_type: documentType,
+      model,
+    }),
+  });
+
+  return handleResponse<CostEstimate>(response);
+}
+
 export async function getDocument(documentId: string): Promise<Document> {
   const response = await fetch(`${API_BASE}/api/documents/${documentId}`);
   return handleResponse<Document>(response);
@@ -76,4 +242,128 @@ export async function getPrompt(promptId: string): Promise<PromptRecord> {
   return handleResponse<PromptRecord>(response);
 }
 
+export async function listDocumentTypes(): Promise<DocumentType[]> {
+  const response = await fetch(`${API_BASE}/api/document-types`);
+  return handleResponse<DocumentType[]>(response);
+}
+
+export async function saveDocumentType(
+  documentType: Pick<DocumentType, 'name' | 'description' | 'indicators' | 'parent'>
+): Promise<DocumentType> {
+  const response = await fetch(`${API_BASE}/api/document-types`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(documentType),
+  });
+
+  return handleResponse<DocumentType>(response);
+}
+
+export async function deleteDocumentType(name: string): Promise<void> {
+  const response = await fetch(`${API_BASE}/api/document-types/${encodeURIComponent(name)}`, {
+    method: 'DELETE',
+  });
+  if (!response.ok) {
+    const text = await response.text();
+    throw new ApiError(response.status, text || `HTTP ${response.status}`);
+  }
+}
+
+export async function listPromptTemplates(
+  agentType?: PromptTemplate['agent_type']
+): Promise<PromptTemplate[]> {
+  const query = agentType ? `?agent_type=${agentType}` : '';
+  const response = await fetch(`${API_BASE}/api/prompt-templates${query}`);
+  return handleResponse<PromptTemplate[]>(response);
+}
+
+export async function savePromptTemplate(
+  template: Pick<PromptTemplate, 'id' | 'agent_type' | 'template'> & { weight?: number }
+): Promise<PromptTemplate> {
+  const response = await fetch(`${API_BASE}/api/prompt-templates`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(template),
+  });
+
+  return handleResponse<PromptTemplate>(response);
+}
+
+export async function setPromptTemplateWeight(
+  id: string,
+  version: number,
+  weight: number
+): Promise<PromptTemplate> {
+  const response = await fetch(
+    `${API_BASE}/api/prompt-templates/${encodeURIComponent(id)}/versions/${version}`,
+    {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ weight }),
+    }
+  );
+
+  return handleResponse<PromptTemplate>(response);
+}
+
+// chatWithDocument asks a question about a document, calling onText with
+// each piece of the answer as it streams in
+export async function chatWithDocument(
+  documentId: string,
+  message: string,
+  onText: (text: string) => void,
+  threadId?: string
+): Promise<ChatResponse> {
+  const response = await fetch(`${API_BASE}/api/documents/${documentId}/chat`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ message, thread_id: threadId }),
+  });
+  if (!response.ok || !response.body) {
+    const text = await response.text();
+    throw new ApiError(response.status, text || `HTTP ${response.status}`);
+  }
+
+  const reader = response.body.getReader();
+  const decoder = new TextDecoder();
+  let buffer = '';
+  for (;;) {
+    const { done, value } = await reader.read();
+    if (done) break;
+    buffer += decoder.decode(value, { stream: true });
+
+    let end;
+    while ((end = buffer.indexOf('\n\n')) !== -1) {
+      const lines = buffer.slice(0, end).split('\n');
+      buffer = buffer.slice(end + 2);
+      const event = lines.find((line) => line.startsWith('event: '))?.slice(7);
+      const data = JSON.parse(lines.find((line) => line.startsWith('data: '))?.slice(6) ?? '{}');
+      if (event === 'delta') onText(data.text);
+      if (event === 'error') throw new ApiError(502, data.error);
+      if (event === 'done') return data as ChatResponse;
+    }
+  }
+  throw new ApiError(502, 'Chat stream ended without an answer');
+}
+
+export async function listChatThreads(documentId: string): Promise<ChatThread[]> {
+  const response = await fetch(`${API_BASE}/api/documents/${documentId}/chat`);
+  return handleResponse<ChatThread[]>(response);
+}
+
+export async function getChatThread(documentId: string, threadId: string): Promise<ChatThread> {
+  const response = await fetch(`${API_BASE}/api/documents/${documentId}/chat/${threadId}`);
+  return handleResponse<ChatThread>(response);
+}
+
+export async function deleteChatThread(documentId: string, threadId: string): Promise<void> {
+  const response = await fetch(`${API_BASE}/api/documents/${documentId}/chat/${threadId}`, {
+    method: 'DELETE',
+  });
+  if (!response.ok) {
+    const text = await response.text();
+    throw new ApiError(response.status, text || `HTTP ${response.status}`);
+  }
+}
+
 export { ApiError };
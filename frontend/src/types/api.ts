@@ -6,6 +6,43 @@ export interface Classification {
   reasoning: string;
   subtypes?: string[];
   language?: string;
+  unmapped_type?: string;
+  ensemble?: EnsembleReport;
+}
+
+export interface DocumentType {
+  name: string;
+  description: string;
+  indicators?: string[];
+  parent?: string;
+  built_in?: boolean;
+  created_at: string;
+}
+
+export interface PromptTemplate {
+  id: string;
+  version: number;
+  agent_type: 'classification' | 'extraction';
+  template: string;
+  weight: number;
+  created_at: string;
+}
+
+export interface EnsembleReport {
+  samples: number;
+  votes: ClassificationVote[];
+  tally: Record<string, number>;
+  agreement: number;
+  model_confidence: number;
+  disagreement: boolean;
+  failed?: number;
+}
+
+export interface ClassificationVote {
+  model: string;
+  document_type?: string;
+  confidence?: number;
+  error?: string;
 }
 
 export interface ExtractedField {
@@ -14,12 +51,64 @@ export interface ExtractedField {
   source_text: string;
   page_number: number;
   confidence: number;
+  verification?: VerificationStatus;
+  matched_page?: number;
+  match_score?: number;
+  citations?: Citation[];
+  uncited?: boolean;
+}
+
+export interface Citation {
+  start_page: number;
+  end_page: number;
+  cited_text: string;
+}
+
+export interface GroundingReport {
+  cited: number;
+  uncited: number;
+  error?: string;
+}
+
+export type VerificationStatus = 'exact' | 'fuzzy' | 'wrong_page' | 'not_found';
+
+export interface VerificationReport {
+  exact: number;
+  fuzzy: number;
+  wrong_page: number;
+  not_found: number;
+  hallucination_score: number;
+  error?: string;
+}
+
+export interface ValidationError {
+  path: string;
+  message: string;
+}
+
+export interface ValidationReport {
+  valid: boolean;
+  errors?: ValidationError[];
+  repair_rounds: number;
+  repair_error?: string;
 }
 
 export interface Extraction {
   schema_used: string;
   data: Record<string, unknown>;
   fields: ExtractedField[];
+  validation?: ValidationReport;
+  verification?: VerificationReport;
+  grounding?: GroundingReport;
+  chunks?: ExtractionChunk[];
+  truncated?: boolean;
+}
+
+export interface ExtractionChunk {
+  start_page: number;
+  end_page: number;
+  fields: number;
+  error?: string;
 }
 
 export interface Document {
@@ -30,9 +119,203 @@ export interface Document {
   pdf_base64: string;
   classification?: Classification;
   extraction?: Extraction;
+  summaries?: Partial<Record<SummaryLength, Summary>>;
+  translations?: Record<string, Translation>;
+  redaction?: RedactionReport;
+  table_extraction?: TableExtraction;
+  bundle?: BundleReport;
+  parent_id?: string;
+  created_at: string;
+}
+
+export type SummaryLength = 'one_line' | 'paragraph' | 'sections';
+export type SummaryStyle = 'prose' | 'bullets';
+
+export interface SummarySection {
+  title: string;
+  start_page: number;
+  end_page: number;
+  summary: string;
+}
+
+export interface Summary {
+  length: SummaryLength;
+  style: SummaryStyle;
+  text: string;
+  sections?: SummarySection[];
+  model?: string;
+  created_at: string;
+}
+
+export interface SummarizeResponse {
+  document_id: string;
+  summary: Summary;
+  prompt_id: string;
+}
+
+export interface TranslatedValue {
+  path: string;
+  original: string;
+  translated: string;
+}
+
+export interface TranslatedField {
+  name: string;
+  original: string;
+  translated: string;
+  source_text: string;
+  page_number: number;
+}
+
+export interface TranslatedPage {
+  page_number: number;
+  text: string;
+}
+
+export interface Translation {
+  target_language: string;
+  source_language?: string;
+  data?: Record<string, unknown>;
+  values?: TranslatedValue[];
+  fields?: TranslatedField[];
+  pages?: TranslatedPage[];
+  model?: string;
+  created_at: string;
+}
+
+export interface TranslateResponse {
+  document_id: string;
+  translation: Translation;
+  prompt_id: string;
+}
+
+export type PIIType =
+  | 'ssn'
+  | 'credit_card'
+  | 'iban'
+  | 'routing_number'
+  | 'account_number'
+  | 'email'
+  | 'phone'
+  | 'date_of_birth'
+  | 'address'
+  | 'person_name';
+
+export interface PIISpan {
+  type: PIIType;
+  masked: string;
+  page_number: number;
+  start: number;
+  end: number;
+  detector: 'regex' | 'checksum' | 'agent';
+  redacted: boolean;
+}
+
+export interface RedactionReport {
+  spans: PIISpan[];
+  counts: Partial<Record<PIIType, number>>;
+  unredacted: number;
+  agent_pass: boolean;
+  model?: string;
+  created_at: string;
+}
+
+export interface RedactResponse {
+  document_id: string;
+  redaction: RedactionReport;
+  prompt_id?: string;
+}
+
+export interface BundleSegment {
+  start_page: number;
+  end_page: number;
+  document_type: string;
+  confidence?: number;
+  title?: string;
+  reasoning?: string;
+  child_id?: string;
+}
+
+export interface BundleReport {
+  segments: BundleSegment[];
+  model?: string;
+  created_at: string;
+  split_at?: string;
+}
+
+export interface BundleResponse {
+  document_id: string;
+  bundle: BundleReport;
+  prompt_id?: string;
+  children?: UploadResponse[];
+}
+
+export interface MergedCell {
+  row: number;
+  column: number;
+  row_span: number;
+  col_span: number;
+}
+
+export interface Table {
+  index: number;
+  page_number: number;
+  caption?: string;
+  header_rows: number;
+  cells: string[][];
+  merged_cells?: MergedCell[];
+}
+
+export interface TableExtraction {
+  tables: Table[];
+  model?: string;
+  created_at: string;
+}
+
+export interface ExtractTablesResponse {
+  document_id: string;
+  table_extraction: TableExtraction;
+  prompt_id: string;
+}
+
+export type ChangeKind = 'added' | 'removed' | 'modified';
+
+export type Materiality = 'high' | 'medium' | 'low';
+
+export interface ClauseChange {
+  kind: ChangeKind;
+  clause: string;
+  before_text?: string;
+  after_text?: string;
+  before_page?: number;
+  after_page?: number;
+  materiality: Materiality;
+  explanation?: string;
+}
+
+export interface FieldChange {
+  field: string;
+  kind: ChangeKind;
+  before?: unknown;
+  after?: unknown;
+  highlight?: boolean;
+}
+
+export interface Comparison {
+  before_document_id: string;
+  after_document_id: string;
+  summary: string;
+  changes: ClauseChange[];
+  field_changes?: FieldChange[];
+  model?: string;
   created_at: string;
 }
 
+export interface CompareResponse {
+  comparison: Comparison;
+  prompt_id: string;
+}
+
 export interface UploadResponse {
   id: string;
   filename: string;
@@ -52,10 +335,23 @@ export interface ExtractResponse {
   schema_used: string;
 }
 
+export interface CostEstimate {
+  model: string;
+  agent_type: 'classification' | 'extraction';
+  input_tokens: number;
+  calls: number;
+  max_calls: number;
+  max_output_tokens: number;
+  min_cost: number;
+  max_cost: number;
+  priced: boolean;
+  approximate: boolean;
+}
+
 export interface PromptRecord {
   id: string;
   document_id: string;
-  agent_type: 'classification' | 'extraction';
+  agent_type: 'classification' | 'classification_vote' | 'extraction' | 'validation' | 'extraction_repair' | 'verification' | 'citations' | 'chat' | 'summary' | 'translation' | 'pii' | 'comparison' | 'tables' | 'bundle';
   prompt: string;
   response: string;
   schema?: string;
@@ -63,9 +359,62 @@ export interface PromptRecord {
   input_tokens: number;
   output_tokens: number;
   total_cost: number;
+  unpriced?: boolean;
+  attempts?: number;
+  page_start?: number;
+  page_end?: number;
+  cache_creation_input_tokens?: number;
+  cache_read_input_tokens?: number;
+  cache_savings?: number;
+  batch_id?: string;
+  template_id?: string;
+  template_version?: number;
+  thinking?: string;
+  thinking_tokens?: number;
+  thinking_cost?: number;
+  raw_response?: string;
+  stop_reason?: string;
+  continuations?: number;
+  json_repairs?: string[];
+  error?: string;
   created_at: string;
 }
 
+export type BatchStatus = 'in_progress' | 'canceling' | 'ended' | 'completed';
+
+export interface BatchCounts {
+  processing: number;
+  succeeded: number;
+  errored: number;
+  canceled: number;
+  expired: number;
+}
+
+export interface BatchRequest {
+  custom_id: string;
+  provider_batch_id: string;
+  document_id: string;
+  document_type?: string;
+  prompt: string;
+  status: 'pending' | 'succeeded' | 'errored' | 'canceled' | 'expired';
+  error?: string;
+  prompt_id?: string;
+}
+
+export interface Batch {
+  id: string;
+  provider_batch_ids: string[];
+  agent_type: 'classification' | 'extraction';
+  model: string;
+  status: BatchStatus;
+  counts: BatchCounts;
+  requests: BatchRequest[];
+  total_cost: number;
+  unpriced?: boolean;
+  created_at: string;
+  ended_at?: string;
+}
+
 // App state types
 export type AppStep = 'upload' | 'classify' | 'extract';
 
@@ -79,3 +428,36 @@ export interface AppState {
   loading: boolean;
   error: string | null;
 }
+
+export interface ChatTurn {
+  role: 'user' | 'assistant';
+  content: string;
+  prompt_id?: string;
+  created_at: string;
+}
+
+export interface ChatThread {
+  id: string;
+  document_id: string;
+  title: string;
+  turns: ChatTurn[];
+  created_at: string;
+  updated_at: string;
+}
+
+export interface TokenUsage {
+  model: string;
+  input_tokens: number;
+  output_tokens: number;
+  total_cost: number;
+  cache_creation_input_tokens?: number;
+  cache_read_input_tokens?: number;
+  cache_savings?: number;
+}
+
+export interface ChatResponse {
+  thread_id: string;
+  answer: string;
+  prompt_id: string;
+  token_usage: TokenUsage;
+}